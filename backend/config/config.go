@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -14,6 +15,12 @@ type Config struct {
 	DB     DatabaseConfig
 	AI     AIConfig
 	Wechat WechatConfig
+	Auth   AuthConfig
+	JWT    JWTConfig
+	Mail   MailConfig
+	OAuth  OAuthConfig
+	Cache  CacheConfig
+	Job    JobConfig
 }
 
 type DatabaseConfig struct {
@@ -35,6 +42,100 @@ type WechatConfig struct {
 	AppSecret      string
 	Token          string // 用于回调验证
 	EncodingAESKey string
+	AutoRegister   bool // 扫码的微信未绑定账号时，允许直接注册新账号（否则只能绑定已有账号）
+
+	// 微信小程序，与公众号绑定在同一开放平台账号下时可通过 UnionID 关联同一用户
+	MiniAppID     string
+	MiniAppSecret string
+
+	// 模板消息ID，为空时不发送对应通知
+	TemplateLotteryWin  string
+	TemplateOrderStatus string
+}
+
+type AuthConfig struct {
+	MaxSessions int    // 角色未单独配置时，每个用户允许的最大同时在线会话数，0 表示不限制
+	TOTPIssuer  string // 两步验证在认证器 App 中显示的发行方名称
+
+	LoginMaxFailures    int // 同一用户名连续失败多少次后开始锁定
+	LoginIPMaxFailures  int // 同一 IP 连续失败多少次后开始锁定
+	LoginLockMinutes    int // 首次锁定时长（分钟），之后每多失败一次翻倍
+	LoginMaxLockMinutes int // 最长锁定时长（分钟）
+
+	// 登录/注册风险评估：评分达到阈值时要求验证码
+	RiskCaptchaThreshold  int    // 要求验证码的风险分阈值，0 表示始终要求
+	RiskVelocityPerMinute int    // 同一 IP 每分钟登录/注册请求数超过该值时加分
+	RiskIPDenylist        string // 高风险 IP 或网段，逗号分隔（如 203.0.113.0/24），始终要求验证码
+	RiskIPAllowlist       string // 可信 IP 或网段，逗号分隔，不计 IP 相关风险
+}
+
+type JWTConfig struct {
+	Algorithm      string // 未配置私钥时临时生成密钥使用的算法：RS256 或 EdDSA
+	AllowEphemeral bool   // 允许未配置私钥时生成临时密钥，仅 debug 模式开启
+	KeyID          string // 签名密钥ID（kid），为空时使用公钥指纹
+	PrivateKeyFile string // PEM 私钥文件路径
+	PrivateKey     string // PEM 私钥内容，优先于文件
+	VerifyKeys     string // 轮换期间仍需验证的旧公钥，格式 kid=path,kid2=path2
+}
+
+type MailConfig struct {
+	Driver      string // smtp 或 outbox（开发/测试用，邮件只写入发件箱）
+	Host        string
+	Port        int
+	Username    string
+	Password    string
+	From        string
+	OutboxFile  string // outbox 驱动下追加保存邮件的文件，为空则只保存在内存
+	TokenSecret string // 重置密码/验证邮箱链接的签名密钥，为空时启动时随机生成
+	APIBaseURL  string // 后端对外地址，用于拼接邮箱验证链接
+	FrontendURL string // 前端地址，用于拼接重置密码页面链接
+}
+
+type OAuthConfig struct {
+	RedirectBaseURL string // 后端对外地址，回调地址为 {RedirectBaseURL}/api/v1/auth/oauth/{provider}/callback
+	FrontendURL     string // 登录完成后跳转的前端地址
+
+	GitHubClientID     string
+	GitHubClientSecret string
+	GoogleClientID     string
+	GoogleClientSecret string
+
+	// 通用 OIDC 提供方（如 Keycloak、Authing），通过 Issuer 自动发现端点
+	OIDCName         string // 提供方标识，出现在登录地址中
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCScopes       string // 空格分隔，默认 openid profile email
+}
+
+type CacheConfig struct {
+	Driver               string // auto（Redis 可用时用 Redis，否则降级为内存）、memory、redis 或 tiered（内存 + Redis 两级）
+	MaxEntries           int    // 内存缓存最多保存的键数，超出时淘汰最久未访问的键，0 表示不限制
+	SweepIntervalSeconds int    // 内存缓存后台清理过期键的间隔（秒）
+	L1TTLSeconds         int    // tiered 模式下本地内存中值的最长保留时间（秒），失效消息丢失时的兜底
+
+	// 用户ID布隆过滤器：启动时把用户ID加载到 Redis 中各实例共享的过滤器，查询不存在的ID时不访问缓存和数据库（需要 Redis）
+	UserIDFilter         bool
+	UserIDFilterCapacity int // 过滤器容量，各实例必须一致，应明显大于用户数
+
+	// 注册布隆过滤器：启动时在后台加载全部用户名和邮箱，注册时一定不存在的值不再查询数据库
+	RegisterFilter         bool
+	RegisterFilterCapacity int    // 过滤器容量，应明显大于用户数
+	RegisterFilterSnapshot string // Redis 不可用时内存过滤器的快照路径，启动时加载、停止时保存，为空表示不保存
+
+	// 内存缓存持久化：写操作追加到 {PersistFile}.aof，定期合并为 PersistFile 快照
+	PersistFile            string // 快照路径，为空表示不持久化
+	CompactIntervalSeconds int    // 合并追加日志的间隔（秒）
+	FsyncAlways            bool   // 每次写入都 fsync，否则每秒一次
+}
+
+type JobConfig struct {
+	Driver                   string // auto（Redis 可用时用 Redis，否则用 MySQL）、mysql 或 redis
+	Workers                  int    // 工作协程数
+	VisibilityTimeoutSeconds int    // 任务租约时长（秒），工作进程崩溃后超过该时长重新投递
+	MaxAttempts              int    // 每个任务最多执行次数，用尽后转入死信
+	BaseBackoffSeconds       int    // 第一次重试的等待时间（秒），之后每次翻倍
+	ShutdownTimeoutSeconds   int    // 停止服务时等待执行中任务完成的最长时间（秒）
 }
 
 var AppConfig *Config
@@ -46,10 +147,12 @@ func LoadConfig() {
 		log.Printf("未找到 .env 文件或读取失败: %v (将使用默认配置或环境变量)", err)
 	}
 
+	mode := getEnv("APP_MODE", "debug") // debug 或 release
+
 	AppConfig = &Config{
 		Host: getEnv("APP_HOST", "0.0.0.0"),
 		Port: getEnv("APP_PORT", "8080"),
-		Mode: getEnv("APP_MODE", "debug"), // debug 或 release
+		Mode: mode,
 		DB: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnv("DB_PORT", "3306"),
@@ -67,6 +170,84 @@ func LoadConfig() {
 			AppSecret:      getEnv("WECHAT_APP_SECRET", ""),
 			Token:          getEnv("WECHAT_TOKEN", ""),
 			EncodingAESKey: getEnv("WECHAT_AES_KEY", ""),
+			AutoRegister:   getEnvBool("WECHAT_AUTO_REGISTER", true),
+			MiniAppID:      getEnv("WECHAT_MINI_APP_ID", ""),
+			MiniAppSecret:  getEnv("WECHAT_MINI_APP_SECRET", ""),
+
+			TemplateLotteryWin:  getEnv("WECHAT_TPL_LOTTERY_WIN", ""),
+			TemplateOrderStatus: getEnv("WECHAT_TPL_ORDER_STATUS", ""),
+		},
+		Auth: AuthConfig{
+			MaxSessions: getEnvInt("AUTH_MAX_SESSIONS", 5),
+			TOTPIssuer:  getEnv("AUTH_TOTP_ISSUER", "GinBackend"),
+
+			LoginMaxFailures:    getEnvInt("AUTH_LOGIN_MAX_FAILURES", 5),
+			LoginIPMaxFailures:  getEnvInt("AUTH_LOGIN_IP_MAX_FAILURES", 20),
+			LoginLockMinutes:    getEnvInt("AUTH_LOGIN_LOCK_MINUTES", 1),
+			LoginMaxLockMinutes: getEnvInt("AUTH_LOGIN_MAX_LOCK_MINUTES", 60),
+
+			RiskCaptchaThreshold:  getEnvInt("AUTH_RISK_CAPTCHA_THRESHOLD", 50),
+			RiskVelocityPerMinute: getEnvInt("AUTH_RISK_VELOCITY_PER_MINUTE", 10),
+			RiskIPDenylist:        getEnv("AUTH_RISK_IP_DENYLIST", ""),
+			RiskIPAllowlist:       getEnv("AUTH_RISK_IP_ALLOWLIST", ""),
+		},
+		JWT: JWTConfig{
+			Algorithm:      getEnv("JWT_ALG", "RS256"),
+			AllowEphemeral: mode == "debug",
+			KeyID:          getEnv("JWT_KEY_ID", ""),
+			PrivateKeyFile: getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			PrivateKey:     getEnv("JWT_PRIVATE_KEY", ""),
+			VerifyKeys:     getEnv("JWT_VERIFY_KEYS", ""),
+		},
+		Mail: MailConfig{
+			Driver:      getEnv("MAIL_DRIVER", "outbox"),
+			Host:        getEnv("MAIL_HOST", ""),
+			Port:        getEnvInt("MAIL_PORT", 587),
+			Username:    getEnv("MAIL_USERNAME", ""),
+			Password:    getEnv("MAIL_PASSWORD", ""),
+			From:        getEnv("MAIL_FROM", "no-reply@localhost"),
+			OutboxFile:  getEnv("MAIL_OUTBOX_FILE", "mail_outbox.log"),
+			TokenSecret: getEnv("MAIL_TOKEN_SECRET", ""),
+			APIBaseURL:  getEnv("APP_PUBLIC_URL", "http://localhost:8080"),
+			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:5173"),
+		},
+		OAuth: OAuthConfig{
+			RedirectBaseURL:    getEnv("APP_PUBLIC_URL", "http://localhost:8080"),
+			FrontendURL:        getEnv("FRONTEND_URL", "http://localhost:5173"),
+			GitHubClientID:     getEnv("OAUTH_GITHUB_CLIENT_ID", ""),
+			GitHubClientSecret: getEnv("OAUTH_GITHUB_CLIENT_SECRET", ""),
+			GoogleClientID:     getEnv("OAUTH_GOOGLE_CLIENT_ID", ""),
+			GoogleClientSecret: getEnv("OAUTH_GOOGLE_CLIENT_SECRET", ""),
+			OIDCName:           getEnv("OAUTH_OIDC_NAME", "oidc"),
+			OIDCIssuer:         getEnv("OAUTH_OIDC_ISSUER", ""),
+			OIDCClientID:       getEnv("OAUTH_OIDC_CLIENT_ID", ""),
+			OIDCClientSecret:   getEnv("OAUTH_OIDC_CLIENT_SECRET", ""),
+			OIDCScopes:         getEnv("OAUTH_OIDC_SCOPES", "openid profile email"),
+		},
+		Cache: CacheConfig{
+			Driver:               getEnv("CACHE_DRIVER", "auto"),
+			MaxEntries:           getEnvInt("CACHE_MAX_ENTRIES", 100000),
+			SweepIntervalSeconds: getEnvInt("CACHE_SWEEP_INTERVAL_SECONDS", 60),
+			L1TTLSeconds:         getEnvInt("CACHE_L1_TTL_SECONDS", 30),
+
+			UserIDFilter:         getEnvBool("CACHE_USER_ID_FILTER", false),
+			UserIDFilterCapacity: getEnvInt("CACHE_USER_ID_FILTER_CAPACITY", 1000000),
+
+			RegisterFilter:         getEnvBool("CACHE_REGISTER_FILTER", true),
+			RegisterFilterCapacity: getEnvInt("CACHE_REGISTER_FILTER_CAPACITY", 1000000),
+			RegisterFilterSnapshot: getEnv("CACHE_REGISTER_FILTER_SNAPSHOT", "register_filter.bloom"),
+
+			PersistFile:            getEnv("CACHE_PERSIST_FILE", "cache_persistence.json"),
+			CompactIntervalSeconds: getEnvInt("CACHE_COMPACT_INTERVAL_SECONDS", 300),
+			FsyncAlways:            getEnvBool("CACHE_FSYNC_ALWAYS", false),
+		},
+		Job: JobConfig{
+			Driver:                   getEnv("JOB_DRIVER", "auto"),
+			Workers:                  getEnvInt("JOB_WORKERS", 4),
+			VisibilityTimeoutSeconds: getEnvInt("JOB_VISIBILITY_TIMEOUT_SECONDS", 300),
+			MaxAttempts:              getEnvInt("JOB_MAX_ATTEMPTS", 5),
+			BaseBackoffSeconds:       getEnvInt("JOB_BASE_BACKOFF_SECONDS", 10),
+			ShutdownTimeoutSeconds:   getEnvInt("JOB_SHUTDOWN_TIMEOUT_SECONDS", 30),
 		},
 	}
 
@@ -81,3 +262,21 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt 获取整型环境变量，不存在或格式错误时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvBool 获取布尔环境变量，不存在或格式错误时返回默认值
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -138,3 +138,66 @@ func InitRolesAndMenus(db *gorm.DB) error {
 
 	return nil
 }
+
+// InitPermissions 补齐内置的按钮权限（幂等，可在每次启动时执行）
+// 新增的权限会自动分配给超级管理员角色
+func InitPermissions(db *gorm.DB) error {
+	var superAdminRole models.Role
+	if err := db.Where("code = ?", "super_admin").First(&superAdminRole).Error; err != nil {
+		return fmt.Errorf("查找超级管理员角色失败: %v", err)
+	}
+
+	parents := make(map[string]uint)
+	for i, def := range models.BuiltinPermissions {
+		var count int64
+		db.Model(&models.Menu{}).Where("permission = ?", def.Code).Count(&count)
+		if count > 0 {
+			continue
+		}
+
+		parentID, ok := parents[def.ParentPath]
+		if !ok {
+			var parent models.Menu
+			err := db.Where("path = ? AND type = ?", def.ParentPath, 1).First(&parent).Error
+			if err == gorm.ErrRecordNotFound && def.ParentPath == "/lottery-admin" {
+				// 抽奖管理菜单此前由前端写死，这里补建以便挂载按钮权限
+				parent = models.Menu{
+					Name:      "抽奖管理",
+					Path:      "/lottery-admin",
+					Component: "LotteryAdmin",
+					Icon:      "GiftOutlined",
+					Sort:      4,
+					Type:      1,
+					Status:    1,
+				}
+				if err = db.Create(&parent).Error; err == nil {
+					err = db.Model(&superAdminRole).Association("Menus").Append(&parent)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("查找菜单 %s 失败: %v", def.ParentPath, err)
+			}
+			parentID = parent.ID
+			parents[def.ParentPath] = parentID
+		}
+
+		button := models.Menu{
+			ParentID:   parentID,
+			Name:       def.Name,
+			Path:       def.ParentPath,
+			Sort:       i + 1,
+			Type:       2,
+			Permission: def.Code,
+			Status:     1,
+			Hidden:     true,
+		}
+		if err := db.Create(&button).Error; err != nil {
+			return fmt.Errorf("创建按钮权限 %s 失败: %v", def.Code, err)
+		}
+		if err := db.Model(&superAdminRole).Association("Menus").Append(&button); err != nil {
+			return fmt.Errorf("分配按钮权限 %s 失败: %v", def.Code, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"gin-backend/models"
+	"gin-backend/services"
+	"gin-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountController 账号安全控制器（找回密码、邮箱验证）
+type AccountController struct {
+	accountService services.AccountService
+}
+
+// NewAccountController 创建账号安全控制器实例
+func NewAccountController(accountService services.AccountService) *AccountController {
+	return &AccountController{
+		accountService: accountService,
+	}
+}
+
+// ForgotPassword 忘记密码
+// @Summary 忘记密码
+// @Description 向注册邮箱发送重置密码链接，邮箱未注册时同样返回成功
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body models.ForgotPasswordRequest true "注册邮箱"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /password/forgot [post]
+func (ctrl *AccountController) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.accountService.ForgotPassword(req.Email); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "发送重置邮件失败")
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "如果该邮箱已注册，重置密码邮件已发送", nil)
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用邮件中的令牌设置新密码，成功后所有设备需要重新登录
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body models.ResetPasswordRequest true "重置令牌和新密码"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /password/reset [post]
+func (ctrl *AccountController) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.accountService.ResetPassword(req.Token, req.Password); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "密码已重置，请重新登录", nil)
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 打开验证邮件中的链接完成邮箱验证
+// @Tags 认证管理
+// @Produce json
+// @Param token query string true "验证令牌"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /email/verify [get]
+func (ctrl *AccountController) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "缺少验证令牌")
+		return
+	}
+
+	if err := ctrl.accountService.VerifyEmail(token); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "邮箱验证成功", nil)
+}
+
+// ResendVerification 重新发送邮箱验证邮件
+// @Summary 重新发送验证邮件
+// @Tags 认证管理
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/email/verify [post]
+func (ctrl *AccountController) ResendVerification(c *gin.Context) {
+	if err := ctrl.accountService.SendVerificationEmail(c.GetUint("userID")); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "验证邮件已发送", nil)
+}
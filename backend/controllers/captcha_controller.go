@@ -1,8 +1,6 @@
 package controllers
 
 import (
-	"bytes"
-	"encoding/base64"
 	"gin-backend/models"
 	"gin-backend/utils"
 	"net/http"
@@ -19,31 +17,29 @@ func NewCaptchaController() *CaptchaController {
 	return &CaptchaController{}
 }
 
-// GetCaptcha 获取验证码（返回 base64 图片）
-// 支持刷新：GET /api/v1/captcha?refresh=captcha_id
+// GetCaptcha 获取验证码
+// 支持刷新：GET /api/v1/captcha?refresh=captcha_id（仅数字验证码）
+// 滑块验证码：GET /api/v1/captcha?captcha_type=slider
 func (ctrl *CaptchaController) GetCaptcha(c *gin.Context) {
-	var captchaID string
-
-	// 检查是否是刷新请求
-	refreshID := c.Query("refresh")
-	if refreshID != "" {
-		// 刷新现有验证码
-		if utils.ReloadCaptcha(refreshID) {
-			captchaID = refreshID
-		} else {
-			// 如果刷新失败（验证码已过期），生成新的
-			captchaID = utils.GenerateCaptcha()
-		}
-	} else {
-		// 生成新的验证码
-		captchaID = utils.GenerateCaptcha()
+	provider, ok := utils.GetCaptchaProvider(c.Query("captcha_type"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "不支持的验证码类型",
+		})
+		return
 	}
 
-	// 创建一个 buffer 来存储图片
-	var buf bytes.Buffer
+	// 刷新现有数字验证码，刷新失败（验证码已过期）时生成新的
+	var challenge *utils.CaptchaChallenge
+	if refreshID := c.Query("refresh"); refreshID != "" && provider.Type() == utils.CaptchaTypeDigit {
+		challenge, _ = utils.RefreshDigitCaptcha(refreshID)
+	}
 
-	// 生成验证码图片
-	err := captcha.WriteImage(&buf, captchaID, captcha.StdWidth, captcha.StdHeight)
+	var err error
+	if challenge == nil {
+		challenge, err = provider.Generate()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -52,16 +48,10 @@ func (ctrl *CaptchaController) GetCaptcha(c *gin.Context) {
 		return
 	}
 
-	// 将图片转换为 base64
-	base64Image := base64.StdEncoding.EncodeToString(buf.Bytes())
-
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "success",
-		"data": gin.H{
-			"captcha_id":    captchaID,
-			"captcha_image": "data:image/png;base64," + base64Image,
-		},
+		"data":    challenge,
 	})
 }
 
@@ -117,8 +107,9 @@ func (ctrl *CaptchaController) RefreshCaptcha(c *gin.Context) {
 // VerifyCaptcha 验证验证码（用于测试）
 func (ctrl *CaptchaController) VerifyCaptcha(c *gin.Context) {
 	var req struct {
-		CaptchaID string `json:"captcha_id" binding:"required"`
-		Captcha   string `json:"captcha" binding:"required"`
+		CaptchaID   string `json:"captcha_id" binding:"required"`
+		Captcha     string `json:"captcha" binding:"required"`
+		CaptchaType string `json:"captcha_type"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -130,7 +121,7 @@ func (ctrl *CaptchaController) VerifyCaptcha(c *gin.Context) {
 		return
 	}
 
-	isValid := utils.VerifyCaptcha(req.CaptchaID, req.Captcha)
+	isValid := utils.VerifyCaptchaByType(req.CaptchaType, req.CaptchaID, req.Captcha)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
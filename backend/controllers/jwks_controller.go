@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"gin-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS 获取令牌验证公钥（JWK Set）
+// @Summary 获取 JWKS
+// @Description 返回当前所有可用于验证访问令牌的公钥，其他服务据此验证令牌而无需共享密钥
+// @Tags 认证管理
+// @Produce json
+// @Success 200 {object} utils.JWKSet
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(c *gin.Context) {
+	// 允许其他服务短时间缓存，轮换时新公钥会先于签名切换发布
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.JWKS())
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"gin-backend/models"
+	"gin-backend/services"
+	"gin-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationController 微信通知控制器
+type NotificationController struct {
+	notificationService services.NotificationService
+}
+
+// NewNotificationController 创建微信通知控制器实例
+func NewNotificationController(notificationService services.NotificationService) *NotificationController {
+	return &NotificationController{
+		notificationService: notificationService,
+	}
+}
+
+// GetSettings 获取我的通知开关
+// @Summary 我的通知设置
+// @Description 返回各通知事件是否开启，绑定微信后才会收到模板消息
+// @Tags 通知管理
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /notifications/settings [get]
+func (ctrl *NotificationController) GetSettings(c *gin.Context) {
+	settings, err := ctrl.notificationService.GetSettings(c.GetUint("userID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取通知设置失败")
+		return
+	}
+	utils.SuccessResponse(c, settings)
+}
+
+// UpdateSetting 开启或关闭某个通知
+// @Summary 修改通知设置
+// @Tags 通知管理
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param body body models.NotificationSetting true "通知事件和开关"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /notifications/settings [put]
+func (ctrl *NotificationController) UpdateSetting(c *gin.Context) {
+	var req models.NotificationSetting
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.notificationService.UpdateSetting(c.GetUint("userID"), &req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "设置已保存", nil)
+}
+
+// GetLogs 分页查询通知投递记录
+// @Summary 通知投递记录
+// @Description 按用户、事件、投递状态筛选微信模板消息投递记录
+// @Tags 通知管理
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Param user_id query int false "用户ID"
+// @Param event query string false "通知事件，如 lottery.win"
+// @Param status query string false "投递状态：pending、sent、failed"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,500 {object} map[string]interface{}
+// @Router /notifications/logs [get]
+func (ctrl *NotificationController) GetLogs(c *gin.Context) {
+	var query models.NotificationLogQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "查询参数错误: "+err.Error())
+		return
+	}
+
+	pageResp, err := ctrl.notificationService.ListLogs(&query)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取通知记录失败")
+		return
+	}
+	utils.SuccessResponse(c, pageResp)
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/services"
+	"gin-backend/utils"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// oauthStateCookie 保存授权请求 state 摘要的 Cookie，回调时校验发起授权和完成授权的是同一浏览器
+	oauthStateCookie = "oauth_state"
+	// oauthStateCookieMaxAge Cookie 有效期（秒），与服务端 state 有效期一致
+	oauthStateCookieMaxAge = 10 * 60
+)
+
+// OAuthController 第三方登录控制器
+type OAuthController struct {
+	oauthService services.OAuthService
+}
+
+// NewOAuthController 创建第三方登录控制器实例
+func NewOAuthController(oauthService services.OAuthService) *OAuthController {
+	return &OAuthController{
+		oauthService: oauthService,
+	}
+}
+
+// Providers 获取已启用的第三方登录方式
+// @Summary 第三方登录方式
+// @Tags 认证管理
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/oauth/providers [get]
+func (ctrl *OAuthController) Providers(c *gin.Context) {
+	utils.SuccessResponse(c, ctrl.oauthService.Providers())
+}
+
+// Login 跳转到第三方授权页面
+// @Summary 第三方登录
+// @Description 302 跳转到提供方授权页面，授权完成后回调再跳转到前端 /oauth/callback?ticket=xxx
+// @Tags 认证管理
+// @Param provider path string true "提供方，如 github、google、oidc"
+// @Param device_name query string false "设备名称"
+// @Success 302
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/oauth/{provider}/login [get]
+func (ctrl *OAuthController) Login(c *gin.Context) {
+	authURL, binding, err := ctrl.oauthService.AuthURL(c.Param("provider"), 0, &models.DeviceInfo{
+		DeviceName: c.Query("device_name"),
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	setOAuthStateCookie(c, binding, oauthStateCookieMaxAge)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Link 已登录用户绑定第三方账号
+// @Summary 绑定第三方账号
+// @Description 返回授权地址，前端跳转后完成绑定，结果通过 /oauth/callback?linked=provider 返回；响应会写入 state Cookie，跨域调用时需携带凭据
+// @Tags 认证管理
+// @Produce json
+// @Security Bearer
+// @Param provider path string true "提供方"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/oauth/{provider}/link [post]
+func (ctrl *OAuthController) Link(c *gin.Context) {
+	authURL, binding, err := ctrl.oauthService.AuthURL(c.Param("provider"), c.GetUint("userID"), &models.DeviceInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	setOAuthStateCookie(c, binding, oauthStateCookieMaxAge)
+	utils.SuccessResponse(c, gin.H{"url": authURL})
+}
+
+// Callback 第三方授权回调
+// @Summary 第三方授权回调
+// @Description 由提供方重定向调用，处理完成后跳转到前端页面
+// @Tags 认证管理
+// @Param provider path string true "提供方"
+// @Param code query string true "授权码"
+// @Param state query string true "state"
+// @Success 302
+// @Router /auth/oauth/{provider}/callback [get]
+func (ctrl *OAuthController) Callback(c *gin.Context) {
+	// state Cookie 只用一次，无论结果如何都清除
+	binding, _ := c.Cookie(oauthStateCookie)
+	setOAuthStateCookie(c, "", -1)
+
+	params := url.Values{}
+	if errMsg := c.Query("error"); errMsg != "" {
+		// 用户在提供方拒绝授权
+		params.Set("error", "已取消授权")
+	} else if result, err := ctrl.oauthService.HandleCallback(c.Param("provider"), c.Query("state"), binding, c.Query("code")); err != nil {
+		params.Set("error", err.Error())
+	} else if result.Linked {
+		params.Set("linked", result.Provider)
+	} else {
+		params.Set("ticket", result.Ticket)
+	}
+
+	frontend := strings.TrimSuffix(config.AppConfig.OAuth.FrontendURL, "/")
+	c.Redirect(http.StatusFound, frontend+"/oauth/callback?"+params.Encode())
+}
+
+// setOAuthStateCookie 写入（maxAge<0 时清除）state 摘要 Cookie。
+// SameSite=Lax 使提供方重定向回来的顶级 GET 请求仍会携带该 Cookie
+func setOAuthStateCookie(c *gin.Context, binding string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, binding, maxAge, "/", "", secure, true)
+}
+
+// Exchange 使用回调票据换取登录结果
+// @Summary 换取第三方登录结果
+// @Description 票据一次有效，返回内容与账号密码登录一致（可能要求两步验证）
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body models.OAuthExchangeRequest true "票据"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /auth/oauth/exchange [post]
+func (ctrl *OAuthController) Exchange(c *gin.Context) {
+	var req models.OAuthExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	resp, err := ctrl.oauthService.Exchange(req.Ticket)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "登录成功", resp)
+}
+
+// GetIdentities 获取已绑定的第三方账号
+// @Summary 我的第三方账号
+// @Tags 认证管理
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/identities [get]
+func (ctrl *OAuthController) GetIdentities(c *gin.Context) {
+	identities, err := ctrl.oauthService.ListIdentities(c.GetUint("userID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取第三方账号失败")
+		return
+	}
+	utils.SuccessResponse(c, identities)
+}
+
+// Unlink 解绑第三方账号
+// @Summary 解绑第三方账号
+// @Tags 认证管理
+// @Produce json
+// @Security Bearer
+// @Param provider path string true "提供方"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/identities/{provider} [delete]
+func (ctrl *OAuthController) Unlink(c *gin.Context) {
+	if err := ctrl.oauthService.Unlink(c.GetUint("userID"), c.Param("provider"), operatorFromContext(c)); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "已解绑", nil)
+}
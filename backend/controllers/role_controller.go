@@ -56,7 +56,7 @@ func (ctrl *RoleController) CreateRole(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.roleService.CreateRole(&req); err != nil {
+	if err := ctrl.roleService.CreateRole(&req, operatorFromContext(c)); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "创建角色失败: "+err.Error())
 		return
 	}
@@ -78,7 +78,7 @@ func (ctrl *RoleController) UpdateRole(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.roleService.UpdateRole(uint(id), &req); err != nil {
+	if err := ctrl.roleService.UpdateRole(uint(id), &req, operatorFromContext(c)); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "更新角色失败: "+err.Error())
 		return
 	}
@@ -118,7 +118,7 @@ func (ctrl *RoleController) AssignMenus(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.roleService.AssignMenus(uint(id), req.MenuIDs); err != nil {
+	if err := ctrl.roleService.AssignMenus(uint(id), req.MenuIDs, operatorFromContext(c)); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "分配菜单失败: "+err.Error())
 		return
 	}
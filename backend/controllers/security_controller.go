@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"gin-backend/models"
+	"gin-backend/services"
+	"gin-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityController 安全审计控制器
+type SecurityController struct {
+	loginGuard   services.LoginGuardService
+	eventService services.SecurityEventService
+}
+
+// NewSecurityController 创建安全审计控制器实例
+func NewSecurityController(loginGuard services.LoginGuardService, eventService services.SecurityEventService) *SecurityController {
+	return &SecurityController{
+		loginGuard:   loginGuard,
+		eventService: eventService,
+	}
+}
+
+// GetLoginAttempts 分页查询登录尝试记录
+// @Summary 登录尝试记录
+// @Description 按用户名、IP、是否成功筛选登录尝试记录
+// @Tags 安全审计
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Param username query string false "用户名"
+// @Param ip query string false "IP"
+// @Param success query bool false "是否成功"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,500 {object} map[string]interface{}
+// @Router /security/login-attempts [get]
+func (ctrl *SecurityController) GetLoginAttempts(c *gin.Context) {
+	var query models.LoginAttemptQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "查询参数错误: "+err.Error())
+		return
+	}
+
+	pageResp, err := ctrl.loginGuard.ListAttempts(&query)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取登录记录失败")
+		return
+	}
+	utils.SuccessResponse(c, pageResp)
+}
+
+// UnlockLogin 解除登录锁定
+// @Summary 解除登录锁定
+// @Description 清除指定用户名或 IP 的登录失败计数，立即解除锁定
+// @Tags 安全审计
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param body body models.LoginUnlockRequest true "用户名或 IP"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /security/unlock [post]
+func (ctrl *SecurityController) UnlockLogin(c *gin.Context) {
+	var req models.LoginUnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	ctrl.loginGuard.Unlock(req.Username, req.IP)
+	utils.SuccessResponseWithMessage(c, "已解除锁定", nil)
+}
+
+// GetSecurityEvents 分页查询安全事件
+// @Summary 安全事件日志
+// @Description 按用户、事件类型和时间范围筛选登录、退出、会话、令牌、角色等安全事件
+// @Tags 安全审计
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Param user_id query int false "用户ID（匹配事件涉及的用户或操作人）"
+// @Param type query string false "事件类型，如 login.password"
+// @Param start_time query string false "开始时间，格式 2006-01-02 15:04:05"
+// @Param end_time query string false "结束时间，格式 2006-01-02 15:04:05"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,500 {object} map[string]interface{}
+// @Router /security/events [get]
+func (ctrl *SecurityController) GetSecurityEvents(c *gin.Context) {
+	var query models.SecurityEventQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "查询参数错误: "+err.Error())
+		return
+	}
+
+	pageResp, err := ctrl.eventService.ListEvents(&query)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取安全事件失败")
+		return
+	}
+	utils.SuccessResponse(c, pageResp)
+}
+
+// operatorFromContext 从请求上下文中获取当前操作人
+func operatorFromContext(c *gin.Context) *models.Operator {
+	return &models.Operator{
+		UserID:    c.GetUint("userID"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"errors"
+	"gin-backend/models"
+	"gin-backend/services"
+	"gin-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorController 两步验证控制器
+type TwoFactorController struct {
+	twoFactorService services.TwoFactorService
+}
+
+// NewTwoFactorController 创建两步验证控制器实例
+func NewTwoFactorController(twoFactorService services.TwoFactorService) *TwoFactorController {
+	return &TwoFactorController{
+		twoFactorService: twoFactorService,
+	}
+}
+
+// Setup 获取两步验证绑定信息
+// @Summary 开始绑定两步验证
+// @Description 生成新的 TOTP 密钥和 otpauth URI，需在 10 分钟内调用确认接口完成绑定
+// @Tags 认证管理
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/2fa/setup [post]
+func (ctrl *TwoFactorController) Setup(c *gin.Context) {
+	resp, err := ctrl.twoFactorService.BeginSetup(c.GetUint("userID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponse(c, resp)
+}
+
+// Confirm 确认绑定两步验证
+// @Summary 确认绑定两步验证
+// @Description 使用认证器生成的验证码确认绑定，成功后返回恢复码（仅显示一次）
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param body body models.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/2fa/confirm [post]
+func (ctrl *TwoFactorController) Confirm(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	codes, err := ctrl.twoFactorService.ConfirmSetup(c.GetUint("userID"), req.Code)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "两步验证已启用", gin.H{"recovery_codes": codes})
+}
+
+// Disable 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 提供验证码或恢复码后关闭两步验证，角色强制启用时不可关闭
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param body body models.TwoFactorCodeRequest true "验证码或恢复码"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/2fa/disable [post]
+func (ctrl *TwoFactorController) Disable(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.twoFactorService.Disable(c.GetUint("userID"), req.Code); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "两步验证已关闭", nil)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 提供验证码后重新生成恢复码，旧恢复码全部作废
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param body body models.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/2fa/recovery-codes [post]
+func (ctrl *TwoFactorController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	codes, err := ctrl.twoFactorService.RegenerateRecoveryCodes(c.GetUint("userID"), req.Code)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"recovery_codes": codes})
+}
+
+// LoginSetup 登录过程中绑定两步验证
+// @Summary 登录时绑定两步验证
+// @Description 角色强制两步验证但用户尚未绑定时，凭登录挑战令牌获取绑定信息
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body models.TwoFactorChallengeRequest true "登录挑战令牌"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401 {object} map[string]interface{}
+// @Router /login/2fa/setup [post]
+func (ctrl *TwoFactorController) LoginSetup(c *gin.Context) {
+	var req models.TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	resp, err := ctrl.twoFactorService.BeginChallengeSetup(req.ChallengeToken)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	utils.SuccessResponse(c, resp)
+}
+
+// LoginVerify 完成两步验证登录
+// @Summary 两步验证登录
+// @Description 提交登录挑战令牌和验证码（或恢复码）完成登录
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body models.TwoFactorLoginRequest true "两步验证信息"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401,429 {object} map[string]interface{}
+// @Router /login/2fa [post]
+func (ctrl *TwoFactorController) LoginVerify(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	loginResp, err := ctrl.twoFactorService.CompleteLogin(req.ChallengeToken, req.Code)
+	if errors.Is(err, services.ErrLoginLocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"code":    429,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "登录成功",
+		"data":    loginResp,
+	})
+}
@@ -1,13 +1,16 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"gin-backend/models"
 	"gin-backend/services"
 	"gin-backend/utils"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/silenceper/wechat/v2/officialaccount/message"
@@ -15,15 +18,23 @@ import (
 
 // UserController 用户控制器
 type UserController struct {
-	userService   services.UserService
-	wechatService services.WechatService
+	userService        services.UserService
+	wechatService      services.WechatService
+	miniProgramService services.WechatMiniProgramService
+	accountService     services.AccountService
+	loginGuard         services.LoginGuardService
+	jobQueue           services.JobQueue
 }
 
 // NewUserController 创建用户控制器实例
-func NewUserController(userService services.UserService, wechatService services.WechatService) *UserController {
+func NewUserController(userService services.UserService, wechatService services.WechatService, miniProgramService services.WechatMiniProgramService, accountService services.AccountService, loginGuard services.LoginGuardService, jobQueue services.JobQueue) *UserController {
 	return &UserController{
-		userService:   userService,
-		wechatService: wechatService,
+		userService:        userService,
+		wechatService:      wechatService,
+		miniProgramService: miniProgramService,
+		accountService:     accountService,
+		loginGuard:         loginGuard,
+		jobQueue:           jobQueue,
 	}
 }
 
@@ -150,8 +161,9 @@ func (ctrl *UserController) CreateUser(c *gin.Context) {
 func (ctrl *UserController) Register(c *gin.Context) {
 	var req struct {
 		models.UserCreateRequest
-		CaptchaID string `json:"captcha_id" binding:"required"`
-		Captcha   string `json:"captcha" binding:"required,len=6"`
+		CaptchaID   string `json:"captcha_id"`
+		Captcha     string `json:"captcha" binding:"omitempty,max=8192"`
+		CaptchaType string `json:"captcha_type" binding:"omitempty,oneof=digit slider"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -163,11 +175,15 @@ func (ctrl *UserController) Register(c *gin.Context) {
 		return
 	}
 
-	// 验证验证码
-	if !utils.VerifyCaptcha(req.CaptchaID, req.Captcha) {
+	// 按风险决定是否需要验证码
+	device := &models.DeviceInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	ctrl.loginGuard.TrackRequest(device)
+	risk := ctrl.loginGuard.Assess(models.RiskSceneRegister, "", device)
+	if err := services.CheckCaptcha(risk, req.CaptchaType, req.CaptchaID, req.Captcha); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "验证码错误或已过期",
+			"code":             400,
+			"message":          err.Error(),
+			"captcha_required": true,
 		})
 		return
 	}
@@ -186,13 +202,46 @@ func (ctrl *UserController) Register(c *gin.Context) {
 		return
 	}
 
+	// 由后台任务发送邮箱验证邮件，失败时自动重试，不影响注册结果
+	if _, err := ctrl.jobQueue.Enqueue(services.JobSendVerificationEmail, services.VerificationEmailJob{UserID: user.ID}); err != nil {
+		log.Printf("提交邮箱验证邮件任务失败: user=%d err=%v", user.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"code":    0,
-		"message": "注册成功",
+		"message": "注册成功，请查收邮箱验证邮件",
 		"data":    user,
 	})
 }
 
+// CheckUsername 检查用户名是否可用
+// @Summary 检查用户名是否可用
+// @Description 注册表单输入用户名后调用，已注册的用户名返回 available=false
+// @Tags 认证管理
+// @Produce json
+// @Param username query string true "用户名"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,500 {object} map[string]interface{}
+// @Router /register/check [get]
+func (ctrl *UserController) CheckUsername(c *gin.Context) {
+	var query models.UsernameCheckQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"errors":  utils.FormatValidationErrors(err),
+		})
+		return
+	}
+
+	available, err := ctrl.userService.IsUsernameAvailable(query.Username)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "检查用户名失败")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"available": available})
+}
+
 // UpdateUser 更新用户
 // @Summary 更新用户信息
 // @Description 修改指定用户的信息
@@ -227,7 +276,7 @@ func (ctrl *UserController) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	user, err := ctrl.userService.UpdateUser(uint(id), &req)
+	user, err := ctrl.userService.UpdateUser(uint(id), &req, operatorFromContext(c))
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "用户不存在" {
@@ -347,11 +396,33 @@ func (ctrl *UserController) Login(c *gin.Context) {
 		return
 	}
 
-	loginResp, err := ctrl.userService.Login(&req)
+	device := &models.DeviceInfo{
+		DeviceName: req.DeviceName,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	loginResp, err := ctrl.userService.Login(&req, device)
+	if errors.Is(err, services.ErrLoginLocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"code":    429,
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrCaptchaRequired) || errors.Is(err, services.ErrCaptchaInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":             400,
+			"message":          err.Error(),
+			"captcha_required": true,
+		})
+		return
+	}
 	if err != nil {
+		// 告知客户端下次尝试是否需要验证码
 		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": err.Error(),
+			"code":             401,
+			"message":          err.Error(),
+			"captcha_required": ctrl.loginGuard.Assess(models.RiskSceneLogin, req.Username, device).CaptchaRequired,
 		})
 		return
 	}
@@ -363,6 +434,70 @@ func (ctrl *UserController) Login(c *gin.Context) {
 	})
 }
 
+// CaptchaRequired 查询本次登录/注册是否需要验证码
+// @Summary 是否需要验证码
+// @Description 根据失败次数、IP 名单、是否新设备和请求频率评估风险，客户端据此决定是否展示验证码
+// @Tags 认证管理
+// @Produce json
+// @Param scene query string false "场景：login（默认）或 register"
+// @Param username query string false "登录用户名"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /captcha/required [get]
+func (ctrl *UserController) CaptchaRequired(c *gin.Context) {
+	var query models.RiskQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "查询参数错误: "+err.Error())
+		return
+	}
+	if query.Scene == "" {
+		query.Scene = models.RiskSceneLogin
+	}
+
+	risk := ctrl.loginGuard.Assess(query.Scene, query.Username, &models.DeviceInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	utils.SuccessResponse(c, gin.H{"captcha_required": risk.CaptchaRequired})
+}
+
+// RefreshToken 刷新令牌
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧刷新令牌随即失效
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param refresh body models.RefreshTokenRequest true "刷新令牌"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401 {object} map[string]interface{}
+// @Router /auth/refresh [post]
+func (ctrl *UserController) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"errors":  utils.FormatValidationErrors(err),
+		})
+		return
+	}
+
+	tokenResp, err := ctrl.userService.RefreshToken(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "刷新成功",
+		"data":    tokenResp,
+	})
+}
+
 // ExitLogin 退出登录
 func (ctrl *UserController) ExitLogin(c *gin.Context) {
 	// 从请求头获取 token
@@ -387,8 +522,14 @@ func (ctrl *UserController) ExitLogin(c *gin.Context) {
 
 	token := parts[1]
 
+	// 可选：同时吊销刷新令牌
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
 	// 调用服务层退出登录
-	err := ctrl.userService.ExitLogin(token)
+	err := ctrl.userService.ExitLogin(token, req.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -404,6 +545,82 @@ func (ctrl *UserController) ExitLogin(c *gin.Context) {
 	})
 }
 
+// GetSessions 获取当前用户的在线会话
+// @Summary 获取我的登录会话
+// @Description 列出当前用户在各设备上的登录会话
+// @Tags 认证管理
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/sessions [get]
+func (ctrl *UserController) GetSessions(c *gin.Context) {
+	userID := c.GetUint("userID")
+	sessions := ctrl.userService.ListSessions(userID, c.GetString("sessionID"))
+	utils.SuccessResponse(c, sessions)
+}
+
+// RevokeSession 注销当前用户的指定会话
+// @Summary 注销登录会话
+// @Description 将当前用户在指定设备上的会话下线
+// @Tags 认证管理
+// @Produce json
+// @Security Bearer
+// @Param id path string true "会话ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /auth/sessions/{id} [delete]
+func (ctrl *UserController) RevokeSession(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if err := ctrl.userService.RevokeSession(userID, c.Param("id"), operatorFromContext(c)); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "会话已注销", nil)
+}
+
+// GetUserSessions 管理员查看指定用户的在线会话
+// @Summary 获取用户登录会话
+// @Tags 用户管理
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /users/{id}/sessions [get]
+func (ctrl *UserController) GetUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
+		return
+	}
+
+	sessions := ctrl.userService.ListSessions(uint(id), c.GetString("sessionID"))
+	utils.SuccessResponse(c, sessions)
+}
+
+// RevokeUserSession 管理员注销指定用户的会话
+// @Summary 注销用户登录会话
+// @Tags 用户管理
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Param sid path string true "会话ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404 {object} map[string]interface{}
+// @Router /users/{id}/sessions/{sid} [delete]
+func (ctrl *UserController) RevokeUserSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
+		return
+	}
+
+	if err := ctrl.userService.RevokeSession(uint(id), c.Param("sid"), operatorFromContext(c)); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "会话已注销", nil)
+}
+
 // GetWeChatQRCode 获取微信登录二维码
 func (ctrl *UserController) GetWeChatQRCode(c *gin.Context) {
 	session, qrURL, err := ctrl.wechatService.GetQRCode()
@@ -440,23 +657,18 @@ func (ctrl *UserController) CheckWeChatStatus(c *gin.Context) {
 		return
 	}
 
-	// 如果状态是成功，则返回用户 Token
+	// 如果状态是成功，则返回用户 Token（每个会话只签发一次）
 	if session.Status == services.StatusSuccess {
-		loginResp, err := ctrl.userService.LoginByUserID(session.UserID)
+		data, err := ctrl.wechatLogin(c, sceneID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "授权登录失败"})
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"code":    0,
 			"message": "success",
-			"data": gin.H{
-				"status": "SUCCESS",
-				"token":  loginResp.Token,
-				"user":   loginResp.User,
-				"menus":  loginResp.Menus,
-			},
+			"data":    data,
 		})
 		return
 	}
@@ -465,18 +677,258 @@ func (ctrl *UserController) CheckWeChatStatus(c *gin.Context) {
 		"code":    0,
 		"message": "success",
 		"data": gin.H{
-			"status": session.Status,
+			"status":    session.Status,
+			"need_bind": session.NeedBind, // 微信未绑定账号，需调用注册或绑定接口
 		},
 	})
 }
 
-// MockWeChatScan 模拟微信扫码成功（仅供开发测试使用）
+// WechatEvents 推送微信登录状态（SSE）
+// @Summary 微信登录状态推送
+// @Description Server-Sent Events：status 事件推送 PENDING → SCANNING → SUCCESS/EXPIRED，SUCCESS 时携带令牌（只签发一次），需要两步验证时改为携带 challenge_token；领取失败时推送 fail 事件
+// @Tags 认证管理
+// @Produce text/event-stream
+// @Param scene_id query string true "场景ID"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/wechat/events [get]
+func (ctrl *UserController) WechatEvents(c *gin.Context) {
+	sceneID := c.Query("scene_id")
+	if sceneID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "scene_id 不能为空"})
+		return
+	}
+
+	// 先订阅再读取当前状态，避免两者之间的状态变化丢失
+	ctx := c.Request.Context()
+	events, stop, err := ctrl.wechatService.Watch(ctx, sceneID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "订阅登录状态失败"})
+		return
+	}
+	defer stop()
+
+	session, err := ctrl.wechatService.CheckStatus(sceneID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁止 Nginx 缓冲
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	expire := time.NewTimer(time.Until(session.ExpireAt))
+	defer expire.Stop()
+
+	if ctrl.pushWechatStatus(c, session) {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+			c.Writer.Flush()
+		case <-expire.C:
+			session.Status = services.StatusExpired
+			ctrl.pushWechatStatus(c, session)
+			return
+		case next, ok := <-events:
+			if !ok {
+				return
+			}
+			session = next
+			if ctrl.pushWechatStatus(c, session) {
+				return
+			}
+		}
+	}
+}
+
+// pushWechatStatus 推送一次会话状态，返回是否已到终态（连接应关闭）
+func (ctrl *UserController) pushWechatStatus(c *gin.Context, session *services.WechatSession) bool {
+	switch session.Status {
+	case services.StatusSuccess:
+		data, err := ctrl.wechatLogin(c, session.SceneID)
+		if err != nil {
+			c.SSEvent("fail", gin.H{"message": err.Error()})
+		} else {
+			c.SSEvent("status", data)
+		}
+	case services.StatusExpired:
+		c.SSEvent("status", gin.H{"status": session.Status})
+	default:
+		c.SSEvent("status", gin.H{"status": session.Status, "need_bind": session.NeedBind})
+		c.Writer.Flush()
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// wechatLogin 领取扫码成功的会话并签发令牌，同一会话只能领取一次；
+// 已启用或角色要求两步验证时返回登录挑战，客户端凭 challenge_token 调用 /login/2fa 完成登录
+func (ctrl *UserController) wechatLogin(c *gin.Context, sceneID string) (gin.H, error) {
+	session, err := ctrl.wechatService.ClaimLogin(sceneID)
+	if err != nil {
+		return nil, err
+	}
+
+	method := models.SecurityEventLoginWechat
+	if session.Mock {
+		method = models.SecurityEventLoginWechatMock
+	}
+	loginResp, err := ctrl.userService.BeginLogin(session.UserID, &models.DeviceInfo{
+		DeviceName:  "微信扫码登录",
+		IP:          c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		LoginMethod: method,
+	})
+	if err != nil {
+		return nil, errors.New("授权登录失败")
+	}
+	if loginResp.TwoFactorRequired {
+		return gin.H{
+			"status":                    services.StatusSuccess,
+			"two_factor_required":       true,
+			"two_factor_setup_required": loginResp.TwoFactorSetupRequired,
+			"challenge_token":           loginResp.ChallengeToken,
+		}, nil
+	}
+
+	return gin.H{
+		"status":        services.StatusSuccess,
+		"token":         loginResp.Token,
+		"refresh_token": loginResp.RefreshToken,
+		"expires_in":    loginResp.ExpiresIn,
+		"user":          loginResp.User,
+		"menus":         loginResp.Menus,
+	}, nil
+}
+
+// WechatRegister 扫码的微信未绑定账号时注册新账号
+// @Summary 微信扫码注册
+// @Description 状态为 SCANNING 且 need_bind 时调用，完成后继续轮询状态接口获取令牌
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body models.WechatRegisterRequest true "场景ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/wechat/register [post]
+func (ctrl *UserController) WechatRegister(c *gin.Context) {
+	var req models.WechatRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.wechatService.RegisterByScene(req.SceneID); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "注册成功", nil)
+}
+
+// WechatBind 扫码的微信未绑定账号时绑定已有账号
+// @Summary 微信扫码绑定已有账号
+// @Description 状态为 SCANNING 且 need_bind 时调用，验证账号密码后绑定，完成后继续轮询状态接口获取令牌；账号需要两步验证时返回 challenge_token，调用 /login/2fa 完成后才绑定并登录
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body models.WechatBindRequest true "场景ID和账号密码"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,429 {object} map[string]interface{}
+// @Router /auth/wechat/bind [post]
+func (ctrl *UserController) WechatBind(c *gin.Context) {
+	var req models.WechatBindRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	challenge, err := ctrl.wechatService.BindByScene(req.SceneID, req.Username, req.Password, &models.DeviceInfo{
+		DeviceName:  "微信扫码登录",
+		IP:          c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		LoginMethod: models.SecurityEventLoginWechat,
+	})
+	if errors.Is(err, services.ErrLoginLocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"code":    429,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if challenge != nil {
+		utils.SuccessResponseWithMessage(c, "请完成两步验证", challenge)
+		return
+	}
+	utils.SuccessResponseWithMessage(c, "绑定成功", nil)
+}
+
+// WechatMiniProgramLogin 微信小程序登录
+// @Summary 微信小程序登录
+// @Description 用 wx.login 获取的 code 登录，可附带加密的用户信息和手机号；未绑定的微信按 UnionID 关联公众号扫码登录过的账号，否则自动注册
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body models.WechatMiniProgramLoginRequest true "登录凭证及加密数据"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401 {object} map[string]interface{}
+// @Router /auth/wechat/miniprogram [post]
+func (ctrl *UserController) WechatMiniProgramLogin(c *gin.Context) {
+	var req models.WechatMiniProgramLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	deviceName := req.DeviceName
+	if deviceName == "" {
+		deviceName = "微信小程序"
+	}
+	loginResp, err := ctrl.miniProgramService.Login(&req, &models.DeviceInfo{
+		DeviceName: deviceName,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	})
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "登录成功",
+		"data":    loginResp,
+	})
+}
+
+// MockWeChatScan 模拟微信扫码成功（仅供开发测试使用，只在 debug 模式注册路由）
+// 传 openid 时模拟该微信扫码（未绑定则进入注册/绑定流程），否则必须显式指定 user_id 登录
 func (ctrl *UserController) MockWeChatScan(c *gin.Context) {
 	sceneID := c.Query("scene_id")
-	userIDStr := c.DefaultQuery("user_id", "1") // 默认为管理员用户
-	userID, _ := strconv.Atoi(userIDStr)
+	openID := c.Query("openid")
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	if openID == "" && (err != nil || userID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "请指定 openid 或 user_id"})
+		return
+	}
 
-	err := ctrl.wechatService.MockScan(sceneID, uint(userID))
+	err = ctrl.wechatService.MockScan(sceneID, uint(userID), openID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
 		return
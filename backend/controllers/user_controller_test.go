@@ -7,8 +7,11 @@ import (
 	"testing"
 
 	"gin-backend/config"
+	"gin-backend/repositories"
 	"gin-backend/routes"
+	"gin-backend/services"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,9 +20,14 @@ func init() {
 	config.LoadConfig()
 }
 
+// setupRouter 创建路由，任务队列不启动，提交的任务只写入数据库
+func setupRouter() *gin.Engine {
+	return routes.SetupRouter(config.DB, services.NewJobQueue(repositories.NewJobRepository(config.DB), services.JobQueueOptions{}))
+}
+
 func TestGetUsers(t *testing.T) {
 	// 设置路由
-	router := routes.SetupRouter(config.DB)
+	router := setupRouter()
 
 	// 创建测试请求
 	req, _ := http.NewRequest("GET", "/api/v1/users", nil)
@@ -41,7 +49,7 @@ func TestGetUsers(t *testing.T) {
 }
 
 func TestGetUser(t *testing.T) {
-	router := routes.SetupRouter(config.DB)
+	router := setupRouter()
 
 	req, _ := http.NewRequest("GET", "/api/v1/users/1", nil)
 	w := httptest.NewRecorder()
@@ -58,7 +66,7 @@ func TestGetUser(t *testing.T) {
 }
 
 func TestGetUserNotFound(t *testing.T) {
-	router := routes.SetupRouter(config.DB)
+	router := setupRouter()
 
 	req, _ := http.NewRequest("GET", "/api/v1/users/999", nil)
 	w := httptest.NewRecorder()
@@ -74,7 +82,7 @@ func TestGetUserNotFound(t *testing.T) {
 }
 
 func TestHealthCheck(t *testing.T) {
-	router := routes.SetupRouter(config.DB)
+	router := setupRouter()
 
 	req, _ := http.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"gin-backend/config"
 	"gin-backend/models"
 	"gin-backend/routes"
+	"gin-backend/services"
+	"gin-backend/utils"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,10 +27,21 @@ import (
 // @in header
 // @name Authorization
 
+// httpShutdownTimeout 停止服务时等待处理中的请求完成的最长时间
+const httpShutdownTimeout = 10 * time.Second
+
 func main() {
 	// 加载配置
 	config.LoadConfig()
 
+	// 加载 JWT 签名密钥
+	if err := utils.InitJWTKeys(config.AppConfig.JWT); err != nil {
+		log.Fatalf("JWT 密钥加载失败: %v", err)
+	}
+
+	// 初始化邮件发送
+	utils.InitMailer(config.AppConfig.Mail)
+
 	// 初始化数据库连接
 	if err := config.InitDB(); err != nil {
 		log.Fatalf("数据库连接失败: %v", err)
@@ -36,9 +55,17 @@ func main() {
 		defer config.CloseRedis()
 	}
 
+	// 初始化缓存，包级缓存函数与注入各服务的缓存为同一实例
+	cache, err := utils.NewCache(config.AppConfig.Cache)
+	if err != nil {
+		log.Fatalf("缓存初始化失败: %v", err)
+	}
+	utils.SetDefaultCache(cache)
+	defer cache.Close()
+
 	// 自动迁移数据库表
 	log.Println("开始数据库迁移...")
-	if err := config.AutoMigrate(&models.User{}, &models.Payment{}, &models.Order{}, &models.File{}, &models.LotteryActivity{}, &models.LotteryPrize{}, &models.LotteryRecord{}); err != nil {
+	if err := config.AutoMigrate(&models.User{}, &models.Payment{}, &models.Order{}, &models.File{}, &models.LotteryActivity{}, &models.LotteryPrize{}, &models.LotteryRecord{}, &models.UserRecoveryCode{}, &models.LoginAttempt{}, &models.SecurityEvent{}, &models.UserIdentity{}, &models.NotificationOptOut{}, &models.NotificationLog{}, &models.Job{}); err != nil {
 		log.Fatalf("数据库迁移失败: %v", err)
 	}
 	log.Println("数据库迁移完成")
@@ -48,19 +75,53 @@ func main() {
 	if err := config.InitRolesAndMenus(config.DB); err != nil {
 		log.Fatalf("角色和菜单初始化失败: %v", err)
 	}
+	if err := config.InitPermissions(config.DB); err != nil {
+		log.Fatalf("按钮权限初始化失败: %v", err)
+	}
 
 	// 设置 Gin 模式
 	if config.AppConfig.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// 创建路由，传入数据库连接
-	r := routes.SetupRouter(config.DB)
+	// 初始化后台任务队列
+	jobQueue, err := services.NewJobQueueFromConfig(config.AppConfig.Job, config.DB)
+	if err != nil {
+		log.Fatalf("任务队列初始化失败: %v", err)
+	}
+
+	// 创建路由，传入数据库连接；处理函数在此注册，之后再启动工作协程
+	r := routes.SetupRouter(config.DB, jobQueue)
+	jobQueue.Start()
 
 	// 启动服务器
 	addr := config.AppConfig.Host + ":" + config.AppConfig.Port
-	log.Printf("服务器启动在: %s", addr)
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+	srv := &http.Server{Addr: addr, Handler: r}
+	go func() {
+		log.Printf("服务器启动在: %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("服务器启动失败: %v", err)
+		}
+	}()
+
+	// 收到停止信号后先停止接收请求，再等待执行中的后台任务完成
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	stop()
+	log.Println("正在停止服务...")
+
+	// HTTP 服务和后台任务各自计时，等待慢请求不会占用任务的等待时间
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancelHTTP()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		log.Printf("HTTP 服务停止失败: %v", err)
+	}
+	jobCtx, cancelJobs := context.WithTimeout(context.Background(), time.Duration(config.AppConfig.Job.ShutdownTimeoutSeconds)*time.Second)
+	defer cancelJobs()
+	if err := jobQueue.Shutdown(jobCtx); err != nil {
+		log.Printf("后台任务未在限定时间内完成，已归还给队列: %v", err)
 	}
+	routes.Shutdown()
+	log.Println("服务已停止")
 }
@@ -50,6 +50,15 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// 解析并验证 JWT token
 		claims, err := utils.ParseToken(token)
+		if err != nil && utils.IsTokenExpired(err) {
+			// 访问令牌过期，提示客户端调用 /auth/refresh
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "认证令牌已过期",
+			})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":    401,
@@ -59,20 +68,22 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 单点登录检查：验证 token 是否是用户的当前有效 token
-		if !utils.IsUserCurrentToken(claims.UserID, token) {
+		// 会话检查：会话可能已被用户/管理员注销，或因超出设备数被挤下线
+		if !utils.IsSessionActive(claims.UserID, claims.SessionID) {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":    401,
-				"message": "您的账号已在其他设备登录",
+				"message": "登录会话已失效，请重新登录",
 			})
 			c.Abort()
 			return
 		}
+		utils.TouchSession(claims.UserID, claims.SessionID, c.ClientIP(), c.Request.UserAgent())
 
 		// 将用户信息存储到上下文中
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
+		c.Set("sessionID", claims.SessionID)
 
 		c.Next()
 
@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionChecker 权限校验接口
+type PermissionChecker interface {
+	HasPermission(userID uint, code string) (bool, error)
+}
+
+var permissionChecker PermissionChecker
+
+// InitPermission 注册权限校验实现，需在设置路由前调用
+func InitPermission(checker PermissionChecker) {
+	permissionChecker = checker
+}
+
+// RequirePermission 权限校验中间件，需放在 AuthMiddleware 之后
+// code 为菜单/按钮上配置的权限标识，如 system:user:delete
+func RequirePermission(code string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userID")
+		if userID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "未授权",
+			})
+			c.Abort()
+			return
+		}
+
+		if permissionChecker == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "权限校验未初始化",
+			})
+			c.Abort()
+			return
+		}
+
+		allowed, err := permissionChecker.HasPermission(userID, code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "权限校验失败",
+			})
+			c.Abort()
+			return
+		}
+
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "权限不足",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// 后台任务状态
+const (
+	JobPending   = "pending"   // 等待执行（包括等待重试）
+	JobRunning   = "running"   // 已被工作协程领取，租约到期前未完成会重新投递
+	JobCompleted = "completed" // 执行成功
+	JobDead      = "dead"      // 重试次数用尽或不可重试的错误，需人工处理
+)
+
+// Job 后台任务
+type Job struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type" gorm:"size:100;not null;index"` // 任务类型，对应注册的处理函数
+	Payload     string     `json:"payload" gorm:"type:text"`            // 任务参数（JSON）
+	Status      string     `json:"status" gorm:"size:20;not null;index:idx_job_status_run_at,priority:1"`
+	Attempts    int        `json:"attempts"`                                             // 已执行次数
+	MaxAttempts int        `json:"max_attempts"`                                         // 最多执行次数
+	RunAt       time.Time  `json:"run_at" gorm:"index:idx_job_status_run_at,priority:2"` // 最早执行时间，重试时按退避时间推后
+	LockedBy    string     `json:"locked_by" gorm:"size:64"`                             // 领取任务的工作协程
+	LockedUntil *time.Time `json:"locked_until"`                                         // 租约到期时间
+	LastError   string     `json:"last_error" gorm:"size:1000"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// LoginAttempt 登录尝试记录
+type LoginAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Username  string    `json:"username" gorm:"size:50;index"` // 尝试登录的用户名（可能不存在）
+	UserID    uint      `json:"user_id" gorm:"index"`          // 用户不存在时为 0
+	IP        string    `json:"ip" gorm:"size:64;index"`
+	UserAgent string    `json:"user_agent" gorm:"size:255"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason" gorm:"size:100"` // 失败原因
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// LoginAttemptQuery 登录尝试记录查询条件
+type LoginAttemptQuery struct {
+	PageRequest
+	Username string `form:"username"`
+	IP       string `form:"ip"`
+	Success  *bool  `form:"success"`
+}
+
+// LoginUnlockRequest 解除登录锁定请求，按用户名或 IP 解锁
+type LoginUnlockRequest struct {
+	Username string `json:"username" binding:"required_without=IP,omitempty,max=50" validate:"required_without=IP,omitempty,max=50"`
+	IP       string `json:"ip" binding:"required_without=Username,omitempty,ip" validate:"required_without=Username,omitempty,ip"`
+}
+
+// 风险评估场景
+const (
+	RiskSceneLogin    = "login"
+	RiskSceneRegister = "register"
+)
+
+// RiskAssessment 登录/注册请求的风险评估结果
+type RiskAssessment struct {
+	Score           int      `json:"score"`
+	Reasons         []string `json:"reasons,omitempty"` // 命中的风险信号
+	CaptchaRequired bool     `json:"captcha_required"`
+}
+
+// RiskQuery 查询是否需要验证码
+type RiskQuery struct {
+	Scene    string `form:"scene" binding:"omitempty,oneof=login register"` // 默认 login
+	Username string `form:"username" binding:"omitempty,max=50"`
+}
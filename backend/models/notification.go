@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// 通知事件类型
+const (
+	NotifyLotteryWin  = "lottery.win"  // 抽奖中奖
+	NotifyOrderStatus = "order.status" // 订单状态变更
+)
+
+// NotifyEvents 用户可以单独关闭的通知事件
+var NotifyEvents = []string{NotifyLotteryWin, NotifyOrderStatus}
+
+// 通知投递状态
+const (
+	NotificationPending = "pending" // 投递中
+	NotificationSent    = "sent"    // 已送达微信
+	NotificationFailed  = "failed"  // 重试后仍失败
+)
+
+// NotificationOptOut 用户关闭的通知事件，没有记录即为开启
+type NotificationOptOut struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_opt_out_user_event"`
+	Event     string    `json:"event" gorm:"not null;size:50;uniqueIndex:idx_opt_out_user_event"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationLog 微信模板消息投递记录
+type NotificationLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"index"`
+	OpenID     string    `json:"openid" gorm:"size:64"`
+	Event      string    `json:"event" gorm:"size:50;index"`
+	TemplateID string    `json:"template_id" gorm:"size:64"`
+	Payload    string    `json:"payload" gorm:"type:text"` // 模板数据（JSON）
+	Status     string    `json:"status" gorm:"size:20;index"`
+	Attempts   int       `json:"attempts"`
+	MsgID      int64     `json:"msg_id"` // 微信返回的消息ID
+	Error      string    `json:"error" gorm:"size:500"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NotificationLogQuery 投递记录查询条件
+type NotificationLogQuery struct {
+	PageRequest
+	UserID uint   `form:"user_id"`
+	Event  string `form:"event"`
+	Status string `form:"status"`
+}
+
+// NotificationSetting 单个通知事件的开关
+type NotificationSetting struct {
+	Event   string `json:"event" binding:"required" validate:"required"`
+	Enabled bool   `json:"enabled"`
+}
@@ -0,0 +1,65 @@
+package models
+
+// 权限标识（对应按钮类型菜单的 Permission 字段）
+const (
+	PermUserList    = "system:user:list"
+	PermUserCreate  = "system:user:create"
+	PermUserUpdate  = "system:user:update"
+	PermUserDelete  = "system:user:delete"
+	PermUserSession = "system:user:session"
+
+	PermSecurityAttempts = "system:security:attempts"
+	PermSecurityUnlock   = "system:security:unlock"
+	PermSecurityEvents   = "system:security:events"
+	PermNotificationLogs = "system:notification:logs"
+
+	PermRoleList   = "system:role:list"
+	PermRoleCreate = "system:role:create"
+	PermRoleUpdate = "system:role:update"
+	PermRoleDelete = "system:role:delete"
+	PermRoleAssign = "system:role:assign"
+
+	PermMenuList   = "system:menu:list"
+	PermMenuCreate = "system:menu:create"
+	PermMenuUpdate = "system:menu:update"
+	PermMenuDelete = "system:menu:delete"
+
+	PermLotteryList   = "lottery:admin:list"
+	PermLotteryEdit   = "lottery:admin:edit"
+	PermLotteryDelete = "lottery:admin:delete"
+)
+
+// PermissionDefinition 内置权限定义
+type PermissionDefinition struct {
+	Code       string // 权限标识
+	Name       string // 按钮名称
+	ParentPath string // 所属菜单路径
+}
+
+// BuiltinPermissions 系统内置的按钮权限，启动时自动补齐
+var BuiltinPermissions = []PermissionDefinition{
+	{Code: PermUserList, Name: "查询用户", ParentPath: "/system/users"},
+	{Code: PermUserCreate, Name: "新增用户", ParentPath: "/system/users"},
+	{Code: PermUserUpdate, Name: "修改用户", ParentPath: "/system/users"},
+	{Code: PermUserDelete, Name: "删除用户", ParentPath: "/system/users"},
+	{Code: PermUserSession, Name: "管理会话", ParentPath: "/system/users"},
+	{Code: PermSecurityAttempts, Name: "登录记录", ParentPath: "/system/users"},
+	{Code: PermSecurityUnlock, Name: "解除锁定", ParentPath: "/system/users"},
+	{Code: PermSecurityEvents, Name: "安全事件", ParentPath: "/system/users"},
+	{Code: PermNotificationLogs, Name: "通知记录", ParentPath: "/system/users"},
+
+	{Code: PermRoleList, Name: "查询角色", ParentPath: "/system/roles"},
+	{Code: PermRoleCreate, Name: "新增角色", ParentPath: "/system/roles"},
+	{Code: PermRoleUpdate, Name: "修改角色", ParentPath: "/system/roles"},
+	{Code: PermRoleDelete, Name: "删除角色", ParentPath: "/system/roles"},
+	{Code: PermRoleAssign, Name: "分配菜单", ParentPath: "/system/roles"},
+
+	{Code: PermMenuList, Name: "查询菜单", ParentPath: "/system/menus"},
+	{Code: PermMenuCreate, Name: "新增菜单", ParentPath: "/system/menus"},
+	{Code: PermMenuUpdate, Name: "修改菜单", ParentPath: "/system/menus"},
+	{Code: PermMenuDelete, Name: "删除菜单", ParentPath: "/system/menus"},
+
+	{Code: PermLotteryList, Name: "查询抽奖", ParentPath: "/lottery-admin"},
+	{Code: PermLotteryEdit, Name: "编辑抽奖", ParentPath: "/lottery-admin"},
+	{Code: PermLotteryDelete, Name: "删除抽奖", ParentPath: "/lottery-admin"},
+}
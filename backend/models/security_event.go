@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// 安全事件类型
+const (
+	SecurityEventLoginPassword   = "login.password"    // 账号密码登录
+	SecurityEventLoginWechat     = "login.wechat"      // 微信扫码登录
+	SecurityEventLoginWechatMock = "login.wechat_mock" // 模拟扫码登录（开发用）
+	SecurityEventLoginOAuth      = "login.oauth"       // 第三方账号（OAuth2/OIDC）登录
+	SecurityEventLoginWechatMini = "login.wechat_mini" // 微信小程序登录
+	SecurityEventLogout          = "logout"            // 退出登录
+	SecurityEventSessionEvicted  = "session.evicted"   // 在线设备数超限，被新登录的设备挤下线
+	SecurityEventSessionRevoked  = "session.revoked"   // 会话被本人或管理员注销
+	SecurityEventTokenBlacklist  = "token.blacklisted" // 访问令牌被拉黑
+	SecurityEventRefreshReused   = "token.reused"      // 刷新令牌被重放，整个会话被吊销
+	SecurityEventRoleChanged     = "user.role_changed" // 用户角色变更
+	SecurityEventRoleMenus       = "role.menus"        // 角色菜单/权限分配
+	SecurityEventIdentityLinked  = "identity.linked"   // 绑定第三方账号
+	SecurityEventIdentityRemoved = "identity.unlinked" // 解绑第三方账号
+)
+
+// SecurityEvent 安全事件日志
+type SecurityEvent struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"index"`      // 事件涉及的用户
+	Type       string    `json:"type" gorm:"size:50;index"` // 事件类型
+	SessionID  string    `json:"session_id" gorm:"size:64"`
+	OperatorID uint      `json:"operator_id" gorm:"index"` // 操作人，用户本人操作或系统触发时为 0
+	IP         string    `json:"ip" gorm:"size:64"`
+	UserAgent  string    `json:"user_agent" gorm:"size:255"`
+	Detail     string    `json:"detail" gorm:"size:500"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// SecurityEventQuery 安全事件查询条件
+type SecurityEventQuery struct {
+	PageRequest
+	UserID    uint      `form:"user_id"`
+	Type      string    `form:"type"`
+	StartTime time.Time `form:"start_time" time_format:"2006-01-02 15:04:05" time_location:"Local"`
+	EndTime   time.Time `form:"end_time" time_format:"2006-01-02 15:04:05" time_location:"Local"`
+}
+
+// Operator 发起操作的用户及其来源，用于记录安全事件
+type Operator struct {
+	UserID    uint
+	IP        string
+	UserAgent string
+}
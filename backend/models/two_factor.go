@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// UserRecoveryCode 两步验证恢复码（只保存哈希，每个恢复码只能使用一次）
+type UserRecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;size:64;index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TwoFactorCodeRequest 两步验证码请求（TOTP 验证码或恢复码）
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required,max=20" validate:"required,max=20"`
+}
+
+// TwoFactorLoginRequest 两步验证登录请求
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required" validate:"required"`
+	Code           string `json:"code" binding:"required,max=20" validate:"required,max=20"`
+}
+
+// TwoFactorChallengeRequest 登录挑战请求（角色强制两步验证但用户尚未绑定时获取绑定信息）
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required" validate:"required"`
+}
+
+// TwoFactorSetupResponse 两步验证绑定信息
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`      // Base32 密钥，供无法扫码时手动输入
+	OtpauthURI string `json:"otpauth_uri"` // 前端据此生成二维码
+}
@@ -4,16 +4,20 @@ import "time"
 
 // User 用户模型
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Username  string    `json:"username" gorm:"uniqueIndex;not null;size:50"`
-	Email     string    `json:"email" gorm:"uniqueIndex;not null;size:100"`
-	Password  string    `json:"-" gorm:"not null;size:255"` // - 表示不在 JSON 中序列化
-	Nickname  string    `json:"nickname" gorm:"size:50"`
-	Avatar    string    `json:"avatar" gorm:"size:500"`
-	RoleID    uint      `json:"role_id" gorm:"default:2"` // 角色ID，默认为普通用户
-	Role      *Role     `json:"role,omitempty" gorm:"foreignKey:RoleID"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	Username         string     `json:"username" gorm:"uniqueIndex;not null;size:50"`
+	Email            string     `json:"email" gorm:"uniqueIndex;not null;size:100"`
+	Password         string     `json:"-" gorm:"not null;size:255"` // - 表示不在 JSON 中序列化
+	Nickname         string     `json:"nickname" gorm:"size:50"`
+	Avatar           string     `json:"avatar" gorm:"size:500"`
+	Phone            string     `json:"phone" gorm:"size:20"`     // 手机号，目前仅由微信小程序授权获取
+	RoleID           uint       `json:"role_id" gorm:"default:2"` // 角色ID，默认为普通用户
+	Role             *Role      `json:"role,omitempty" gorm:"foreignKey:RoleID"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled" gorm:"default:false"` // 是否已启用两步验证
+	TwoFactorSecret  string     `json:"-" gorm:"size:64"`                        // TOTP 密钥
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`                       // 邮箱验证时间，为空表示未验证
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 }
 
 // UserCreateRequest 创建用户请求
@@ -24,6 +28,11 @@ type UserCreateRequest struct {
 	Nickname string `json:"nickname" binding:"omitempty,max=50" validate:"omitempty,max=50"`
 }
 
+// UsernameCheckQuery 注册前检查用户名是否可用，规则与 UserCreateRequest 一致
+type UsernameCheckQuery struct {
+	Username string `form:"username" binding:"required,min=3,max=20,alphanum"`
+}
+
 // UserUpdateRequest 更新用户请求
 type UserUpdateRequest struct {
 	RoleID   uint   `json:"role_id" binding:"omitempty,min=1"`
@@ -34,17 +43,50 @@ type UserUpdateRequest struct {
 
 // LoginRequest 登录请求
 type LoginRequest struct {
-	Username  string `json:"username" binding:"required" validate:"required"`
-	Password  string `json:"password" binding:"required" validate:"required"`
-	CaptchaID string `json:"captcha_id" binding:"required" validate:"required"`
-	Captcha   string `json:"captcha" binding:"required,len=6" validate:"required,len=6"`
+	Username string `json:"username" binding:"required" validate:"required"`
+	Password string `json:"password" binding:"required" validate:"required"`
+	// 验证码仅在风险评估要求时必填（响应中 captcha_required 为 true），可先调用 /captcha/required 查询
+	CaptchaID string `json:"captcha_id" binding:"omitempty" validate:"omitempty"`
+	// Captcha 数字验证码为 6 位数字，滑块验证码为 utils.SliderAnswer 的 JSON 字符串
+	Captcha     string `json:"captcha" binding:"omitempty,max=8192" validate:"omitempty,max=8192"`
+	CaptchaType string `json:"captcha_type" binding:"omitempty,oneof=digit slider" validate:"omitempty,oneof=digit slider"` // 为空时为数字验证码
+	// DeviceName 客户端自报的设备名称（如 "iPhone 15"），用于会话列表展示
+	DeviceName string `json:"device_name" binding:"omitempty,max=100" validate:"omitempty,max=100"`
+}
+
+// DeviceInfo 登录设备信息
+type DeviceInfo struct {
+	DeviceName  string
+	IP          string
+	UserAgent   string
+	LoginMethod string // 登录方式，对应安全事件类型，如 login.password、login.wechat
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Token string             `json:"token"`
-	User  UserResponse       `json:"user"`
-	Menus []MenuTreeResponse `json:"menus"` // 用户可访问的菜单
+	Token        string             `json:"token"`         // 访问令牌
+	RefreshToken string             `json:"refresh_token"` // 刷新令牌
+	ExpiresIn    int64              `json:"expires_in"`    // 访问令牌有效秒数
+	User         UserResponse       `json:"user"`
+	Menus        []MenuTreeResponse `json:"menus"` // 用户可访问的菜单
+
+	// 两步验证：需要验证时不签发令牌，客户端凭 ChallengeToken 调用 /login/2fa 完成登录
+	TwoFactorRequired      bool     `json:"two_factor_required,omitempty"`
+	TwoFactorSetupRequired bool     `json:"two_factor_setup_required,omitempty"` // 角色强制两步验证但用户尚未绑定
+	ChallengeToken         string   `json:"challenge_token,omitempty"`
+	RecoveryCodes          []string `json:"recovery_codes,omitempty"` // 登录时完成绑定才会返回
+}
+
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required" validate:"required"`
+}
+
+// TokenResponse 刷新令牌响应
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
 }
 
 // CaptchaResponse 验证码响应
@@ -55,14 +97,17 @@ type CaptchaResponse struct {
 
 // UserResponse 用户响应（不包含敏感信息）
 type UserResponse struct {
-	ID        uint      `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Nickname  string    `json:"nickname"`
-	Avatar    string    `json:"avatar"`
-	RoleID    uint      `json:"role_id"`
-	RoleName  string    `json:"role_name,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               uint      `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	Nickname         string    `json:"nickname"`
+	Avatar           string    `json:"avatar"`
+	Phone            string    `json:"phone,omitempty"`
+	RoleID           uint      `json:"role_id"`
+	RoleName         string    `json:"role_name,omitempty"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	EmailVerified    bool      `json:"email_verified"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 //
@@ -70,16 +115,30 @@ type UserResponse struct {
 // ToResponse 转换为响应格式
 func (u *User) ToResponse() UserResponse {
 	resp := UserResponse{
-		ID:        u.ID,
-		Username:  u.Username,
-		Email:     u.Email,
-		Nickname:  u.Nickname,
-		Avatar:    u.Avatar,
-		RoleID:    u.RoleID,
-		CreatedAt: u.CreatedAt,
+		ID:               u.ID,
+		Username:         u.Username,
+		Email:            u.Email,
+		Nickname:         u.Nickname,
+		Avatar:           u.Avatar,
+		Phone:            u.Phone,
+		RoleID:           u.RoleID,
+		TwoFactorEnabled: u.TwoFactorEnabled,
+		EmailVerified:    u.EmailVerifiedAt != nil,
+		CreatedAt:        u.CreatedAt,
 	}
 	if u.Role != nil {
 		resp.RoleName = u.Role.Name
 	}
 	return resp
 }
+
+// ForgotPasswordRequest 忘记密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email,max=100" validate:"required,email,max=100"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required" validate:"required"`
+	Password string `json:"password" binding:"required,min=6,max=50" validate:"required,min=6,max=50"`
+}
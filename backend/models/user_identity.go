@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// UserIdentity 第三方登录身份（OAuth2/OIDC），同一提供方的同一外部账号只能绑定一个用户
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"not null;size:32;uniqueIndex:idx_identity_provider_subject"`
+	Subject   string    `json:"-" gorm:"not null;size:191;uniqueIndex:idx_identity_provider_subject"` // 提供方的用户唯一标识
+	UnionID   string    `json:"-" gorm:"size:64;index"`                                               // 微信开放平台 UnionID，用于关联同一微信用户在公众号、小程序下的身份
+	Email     string    `json:"email" gorm:"size:100"`
+	Name      string    `json:"name" gorm:"size:100"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OAuthExchangeRequest 使用一次性票据换取登录结果
+type OAuthExchangeRequest struct {
+	Ticket string `json:"ticket" binding:"required" validate:"required"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// WechatRegisterRequest 扫码的微信未绑定账号时，直接注册新账号
+type WechatRegisterRequest struct {
+	SceneID string `json:"scene_id" binding:"required" validate:"required"`
+}
+
+// WechatBindRequest 扫码的微信未绑定账号时，凭账号密码绑定已有账号
+type WechatBindRequest struct {
+	SceneID  string `json:"scene_id" binding:"required" validate:"required"`
+	Username string `json:"username" binding:"required" validate:"required"`
+	Password string `json:"password" binding:"required" validate:"required"`
+}
+
+// WechatEncryptedData 小程序端 wx.getUserProfile / getPhoneNumber 返回的加密数据
+type WechatEncryptedData struct {
+	EncryptedData string `json:"encrypted_data" binding:"required" validate:"required"`
+	IV            string `json:"iv" binding:"required" validate:"required"`
+}
+
+// WechatMiniProgramLoginRequest 微信小程序登录请求
+type WechatMiniProgramLoginRequest struct {
+	Code     string               `json:"code" binding:"required" validate:"required"` // wx.login 获取的 js_code
+	UserInfo *WechatEncryptedData `json:"user_info"`                                   // 可选，加密的用户信息（昵称、头像、UnionID）
+	Phone    *WechatEncryptedData `json:"phone"`                                       // 可选，加密的手机号
+	// DeviceName 客户端自报的设备名称，用于会话列表展示
+	DeviceName string `json:"device_name" binding:"omitempty,max=100" validate:"omitempty,max=100"`
+}
@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"errors"
+	"gin-backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrJobLeaseLost 任务的租约已不属于当前工作协程（已过期并被重新领取，或已被其他协程完成）
+var ErrJobLeaseLost = errors.New("任务租约已失效")
+
+// jobReserveAttempts 领取任务时与其他实例冲突的最多重试次数
+const jobReserveAttempts = 3
+
+// JobRepository 后台任务存储接口。领取任务后的所有修改都要求 job.LockedBy 仍是当前工作协程，
+// 否则返回 ErrJobLeaseLost
+type JobRepository interface {
+	// Create 保存新任务
+	Create(job *models.Job) error
+	// Reserve 领取一个到期的等待任务或租约已过期的运行中任务，没有可领取的任务时返回 nil, nil
+	Reserve(worker string, lease time.Duration) (*models.Job, error)
+	// Extend 延长租约
+	Extend(job *models.Job, lease time.Duration) error
+	// Complete 标记为执行成功
+	Complete(job *models.Job) error
+	// Retry 执行失败，在 runAt 之后重新执行
+	Retry(job *models.Job, runAt time.Time, lastErr string) error
+	// Bury 执行失败且不再重试，转入死信状态
+	Bury(job *models.Job, lastErr string) error
+	// Release 未执行完就归还任务（如服务停止），不计入执行次数
+	Release(job *models.Job) error
+	// Requeue 把死信任务重新放回队列，执行次数清零
+	Requeue(id uint) error
+	FindByID(id uint) (*models.Job, error)
+}
+
+// jobRepository 基于 MySQL 的任务存储
+type jobRepository struct {
+	db *gorm.DB
+}
+
+// NewJobRepository 创建基于 MySQL 的任务仓储实例
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &jobRepository{db: db}
+}
+
+// Create 保存新任务
+func (r *jobRepository) Create(job *models.Job) error {
+	return r.db.Create(job).Error
+}
+
+// Reserve 先查出一个候选任务，再用带原状态的条件更新抢占；
+// 多个实例同时选中同一个任务时只有一个能更新成功，其余重新查询
+func (r *jobRepository) Reserve(worker string, lease time.Duration) (*models.Job, error) {
+	for i := 0; i < jobReserveAttempts; i++ {
+		now := time.Now()
+		var job models.Job
+		err := r.db.Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+			models.JobPending, now, models.JobRunning, now).
+			Order("run_at").
+			First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		until := now.Add(lease)
+		result := r.db.Model(&models.Job{}).
+			Where("id = ? AND status = ? AND attempts = ?", job.ID, job.Status, job.Attempts).
+			Updates(map[string]interface{}{
+				"status":       models.JobRunning,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"locked_by":    worker,
+				"locked_until": until,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status = models.JobRunning
+			job.Attempts++
+			job.LockedBy = worker
+			job.LockedUntil = &until
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+// Extend 延长租约
+func (r *jobRepository) Extend(job *models.Job, lease time.Duration) error {
+	until := time.Now().Add(lease)
+	if err := r.updateOwned(job, map[string]interface{}{"locked_until": until}); err != nil {
+		return err
+	}
+	job.LockedUntil = &until
+	return nil
+}
+
+// Complete 标记为执行成功
+func (r *jobRepository) Complete(job *models.Job) error {
+	now := time.Now()
+	return r.updateOwned(job, map[string]interface{}{
+		"status":       models.JobCompleted,
+		"locked_by":    "",
+		"locked_until": nil,
+		"finished_at":  now,
+	})
+}
+
+// Retry 执行失败，在 runAt 之后重新执行
+func (r *jobRepository) Retry(job *models.Job, runAt time.Time, lastErr string) error {
+	return r.updateOwned(job, map[string]interface{}{
+		"status":       models.JobPending,
+		"run_at":       runAt,
+		"locked_by":    "",
+		"locked_until": nil,
+		"last_error":   lastErr,
+	})
+}
+
+// Bury 执行失败且不再重试，转入死信状态
+func (r *jobRepository) Bury(job *models.Job, lastErr string) error {
+	now := time.Now()
+	return r.updateOwned(job, map[string]interface{}{
+		"status":       models.JobDead,
+		"locked_by":    "",
+		"locked_until": nil,
+		"last_error":   lastErr,
+		"finished_at":  now,
+	})
+}
+
+// Release 未执行完就归还任务，不计入执行次数
+func (r *jobRepository) Release(job *models.Job) error {
+	return r.updateOwned(job, map[string]interface{}{
+		"status":       models.JobPending,
+		"attempts":     gorm.Expr("attempts - 1"),
+		"run_at":       time.Now(),
+		"locked_by":    "",
+		"locked_until": nil,
+	})
+}
+
+// Requeue 把死信任务重新放回队列
+func (r *jobRepository) Requeue(id uint) error {
+	result := r.db.Model(&models.Job{}).
+		Where("id = ? AND status = ?", id, models.JobDead).
+		Updates(map[string]interface{}{
+			"status":      models.JobPending,
+			"attempts":    0,
+			"run_at":      time.Now(),
+			"finished_at": nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("任务不存在或不是死信状态")
+	}
+	return nil
+}
+
+// FindByID 根据ID查找任务
+func (r *jobRepository) FindByID(id uint) (*models.Job, error) {
+	var job models.Job
+	if err := r.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// updateOwned 仅当任务仍由 job.LockedBy 持有时才更新
+func (r *jobRepository) updateOwned(job *models.Job, values map[string]interface{}) error {
+	result := r.db.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, models.JobRunning, job.LockedBy).
+		Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-backend/models"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis 任务存储的键：每个任务一个哈希，按状态放在不同的有序集合中。
+// 领取脚本执行前不知道任务ID，只能在脚本中拼出任务哈希的键，因此所有键带相同的 hash tag {jobs}，
+// Redis Cluster 下落在同一个槽，脚本访问未在 KEYS 中声明的键也不会跨槽
+const (
+	redisJobSeqKey     = "{jobs}:seq"
+	redisJobKeyPrefix  = "{jobs}:job:"
+	redisJobPendingKey = "{jobs}:pending" // 分数为最早执行时间
+	redisJobRunningKey = "{jobs}:running" // 分数为租约到期时间
+	redisJobDeadKey    = "{jobs}:dead"    // 分数为转入死信的时间
+
+	// redisJobCompletedTTL 执行成功的任务保留时间
+	redisJobCompletedTTL = 7 * 24 * time.Hour
+)
+
+// redisReserveScript 取出最早到期的等待任务，没有时取租约已过期的运行中任务，原子地改为运行中。
+// 任务哈希的键由 ARGV[4] 前缀和任务ID拼出，与 KEYS 同在 {jobs} 槽
+var redisReserveScript = redis.NewScript(`
+local id = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)[1]
+local from = KEYS[1]
+if not id then
+	id = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', '(' .. ARGV[1], 'LIMIT', 0, 1)[1]
+	from = KEYS[2]
+end
+if not id then
+	return false
+end
+redis.call('ZREM', from, id)
+redis.call('ZADD', KEYS[2], ARGV[2], id)
+local key = ARGV[4] .. id
+redis.call('HSET', key, 'status', 'running', 'locked_by', ARGV[3], 'locked_until', ARGV[2], 'updated_at', ARGV[1])
+redis.call('HINCRBY', key, 'attempts', 1)
+return redis.call('HGETALL', key)
+`)
+
+// redisOwnedUpdateScript 仅当任务仍由 ARGV[1] 持有时，把任务从运行中集合移到 KEYS[3]（为空则不放入任何集合）并更新字段。
+// ARGV: worker, id, 新集合中的分数, 执行次数增量, 哈希过期秒数（0 不过期）, 字段/值...
+var redisOwnedUpdateScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'status') ~= 'running' or redis.call('HGET', KEYS[1], 'locked_by') ~= ARGV[1] then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[2])
+if KEYS[3] ~= '' then
+	redis.call('ZADD', KEYS[3], ARGV[3], ARGV[2])
+end
+if tonumber(ARGV[4]) ~= 0 then
+	redis.call('HINCRBY', KEYS[1], 'attempts', ARGV[4])
+end
+redis.call('HSET', KEYS[1], unpack(ARGV, 6))
+if tonumber(ARGV[5]) > 0 then
+	redis.call('EXPIRE', KEYS[1], ARGV[5])
+end
+return 1
+`)
+
+// redisRequeueScript 把死信任务放回等待集合
+var redisRequeueScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'status') ~= 'dead' then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('ZADD', KEYS[3], ARGV[2], ARGV[1])
+redis.call('HSET', KEYS[1], 'status', 'pending', 'attempts', 0, 'run_at', ARGV[2], 'finished_at', '', 'updated_at', ARGV[2])
+return 1
+`)
+
+// redisJobRepository 基于 Redis 的任务存储
+type redisJobRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisJobRepository 创建基于 Redis 的任务仓储实例，Redis 需开启持久化才能在重启后保留任务
+func NewRedisJobRepository(client *redis.Client) JobRepository {
+	return &redisJobRepository{client: client, ctx: context.Background()}
+}
+
+func redisJobKey(id uint) string {
+	return redisJobKeyPrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+// Create 保存新任务
+func (r *redisJobRepository) Create(job *models.Job) error {
+	id, err := r.client.Incr(r.ctx, redisJobSeqKey).Result()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	job.ID = uint(id)
+	job.CreatedAt, job.UpdatedAt = now, now
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+
+	_, err = r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(r.ctx, redisJobKey(job.ID), encodeRedisJob(job))
+		pipe.ZAdd(r.ctx, redisJobPendingKey, redis.Z{Score: float64(job.RunAt.UnixMilli()), Member: job.ID})
+		return nil
+	})
+	return err
+}
+
+// Reserve 领取一个到期的任务
+func (r *redisJobRepository) Reserve(worker string, lease time.Duration) (*models.Job, error) {
+	now := time.Now()
+	fields, err := redisReserveScript.Run(r.ctx, r.client,
+		[]string{redisJobPendingKey, redisJobRunningKey},
+		now.UnixMilli(), now.Add(lease).UnixMilli(), worker, redisJobKeyPrefix,
+	).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		values[fields[i]] = fields[i+1]
+	}
+	return decodeRedisJob(values), nil
+}
+
+// Extend 延长租约
+func (r *redisJobRepository) Extend(job *models.Job, lease time.Duration) error {
+	until := time.Now().Add(lease)
+	if err := r.updateOwned(job, redisJobRunningKey, until, 0, 0, "locked_until", until.UnixMilli()); err != nil {
+		return err
+	}
+	job.LockedUntil = &until
+	return nil
+}
+
+// Complete 标记为执行成功，任务在保留期后自动删除
+func (r *redisJobRepository) Complete(job *models.Job) error {
+	now := time.Now()
+	return r.updateOwned(job, "", now, 0, redisJobCompletedTTL,
+		"status", models.JobCompleted, "locked_by", "", "locked_until", "", "finished_at", now.UnixMilli())
+}
+
+// Retry 执行失败，在 runAt 之后重新执行
+func (r *redisJobRepository) Retry(job *models.Job, runAt time.Time, lastErr string) error {
+	return r.updateOwned(job, redisJobPendingKey, runAt, 0, 0,
+		"status", models.JobPending, "run_at", runAt.UnixMilli(), "locked_by", "", "locked_until", "", "last_error", lastErr)
+}
+
+// Bury 执行失败且不再重试，转入死信状态
+func (r *redisJobRepository) Bury(job *models.Job, lastErr string) error {
+	now := time.Now()
+	return r.updateOwned(job, redisJobDeadKey, now, 0, 0,
+		"status", models.JobDead, "locked_by", "", "locked_until", "", "last_error", lastErr, "finished_at", now.UnixMilli())
+}
+
+// Release 未执行完就归还任务，不计入执行次数
+func (r *redisJobRepository) Release(job *models.Job) error {
+	now := time.Now()
+	return r.updateOwned(job, redisJobPendingKey, now, -1, 0,
+		"status", models.JobPending, "run_at", now.UnixMilli(), "locked_by", "", "locked_until", "")
+}
+
+// Requeue 把死信任务重新放回队列
+func (r *redisJobRepository) Requeue(id uint) error {
+	ok, err := redisRequeueScript.Run(r.ctx, r.client,
+		[]string{redisJobKey(id), redisJobDeadKey, redisJobPendingKey},
+		id, time.Now().UnixMilli(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return errors.New("任务不存在或不是死信状态")
+	}
+	return nil
+}
+
+// FindByID 根据ID查找任务
+func (r *redisJobRepository) FindByID(id uint) (*models.Job, error) {
+	values, err := r.client.HGetAll(r.ctx, redisJobKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("任务不存在")
+	}
+	return decodeRedisJob(values), nil
+}
+
+// updateOwned 仅当任务仍由 job.LockedBy 持有时，把任务移到 target 集合并更新字段
+func (r *redisJobRepository) updateOwned(job *models.Job, target string, score time.Time, attemptsDelta int, ttl time.Duration, fields ...interface{}) error {
+	args := []interface{}{job.LockedBy, job.ID, score.UnixMilli(), attemptsDelta, int64(ttl.Seconds()), "updated_at", time.Now().UnixMilli()}
+	ok, err := redisOwnedUpdateScript.Run(r.ctx, r.client,
+		[]string{redisJobKey(job.ID), redisJobRunningKey, target},
+		append(args, fields...)...,
+	).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
+
+// encodeRedisJob 时间字段保存为毫秒时间戳，空指针保存为空字符串
+func encodeRedisJob(job *models.Job) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           job.ID,
+		"type":         job.Type,
+		"payload":      job.Payload,
+		"status":       job.Status,
+		"attempts":     job.Attempts,
+		"max_attempts": job.MaxAttempts,
+		"run_at":       job.RunAt.UnixMilli(),
+		"locked_by":    job.LockedBy,
+		"locked_until": encodeRedisTime(job.LockedUntil),
+		"last_error":   job.LastError,
+		"finished_at":  encodeRedisTime(job.FinishedAt),
+		"created_at":   job.CreatedAt.UnixMilli(),
+		"updated_at":   job.UpdatedAt.UnixMilli(),
+	}
+}
+
+func decodeRedisJob(values map[string]string) *models.Job {
+	id, _ := strconv.ParseUint(values["id"], 10, 64)
+	attempts, _ := strconv.Atoi(values["attempts"])
+	maxAttempts, _ := strconv.Atoi(values["max_attempts"])
+	return &models.Job{
+		ID:          uint(id),
+		Type:        values["type"],
+		Payload:     values["payload"],
+		Status:      values["status"],
+		Attempts:    attempts,
+		MaxAttempts: maxAttempts,
+		RunAt:       decodeRedisMillis(values["run_at"]),
+		LockedBy:    values["locked_by"],
+		LockedUntil: decodeRedisTime(values["locked_until"]),
+		LastError:   values["last_error"],
+		FinishedAt:  decodeRedisTime(values["finished_at"]),
+		CreatedAt:   decodeRedisMillis(values["created_at"]),
+		UpdatedAt:   decodeRedisMillis(values["updated_at"]),
+	}
+}
+
+func encodeRedisTime(t *time.Time) interface{} {
+	if t == nil {
+		return ""
+	}
+	return t.UnixMilli()
+}
+
+func decodeRedisTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t := decodeRedisMillis(value)
+	return &t
+}
+
+func decodeRedisMillis(value string) time.Time {
+	millis, _ := strconv.ParseInt(value, 10, 64)
+	return time.UnixMilli(millis)
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gin-backend/config"
+	"gin-backend/models"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// testJobRepository 两种存储共用的领取和租约测试，调用前队列应为空
+func testJobRepository(t *testing.T, repo JobRepository) {
+	job, err := repo.Reserve("worker-1", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	due := &models.Job{Type: "test", Status: models.JobPending, MaxAttempts: 3, RunAt: time.Now().Add(-time.Second)}
+	later := &models.Job{Type: "test", Status: models.JobPending, MaxAttempts: 3, RunAt: time.Now().Add(time.Hour)}
+	assert.NoError(t, repo.Create(due))
+	assert.NoError(t, repo.Create(later))
+
+	// 只能领取到期的任务，同一个任务不会被两个工作协程同时领取
+	job, err = repo.Reserve("worker-1", time.Minute)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, job) {
+		return
+	}
+	assert.Equal(t, due.ID, job.ID)
+	assert.Equal(t, models.JobRunning, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, "worker-1", job.LockedBy)
+	other, err := repo.Reserve("worker-2", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, other)
+
+	// 未持有租约的工作协程不能修改任务
+	stolen := *job
+	stolen.LockedBy = "worker-2"
+	assert.ErrorIs(t, repo.Extend(&stolen, time.Minute), ErrJobLeaseLost)
+	assert.ErrorIs(t, repo.Complete(&stolen), ErrJobLeaseLost)
+
+	// 归还不计入执行次数
+	assert.NoError(t, repo.Release(job))
+	job, err = repo.Reserve("worker-2", 10*time.Millisecond)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, job) {
+		return
+	}
+	assert.Equal(t, due.ID, job.ID)
+	assert.Equal(t, 1, job.Attempts)
+
+	// 租约过期后由其他工作协程重新领取，原持有者的修改失效
+	time.Sleep(50 * time.Millisecond)
+	expired := job
+	job, err = repo.Reserve("worker-3", time.Minute)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, job) {
+		return
+	}
+	assert.Equal(t, due.ID, job.ID)
+	assert.Equal(t, 2, job.Attempts)
+	assert.ErrorIs(t, repo.Complete(expired), ErrJobLeaseLost)
+	assert.NoError(t, repo.Complete(job))
+	stored, err := repo.FindByID(due.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobCompleted, stored.Status)
+	assert.NotNil(t, stored.FinishedAt)
+
+	// 已完成的任务不再由原持有者修改
+	assert.ErrorIs(t, repo.Retry(&models.Job{ID: due.ID, LockedBy: "worker-3"}, time.Now(), "late"), ErrJobLeaseLost)
+
+	// 死信任务重新放回队列后执行次数清零，只能放回一次
+	dead := &models.Job{Type: "test", Status: models.JobPending, MaxAttempts: 1, RunAt: time.Now().Add(-time.Second)}
+	assert.NoError(t, repo.Create(dead))
+	job, err = repo.Reserve("worker-1", time.Minute)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, job) {
+		return
+	}
+	assert.Equal(t, dead.ID, job.ID)
+	assert.NoError(t, repo.Bury(job, "boom"))
+	assert.NoError(t, repo.Requeue(dead.ID))
+	assert.Error(t, repo.Requeue(dead.ID))
+	job, err = repo.Reserve("worker-1", time.Minute)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, job) {
+		return
+	}
+	assert.Equal(t, dead.ID, job.ID)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestJobRepository(t *testing.T) {
+	config.LoadConfig()
+	if err := config.InitDB(); err != nil {
+		t.Skipf("mysql not available: %v", err)
+	}
+	defer config.CloseDB()
+	assert.NoError(t, config.DB.AutoMigrate(&models.Job{}))
+
+	// 在事务中执行并回滚，不影响库中已有的任务
+	tx := config.DB.Begin()
+	defer tx.Rollback()
+	assert.NoError(t, tx.Where("1 = 1").Delete(&models.Job{}).Error)
+	testJobRepository(t, NewJobRepository(tx))
+}
+
+func TestRedisJobRepository(t *testing.T) {
+	// 使用 15 号库，避免与正在运行的服务共用任务队列
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 15, MaxRetries: -1, DialerRetries: 1})
+	defer client.Close()
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+	cleanup := func() {
+		keys, _ := client.Keys(ctx, "{jobs}:*").Result()
+		if len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	testJobRepository(t, NewRedisJobRepository(client))
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"gin-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// LoginAttemptRepository 登录尝试记录数据访问接口
+type LoginAttemptRepository interface {
+	Create(attempt *models.LoginAttempt) error
+	FindWithPage(query *models.LoginAttemptQuery) ([]models.LoginAttempt, int64, error)
+}
+
+// loginAttemptRepository 登录尝试记录数据访问实现
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginAttemptRepository 创建登录尝试记录仓储实例
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+// Create 保存登录尝试记录
+func (r *loginAttemptRepository) Create(attempt *models.LoginAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// FindWithPage 分页查询登录尝试记录，按时间倒序
+func (r *loginAttemptRepository) FindWithPage(query *models.LoginAttemptQuery) ([]models.LoginAttempt, int64, error) {
+	var attempts []models.LoginAttempt
+	var total int64
+
+	db := r.db.Model(&models.LoginAttempt{})
+	if query.Username != "" {
+		db = db.Where("username = ?", query.Username)
+	}
+	if query.IP != "" {
+		db = db.Where("ip = ?", query.IP)
+	}
+	if query.Success != nil {
+		db = db.Where("success = ?", *query.Success)
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.Offset(query.GetOffset()).
+		Limit(query.GetPageSize()).
+		Order("created_at DESC").
+		Find(&attempts).Error
+	return attempts, total, err
+}
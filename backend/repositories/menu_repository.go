@@ -14,6 +14,7 @@ type MenuRepository interface {
 	FindByID(id uint) (*models.Menu, error)
 	FindAll() ([]models.Menu, error)
 	FindByRoleID(roleID uint) ([]models.Menu, error)
+	FindPermissionsByRoleID(roleID uint) ([]string, error)
 	BuildMenuTree(menus []models.Menu) []models.MenuTreeResponse
 }
 
@@ -67,6 +68,17 @@ func (r *menuRepository) FindByRoleID(roleID uint) ([]models.Menu, error) {
 	return menus, err
 }
 
+// FindPermissionsByRoleID 查找角色拥有的权限标识（包含菜单和按钮）
+func (r *menuRepository) FindPermissionsByRoleID(roleID uint) ([]string, error) {
+	var permissions []string
+	err := r.db.Table("menus").
+		Joins("INNER JOIN role_menus ON menus.id = role_menus.menu_id").
+		Where("role_menus.role_id = ? AND menus.status = ? AND menus.permission <> ''", roleID, 1).
+		Distinct().
+		Pluck("menus.permission", &permissions).Error
+	return permissions, err
+}
+
 // BuildMenuTree 构建菜单树
 func (r *menuRepository) BuildMenuTree(menus []models.Menu) []models.MenuTreeResponse {
 	menuMap := make(map[uint]*models.MenuTreeResponse)
@@ -75,16 +87,17 @@ func (r *menuRepository) BuildMenuTree(menus []models.Menu) []models.MenuTreeRes
 	// 第一遍：创建所有菜单节点
 	for _, menu := range menus {
 		menuMap[menu.ID] = &models.MenuTreeResponse{
-			ID:        menu.ID,
-			ParentID:  menu.ParentID,
-			Name:      menu.Name,
-			Path:      menu.Path,
-			Component: menu.Component,
-			Icon:      menu.Icon,
-			Sort:      menu.Sort,
-			Type:      menu.Type,
-			Hidden:    menu.Hidden,
-			Children:  []models.MenuTreeResponse{},
+			ID:         menu.ID,
+			ParentID:   menu.ParentID,
+			Name:       menu.Name,
+			Path:       menu.Path,
+			Component:  menu.Component,
+			Icon:       menu.Icon,
+			Sort:       menu.Sort,
+			Type:       menu.Type,
+			Permission: menu.Permission,
+			Hidden:     menu.Hidden,
+			Children:   []models.MenuTreeResponse{},
 		}
 	}
 
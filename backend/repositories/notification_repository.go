@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"gin-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationRepository 通知偏好和投递记录数据访问接口
+type NotificationRepository interface {
+	FindOptOuts(userID uint) ([]string, error)
+	SetOptOut(userID uint, event string, optOut bool) error
+	CreateLog(log *models.NotificationLog) error
+	UpdateLog(log *models.NotificationLog) error
+	FindLogsWithPage(query *models.NotificationLogQuery) ([]models.NotificationLog, int64, error)
+}
+
+// notificationRepository 通知偏好和投递记录数据访问实现
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepository 创建通知仓储实例
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// FindOptOuts 获取用户关闭的通知事件
+func (r *notificationRepository) FindOptOuts(userID uint) ([]string, error) {
+	var events []string
+	err := r.db.Model(&models.NotificationOptOut{}).Where("user_id = ?", userID).Pluck("event", &events).Error
+	return events, err
+}
+
+// SetOptOut 关闭或重新开启某个通知事件
+func (r *notificationRepository) SetOptOut(userID uint, event string, optOut bool) error {
+	if !optOut {
+		return r.db.Where("user_id = ? AND event = ?", userID, event).Delete(&models.NotificationOptOut{}).Error
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.NotificationOptOut{UserID: userID, Event: event}).Error
+}
+
+// CreateLog 保存投递记录
+func (r *notificationRepository) CreateLog(log *models.NotificationLog) error {
+	return r.db.Create(log).Error
+}
+
+// UpdateLog 更新投递记录
+func (r *notificationRepository) UpdateLog(log *models.NotificationLog) error {
+	return r.db.Save(log).Error
+}
+
+// FindLogsWithPage 分页查询投递记录，按时间倒序
+func (r *notificationRepository) FindLogsWithPage(query *models.NotificationLogQuery) ([]models.NotificationLog, int64, error) {
+	var logs []models.NotificationLog
+	var total int64
+
+	db := r.db.Model(&models.NotificationLog{})
+	if query.UserID > 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Event != "" {
+		db = db.Where("event = ?", query.Event)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.Offset(query.GetOffset()).
+		Limit(query.GetPageSize()).
+		Order("created_at DESC").
+		Find(&logs).Error
+	return logs, total, err
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"gin-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// SecurityEventRepository 安全事件数据访问接口
+type SecurityEventRepository interface {
+	Create(event *models.SecurityEvent) error
+	FindWithPage(query *models.SecurityEventQuery) ([]models.SecurityEvent, int64, error)
+}
+
+// securityEventRepository 安全事件数据访问实现
+type securityEventRepository struct {
+	db *gorm.DB
+}
+
+// NewSecurityEventRepository 创建安全事件仓储实例
+func NewSecurityEventRepository(db *gorm.DB) SecurityEventRepository {
+	return &securityEventRepository{db: db}
+}
+
+// Create 保存安全事件
+func (r *securityEventRepository) Create(event *models.SecurityEvent) error {
+	return r.db.Create(event).Error
+}
+
+// FindWithPage 分页查询安全事件，按时间倒序
+func (r *securityEventRepository) FindWithPage(query *models.SecurityEventQuery) ([]models.SecurityEvent, int64, error) {
+	var events []models.SecurityEvent
+	var total int64
+
+	db := r.db.Model(&models.SecurityEvent{})
+	if query.UserID > 0 {
+		// 既包括与该用户相关的事件，也包括该用户作为操作人执行的操作
+		db = db.Where("user_id = ? OR operator_id = ?", query.UserID, query.UserID)
+	}
+	if query.Type != "" {
+		db = db.Where("type = ?", query.Type)
+	}
+	if !query.StartTime.IsZero() {
+		db = db.Where("created_at >= ?", query.StartTime)
+	}
+	if !query.EndTime.IsZero() {
+		db = db.Where("created_at <= ?", query.EndTime)
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.Offset(query.GetOffset()).
+		Limit(query.GetPageSize()).
+		Order("created_at DESC").
+		Find(&events).Error
+	return events, total, err
+}
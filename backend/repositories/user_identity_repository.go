@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"gin-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentityRepository 第三方登录身份数据访问接口
+type UserIdentityRepository interface {
+	FindByProviderSubject(provider, subject string) (*models.UserIdentity, error)
+	FindByUserID(userID uint) ([]models.UserIdentity, error)
+	FindByUnionID(unionID string) (*models.UserIdentity, error)
+	Create(identity *models.UserIdentity) error
+	Update(identity *models.UserIdentity) error
+	Delete(userID uint, provider string) (bool, error)
+}
+
+// userIdentityRepository 第三方登录身份数据访问实现
+type userIdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewUserIdentityRepository 创建第三方登录身份仓储实例
+func NewUserIdentityRepository(db *gorm.DB) UserIdentityRepository {
+	return &userIdentityRepository{db: db}
+}
+
+// FindByProviderSubject 根据提供方和外部用户标识查找身份，不存在时返回 nil
+func (r *userIdentityRepository) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil // 不存在返回 nil 而不是错误
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// FindByUserID 获取用户绑定的所有第三方身份
+func (r *userIdentityRepository) FindByUserID(userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// FindByUnionID 根据微信 UnionID 查找任一已绑定的身份，不存在时返回 nil
+func (r *userIdentityRepository) FindByUnionID(unionID string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.Where("union_id = ?", unionID).Order("id").First(&identity).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// Create 保存第三方身份
+func (r *userIdentityRepository) Create(identity *models.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// Update 更新第三方身份
+func (r *userIdentityRepository) Update(identity *models.UserIdentity) error {
+	return r.db.Save(identity).Error
+}
+
+// Delete 解除用户与指定提供方的绑定，返回是否删除了记录
+func (r *userIdentityRepository) Delete(userID uint, provider string) (bool, error) {
+	result := r.db.Where("user_id = ? AND provider = ?", userID, provider).Delete(&models.UserIdentity{})
+	return result.RowsAffected > 0, result.Error
+}
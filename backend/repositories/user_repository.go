@@ -1,7 +1,6 @@
 package repositories
 
 import (
-	"errors"
 	"gin-backend/models"
 
 	"gorm.io/gorm"
@@ -10,10 +9,13 @@ import (
 // UserRepository 用户数据访问接口
 type UserRepository interface {
 	FindAll() ([]models.User, error)
+	FindAllIDs() ([]uint, error)
+	FindAllUsernamesAndEmails() ([]models.User, error)
 	FindByID(id uint) (*models.User, error)
 	FindByUsername(username string) (*models.User, error)
 	FindByEmail(email string) (*models.User, error)
 	Create(user *models.User) error
+	CreateWithIdentity(user *models.User, identity *models.UserIdentity) error
 	Update(user *models.User) error
 	Delete(id uint) error
 	CountByRoleID(roleID uint) (int64, error)
@@ -38,6 +40,24 @@ func (r *userRepository) FindAll() ([]models.User, error) {
 	return users, nil
 }
 
+// FindAllIDs 获取所有用户ID
+func (r *userRepository) FindAllIDs() ([]uint, error) {
+	var ids []uint
+	if err := r.db.Model(&models.User{}).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// FindAllUsernamesAndEmails 获取所有用户的用户名和邮箱，只查询这两列
+func (r *userRepository) FindAllUsernamesAndEmails() ([]models.User, error) {
+	var users []models.User
+	if err := r.db.Select("username", "email").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // FindByID 根据ID查找用户
 func (r *userRepository) FindByID(id uint) (*models.User, error) {
 	var user models.User
@@ -76,15 +96,21 @@ func (r *userRepository) FindByEmail(email string) (*models.User, error) {
 
 // Create 创建用户
 func (r *userRepository) Create(user *models.User) error {
-
-	_, err := r.FindByUsername(user.Username)
-	if err != nil {
-		return errors.New("用户名已存在")
-	}
-
+	// 用户名、邮箱是否重复由服务层检查，并发注册时由唯一索引兜底
 	return r.db.Create(user).Error
 }
 
+// CreateWithIdentity 在同一事务中创建用户和绑定的第三方身份，任一失败都不会留下记录
+func (r *userRepository) CreateWithIdentity(user *models.User, identity *models.UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
 // Update 更新用户
 func (r *userRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
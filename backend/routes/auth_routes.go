@@ -8,26 +8,68 @@ import (
 )
 
 // SetupAuthRoutes 设置认证相关路由
-func SetupAuthRoutes(api *gin.RouterGroup, userController *controllers.UserController, captchaController *controllers.CaptchaController) {
+func SetupAuthRoutes(api *gin.RouterGroup, userController *controllers.UserController, captchaController *controllers.CaptchaController, twoFactorController *controllers.TwoFactorController, accountController *controllers.AccountController, oauthController *controllers.OAuthController) {
 	// 验证码接口（不需要认证）
 	api.GET("/captcha", captchaController.GetCaptcha)            // 获取/刷新验证码
 	api.POST("/captcha/verify", captchaController.VerifyCaptcha) // 验证验证码（测试用）
+	api.GET("/captcha/required", userController.CaptchaRequired) // 查询是否需要验证码
 
 	// 认证接口（不需要认证）
-	api.POST("/register", userController.Register) // 用户注册
-	api.POST("/login", userController.Login)       // 用户登录
-	api.POST("/logout", userController.ExitLogin)  // 用户退出登录
+	api.POST("/register", userController.Register)           // 用户注册
+	api.GET("/register/check", userController.CheckUsername) // 检查用户名是否可用
+	api.POST("/login", userController.Login)                 // 用户登录
+	api.POST("/logout", userController.ExitLogin)            // 用户退出登录
+
+	// 两步验证登录（凭密码登录返回的挑战令牌）
+	api.POST("/login/2fa", twoFactorController.LoginVerify)      // 提交验证码完成登录
+	api.POST("/login/2fa/setup", twoFactorController.LoginSetup) // 角色强制启用时先绑定
+
+	// 找回密码与邮箱验证
+	api.POST("/password/forgot", accountController.ForgotPassword) // 发送重置密码邮件
+	api.POST("/password/reset", accountController.ResetPassword)   // 重置密码
+	api.GET("/email/verify", accountController.VerifyEmail)        // 验证邮箱
+
+	// 刷新令牌（访问令牌可能已过期，因此不经过认证中间件）
+	api.POST("/auth/refresh", userController.RefreshToken)
 
 	// 微信扫码登录
-	api.GET("/auth/wechat/qrcode", userController.GetWeChatQRCode)   // 获取微信登录二维码
-	api.GET("/auth/wechat/status", userController.CheckWeChatStatus) // 检查微信登录状态
-	api.GET("/auth/wechat/mock", userController.MockWeChatScan)      // 模拟微信扫码成功（开发用）
-	api.Any("/auth/wechat/callback", userController.WechatCallback)  // 微信服务端回调
+	api.GET("/auth/wechat/qrcode", userController.GetWeChatQRCode)              // 获取微信登录二维码
+	api.GET("/auth/wechat/status", userController.CheckWeChatStatus)            // 检查微信登录状态
+	api.GET("/auth/wechat/events", userController.WechatEvents)                 // 推送微信登录状态（SSE）
+	api.POST("/auth/wechat/register", userController.WechatRegister)            // 未绑定的微信注册新账号
+	api.POST("/auth/wechat/bind", userController.WechatBind)                    // 未绑定的微信绑定已有账号
+	api.POST("/auth/wechat/miniprogram", userController.WechatMiniProgramLogin) // 微信小程序登录
+	api.Any("/auth/wechat/callback", userController.WechatCallback)             // 微信服务端回调
+	if gin.Mode() == gin.DebugMode {
+		// 模拟扫码可以不经微信直接登录任意账号，只在 debug 模式注册
+		api.GET("/auth/wechat/mock", userController.MockWeChatScan)
+	}
+
+	// 第三方登录（OAuth2/OIDC）
+	api.GET("/auth/oauth/providers", oauthController.Providers)         // 已启用的提供方
+	api.GET("/auth/oauth/:provider/login", oauthController.Login)       // 跳转到授权页面
+	api.GET("/auth/oauth/:provider/callback", oauthController.Callback) // 授权回调
+	api.POST("/auth/oauth/exchange", oauthController.Exchange)          // 凭票据换取登录结果
 
 	// 需要认证的路由
 	auth := api.Group("/auth")
 	auth.Use(middlewares.AuthMiddleware())
 	{
-		auth.GET("/profile", userController.GetProfile) // 获取当前用户信息
+		auth.GET("/profile", userController.GetProfile)            // 获取当前用户信息
+		auth.GET("/sessions", userController.GetSessions)          // 获取我的登录会话
+		auth.DELETE("/sessions/:id", userController.RevokeSession) // 注销指定会话
+
+		auth.POST("/email/verify", accountController.ResendVerification) // 重新发送验证邮件
+
+		// 第三方账号绑定
+		auth.POST("/oauth/:provider/link", oauthController.Link)     // 获取绑定授权地址
+		auth.GET("/identities", oauthController.GetIdentities)       // 已绑定的第三方账号
+		auth.DELETE("/identities/:provider", oauthController.Unlink) // 解绑
+
+		// 两步验证管理
+		auth.POST("/2fa/setup", twoFactorController.Setup)                            // 获取绑定信息
+		auth.POST("/2fa/confirm", twoFactorController.Confirm)                        // 确认绑定
+		auth.POST("/2fa/disable", twoFactorController.Disable)                        // 关闭两步验证
+		auth.POST("/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes) // 重新生成恢复码
 	}
 }
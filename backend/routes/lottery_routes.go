@@ -3,6 +3,7 @@ package routes
 import (
 	"gin-backend/controllers"
 	"gin-backend/middlewares"
+	"gin-backend/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,12 +20,12 @@ func SetupLotteryRoutes(r *gin.RouterGroup, controller *controllers.LotteryContr
 		lotteryGroup.GET("/records/public", controller.GetPublicRecords)
 
 		// 管理后台接口
-		lotteryGroup.GET("/admin/activities", controllers.AdminGetActivities)
-		lotteryGroup.POST("/admin/activities", controllers.AdminSaveConfig)
-		lotteryGroup.PUT("/admin/activities/:id/status", controllers.AdminToggleStatus)
-		lotteryGroup.DELETE("/admin/activities/:id", controllers.AdminDeleteActivity)
-		
+		lotteryGroup.GET("/admin/activities", middlewares.RequirePermission(models.PermLotteryList), controllers.AdminGetActivities)
+		lotteryGroup.POST("/admin/activities", middlewares.RequirePermission(models.PermLotteryEdit), controllers.AdminSaveConfig)
+		lotteryGroup.PUT("/admin/activities/:id/status", middlewares.RequirePermission(models.PermLotteryEdit), controllers.AdminToggleStatus)
+		lotteryGroup.DELETE("/admin/activities/:id", middlewares.RequirePermission(models.PermLotteryDelete), controllers.AdminDeleteActivity)
+
 		// 抽奖统计流水
-		lotteryGroup.GET("/admin/records", controllers.AdminGetRecords)
+		lotteryGroup.GET("/admin/records", middlewares.RequirePermission(models.PermLotteryList), controllers.AdminGetRecords)
 	}
 }
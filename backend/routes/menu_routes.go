@@ -3,6 +3,7 @@ package routes
 import (
 	"gin-backend/controllers"
 	"gin-backend/middlewares"
+	"gin-backend/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,13 +14,13 @@ func SetupMenuRoutes(api *gin.RouterGroup, menuController *controllers.MenuContr
 	{
 		// 公开路由（需要认证）
 		menus.Use(middlewares.AuthMiddleware())
-		menus.GET("/user", menuController.GetUserMenus) // 获取当前用户菜单
-		menus.GET("/tree", menuController.GetMenuTree)  // 获取菜单树
+		menus.GET("/user", menuController.GetUserMenus)                                                    // 获取当前用户菜单
+		menus.GET("/tree", middlewares.RequirePermission(models.PermMenuList), menuController.GetMenuTree) // 获取菜单树
 
-		// 管理路由（需要管理员权限）
-		menus.POST("", menuController.CreateMenu)       // 创建菜单
-		menus.PUT("/:id", menuController.UpdateMenu)    // 更新菜单
-		menus.DELETE("/:id", menuController.DeleteMenu) // 删除菜单
+		// 管理路由（需要对应的按钮权限）
+		menus.POST("", middlewares.RequirePermission(models.PermMenuCreate), menuController.CreateMenu)       // 创建菜单
+		menus.PUT("/:id", middlewares.RequirePermission(models.PermMenuUpdate), menuController.UpdateMenu)    // 更新菜单
+		menus.DELETE("/:id", middlewares.RequirePermission(models.PermMenuDelete), menuController.DeleteMenu) // 删除菜单
 	}
 }
 
@@ -30,11 +31,11 @@ func SetupRoleRoutes(api *gin.RouterGroup, roleController *controllers.RoleContr
 		// 所有路由都需要认证
 		roles.Use(middlewares.AuthMiddleware())
 
-		roles.GET("", roleController.GetAllRoles)            // 获取所有角色
-		roles.GET("/:id", roleController.GetRoleByID)        // 根据ID获取角色
-		roles.POST("", roleController.CreateRole)            // 创建角色
-		roles.PUT("/:id", roleController.UpdateRole)         // 更新角色
-		roles.DELETE("/:id", roleController.DeleteRole)      // 删除角色
-		roles.POST("/:id/menus", roleController.AssignMenus) // 为角色分配菜单
+		roles.GET("", middlewares.RequirePermission(models.PermRoleList), roleController.GetAllRoles)              // 获取所有角色
+		roles.GET("/:id", middlewares.RequirePermission(models.PermRoleList), roleController.GetRoleByID)          // 根据ID获取角色
+		roles.POST("", middlewares.RequirePermission(models.PermRoleCreate), roleController.CreateRole)            // 创建角色
+		roles.PUT("/:id", middlewares.RequirePermission(models.PermRoleUpdate), roleController.UpdateRole)         // 更新角色
+		roles.DELETE("/:id", middlewares.RequirePermission(models.PermRoleDelete), roleController.DeleteRole)      // 删除角色
+		roles.POST("/:id/menus", middlewares.RequirePermission(models.PermRoleAssign), roleController.AssignMenus) // 为角色分配菜单
 	}
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"gin-backend/controllers"
+	"gin-backend/middlewares"
+	"gin-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupNotificationRoutes 设置微信通知相关路由
+func SetupNotificationRoutes(api *gin.RouterGroup, notificationController *controllers.NotificationController) {
+	notifications := api.Group("/notifications")
+	notifications.Use(middlewares.AuthMiddleware())
+	{
+		notifications.GET("/settings", notificationController.GetSettings)                                                     // 我的通知设置
+		notifications.PUT("/settings", notificationController.UpdateSetting)                                                   // 修改通知设置
+		notifications.GET("/logs", middlewares.RequirePermission(models.PermNotificationLogs), notificationController.GetLogs) // 投递记录
+	}
+}
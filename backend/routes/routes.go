@@ -1,10 +1,13 @@
 package routes
 
 import (
+	"gin-backend/config"
 	"gin-backend/controllers"
 	"gin-backend/middlewares"
 	"gin-backend/repositories"
 	"gin-backend/services"
+	"gin-backend/utils"
+	"log"
 
 	_ "gin-backend/docs"
 
@@ -14,8 +17,19 @@ import (
 	"gorm.io/gorm"
 )
 
-// SetupRouter 设置路由
-func SetupRouter(db *gorm.DB) *gin.Engine {
+// shutdownHooks SetupRouter 注册的停止前清理函数，由 Shutdown 执行
+var shutdownHooks []func()
+
+// Shutdown 停止服务前保存需要持久化的状态，如内存注册过滤器的快照
+func Shutdown() {
+	for _, hook := range shutdownHooks {
+		hook()
+	}
+}
+
+// SetupRouter 设置路由，并把各模块的后台任务处理函数注册到 jobQueue
+func SetupRouter(db *gorm.DB, jobQueue services.JobQueue) *gin.Engine {
+	shutdownHooks = nil
 	r := gin.Default()
 
 	// 禁用自动重定向，防止 301 问题
@@ -36,18 +50,64 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	roleRepo := repositories.NewRoleRepository(db)
 	fileRepo := repositories.NewFileRepository(db)
 	lotteryRepo := repositories.NewLotteryRepository(db)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(db)
+	loginAttemptRepo := repositories.NewLoginAttemptRepository(db)
+	securityEventRepo := repositories.NewSecurityEventRepository(db)
+	userIdentityRepo := repositories.NewUserIdentityRepository(db)
+	notificationRepo := repositories.NewNotificationRepository(db)
+
+	// 缓存 - main 中按配置初始化的默认缓存
+	cache := utils.DefaultCache()
 
 	// Service 层 - 注入 Repository
-	userService := services.NewUserService(userRepo, menuRepo)
-	orderService := services.NewOrderService(orderRepo)
+	securityEventService := services.NewSecurityEventService(securityEventRepo)
+	loginGuard := services.NewLoginGuardService(loginAttemptRepo)
+	userService := services.NewUserService(userRepo, menuRepo, roleRepo, loginGuard, cache)
+	// 布隆过滤器从数据库加载，没有数据库连接时（如控制器测试）不启用
+	if config.AppConfig.Cache.UserIDFilter && db != nil {
+		if err := userService.EnableIDFilter(uint(config.AppConfig.Cache.UserIDFilterCapacity)); err != nil {
+			log.Printf("用户ID布隆过滤器加载失败: %v", err)
+		}
+	}
+	if config.AppConfig.Cache.RegisterFilter && db != nil {
+		// 用户多时加载较慢，放到后台，加载完成前注册照常查询数据库；加载失败只记录日志，不影响服务
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("注册布隆过滤器加载失败: %v", r)
+				}
+			}()
+			if err := userService.EnableRegistrationFilter(uint(config.AppConfig.Cache.RegisterFilterCapacity), config.AppConfig.Cache.RegisterFilterSnapshot); err != nil {
+				log.Printf("注册布隆过滤器加载失败: %v", err)
+			}
+		}()
+		shutdownHooks = append(shutdownHooks, func() {
+			if err := userService.SaveRegistrationFilter(); err != nil {
+				log.Printf("注册布隆过滤器快照保存失败: %v", err)
+			}
+		})
+	}
 	menuService := services.NewMenuService(menuRepo, userRepo, roleRepo)
 	roleService := services.NewRoleService(roleRepo)
 	fileService := services.NewFileService(fileRepo)
-	wechatService := services.NewWechatService()
-	lotteryService := services.NewLotteryService(lotteryRepo)
+	wechatService := services.NewWechatService(userIdentityRepo, userRepo, roleRepo, userService, loginGuard)
+	notificationService := services.NewNotificationService(wechatService, notificationRepo, userIdentityRepo)
+	orderService := services.NewOrderService(orderRepo, notificationService)
+	lotteryService := services.NewLotteryService(lotteryRepo, notificationService)
+	permissionService := services.NewPermissionService(userRepo, roleRepo, menuRepo, cache)
+	accountService := services.NewAccountService(userRepo, utils.GetMailer())
+	twoFactorService := services.NewTwoFactorService(userRepo, roleRepo, recoveryCodeRepo, userIdentityRepo, userService, loginGuard)
+	miniProgramService := services.NewWechatMiniProgramService(services.NewWechatMiniProgramClient(), userIdentityRepo, userRepo, roleRepo, userService)
+	oauthService := services.NewOAuthService(services.NewOAuthProviders(config.AppConfig.OAuth), userIdentityRepo, userRepo, roleRepo, userService)
+
+	// 注册后台任务处理函数
+	services.RegisterAccountJobs(jobQueue, accountService)
+
+	// 注册权限校验实现，供 RequirePermission 中间件使用
+	middlewares.InitPermission(permissionService)
 
 	// Controller 层 - 注入 Service
-	userController := controllers.NewUserController(userService, wechatService)
+	userController := controllers.NewUserController(userService, wechatService, miniProgramService, accountService, loginGuard, jobQueue)
 	orderController := controllers.NewOrderController(orderService)
 	menuController := controllers.NewMenuController(menuService)
 	roleController := controllers.NewRoleController(roleService)
@@ -55,6 +115,11 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	videoController := controllers.NewVideoController()
 	captchaController := controllers.NewCaptchaController()
 	lotteryController := controllers.NewLotteryController(lotteryService)
+	twoFactorController := controllers.NewTwoFactorController(twoFactorService)
+	accountController := controllers.NewAccountController(accountService)
+	securityController := controllers.NewSecurityController(loginGuard, securityEventService)
+	oauthController := controllers.NewOAuthController(oauthService)
+	notificationController := controllers.NewNotificationController(notificationService)
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
@@ -64,6 +129,9 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		})
 	})
 
+	// JWKS：公开令牌验证公钥，供其他内部服务验证令牌
+	r.GET("/.well-known/jwks.json", controllers.GetJWKS)
+
 	// Swagger 接口文档
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -71,15 +139,17 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	api := r.Group("/api/v1")
 
 	// 设置各模块路由
-	SetupAuthRoutes(api, userController, captchaController) // 认证路由
-	SetupUserRoutes(api, userController)                    // 用户路由
-	SetupOrderRoutes(api, orderController)                  // 订单路由
-	SetupMenuRoutes(api, menuController)                    // 菜单路由
-	SetupRoleRoutes(api, roleController)                    // 角色路由
-	SetupFileRoutes(api, fileController)                    // 文件路由
-	SetupVideoRoutes(api, videoController)                   // 视频路由
-	RegisterAIRoutes(api)                                   // AI 路由
-	SetupLotteryRoutes(api, lotteryController)              // 抽奖路由
+	SetupAuthRoutes(api, userController, captchaController, twoFactorController, accountController, oauthController)
+	SetupUserRoutes(api, userController)                 // 用户路由
+	SetupOrderRoutes(api, orderController)               // 订单路由
+	SetupMenuRoutes(api, menuController)                 // 菜单路由
+	SetupRoleRoutes(api, roleController)                 // 角色路由
+	SetupFileRoutes(api, fileController)                 // 文件路由
+	SetupVideoRoutes(api, videoController)               // 视频路由
+	RegisterAIRoutes(api)                                // AI 路由
+	SetupLotteryRoutes(api, lotteryController)           // 抽奖路由
+	SetupSecurityRoutes(api, securityController)         // 安全审计路由
+	SetupNotificationRoutes(api, notificationController) // 微信通知路由
 
 	return r
 }
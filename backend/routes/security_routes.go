@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"gin-backend/controllers"
+	"gin-backend/middlewares"
+	"gin-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSecurityRoutes 设置安全审计相关路由
+func SetupSecurityRoutes(api *gin.RouterGroup, securityController *controllers.SecurityController) {
+	security := api.Group("/security")
+	security.Use(middlewares.AuthMiddleware())
+	{
+		security.GET("/login-attempts", middlewares.RequirePermission(models.PermSecurityAttempts), securityController.GetLoginAttempts) // 登录尝试记录
+		security.POST("/unlock", middlewares.RequirePermission(models.PermSecurityUnlock), securityController.UnlockLogin)               // 解除登录锁定
+		security.GET("/events", middlewares.RequirePermission(models.PermSecurityEvents), securityController.GetSecurityEvents)          // 安全事件日志
+	}
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"gin-backend/controllers"
 	"gin-backend/middlewares"
+	"gin-backend/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,13 +15,15 @@ func SetupUserRoutes(api *gin.RouterGroup, userController *controllers.UserContr
 		// 需要认证的用户操作
 		users.Use(middlewares.AuthMiddleware())
 		{
-			users.GET("", userController.GetUsers)          // 获取用户列表
-			users.GET("/:id", userController.GetUser)       // 获取单个用户
-			users.PUT("/:id", userController.UpdateUser)    // 更新用户
-			users.DELETE("/:id", userController.DeleteUser) // 删除用户
-		}
+			users.GET("", middlewares.RequirePermission(models.PermUserList), userController.GetUsers)            // 获取用户列表
+			users.GET("/:id", middlewares.RequirePermission(models.PermUserList), userController.GetUser)         // 获取单个用户
+			users.POST("", middlewares.RequirePermission(models.PermUserCreate), userController.CreateUser)       // 创建用户
+			users.PUT("/:id", middlewares.RequirePermission(models.PermUserUpdate), userController.UpdateUser)    // 更新用户
+			users.DELETE("/:id", middlewares.RequirePermission(models.PermUserDelete), userController.DeleteUser) // 删除用户
 
-		// 创建用户（可能不需要认证，根据业务需求调整）
-		users.POST("", userController.CreateUser)
+			// 会话管理
+			users.GET("/:id/sessions", middlewares.RequirePermission(models.PermUserSession), userController.GetUserSessions)           // 获取用户登录会话
+			users.DELETE("/:id/sessions/:sid", middlewares.RequirePermission(models.PermUserSession), userController.RevokeUserSession) // 注销用户会话
+		}
 	}
 }
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin-backend/config"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// passwordResetExpiration 重置密码链接有效期
+	passwordResetExpiration = 30 * time.Minute
+	// emailVerifyExpiration 邮箱验证链接有效期
+	emailVerifyExpiration = 24 * time.Hour
+	// mailResendInterval 同一邮箱两次发送邮件的最小间隔
+	mailResendInterval = time.Minute
+)
+
+var (
+	errEmailAlreadyVerified = errors.New("邮箱已验证")
+	errMailTooFrequent      = errors.New("发送过于频繁，请稍后再试")
+)
+
+// JobSendVerificationEmail 发送邮箱验证邮件的后台任务类型
+const JobSendVerificationEmail = "account.send_verification_email"
+
+// VerificationEmailJob 发送邮箱验证邮件的任务参数
+type VerificationEmailJob struct {
+	UserID uint `json:"user_id"`
+}
+
+// passwordResetPayload 重置密码令牌内容
+type passwordResetPayload struct {
+	UserID uint   `json:"user_id"`
+	Stamp  string `json:"stamp"` // 签发时密码哈希的指纹，密码变更后旧链接自动失效
+}
+
+// emailVerifyPayload 邮箱验证令牌内容
+type emailVerifyPayload struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"` // 邮箱变更后旧链接自动失效
+}
+
+// AccountService 账号安全服务接口（找回密码、邮箱验证）
+type AccountService interface {
+	ForgotPassword(email string) error
+	ResetPassword(token, newPassword string) error
+	SendVerificationEmail(userID uint) error
+	VerifyEmail(token string) error
+}
+
+// accountService 账号安全服务实现
+type accountService struct {
+	userRepo repositories.UserRepository
+	mailer   utils.Mailer
+}
+
+// NewAccountService 创建账号安全服务实例
+func NewAccountService(userRepo repositories.UserRepository, mailer utils.Mailer) AccountService {
+	return &accountService{
+		userRepo: userRepo,
+		mailer:   mailer,
+	}
+}
+
+// ForgotPassword 发送重置密码邮件
+// 邮箱不存在时同样返回成功，避免被用来探测已注册邮箱
+func (s *accountService) ForgotPassword(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil || user == nil {
+		return nil
+	}
+	if !allowMail("reset", user.Email) {
+		return nil
+	}
+
+	token, err := utils.IssueActionToken(utils.TokenPurposePasswordReset, passwordResetPayload{
+		UserID: user.ID,
+		Stamp:  passwordStamp(user.Password),
+	}, passwordResetExpiration)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", mailConfig().FrontendURL, url.QueryEscape(token))
+	return s.mailer.Send(utils.Mail{
+		To:      user.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s，您好：\n\n我们收到了重置您账号密码的请求，请在 %d 分钟内打开以下链接设置新密码：\n\n%s\n\n如果这不是您本人的操作，请忽略本邮件。",
+			user.Username, int(passwordResetExpiration/time.Minute), link),
+	})
+}
+
+// ResetPassword 使用重置链接中的令牌设置新密码，并注销该用户的所有登录会话
+func (s *accountService) ResetPassword(token, newPassword string) error {
+	var payload passwordResetPayload
+	if err := utils.ConsumeActionToken(utils.TokenPurposePasswordReset, token, &payload); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(payload.UserID)
+	if err != nil || user == nil || passwordStamp(user.Password) != payload.Stamp {
+		return utils.ErrActionTokenInvalid
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return errors.New("密码加密失败")
+	}
+
+	now := time.Now()
+	user.Password = hashedPassword
+	if user.EmailVerifiedAt == nil {
+		// 能收到重置邮件即证明拥有该邮箱
+		user.EmailVerifiedAt = &now
+	}
+	user.UpdatedAt = now
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	invalidateUserProfile(user.ID)
+
+	// 密码已变更，所有设备需要重新登录
+	for _, session := range utils.ListSessions(user.ID) {
+		utils.RevokeRefreshFamily(session.ID)
+	}
+	return nil
+}
+
+// SendVerificationEmail 发送邮箱验证邮件
+func (s *accountService) SendVerificationEmail(userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return errEmailAlreadyVerified
+	}
+	if !allowMail("verify", user.Email) {
+		return errMailTooFrequent
+	}
+
+	token, err := utils.IssueActionToken(utils.TokenPurposeEmailVerify, emailVerifyPayload{
+		UserID: user.ID,
+		Email:  user.Email,
+	}, emailVerifyExpiration)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/v1/email/verify?token=%s", mailConfig().APIBaseURL, url.QueryEscape(token))
+	return s.mailer.Send(utils.Mail{
+		To:      user.Email,
+		Subject: "验证您的邮箱",
+		Body: fmt.Sprintf("%s，您好：\n\n请在 24 小时内打开以下链接完成邮箱验证：\n\n%s\n\n如果您没有注册过账号，请忽略本邮件。",
+			user.Username, link),
+	})
+}
+
+// VerifyEmail 使用验证链接中的令牌完成邮箱验证
+func (s *accountService) VerifyEmail(token string) error {
+	var payload emailVerifyPayload
+	if err := utils.ConsumeActionToken(utils.TokenPurposeEmailVerify, token, &payload); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(payload.UserID)
+	if err != nil || user == nil || !strings.EqualFold(user.Email, payload.Email) {
+		return utils.ErrActionTokenInvalid
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	user.UpdatedAt = now
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	invalidateUserProfile(user.ID)
+	return nil
+}
+
+// RegisterAccountJobs 注册账号相关的后台任务处理函数
+func RegisterAccountJobs(queue JobQueue, accountService AccountService) {
+	RegisterJobHandler(queue, JobSendVerificationEmail, func(ctx context.Context, payload VerificationEmailJob) error {
+		err := accountService.SendVerificationEmail(payload.UserID)
+		switch {
+		case errors.Is(err, errEmailAlreadyVerified):
+			return nil
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return PermanentJobError(err)
+		}
+		// 发送失败后重试时可能仍在发信间隔内，按普通失败等待退避后重试
+		return err
+	})
+}
+
+// allowMail 限制同一邮箱的发信频率，间隔内并发的请求只有一个能发信。缓存不可用时不限制
+func allowMail(kind, email string) bool {
+	key := fmt.Sprintf("mail:throttle:%s:%s", kind, strings.ToLower(email))
+	ok, err := utils.CacheSetNX(key, true, mailResendInterval)
+	return err != nil || ok
+}
+
+// passwordStamp 密码哈希的短指纹
+func passwordStamp(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
+
+// mailConfig 邮件相关配置
+func mailConfig() config.MailConfig {
+	if config.AppConfig == nil {
+		return config.MailConfig{}
+	}
+	return config.AppConfig.Mail
+}
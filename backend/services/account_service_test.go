@@ -0,0 +1,110 @@
+package services
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"gin-backend/models"
+	"gin-backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// tokenFromMail 从邮件正文的链接中取出 token 参数
+func tokenFromMail(t *testing.T, mail utils.Mail) string {
+	for _, line := range strings.Split(mail.Body, "\n") {
+		if u, err := url.Parse(strings.TrimSpace(line)); err == nil && u.Query().Get("token") != "" {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatalf("邮件中没有找到链接: %s", mail.Body)
+	return ""
+}
+
+func TestPasswordReset(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	hashed, _ := utils.HashPassword("old-password")
+	user := &models.User{ID: 9201, Username: "reset_user", Email: "reset@example.com", Password: hashed}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByEmail", user.Email).Return(user, nil)
+	userRepo.On("FindByEmail", "nobody@example.com").Return(nil, assert.AnError)
+	userRepo.On("FindByID", user.ID).Return(user, nil)
+	userRepo.On("Update", mock.Anything).Return(nil)
+	outbox := utils.NewOutboxMailer("")
+	service := NewAccountService(userRepo, outbox)
+
+	// 未注册的邮箱不报错也不发信
+	assert.NoError(t, service.ForgotPassword("nobody@example.com"))
+	assert.Empty(t, outbox.Messages())
+
+	assert.NoError(t, service.ForgotPassword(user.Email))
+	mail, ok := outbox.Last(user.Email)
+	assert.True(t, ok)
+	token := tokenFromMail(t, mail)
+
+	// 篡改签名的令牌无效
+	assert.Equal(t, utils.ErrActionTokenInvalid, service.ResetPassword(token+"x", "new-password"))
+	// 重置密码令牌不能用于验证邮箱
+	assert.Equal(t, utils.ErrActionTokenInvalid, service.VerifyEmail(token))
+
+	assert.NoError(t, service.ResetPassword(token, "new-password"))
+	assert.True(t, utils.CheckPassword("new-password", user.Password))
+	assert.NotNil(t, user.EmailVerifiedAt)
+
+	// 令牌只能使用一次
+	assert.Equal(t, utils.ErrActionTokenInvalid, service.ResetPassword(token, "another-password"))
+
+	utils.CacheDel("mail:throttle:reset:" + user.Email)
+}
+
+func TestForgotPasswordConcurrent(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	user := &models.User{ID: 9203, Username: "reset_burst", Email: "burst@example.com"}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByEmail", user.Email).Return(user, nil)
+	outbox := utils.NewOutboxMailer("")
+	service := NewAccountService(userRepo, outbox)
+
+	// 并发请求只发出一封邮件
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, service.ForgotPassword(user.Email))
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, outbox.Messages(), 1)
+
+	utils.CacheDel("mail:throttle:reset:" + user.Email)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	user := &models.User{ID: 9202, Username: "verify_user", Email: "verify@example.com"}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", user.ID).Return(user, nil)
+	userRepo.On("Update", mock.Anything).Return(nil)
+	outbox := utils.NewOutboxMailer("")
+	service := NewAccountService(userRepo, outbox)
+
+	assert.NoError(t, service.SendVerificationEmail(user.ID))
+	// 短时间内不能重复发送
+	assert.Equal(t, errMailTooFrequent, service.SendVerificationEmail(user.ID))
+
+	mail, ok := outbox.Last(user.Email)
+	assert.True(t, ok)
+	assert.NoError(t, service.VerifyEmail(tokenFromMail(t, mail)))
+	assert.NotNil(t, user.EmailVerifiedAt)
+	assert.Equal(t, errEmailAlreadyVerified, service.SendVerificationEmail(user.ID))
+
+	utils.CacheDel("mail:throttle:verify:" + user.Email)
+}
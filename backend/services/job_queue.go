@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// jobErrorMaxBytes 与 models.Job.LastError 的列长度一致
+const jobErrorMaxBytes = 1000
+
+// JobHandler 任务处理函数，payload 为入队时参数的 JSON。
+// 返回错误时按退避时间重试，返回 PermanentJobError 包装的错误时直接转入死信；
+// ctx 在租约丢失或服务强制停止时取消，处理函数应尽快返回
+type JobHandler func(ctx context.Context, payload []byte) error
+
+// JobQueueOptions 任务队列配置，零值字段使用默认值
+type JobQueueOptions struct {
+	Workers           int           // 工作协程数，默认 4
+	PollInterval      time.Duration // 队列为空时的轮询间隔，默认 1s
+	VisibilityTimeout time.Duration // 租约时长，执行中定期续约，工作进程崩溃后超过该时长重新投递，默认 5m
+	MaxAttempts       int           // 每个任务最多执行次数，默认 5
+	BaseBackoff       time.Duration // 第一次重试的等待时间，之后每次翻倍，默认 10s
+	MaxBackoff        time.Duration // 重试等待时间上限，默认 1h
+}
+
+// JobQueue 持久化的后台任务队列，任务保存在 MySQL 或 Redis 中，重启后继续执行
+type JobQueue interface {
+	// Register 注册任务类型的处理函数，需在 Start 前完成
+	Register(jobType string, handler JobHandler)
+	// Enqueue 提交任务，payload 序列化为 JSON 保存
+	Enqueue(jobType string, payload interface{}) (*models.Job, error)
+	// EnqueueIn 提交在 delay 之后执行的任务
+	EnqueueIn(jobType string, payload interface{}, delay time.Duration) (*models.Job, error)
+	GetJob(id uint) (*models.Job, error)
+	// Requeue 把死信任务重新放回队列
+	Requeue(id uint) error
+	// Start 启动工作协程
+	Start()
+	// Shutdown 停止领取新任务并等待执行中的任务完成；ctx 到期时取消执行中的任务并归还给队列
+	Shutdown(ctx context.Context) error
+}
+
+// NewJobQueueFromConfig 按配置创建任务队列：
+//   - mysql：任务保存在 jobs 表
+//   - redis：任务保存在 Redis，需开启持久化
+//   - auto（默认）：启动时 Redis 已连接则用 Redis，否则用 MySQL
+func NewJobQueueFromConfig(cfg config.JobConfig, db *gorm.DB) (JobQueue, error) {
+	options := JobQueueOptions{
+		Workers:           cfg.Workers,
+		VisibilityTimeout: time.Duration(cfg.VisibilityTimeoutSeconds) * time.Second,
+		MaxAttempts:       cfg.MaxAttempts,
+		BaseBackoff:       time.Duration(cfg.BaseBackoffSeconds) * time.Second,
+	}
+
+	switch cfg.Driver {
+	case "mysql":
+		return NewJobQueue(repositories.NewJobRepository(db), options), nil
+	case "redis":
+		if config.RedisClient == nil {
+			return nil, errors.New("任务队列驱动为 redis，但 Redis 未连接")
+		}
+		return NewJobQueue(repositories.NewRedisJobRepository(config.RedisClient), options), nil
+	case "", "auto":
+		if config.RedisClient != nil {
+			return NewJobQueue(repositories.NewRedisJobRepository(config.RedisClient), options), nil
+		}
+		return NewJobQueue(repositories.NewJobRepository(db), options), nil
+	default:
+		return nil, errors.New("未知的任务队列驱动: " + cfg.Driver)
+	}
+}
+
+// permanentJobError 不可重试的任务错误
+type permanentJobError struct {
+	err error
+}
+
+func (e *permanentJobError) Error() string { return e.err.Error() }
+func (e *permanentJobError) Unwrap() error { return e.err }
+
+// PermanentJobError 包装不可重试的错误（如参数错误），任务直接转入死信状态
+func PermanentJobError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentJobError{err: err}
+}
+
+// RegisterJobHandler 注册参数类型为 T 的处理函数，参数无法解析时任务直接转入死信状态
+func RegisterJobHandler[T any](queue JobQueue, jobType string, handle func(ctx context.Context, payload T) error) {
+	queue.Register(jobType, func(ctx context.Context, data []byte) error {
+		var payload T
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return PermanentJobError(fmt.Errorf("任务参数解析失败: %w", err))
+		}
+		return handle(ctx, payload)
+	})
+}
+
+// jobQueue 任务队列实现
+type jobQueue struct {
+	repo    repositories.JobRepository
+	options JobQueueOptions
+	id      string // 本实例标识，各工作协程在其后加序号作为 locked_by
+
+	mu       sync.RWMutex
+	handlers map[string]JobHandler
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	wake      chan struct{} // 提交立即执行的任务时唤醒空闲的工作协程
+	wg        sync.WaitGroup
+
+	// runCtx 是所有处理函数 ctx 的父 ctx，强制停止时取消
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+}
+
+// NewJobQueue 创建任务队列实例
+func NewJobQueue(repo repositories.JobRepository, options JobQueueOptions) JobQueue {
+	if options.Workers <= 0 {
+		options.Workers = 4
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+	if options.VisibilityTimeout <= 0 {
+		options.VisibilityTimeout = 5 * time.Minute
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 5
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = 10 * time.Second
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = time.Hour
+	}
+
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	return &jobQueue{
+		repo:      repo,
+		options:   options,
+		id:        newJobWorkerID(),
+		handlers:  make(map[string]JobHandler),
+		stop:      make(chan struct{}),
+		wake:      make(chan struct{}, 1),
+		runCtx:    runCtx,
+		cancelRun: cancelRun,
+	}
+}
+
+// newJobWorkerID 主机名 + 进程号 + 随机后缀，同一主机上重启的进程也不会重复
+func newJobWorkerID() string {
+	host, _ := os.Hostname()
+	if len(host) > 32 {
+		host = host[:32]
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Register 注册任务类型的处理函数
+func (q *jobQueue) Register(jobType string, handler JobHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+// Enqueue 提交任务
+func (q *jobQueue) Enqueue(jobType string, payload interface{}) (*models.Job, error) {
+	return q.EnqueueIn(jobType, payload, 0)
+}
+
+// EnqueueIn 提交延迟执行的任务
+func (q *jobQueue) EnqueueIn(jobType string, payload interface{}, delay time.Duration) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("任务参数序列化失败: %w", err)
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      models.JobPending,
+		MaxAttempts: q.options.MaxAttempts,
+		RunAt:       time.Now().Add(delay),
+	}
+	if err := q.repo.Create(job); err != nil {
+		return nil, err
+	}
+
+	if delay <= 0 {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return job, nil
+}
+
+// GetJob 查询任务
+func (q *jobQueue) GetJob(id uint) (*models.Job, error) {
+	return q.repo.FindByID(id)
+}
+
+// Requeue 把死信任务重新放回队列
+func (q *jobQueue) Requeue(id uint) error {
+	return q.repo.Requeue(id)
+}
+
+// Start 启动工作协程，重复调用无效
+func (q *jobQueue) Start() {
+	q.startOnce.Do(func() {
+		for i := 0; i < q.options.Workers; i++ {
+			q.wg.Add(1)
+			go q.work(fmt.Sprintf("%s-%d", q.id, i))
+		}
+	})
+}
+
+// Shutdown 优雅停止
+func (q *jobQueue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancelRun()
+		return nil
+	case <-ctx.Done():
+		// 取消执行中的任务，工作协程在处理函数返回后把任务归还给队列
+		q.cancelRun()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work 工作协程：循环领取并执行任务，队列为空时等待唤醒或轮询
+func (q *jobQueue) work(worker string) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		job, err := q.repo.Reserve(worker, q.options.VisibilityTimeout)
+		if err != nil {
+			log.Printf("领取后台任务失败: %v", err)
+		}
+		if job != nil {
+			q.run(job)
+			continue
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-time.After(q.options.PollInterval):
+		}
+	}
+}
+
+// run 执行一个已领取的任务并根据结果更新状态
+func (q *jobQueue) run(job *models.Job) {
+	// 工作进程在执行中崩溃时任务会被重新投递且计入执行次数，超过上限不再执行
+	if job.Attempts > job.MaxAttempts {
+		q.bury(job, "执行过程中多次中断，超过最多执行次数")
+		return
+	}
+
+	q.mu.RLock()
+	handler := q.handlers[job.Type]
+	q.mu.RUnlock()
+	if handler == nil {
+		// 可能是滚动发布时由旧版本实例领取，按普通失败重试
+		q.fail(job, fmt.Errorf("未注册的任务类型: %s", job.Type))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(q.runCtx)
+	defer cancel()
+
+	// 执行期间定期续约，租约被其他工作协程接管时取消处理函数
+	leaseLost := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(q.options.VisibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := q.repo.Extend(job, q.options.VisibilityTimeout)
+				if errors.Is(err, repositories.ErrJobLeaseLost) {
+					close(leaseLost)
+					cancel()
+					return
+				}
+				if err != nil {
+					log.Printf("后台任务续约失败: job=%d err=%v", job.ID, err)
+				}
+			}
+		}
+	}()
+
+	err := invokeJobHandler(ctx, handler, job)
+	cancel()
+	<-heartbeatDone
+
+	select {
+	case <-leaseLost:
+		log.Printf("后台任务租约已失效，结果丢弃: job=%d", job.ID)
+		return
+	default:
+	}
+
+	switch {
+	case err == nil:
+		if err := q.repo.Complete(job); err != nil {
+			log.Printf("更新后台任务状态失败: job=%d err=%v", job.ID, err)
+		}
+	case q.runCtx.Err() != nil:
+		// 强制停止时被取消，归还任务，不计入执行次数
+		if err := q.repo.Release(job); err != nil {
+			log.Printf("归还后台任务失败: job=%d err=%v", job.ID, err)
+		}
+	default:
+		q.fail(job, err)
+	}
+}
+
+// fail 处理执行失败：可重试且未达上限时按指数退避重试，否则转入死信
+func (q *jobQueue) fail(job *models.Job, err error) {
+	var permanent *permanentJobError
+	if errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts {
+		q.bury(job, err.Error())
+		return
+	}
+
+	runAt := time.Now().Add(q.backoff(job.Attempts))
+	if err := q.repo.Retry(job, runAt, truncateJobError(err.Error())); err != nil {
+		log.Printf("更新后台任务状态失败: job=%d err=%v", job.ID, err)
+	}
+}
+
+func (q *jobQueue) bury(job *models.Job, reason string) {
+	log.Printf("后台任务转入死信: job=%d type=%s attempts=%d err=%s", job.ID, job.Type, job.Attempts, reason)
+	if err := q.repo.Bury(job, truncateJobError(reason)); err != nil {
+		log.Printf("更新后台任务状态失败: job=%d err=%v", job.ID, err)
+	}
+}
+
+// backoff 第 attempts 次执行失败后的等待时间：BaseBackoff * 2^(attempts-1)，不超过 MaxBackoff
+func (q *jobQueue) backoff(attempts int) time.Duration {
+	delay := q.options.BaseBackoff
+	for i := 1; i < attempts && delay < q.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, q.options.MaxBackoff)
+}
+
+// invokeJobHandler 执行处理函数，panic 视为普通失败
+func invokeJobHandler(ctx context.Context, handler JobHandler, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务执行 panic: %v", r)
+		}
+	}()
+	return handler(ctx, []byte(job.Payload))
+}
+
+// truncateJobError 截断到列长度以内，不截断多字节字符
+func truncateJobError(message string) string {
+	if len(message) <= jobErrorMaxBytes {
+		return message
+	}
+	cut := jobErrorMaxBytes
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut]
+}
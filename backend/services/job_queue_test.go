@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryJobRepository 内存实现的任务存储，语义与 MySQL/Redis 实现一致
+type memoryJobRepository struct {
+	mu     sync.Mutex
+	nextID uint
+	jobs   map[uint]*models.Job
+}
+
+func newMemoryJobRepository() *memoryJobRepository {
+	return &memoryJobRepository{jobs: make(map[uint]*models.Job)}
+}
+
+func (r *memoryJobRepository) Create(job *models.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	job.ID = r.nextID
+	stored := *job
+	r.jobs[job.ID] = &stored
+	return nil
+}
+
+func (r *memoryJobRepository) Reserve(worker string, lease time.Duration) (*models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var candidate *models.Job
+	for _, job := range r.jobs {
+		ready := job.Status == models.JobPending && !job.RunAt.After(now)
+		expired := job.Status == models.JobRunning && job.LockedUntil.Before(now)
+		if (ready || expired) && (candidate == nil || job.RunAt.Before(candidate.RunAt)) {
+			candidate = job
+		}
+	}
+	if candidate == nil {
+		return nil, nil
+	}
+	until := now.Add(lease)
+	candidate.Status = models.JobRunning
+	candidate.Attempts++
+	candidate.LockedBy = worker
+	candidate.LockedUntil = &until
+	reserved := *candidate
+	return &reserved, nil
+}
+
+func (r *memoryJobRepository) Extend(job *models.Job, lease time.Duration) error {
+	return r.updateOwned(job, func(stored *models.Job) {
+		until := time.Now().Add(lease)
+		stored.LockedUntil = &until
+	})
+}
+
+func (r *memoryJobRepository) Complete(job *models.Job) error {
+	return r.updateOwned(job, func(stored *models.Job) {
+		stored.Status = models.JobCompleted
+	})
+}
+
+func (r *memoryJobRepository) Retry(job *models.Job, runAt time.Time, lastErr string) error {
+	return r.updateOwned(job, func(stored *models.Job) {
+		stored.Status = models.JobPending
+		stored.RunAt = runAt
+		stored.LastError = lastErr
+	})
+}
+
+func (r *memoryJobRepository) Bury(job *models.Job, lastErr string) error {
+	return r.updateOwned(job, func(stored *models.Job) {
+		stored.Status = models.JobDead
+		stored.LastError = lastErr
+	})
+}
+
+func (r *memoryJobRepository) Release(job *models.Job) error {
+	return r.updateOwned(job, func(stored *models.Job) {
+		stored.Status = models.JobPending
+		stored.Attempts--
+		stored.RunAt = time.Now()
+	})
+}
+
+func (r *memoryJobRepository) Requeue(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok || job.Status != models.JobDead {
+		return errors.New("任务不存在或不是死信状态")
+	}
+	job.Status = models.JobPending
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	return nil
+}
+
+func (r *memoryJobRepository) FindByID(id uint) (*models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, errors.New("任务不存在")
+	}
+	found := *job
+	return &found, nil
+}
+
+func (r *memoryJobRepository) updateOwned(job *models.Job, update func(stored *models.Job)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.jobs[job.ID]
+	if !ok || stored.Status != models.JobRunning || stored.LockedBy != job.LockedBy {
+		return repositories.ErrJobLeaseLost
+	}
+	update(stored)
+	if stored.Status != models.JobRunning {
+		stored.LockedBy = ""
+		stored.LockedUntil = nil
+	}
+	return nil
+}
+
+// waitJobStatus 等待任务进入指定状态
+func waitJobStatus(t *testing.T, queue JobQueue, id uint, status string) *models.Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := queue.GetJob(id)
+		require.NoError(t, err)
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not reach status %s", id, status)
+	return nil
+}
+
+type testJobPayload struct {
+	Name string `json:"name"`
+}
+
+func TestJobQueue_ProcessesTypedPayload(t *testing.T) {
+	queue := NewJobQueue(newMemoryJobRepository(), JobQueueOptions{Workers: 2, PollInterval: 10 * time.Millisecond})
+	received := make(chan string, 1)
+	RegisterJobHandler(queue, "greet", func(ctx context.Context, payload testJobPayload) error {
+		received <- payload.Name
+		return nil
+	})
+	queue.Start()
+	defer queue.Shutdown(context.Background())
+
+	job, err := queue.Enqueue("greet", testJobPayload{Name: "alice"})
+	require.NoError(t, err)
+
+	waitJobStatus(t, queue, job.ID, models.JobCompleted)
+	assert.Equal(t, "alice", <-received)
+}
+
+func TestJobQueue_RetriesWithBackoffThenDeadLetters(t *testing.T) {
+	queue := NewJobQueue(newMemoryJobRepository(), JobQueueOptions{
+		PollInterval: time.Millisecond,
+		MaxAttempts:  3,
+		BaseBackoff:  20 * time.Millisecond,
+	})
+	var mu sync.Mutex
+	var calls []time.Time
+	queue.Register("flaky", func(ctx context.Context, payload []byte) error {
+		mu.Lock()
+		calls = append(calls, time.Now())
+		mu.Unlock()
+		return errors.New("smtp unavailable")
+	})
+	queue.Start()
+	defer queue.Shutdown(context.Background())
+
+	job, err := queue.Enqueue("flaky", nil)
+	require.NoError(t, err)
+
+	dead := waitJobStatus(t, queue, job.ID, models.JobDead)
+	assert.Equal(t, 3, dead.Attempts)
+	assert.Equal(t, "smtp unavailable", dead.LastError)
+
+	mu.Lock()
+	require.Len(t, calls, 3)
+	// 等待时间翻倍：20ms、40ms
+	assert.GreaterOrEqual(t, calls[1].Sub(calls[0]), 20*time.Millisecond)
+	assert.GreaterOrEqual(t, calls[2].Sub(calls[1]), 40*time.Millisecond)
+	mu.Unlock()
+
+	// 死信任务重新放回队列后执行次数清零
+	require.NoError(t, queue.Requeue(job.ID))
+	dead = waitJobStatus(t, queue, job.ID, models.JobDead)
+	assert.Equal(t, 3, dead.Attempts)
+	mu.Lock()
+	assert.Len(t, calls, 6)
+	mu.Unlock()
+}
+
+func TestJobQueue_PermanentErrorSkipsRetries(t *testing.T) {
+	queue := NewJobQueue(newMemoryJobRepository(), JobQueueOptions{PollInterval: time.Millisecond, BaseBackoff: time.Millisecond})
+	var calls atomic.Int32
+	RegisterJobHandler(queue, "typed", func(ctx context.Context, payload testJobPayload) error {
+		calls.Add(1)
+		return nil
+	})
+	queue.Register("invalid", func(ctx context.Context, payload []byte) error {
+		calls.Add(1)
+		return PermanentJobError(errors.New("user not found"))
+	})
+	queue.Start()
+	defer queue.Shutdown(context.Background())
+
+	job, err := queue.Enqueue("invalid", nil)
+	require.NoError(t, err)
+	dead := waitJobStatus(t, queue, job.ID, models.JobDead)
+	assert.Equal(t, 1, dead.Attempts)
+
+	// 参数无法解析时不调用处理函数
+	job, err = queue.Enqueue("typed", "not an object")
+	require.NoError(t, err)
+	dead = waitJobStatus(t, queue, job.ID, models.JobDead)
+	assert.Equal(t, 1, dead.Attempts)
+	assert.Contains(t, dead.LastError, "任务参数解析失败")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestJobQueue_RedeliversExpiredLease(t *testing.T) {
+	repo := newMemoryJobRepository()
+	// 模拟执行中崩溃的工作进程留下的任务
+	job := &models.Job{Type: "resume", Status: models.JobPending, MaxAttempts: 5, RunAt: time.Now()}
+	require.NoError(t, repo.Create(job))
+	crashed, err := repo.Reserve("crashed-worker", 10*time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, crashed)
+
+	queue := NewJobQueue(repo, JobQueueOptions{PollInterval: time.Millisecond})
+	queue.Register("resume", func(ctx context.Context, payload []byte) error { return nil })
+	queue.Start()
+	defer queue.Shutdown(context.Background())
+
+	completed := waitJobStatus(t, queue, job.ID, models.JobCompleted)
+	assert.Equal(t, 2, completed.Attempts)
+
+	// 原工作进程恢复后不能再修改任务
+	assert.ErrorIs(t, repo.Complete(crashed), repositories.ErrJobLeaseLost)
+}
+
+func TestJobQueue_ShutdownDrainsInFlightJobs(t *testing.T) {
+	queue := NewJobQueue(newMemoryJobRepository(), JobQueueOptions{PollInterval: time.Millisecond})
+	started := make(chan struct{})
+	queue.Register("slow", func(ctx context.Context, payload []byte) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	queue.Start()
+
+	job, err := queue.Enqueue("slow", nil)
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, queue.Shutdown(ctx))
+
+	finished, err := queue.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobCompleted, finished.Status)
+
+	// 停止后提交的任务保留到下次启动
+	job, err = queue.Enqueue("slow", nil)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	pending, err := queue.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobPending, pending.Status)
+}
+
+func TestJobQueue_ShutdownTimeoutReleasesJobs(t *testing.T) {
+	queue := NewJobQueue(newMemoryJobRepository(), JobQueueOptions{PollInterval: time.Millisecond})
+	started := make(chan struct{})
+	queue.Register("stuck", func(ctx context.Context, payload []byte) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	queue.Start()
+
+	job, err := queue.Enqueue("stuck", nil)
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, queue.Shutdown(ctx), context.DeadlineExceeded)
+
+	// 被取消的任务归还给队列，不计入执行次数
+	released, err := queue.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobPending, released.Status)
+	assert.Equal(t, 0, released.Attempts)
+}
+
+func TestJobQueue_Backoff(t *testing.T) {
+	queue := NewJobQueue(newMemoryJobRepository(), JobQueueOptions{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second}).(*jobQueue)
+	assert.Equal(t, time.Second, queue.backoff(1))
+	assert.Equal(t, 4*time.Second, queue.backoff(3))
+	assert.Equal(t, 5*time.Second, queue.backoff(4))
+	assert.Equal(t, 5*time.Second, queue.backoff(60))
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// loginFailureWindow 失败计数的保留时间，超过该时间没有新的失败则清零
+	loginFailureWindow = 24 * time.Hour
+
+	// 失败次数为原子计数，锁定截止时间（毫秒时间戳）单独保存，键在锁定结束时过期。
+	// 计数和锁定都保存在缓存中，多个实例共享 Redis 时分散到各实例的尝试同样累计
+	loginFailUserPrefix = "login:fail:user:"
+	loginFailIPPrefix   = "login:fail:ip:"
+	loginLockUserPrefix = "login:lock:user:"
+	loginLockIPPrefix   = "login:lock:ip:"
+)
+
+// ErrLoginLocked 登录失败次数过多，暂时锁定
+var ErrLoginLocked = errors.New("登录失败次数过多，已临时锁定")
+
+// loginCounter 用户名或 IP 的失败计数键、锁定键和锁定阈值
+type loginCounter struct {
+	failKey   string
+	lockKey   string
+	threshold int
+}
+
+// LoginGuardService 登录防暴力破解服务接口
+type LoginGuardService interface {
+	Check(username string, device *models.DeviceInfo) error
+	RecordFailure(username string, userID uint, device *models.DeviceInfo, reason string)
+	RecordSuccess(username string, userID uint, device *models.DeviceInfo)
+	Unlock(username, ip string)
+	TrackRequest(device *models.DeviceInfo)
+	Assess(scene, username string, device *models.DeviceInfo) *models.RiskAssessment
+	ListAttempts(query *models.LoginAttemptQuery) (*models.PageResponse, error)
+}
+
+// loginGuardService 登录防暴力破解服务实现
+type loginGuardService struct {
+	attemptRepo repositories.LoginAttemptRepository
+}
+
+// NewLoginGuardService 创建登录防暴力破解服务实例
+func NewLoginGuardService(attemptRepo repositories.LoginAttemptRepository) LoginGuardService {
+	return &loginGuardService{attemptRepo: attemptRepo}
+}
+
+// Check 检查用户名和 IP 是否处于锁定期，锁定期内的尝试只记录、不再累加计数
+func (s *loginGuardService) Check(username string, device *models.DeviceInfo) error {
+	now := time.Now()
+	var until time.Time
+	for _, counter := range loginCounters(username, deviceIP(device)) {
+		if locked := loadLockedUntil(counter.lockKey); locked.After(until) {
+			until = locked
+		}
+	}
+
+	if !until.After(now) {
+		return nil
+	}
+
+	s.saveAttempt(username, 0, device, false, "锁定期内尝试登录")
+	return fmt.Errorf("%w，请 %s 后再试", ErrLoginLocked, formatWait(until.Sub(now)))
+}
+
+// RecordFailure 记录一次失败，达到阈值后按指数退避锁定
+func (s *loginGuardService) RecordFailure(username string, userID uint, device *models.DeviceInfo, reason string) {
+	now := time.Now()
+	for _, counter := range loginCounters(username, deviceIP(device)) {
+		count, err := utils.CacheIncr(counter.failKey)
+		if err != nil {
+			log.Printf("登录失败计数失败: %v", err)
+			continue
+		}
+		utils.CacheExpire(counter.failKey, loginFailureWindow)
+		if lock := lockDuration(int(count), counter.threshold); lock > 0 {
+			utils.CacheSet(counter.lockKey, now.Add(lock).UnixMilli(), lock)
+		}
+	}
+
+	s.saveAttempt(username, userID, device, false, reason)
+}
+
+// RecordSuccess 记录成功登录，并清零该用户名的失败计数
+// IP 计数不清零，避免攻击者用自己的账号登录来重置 IP 计数
+func (s *loginGuardService) RecordSuccess(username string, userID uint, device *models.DeviceInfo) {
+	name := normalizeUsername(username)
+	utils.CacheDel(loginFailUserPrefix+name, loginLockUserPrefix+name)
+	s.rememberDevice(username, device)
+
+	s.saveAttempt(username, userID, device, true, "")
+}
+
+// Unlock 管理员解除用户名或 IP 的锁定
+func (s *loginGuardService) Unlock(username, ip string) {
+	if username != "" {
+		name := normalizeUsername(username)
+		utils.CacheDel(loginFailUserPrefix+name, loginLockUserPrefix+name)
+	}
+	if ip != "" {
+		utils.CacheDel(loginFailIPPrefix+ip, loginLockIPPrefix+ip)
+	}
+}
+
+// ListAttempts 分页查询登录尝试记录
+func (s *loginGuardService) ListAttempts(query *models.LoginAttemptQuery) (*models.PageResponse, error) {
+	attempts, total, err := s.attemptRepo.FindWithPage(query)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPageResponse(query.GetPage(), query.GetPageSize(), total, attempts), nil
+}
+
+// saveAttempt 保存登录尝试记录，写库失败不影响登录流程
+func (s *loginGuardService) saveAttempt(username string, userID uint, device *models.DeviceInfo, success bool, reason string) {
+	attempt := &models.LoginAttempt{
+		Username:  username,
+		UserID:    userID,
+		Success:   success,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if device != nil {
+		attempt.IP = device.IP
+		attempt.UserAgent = truncate(device.UserAgent, 255)
+	}
+	if err := s.attemptRepo.Create(attempt); err != nil {
+		log.Printf("保存登录记录失败: %v", err)
+	}
+}
+
+// loginCounters 用户名和 IP 的计数（IP 为空时只有用户名）
+func loginCounters(username, ip string) []loginCounter {
+	name := normalizeUsername(username)
+	counters := []loginCounter{{loginFailUserPrefix + name, loginLockUserPrefix + name, guardConfig().LoginMaxFailures}}
+	if ip != "" {
+		counters = append(counters, loginCounter{loginFailIPPrefix + ip, loginLockIPPrefix + ip, guardConfig().LoginIPMaxFailures})
+	}
+	return counters
+}
+
+// loadFailureCount 读取失败次数，不存在时返回 0
+func loadFailureCount(key string) int {
+	var count int
+	utils.CacheGet(key, &count)
+	return count
+}
+
+// loadLockedUntil 读取锁定截止时间，未锁定时返回零值
+func loadLockedUntil(key string) time.Time {
+	var millis int64
+	if err := utils.CacheGet(key, &millis); err != nil || millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
+// lockDuration 计算锁定时长：达到阈值锁定基础时长，之后每多失败一次翻倍，不超过上限
+func lockDuration(count, threshold int) time.Duration {
+	if threshold <= 0 || count < threshold {
+		return 0
+	}
+
+	base := time.Duration(guardConfig().LoginLockMinutes) * time.Minute
+	max := time.Duration(guardConfig().LoginMaxLockMinutes) * time.Minute
+	lock := base
+	for i := threshold; i < count && lock < max; i++ {
+		lock *= 2
+	}
+	if lock > max {
+		lock = max
+	}
+	return lock
+}
+
+// guardConfig 登录锁定配置，未加载配置时使用默认值
+func guardConfig() config.AuthConfig {
+	if config.AppConfig == nil || config.AppConfig.Auth.LoginMaxFailures == 0 {
+		return config.AuthConfig{
+			LoginMaxFailures:    5,
+			LoginIPMaxFailures:  20,
+			LoginLockMinutes:    1,
+			LoginMaxLockMinutes: 60,
+
+			RiskCaptchaThreshold:  50,
+			RiskVelocityPerMinute: 10,
+		}
+	}
+	return config.AppConfig.Auth
+}
+
+// formatWait 将剩余等待时间格式化为易读文字
+func formatWait(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%d 秒", int(d.Seconds())+1)
+	}
+	return fmt.Sprintf("%d 分钟", int(d.Minutes())+1)
+}
+
+// normalizeUsername 用户名统一小写，避免大小写变化绕过计数
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// deviceIP 获取设备 IP
+func deviceIP(device *models.DeviceInfo) string {
+	if device == nil {
+		return ""
+	}
+	return device.IP
+}
+
+// truncate 按字符截断字符串
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/repositories"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockLoginAttemptRepository 模拟登录尝试记录仓储
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) Create(attempt *models.LoginAttempt) error {
+	args := m.Called(attempt)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) FindWithPage(query *models.LoginAttemptQuery) ([]models.LoginAttempt, int64, error) {
+	args := m.Called(query)
+	return args.Get(0).([]models.LoginAttempt), args.Get(1).(int64), args.Error(2)
+}
+
+var _ repositories.LoginAttemptRepository = (*MockLoginAttemptRepository)(nil)
+
+func TestLockDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), lockDuration(4, 5))
+	assert.Equal(t, time.Minute, lockDuration(5, 5))
+	assert.Equal(t, 2*time.Minute, lockDuration(6, 5))
+	assert.Equal(t, 16*time.Minute, lockDuration(9, 5))
+	assert.Equal(t, 60*time.Minute, lockDuration(50, 5))
+}
+
+func TestLoginGuard(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	attemptRepo := new(MockLoginAttemptRepository)
+	attemptRepo.On("Create", mock.Anything).Return(nil)
+	guard := NewLoginGuardService(attemptRepo)
+	device := &models.DeviceInfo{IP: "203.0.113.7", UserAgent: "test"}
+	t.Cleanup(func() { guard.Unlock("Guard_User", device.IP) })
+
+	// 未达到阈值前不锁定
+	for i := 0; i < 4; i++ {
+		guard.RecordFailure("Guard_User", 1, device, "密码错误")
+	}
+	assert.NoError(t, guard.Check("guard_user", device))
+
+	// 达到阈值后锁定，用户名大小写变化也不能绕过
+	guard.RecordFailure("guard_user", 1, device, "密码错误")
+	err := guard.Check("GUARD_USER", &models.DeviceInfo{IP: "198.51.100.1"})
+	assert.True(t, errors.Is(err, ErrLoginLocked))
+
+	// 管理员解锁后可以再次尝试
+	guard.Unlock("guard_user", "")
+	assert.NoError(t, guard.Check("guard_user", device))
+
+	// 成功登录清零用户名计数
+	for i := 0; i < 4; i++ {
+		guard.RecordFailure("guard_user", 1, device, "密码错误")
+	}
+	guard.RecordSuccess("guard_user", 1, device)
+	guard.RecordFailure("guard_user", 1, device, "密码错误")
+	assert.NoError(t, guard.Check("guard_user", device))
+
+	// 失败、成功和锁定期内的尝试都会写入登录记录
+	attemptRepo.AssertNumberOfCalls(t, "Create", 12)
+}
+
+func TestLoginGuardSharedCounters(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	attemptRepo := new(MockLoginAttemptRepository)
+	attemptRepo.On("Create", mock.Anything).Return(nil)
+	// 两个实例共用同一个缓存，分散到各实例的失败同样累计
+	instances := []LoginGuardService{NewLoginGuardService(attemptRepo), NewLoginGuardService(attemptRepo)}
+	device := &models.DeviceInfo{IP: "203.0.113.30", UserAgent: "shared-test"}
+	t.Cleanup(func() { instances[0].Unlock("shared_user", device.IP) })
+
+	const failures = 40
+	var wg sync.WaitGroup
+	for i := 0; i < failures; i++ {
+		wg.Add(1)
+		go func(guard LoginGuardService) {
+			defer wg.Done()
+			guard.RecordFailure("shared_user", 1, device, "密码错误")
+		}(instances[i%2])
+	}
+	wg.Wait()
+	assert.Equal(t, failures, loadFailureCount(loginFailUserPrefix+"shared_user"))
+	assert.Equal(t, failures, loadFailureCount(loginFailIPPrefix+device.IP))
+	assert.ErrorIs(t, instances[1].Check("shared_user", nil), ErrLoginLocked)
+
+	// 不同实例同时记住的设备互不覆盖
+	instances[0].Unlock("shared_user", device.IP)
+	devices := []*models.DeviceInfo{{IP: "198.51.100.30", UserAgent: "first"}, {IP: "192.0.2.30", UserAgent: "second"}}
+	for i, d := range devices {
+		wg.Add(1)
+		go func(guard LoginGuardService, d *models.DeviceInfo) {
+			defer wg.Done()
+			guard.RecordSuccess("shared_user", 1, d)
+		}(instances[i], d)
+	}
+	wg.Wait()
+	known := instances[0].(*loginGuardService).knownDevices("shared_user")
+	assert.Contains(t, known, deviceFingerprint(devices[0]))
+	assert.Contains(t, known, deviceFingerprint(devices[1]))
+}
+
+func TestLoginRiskAssessment(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	attemptRepo := new(MockLoginAttemptRepository)
+	attemptRepo.On("Create", mock.Anything).Return(nil)
+	guard := NewLoginGuardService(attemptRepo)
+	device := &models.DeviceInfo{IP: "203.0.113.20", UserAgent: "risk-test"}
+	t.Cleanup(func() { guard.Unlock("risk_user", device.IP) })
+
+	// 新设备本身不足以要求验证码，再失败一次就需要
+	risk := guard.Assess(models.RiskSceneLogin, "risk_user", device)
+	assert.False(t, risk.CaptchaRequired)
+	assert.Contains(t, risk.Reasons, "new_device")
+	guard.RecordFailure("risk_user", 1, device, "密码错误")
+	assert.True(t, guard.Assess(models.RiskSceneLogin, "risk_user", device).CaptchaRequired)
+
+	// 登录成功后设备被记住，同一网段换 IP 也不算新设备
+	guard.RecordSuccess("risk_user", 1, device)
+	guard.Unlock("", device.IP)
+	risk = guard.Assess(models.RiskSceneLogin, "RISK_USER", &models.DeviceInfo{IP: "203.0.113.21", UserAgent: "risk-test"})
+	assert.Equal(t, 0, risk.Score)
+	assert.False(t, guard.Assess(models.RiskSceneLogin, "risk_user", device).CaptchaRequired)
+
+	// 请求过于频繁
+	for i := 0; i <= guardConfig().RiskVelocityPerMinute; i++ {
+		guard.TrackRequest(device)
+	}
+	risk = guard.Assess(models.RiskSceneRegister, "", device)
+	assert.Contains(t, risk.Reasons, "velocity")
+
+	// 高风险 IP 始终要求验证码，可信 IP 不计 IP 相关风险
+	original := config.AppConfig
+	config.AppConfig = &config.Config{Auth: guardConfig()}
+	config.AppConfig.Auth.RiskIPDenylist = "198.51.100.0/24"
+	config.AppConfig.Auth.RiskIPAllowlist = "203.0.113.20"
+	t.Cleanup(func() { config.AppConfig = original })
+	assert.True(t, guard.Assess(models.RiskSceneRegister, "", &models.DeviceInfo{IP: "198.51.100.9"}).CaptchaRequired)
+	assert.Equal(t, 0, guard.Assess(models.RiskSceneRegister, "", device).Score)
+
+	// 无法评估或需要验证码时必须提供有效验证码
+	assert.NoError(t, CheckCaptcha(&models.RiskAssessment{}, "", "", ""))
+	assert.Equal(t, ErrCaptchaRequired, CheckCaptcha(nil, "", "", ""))
+	assert.Equal(t, ErrCaptchaInvalid, CheckCaptcha(&models.RiskAssessment{CaptchaRequired: true}, "", "missing", "123456"))
+}
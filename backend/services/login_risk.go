@@ -0,0 +1,196 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gin-backend/models"
+	"gin-backend/utils"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 风险信号的分值，总分达到 RiskCaptchaThreshold 时要求验证码
+const (
+	riskUserFailure    = 20  // 该用户名每次连续失败
+	riskUserFailureMax = 60  // 用户名失败最多计分
+	riskIPFailure      = 10  // 该 IP 每次失败
+	riskIPFailureMax   = 40  // IP 失败最多计分
+	riskNewDevice      = 30  // 该账号从未在此设备登录成功过
+	riskVelocity       = 40  // 同一 IP 请求过于频繁
+	riskIPDenylisted   = 100 // 高风险 IP
+
+	loginVelocityPrefix = "login:velocity:"
+	loginDevicesPrefix  = "login:devices:"
+
+	// loginDeviceRetention 常用设备的保留时间，超过该时间未登录视为新设备
+	loginDeviceRetention = 90 * 24 * time.Hour
+	// loginDeviceMax 每个账号最多记住的设备数
+	loginDeviceMax = 20
+)
+
+var (
+	// ErrCaptchaRequired 本次请求风险较高，需要先完成验证码
+	ErrCaptchaRequired = errors.New("请先完成验证码")
+	// ErrCaptchaInvalid 验证码错误或已过期
+	ErrCaptchaInvalid = errors.New("验证码错误或已过期")
+)
+
+// TrackRequest 记录一次登录/注册请求，用于请求频率评估
+func (s *loginGuardService) TrackRequest(device *models.DeviceInfo) {
+	ip := deviceIP(device)
+	if ip == "" {
+		return
+	}
+
+	// 一分钟固定窗口计数，窗口内第一次请求时设置过期时间
+	key := loginVelocityPrefix + ip
+	if count, err := utils.CacheIncr(key); err == nil && count == 1 {
+		utils.CacheExpire(key, time.Minute)
+	}
+}
+
+// Assess 评估登录/注册请求的风险：失败次数、IP 名单、新设备和请求频率，决定是否需要验证码
+func (s *loginGuardService) Assess(scene, username string, device *models.DeviceInfo) *models.RiskAssessment {
+	cfg := guardConfig()
+	ip := deviceIP(device)
+	risk := &models.RiskAssessment{}
+	add := func(score int, reason string) {
+		risk.Score += score
+		risk.Reasons = append(risk.Reasons, reason)
+	}
+
+	trusted := ipInList(ip, cfg.RiskIPAllowlist)
+	if !trusted {
+		if ipInList(ip, cfg.RiskIPDenylist) {
+			add(riskIPDenylisted, "ip_denylisted")
+		}
+		if ip != "" {
+			if count := loadFailureCount(loginFailIPPrefix + ip); count > 0 {
+				add(min(count*riskIPFailure, riskIPFailureMax), "ip_failures")
+			}
+			var velocity int
+			utils.CacheGet(loginVelocityPrefix+ip, &velocity)
+			if cfg.RiskVelocityPerMinute > 0 && velocity > cfg.RiskVelocityPerMinute {
+				add(riskVelocity, "velocity")
+			}
+		}
+	}
+
+	if scene == models.RiskSceneLogin && username != "" {
+		if count := loadFailureCount(loginFailUserPrefix + normalizeUsername(username)); count > 0 {
+			add(min(count*riskUserFailure, riskUserFailureMax), "user_failures")
+		}
+		if _, known := s.knownDevices(username)[deviceFingerprint(device)]; !known {
+			add(riskNewDevice, "new_device")
+		}
+	}
+
+	risk.CaptchaRequired = risk.Score >= cfg.RiskCaptchaThreshold
+	return risk
+}
+
+// CheckCaptcha 按风险评估结果校验验证码，risk 为 nil（无法评估）时始终要求验证码
+func CheckCaptcha(risk *models.RiskAssessment, captchaType, captchaID, captcha string) error {
+	if risk != nil && !risk.CaptchaRequired {
+		return nil
+	}
+	if captchaID == "" || captcha == "" {
+		return ErrCaptchaRequired
+	}
+	if !utils.VerifyCaptchaByType(captchaType, captchaID, captcha) {
+		return ErrCaptchaInvalid
+	}
+	return nil
+}
+
+// rememberDevice 登录成功后记住该设备。每个设备是哈希中的一个字段（指纹 -> 最近登录的秒级时间戳），
+// 各实例并发登录时互不覆盖；超出上限时淘汰最久未使用的设备
+func (s *loginGuardService) rememberDevice(username string, device *models.DeviceInfo) {
+	key := loginDevicesPrefix + normalizeUsername(username)
+	if err := utils.CacheHSet(key, deviceFingerprint(device), time.Now().Unix()); err != nil {
+		return
+	}
+	utils.CacheExpire(key, loginDeviceRetention)
+
+	devices := s.knownDevices(username)
+	fields, err := utils.CacheHGetAll(key)
+	if err != nil {
+		return
+	}
+	var stale []string
+	for fp := range fields {
+		if _, ok := devices[fp]; !ok {
+			stale = append(stale, fp)
+		}
+	}
+	if len(devices) > loginDeviceMax {
+		fingerprints := make([]string, 0, len(devices))
+		for fp := range devices {
+			fingerprints = append(fingerprints, fp)
+		}
+		sort.Slice(fingerprints, func(i, j int) bool { return devices[fingerprints[i]].Before(devices[fingerprints[j]]) })
+		stale = append(stale, fingerprints[:len(devices)-loginDeviceMax]...)
+	}
+	if len(stale) > 0 {
+		utils.CacheHDel(key, stale...)
+	}
+}
+
+// knownDevices 读取账号的常用设备（指纹 -> 最近登录时间），已过期的不计入
+func (s *loginGuardService) knownDevices(username string) map[string]time.Time {
+	fields, _ := utils.CacheHGetAll(loginDevicesPrefix + normalizeUsername(username))
+	devices := make(map[string]time.Time, len(fields))
+	for fp, value := range fields {
+		unix, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		if seen := time.Unix(unix, 0); time.Since(seen) <= loginDeviceRetention {
+			devices[fp] = seen
+		}
+	}
+	return devices
+}
+
+// deviceFingerprint 设备指纹：User-Agent 加 IP 网段（IPv4 /24、IPv6 /48），同一网络内换 IP 不算新设备
+func deviceFingerprint(device *models.DeviceInfo) string {
+	var ua, network string
+	if device != nil {
+		ua = device.UserAgent
+		if ip := net.ParseIP(device.IP); ip != nil {
+			if v4 := ip.To4(); v4 != nil {
+				network = v4.Mask(net.CIDRMask(24, 32)).String()
+			} else {
+				network = ip.Mask(net.CIDRMask(48, 128)).String()
+			}
+		}
+	}
+	sum := sha256.Sum256([]byte(ua + "|" + network))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ipInList 判断 IP 是否在逗号分隔的 IP/网段列表中
+func ipInList(ip, list string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil || list == "" {
+		return false
+	}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(addr) {
+				return true
+			}
+		} else if other := net.ParseIP(entry); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
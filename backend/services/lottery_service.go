@@ -16,13 +16,14 @@ type LotteryService interface {
 }
 
 type lotteryService struct {
-	repo repositories.LotteryRepository
+	repo     repositories.LotteryRepository
+	notifier NotificationService
 }
 
-func NewLotteryService(repo repositories.LotteryRepository) LotteryService {
+func NewLotteryService(repo repositories.LotteryRepository, notifier NotificationService) LotteryService {
 	// 初始化随机种子
 	rand.Seed(time.Now().UnixNano())
-	return &lotteryService{repo: repo}
+	return &lotteryService{repo: repo, notifier: notifier}
 }
 
 func (s *lotteryService) GetLotteryInfo(userID uint) (map[string]interface{}, error) {
@@ -170,6 +171,14 @@ func (s *lotteryService) recordDraw(userID uint, activityID uint, prize *models.
 		CreatedAt:  time.Now(),
 	}
 	_ = s.repo.CreateRecord(record)
+
+	// 中奖时发送微信通知
+	if record.IsHit && s.notifier != nil {
+		s.notifier.Notify(userID, models.NotifyLotteryWin, map[string]string{
+			"prize": prize.Name,
+			"time":  record.CreatedAt.Format("2006-01-02 15:04:05"),
+		}, "")
+	}
 }
 
 func (s *lotteryService) GetUserRecords(userID uint, activityID uint) ([]models.LotteryRecord, error) {
@@ -35,15 +35,16 @@ func NewMenuService(menuRepo repositories.MenuRepository, userRepo repositories.
 // CreateMenu 创建菜单
 func (s *menuService) CreateMenu(req *models.MenuCreateRequest) error {
 	menu := &models.Menu{
-		ParentID:  req.ParentID,
-		Name:      req.Name,
-		Path:      req.Path,
-		Component: req.Component,
-		Icon:      req.Icon,
-		Sort:      req.Sort,
-		Type:      req.Type,
-		Hidden:    req.Hidden,
-		Status:    1,
+		ParentID:   req.ParentID,
+		Name:       req.Name,
+		Path:       req.Path,
+		Component:  req.Component,
+		Icon:       req.Icon,
+		Sort:       req.Sort,
+		Type:       req.Type,
+		Permission: req.Permission,
+		Hidden:     req.Hidden,
+		Status:     1,
 	}
 
 	// 创建菜单
@@ -58,6 +59,7 @@ func (s *menuService) CreateMenu(req *models.MenuCreateRequest) error {
 		// 使用 GORM Association Append 添加菜单到角色
 		// 这会自动在 role_menus 中间表中创建关联记录
 		_ = s.roleRepo.GetDB().Model(superAdminRole).Association("Menus").Append(menu)
+		invalidateRolePermissions(superAdminRole.ID)
 	}
 
 	return nil
@@ -91,6 +93,9 @@ func (s *menuService) UpdateMenu(id uint, req *models.MenuUpdateRequest) error {
 	if req.Type != nil {
 		menu.Type = *req.Type
 	}
+	if req.Permission != nil {
+		menu.Permission = *req.Permission
+	}
 	if req.Status != nil {
 		menu.Status = *req.Status
 	}
@@ -98,12 +103,33 @@ func (s *menuService) UpdateMenu(id uint, req *models.MenuUpdateRequest) error {
 		menu.Hidden = *req.Hidden
 	}
 
-	return s.menuRepo.Update(menu)
+	if err := s.menuRepo.Update(menu); err != nil {
+		return err
+	}
+	s.invalidateAllRolePermissions()
+	return nil
 }
 
 // DeleteMenu 删除菜单
 func (s *menuService) DeleteMenu(id uint) error {
-	return s.menuRepo.Delete(id)
+	if err := s.menuRepo.Delete(id); err != nil {
+		return err
+	}
+	s.invalidateAllRolePermissions()
+	return nil
+}
+
+// invalidateAllRolePermissions 菜单的权限标识或状态变化会影响所有角色，清除全部角色的权限缓存
+func (s *menuService) invalidateAllRolePermissions() {
+	roles, err := s.roleRepo.FindAll()
+	if err != nil {
+		return
+	}
+	ids := make([]uint, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+	invalidateRolePermissions(ids...)
 }
 
 // GetMenuByID 根据ID获取菜单
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/silenceper/wechat/v2/officialaccount/message"
+)
+
+const (
+	// notifyMaxAttempts 模板消息最多投递次数
+	notifyMaxAttempts = 3
+	// notifyBackoff 首次重试前的等待时间，之后每次翻倍
+	notifyBackoff = 2 * time.Second
+)
+
+var errNotifyEvent = errors.New("不支持的通知类型")
+
+// wechatErrCode 从微信接口错误中提取 errcode
+var wechatErrCode = regexp.MustCompile(`errcode=(\d+)`)
+
+// wechatPermanentErrors 重试也不会成功的微信错误码
+var wechatPermanentErrors = map[int]bool{
+	40003: true, // OpenID 无效
+	40036: true, // 模板ID无效
+	40037: true, // 模板ID无效
+	43004: true, // 用户未关注公众号
+	43101: true, // 用户拒绝接收消息
+	47003: true, // 模板参数不正确
+}
+
+// WechatTemplateSender 微信模板消息发送接口，由 WechatService 实现
+type WechatTemplateSender interface {
+	SendTemplate(msg *message.TemplateMessage) (int64, error)
+}
+
+// NotificationService 微信模板消息通知服务接口
+type NotificationService interface {
+	// Notify 异步发送通知，不阻塞业务流程
+	Notify(userID uint, event string, data map[string]string, link string)
+	// Send 同步发送通知（含重试），用户未绑定微信、已关闭该通知或未配置模板时返回 nil
+	Send(userID uint, event string, data map[string]string, link string) (*models.NotificationLog, error)
+	GetSettings(userID uint) ([]models.NotificationSetting, error)
+	UpdateSetting(userID uint, setting *models.NotificationSetting) error
+	ListLogs(query *models.NotificationLogQuery) (*models.PageResponse, error)
+}
+
+// notificationService 微信模板消息通知服务实现
+type notificationService struct {
+	sender       WechatTemplateSender
+	repo         repositories.NotificationRepository
+	identityRepo repositories.UserIdentityRepository
+	backoff      time.Duration
+}
+
+// NewNotificationService 创建通知服务实例
+func NewNotificationService(sender WechatTemplateSender, repo repositories.NotificationRepository, identityRepo repositories.UserIdentityRepository) NotificationService {
+	return &notificationService{
+		sender:       sender,
+		repo:         repo,
+		identityRepo: identityRepo,
+		backoff:      notifyBackoff,
+	}
+}
+
+// notifyTemplateID 通知事件对应的模板消息ID
+func notifyTemplateID(event string) string {
+	switch event {
+	case models.NotifyLotteryWin:
+		return config.AppConfig.Wechat.TemplateLotteryWin
+	case models.NotifyOrderStatus:
+		return config.AppConfig.Wechat.TemplateOrderStatus
+	}
+	return ""
+}
+
+// Notify 异步发送通知
+func (s *notificationService) Notify(userID uint, event string, data map[string]string, link string) {
+	go func() {
+		if _, err := s.Send(userID, event, data, link); err != nil {
+			log.Printf("发送微信通知失败: user=%d event=%s err=%v", userID, event, err)
+		}
+	}()
+}
+
+// Send 同步发送通知，失败时按指数退避重试，每次投递结果写入投递记录
+func (s *notificationService) Send(userID uint, event string, data map[string]string, link string) (*models.NotificationLog, error) {
+	templateID := notifyTemplateID(event)
+	if templateID == "" {
+		return nil, nil
+	}
+
+	openID, err := s.openID(userID)
+	if err != nil || openID == "" {
+		return nil, err
+	}
+
+	optOuts, err := s.repo.FindOptOuts(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range optOuts {
+		if e == event {
+			return nil, nil
+		}
+	}
+
+	payload, _ := json.Marshal(data)
+	entry := &models.NotificationLog{
+		UserID:     userID,
+		OpenID:     openID,
+		Event:      event,
+		TemplateID: templateID,
+		Payload:    string(payload),
+		Status:     models.NotificationPending,
+	}
+	if err := s.repo.CreateLog(entry); err != nil {
+		return nil, err
+	}
+
+	msg := &message.TemplateMessage{
+		ToUser:     openID,
+		TemplateID: templateID,
+		URL:        link,
+		Data:       make(map[string]*message.TemplateDataItem, len(data)),
+	}
+	for k, v := range data {
+		msg.Data[k] = &message.TemplateDataItem{Value: v}
+	}
+
+	wait := s.backoff
+	for entry.Attempts < notifyMaxAttempts {
+		entry.Attempts++
+		msgID, err := s.sender.SendTemplate(msg)
+		if err == nil {
+			entry.Status = models.NotificationSent
+			entry.MsgID = msgID
+			entry.Error = ""
+			break
+		}
+
+		entry.Status = models.NotificationFailed
+		entry.Error = truncate(err.Error(), 500)
+		if isPermanentWechatError(err) || entry.Attempts >= notifyMaxAttempts {
+			break
+		}
+		s.repo.UpdateLog(entry)
+		time.Sleep(wait)
+		wait *= 2
+	}
+
+	if err := s.repo.UpdateLog(entry); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// openID 获取用户绑定的微信 OpenID，未绑定时返回空字符串
+func (s *notificationService) openID(userID uint) (string, error) {
+	identities, err := s.identityRepo.FindByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+	for _, identity := range identities {
+		if identity.Provider == WechatIdentityProvider {
+			return identity.Subject, nil
+		}
+	}
+	return "", nil
+}
+
+// GetSettings 获取用户各通知事件的开关
+func (s *notificationService) GetSettings(userID uint) ([]models.NotificationSetting, error) {
+	optOuts, err := s.repo.FindOptOuts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	disabled := make(map[string]bool, len(optOuts))
+	for _, e := range optOuts {
+		disabled[e] = true
+	}
+
+	settings := make([]models.NotificationSetting, 0, len(models.NotifyEvents))
+	for _, e := range models.NotifyEvents {
+		settings = append(settings, models.NotificationSetting{Event: e, Enabled: !disabled[e]})
+	}
+	return settings, nil
+}
+
+// UpdateSetting 开启或关闭某个通知事件
+func (s *notificationService) UpdateSetting(userID uint, setting *models.NotificationSetting) error {
+	for _, e := range models.NotifyEvents {
+		if e == setting.Event {
+			return s.repo.SetOptOut(userID, setting.Event, !setting.Enabled)
+		}
+	}
+	return errNotifyEvent
+}
+
+// ListLogs 分页查询投递记录
+func (s *notificationService) ListLogs(query *models.NotificationLogQuery) (*models.PageResponse, error) {
+	logs, total, err := s.repo.FindLogsWithPage(query)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPageResponse(query.GetPage(), query.GetPageSize(), total, logs), nil
+}
+
+// isPermanentWechatError 判断是否为重试也不会成功的错误
+func isPermanentWechatError(err error) bool {
+	if errors.Is(err, errWechatNotConfigured) {
+		return true
+	}
+	if m := wechatErrCode.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return wechatPermanentErrors[code]
+	}
+	return false
+}
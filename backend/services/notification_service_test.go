@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/repositories"
+
+	"github.com/silenceper/wechat/v2/officialaccount/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOfficialAccount 模拟公众号模板消息接口，前 failures 次调用返回 err
+type fakeOfficialAccount struct {
+	failures int
+	err      error
+	calls    int
+	sent     []*message.TemplateMessage
+}
+
+func (f *fakeOfficialAccount) SendTemplate(msg *message.TemplateMessage) (int64, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return 0, f.err
+	}
+	f.sent = append(f.sent, msg)
+	return int64(1000 + f.calls), nil
+}
+
+// fakeNotificationRepository 内存版通知仓储
+type fakeNotificationRepository struct {
+	optOuts map[uint][]string
+	logs    []*models.NotificationLog
+	updates int
+}
+
+func (r *fakeNotificationRepository) FindOptOuts(userID uint) ([]string, error) {
+	return r.optOuts[userID], nil
+}
+
+func (r *fakeNotificationRepository) SetOptOut(userID uint, event string, optOut bool) error {
+	if r.optOuts == nil {
+		r.optOuts = make(map[uint][]string)
+	}
+	kept := []string{}
+	for _, e := range r.optOuts[userID] {
+		if e != event {
+			kept = append(kept, e)
+		}
+	}
+	if optOut {
+		kept = append(kept, event)
+	}
+	r.optOuts[userID] = kept
+	return nil
+}
+
+func (r *fakeNotificationRepository) CreateLog(log *models.NotificationLog) error {
+	log.ID = uint(len(r.logs) + 1)
+	r.logs = append(r.logs, log)
+	return nil
+}
+
+func (r *fakeNotificationRepository) UpdateLog(log *models.NotificationLog) error {
+	r.updates++
+	return nil
+}
+
+func (r *fakeNotificationRepository) FindLogsWithPage(query *models.NotificationLogQuery) ([]models.NotificationLog, int64, error) {
+	return nil, 0, nil
+}
+
+var _ repositories.NotificationRepository = (*fakeNotificationRepository)(nil)
+
+func TestNotificationSend(t *testing.T) {
+	config.AppConfig.Wechat.TemplateLotteryWin = "tpl-lottery"
+	t.Cleanup(func() { config.AppConfig.Wechat.TemplateLotteryWin = "" })
+
+	identityRepo := &fakeUserIdentityRepository{}
+	identityRepo.Create(&models.UserIdentity{UserID: 9501, Provider: WechatIdentityProvider, Subject: "openid-notify"})
+	repo := &fakeNotificationRepository{}
+	oa := &fakeOfficialAccount{failures: 2, err: errors.New("template msg send error : errcode=-1 , errmsg=system busy")}
+	service := NewNotificationService(oa, repo, identityRepo).(*notificationService)
+	service.backoff = 0
+
+	// 临时错误重试后成功
+	entry, err := service.Send(9501, models.NotifyLotteryWin, map[string]string{"prize": "一等奖"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.NotificationSent, entry.Status)
+	assert.Equal(t, 3, entry.Attempts)
+	assert.Equal(t, int64(1003), entry.MsgID)
+	assert.Len(t, oa.sent, 1)
+	assert.Equal(t, "openid-notify", oa.sent[0].ToUser)
+	assert.Equal(t, "tpl-lottery", oa.sent[0].TemplateID)
+	assert.Equal(t, "一等奖", oa.sent[0].Data["prize"].Value)
+
+	// 永久错误（用户未关注）不重试
+	oa.calls, oa.failures = 0, 10
+	oa.err = errors.New("template msg send error : errcode=43004 , errmsg=require subscribe")
+	entry, err = service.Send(9501, models.NotifyLotteryWin, map[string]string{"prize": "二等奖"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.NotificationFailed, entry.Status)
+	assert.Equal(t, 1, entry.Attempts)
+	assert.Contains(t, entry.Error, "43004")
+
+	// 临时错误达到最大次数后放弃
+	oa.calls = 0
+	oa.err = errors.New("timeout")
+	entry, _ = service.Send(9501, models.NotifyLotteryWin, nil, "")
+	assert.Equal(t, models.NotificationFailed, entry.Status)
+	assert.Equal(t, notifyMaxAttempts, entry.Attempts)
+
+	// 关闭通知后不再发送
+	oa.calls, oa.failures = 0, 0
+	assert.NoError(t, service.UpdateSetting(9501, &models.NotificationSetting{Event: models.NotifyLotteryWin, Enabled: false}))
+	entry, err = service.Send(9501, models.NotifyLotteryWin, nil, "")
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+	assert.Equal(t, 0, oa.calls)
+
+	settings, _ := service.GetSettings(9501)
+	assert.Contains(t, settings, models.NotificationSetting{Event: models.NotifyLotteryWin, Enabled: false})
+	assert.Contains(t, settings, models.NotificationSetting{Event: models.NotifyOrderStatus, Enabled: true})
+	assert.Equal(t, errNotifyEvent, service.UpdateSetting(9501, &models.NotificationSetting{Event: "unknown"}))
+
+	// 未绑定微信或未配置模板时不发送
+	entry, err = service.Send(9502, models.NotifyOrderStatus, nil, "")
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+	entry, _ = service.Send(9501, models.NotifyOrderStatus, nil, "")
+	assert.Nil(t, entry)
+	assert.Len(t, repo.logs, 3)
+}
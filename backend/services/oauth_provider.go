@@ -0,0 +1,468 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-backend/config"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OAuthUserInfo 第三方账号信息（各提供方映射为统一字段）
+type OAuthUserInfo struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+	Avatar        string
+}
+
+// OAuthProvider 第三方登录提供方（授权码模式 + PKCE）
+type OAuthProvider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, codeChallenge, nonce string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OAuthUserInfo, error)
+}
+
+// oauthEndpoints 提供方端点
+type oauthEndpoints struct {
+	Issuer      string `json:"issuer"`
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+}
+
+// oauthUserMapper 将令牌和用户信息映射为统一的账号信息
+type oauthUserMapper func(ctx context.Context, p *oauth2Provider, accessToken string, claims map[string]interface{}) (*OAuthUserInfo, error)
+
+// oauth2Provider 通用 OAuth2/OIDC 提供方实现
+type oauth2Provider struct {
+	name         string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	issuer       string // 非空时按 OIDC 处理：自动发现端点并校验 ID Token
+	mapUser      oauthUserMapper
+	httpClient   *http.Client
+
+	mu        sync.Mutex
+	endpoints *oauthEndpoints
+	keys      map[string]crypto.PublicKey
+}
+
+// NewOIDCProvider 创建通用 OIDC 提供方，端点通过 {issuer}/.well-known/openid-configuration 自动发现
+func NewOIDCProvider(name, issuer, clientID, clientSecret, redirectURL string, scopes []string) OAuthProvider {
+	return &oauth2Provider{
+		name:         name,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		mapUser:      mapOIDCUser,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewGoogleProvider 创建 Google 登录提供方
+func NewGoogleProvider(clientID, clientSecret, redirectURL string) OAuthProvider {
+	return NewOIDCProvider("google", "https://accounts.google.com", clientID, clientSecret, redirectURL,
+		[]string{"openid", "profile", "email"})
+}
+
+// NewGitHubProvider 创建 GitHub 登录提供方（GitHub 不支持 OIDC，使用固定端点和 REST 接口获取用户信息）
+func NewGitHubProvider(clientID, clientSecret, redirectURL string) OAuthProvider {
+	return &oauth2Provider{
+		name:         "github",
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       []string{"read:user", "user:email"},
+		mapUser:      mapGitHubUser,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		endpoints: &oauthEndpoints{
+			AuthURL:     "https://github.com/login/oauth/authorize",
+			TokenURL:    "https://github.com/login/oauth/access_token",
+			UserInfoURL: "https://api.github.com/user",
+		},
+	}
+}
+
+// NewOAuthProviders 根据配置创建已启用的提供方
+func NewOAuthProviders(cfg config.OAuthConfig) map[string]OAuthProvider {
+	redirect := func(name string) string {
+		return fmt.Sprintf("%s/api/v1/auth/oauth/%s/callback", strings.TrimSuffix(cfg.RedirectBaseURL, "/"), name)
+	}
+
+	providers := make(map[string]OAuthProvider)
+	if cfg.GitHubClientID != "" {
+		providers["github"] = NewGitHubProvider(cfg.GitHubClientID, cfg.GitHubClientSecret, redirect("github"))
+	}
+	if cfg.GoogleClientID != "" {
+		providers["google"] = NewGoogleProvider(cfg.GoogleClientID, cfg.GoogleClientSecret, redirect("google"))
+	}
+	if cfg.OIDCIssuer != "" && cfg.OIDCClientID != "" {
+		providers[cfg.OIDCName] = NewOIDCProvider(cfg.OIDCName, cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCClientSecret,
+			redirect(cfg.OIDCName), strings.Fields(cfg.OIDCScopes))
+	}
+	return providers
+}
+
+// Name 提供方标识
+func (p *oauth2Provider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL 生成跳转到提供方的授权地址
+func (p *oauth2Provider) AuthCodeURL(ctx context.Context, state, codeChallenge, nonce string) (string, error) {
+	endpoints, err := p.getEndpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", p.redirectURL)
+	params.Set("scope", strings.Join(p.scopes, " "))
+	params.Set("state", state)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+	if p.issuer != "" {
+		params.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(endpoints.AuthURL, "?") {
+		sep = "&"
+	}
+	return endpoints.AuthURL + sep + params.Encode(), nil
+}
+
+// Exchange 用授权码换取令牌并获取用户信息
+func (p *oauth2Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OAuthUserInfo, error) {
+	endpoints, err := p.getEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("client_id", p.clientID)
+	form.Set("client_secret", p.clientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("换取令牌失败: %w", err)
+	}
+	if token.Error != "" || token.AccessToken == "" {
+		return nil, fmt.Errorf("换取令牌失败: %s %s", token.Error, token.ErrorDescription)
+	}
+
+	// OIDC：校验 ID Token，其中的声明优先于 userinfo 接口返回的数据
+	claims := map[string]interface{}{}
+	if p.issuer != "" {
+		if token.IDToken == "" {
+			return nil, errors.New("提供方未返回 ID Token")
+		}
+		if claims, err = p.verifyIDToken(ctx, token.IDToken, nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.mapUser(ctx, p, token.AccessToken, claims)
+}
+
+// getEndpoints 获取端点，OIDC 提供方首次使用时自动发现
+func (p *oauth2Provider) getEndpoints(ctx context.Context) (*oauthEndpoints, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.endpoints != nil {
+		return p.endpoints, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var endpoints oauthEndpoints
+	if err := p.doJSON(req, &endpoints); err != nil {
+		return nil, fmt.Errorf("OIDC 发现失败: %w", err)
+	}
+	if strings.TrimSuffix(endpoints.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("OIDC 发现失败: issuer 不匹配 %s", endpoints.Issuer)
+	}
+	if endpoints.AuthURL == "" || endpoints.TokenURL == "" || endpoints.JWKSURL == "" {
+		return nil, errors.New("OIDC 发现失败: 缺少必要端点")
+	}
+
+	p.endpoints = &endpoints
+	return p.endpoints, nil
+}
+
+// verifyIDToken 校验 ID Token 的签名、签发方、受众、有效期和 nonce
+func (p *oauth2Provider) verifyIDToken(ctx context.Context, idToken, nonce string) (map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ID Token 校验失败: %w", err)
+	}
+	if claimString(claims, "nonce") != nonce {
+		return nil, errors.New("ID Token 校验失败: nonce 不匹配")
+	}
+	return claims, nil
+}
+
+// publicKey 按 kid 获取提供方公钥，找不到时重新拉取一次 JWKS（提供方可能已轮换密钥）
+func (p *oauth2Provider) publicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	endpoints, err := p.getEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoints.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("获取 JWKS 失败: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("未找到签名公钥 %s", kid)
+}
+
+// getJSON 携带访问令牌请求提供方接口
+func (p *oauth2Provider) getJSON(ctx context.Context, rawURL, accessToken string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return p.doJSON(req, dest)
+}
+
+// doJSON 发送请求并解析 JSON 响应
+func (p *oauth2Provider) doJSON(req *http.Request, dest interface{}) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// 令牌接口出错时通常也返回 JSON（error 字段），交由调用方判断
+	if resp.StatusCode >= 500 || (resp.StatusCode >= 300 && !json.Valid(body)) {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, dest)
+}
+
+// mapOIDCUser 按 OIDC 标准声明映射用户信息，ID Token 缺失的字段从 userinfo 接口补充
+func mapOIDCUser(ctx context.Context, p *oauth2Provider, accessToken string, claims map[string]interface{}) (*OAuthUserInfo, error) {
+	endpoints, err := p.getEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if endpoints.UserInfoURL != "" {
+		userinfo := map[string]interface{}{}
+		if err := p.getJSON(ctx, endpoints.UserInfoURL, accessToken, &userinfo); err != nil {
+			return nil, fmt.Errorf("获取用户信息失败: %w", err)
+		}
+		// userinfo 的 sub 必须与 ID Token 一致，防止令牌替换
+		if sub := claimString(userinfo, "sub"); sub != claimString(claims, "sub") {
+			return nil, errors.New("获取用户信息失败: sub 不匹配")
+		}
+		for k, v := range userinfo {
+			if _, ok := claims[k]; !ok {
+				claims[k] = v
+			}
+		}
+	}
+
+	info := &OAuthUserInfo{
+		Subject:       claimString(claims, "sub"),
+		Email:         claimString(claims, "email"),
+		EmailVerified: claimBool(claims, "email_verified"),
+		Name:          claimString(claims, "name"),
+		Username:      claimString(claims, "preferred_username"),
+		Avatar:        claimString(claims, "picture"),
+	}
+	if info.Subject == "" {
+		return nil, errors.New("提供方未返回用户标识")
+	}
+	return info, nil
+}
+
+// mapGitHubUser 通过 GitHub REST 接口获取用户信息和已验证的主邮箱
+func mapGitHubUser(ctx context.Context, p *oauth2Provider, accessToken string, _ map[string]interface{}) (*OAuthUserInfo, error) {
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := p.getJSON(ctx, p.endpoints.UserInfoURL, accessToken, &user); err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	if user.ID == 0 {
+		return nil, errors.New("提供方未返回用户标识")
+	}
+
+	info := &OAuthUserInfo{
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+		Username: user.Login,
+		Avatar:   user.AvatarURL,
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.endpoints.UserInfoURL+"/emails", accessToken, &emails); err == nil {
+		for _, e := range emails {
+			if e.Primary {
+				info.Email = e.Email
+				info.EmailVerified = e.Verified
+				break
+			}
+		}
+	}
+	return info, nil
+}
+
+// oidcJWK 提供方 JWKS 中的一把公钥
+type oidcJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey 解析 RSA 或 P-256 公钥
+func (k oidcJWK) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("不支持的曲线 %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型 %s", k.Kty)
+}
+
+// claimString 读取字符串声明
+func claimString(claims map[string]interface{}, key string) string {
+	v, _ := claims[key].(string)
+	return v
+}
+
+// claimBool 读取布尔声明（部分提供方以字符串 "true" 返回）
+func claimBool(claims map[string]interface{}, key string) bool {
+	switch v := claims[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
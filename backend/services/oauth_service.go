@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// oauthStateExpiration 授权请求（state）有效期
+	oauthStateExpiration = 10 * time.Minute
+	// oauthTicketExpiration 回调后换取登录结果的票据有效期
+	oauthTicketExpiration = time.Minute
+	// oauthExchangeTimeout 回调时请求提供方接口的超时时间
+	oauthExchangeTimeout = 15 * time.Second
+)
+
+var (
+	errOAuthProvider      = errors.New("不支持的登录方式")
+	errOAuthState         = errors.New("授权请求无效或已过期，请重新登录")
+	errOAuthIdentityTaken = errors.New("该第三方账号已绑定其他用户")
+	errOAuthEmailExists   = errors.New("该邮箱已注册，请使用密码登录后在个人中心绑定第三方账号")
+	errOAuthNotLinked     = errors.New("未绑定该第三方账号")
+)
+
+// oauthState 发起授权时保存的上下文，回调时凭 state 取回
+type oauthState struct {
+	Provider     string            `json:"provider"`
+	CodeVerifier string            `json:"code_verifier"`
+	Nonce        string            `json:"nonce"`
+	LinkUserID   uint              `json:"link_user_id,omitempty"` // 非零表示已登录用户绑定账号
+	Device       models.DeviceInfo `json:"device"`
+}
+
+// OAuthCallbackResult 授权回调处理结果
+type OAuthCallbackResult struct {
+	Provider string
+	Ticket   string // 登录票据，前端凭此换取登录结果
+	Linked   bool   // 绑定流程，无需登录
+}
+
+// OAuthService 第三方登录服务接口
+type OAuthService interface {
+	Providers() []string
+	AuthURL(provider string, linkUserID uint, device *models.DeviceInfo) (authURL, binding string, err error)
+	HandleCallback(provider, state, binding, code string) (*OAuthCallbackResult, error)
+	Exchange(ticket string) (*models.LoginResponse, error)
+	ListIdentities(userID uint) ([]models.UserIdentity, error)
+	Unlink(userID uint, provider string, operator *models.Operator) error
+}
+
+// oauthService 第三方登录服务实现
+type oauthService struct {
+	providers    map[string]OAuthProvider
+	identityRepo repositories.UserIdentityRepository
+	userRepo     repositories.UserRepository
+	roleRepo     repositories.RoleRepository
+	userService  UserService
+}
+
+// NewOAuthService 创建第三方登录服务实例
+func NewOAuthService(providers map[string]OAuthProvider, identityRepo repositories.UserIdentityRepository, userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, userService UserService) OAuthService {
+	return &oauthService{
+		providers:    providers,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		userService:  userService,
+	}
+}
+
+// Providers 已启用的提供方列表
+func (s *oauthService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AuthURL 生成授权地址，state 和 PKCE code_verifier 保存在服务端。
+// binding 为 state 的摘要，由控制器写入发起授权的浏览器 Cookie，回调时原样传回
+func (s *oauthService) AuthURL(provider string, linkUserID uint, device *models.DeviceInfo) (string, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", errOAuthProvider
+	}
+
+	verifier, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload := oauthState{Provider: provider, CodeVerifier: verifier, Nonce: nonce, LinkUserID: linkUserID}
+	if device != nil {
+		payload.Device = *device
+	}
+	state, err := utils.IssueActionToken(utils.TokenPurposeOAuthState, payload, oauthStateExpiration)
+	if err != nil {
+		return "", "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oauthExchangeTimeout)
+	defer cancel()
+	authURL, err := p.AuthCodeURL(ctx, state, pkceChallenge(verifier), nonce)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, oauthStateBinding(state), nil
+}
+
+// HandleCallback 处理授权回调：换取用户信息后绑定账号或登录。
+// binding 必须与发起授权时返回的一致，防止把别人的授权回调地址诱导给当前浏览器打开（登录 CSRF）
+func (s *oauthService) HandleCallback(provider, state, binding, code string) (*OAuthCallbackResult, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, errOAuthProvider
+	}
+	if subtle.ConstantTimeCompare([]byte(binding), []byte(oauthStateBinding(state))) != 1 {
+		return nil, errOAuthState
+	}
+
+	var payload oauthState
+	if err := utils.ConsumeActionToken(utils.TokenPurposeOAuthState, state, &payload); err != nil || payload.Provider != provider {
+		return nil, errOAuthState
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oauthExchangeTimeout)
+	defer cancel()
+	info, err := p.Exchange(ctx, code, payload.CodeVerifier, payload.Nonce)
+	if err != nil {
+		log.Printf("第三方登录失败: provider=%s err=%v", provider, err)
+		return nil, errors.New("第三方登录失败，请重试")
+	}
+
+	identity, err := s.identityRepo.FindByProviderSubject(provider, info.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.LinkUserID > 0 {
+		if err := s.link(payload.LinkUserID, provider, info, identity, &payload.Device); err != nil {
+			return nil, err
+		}
+		return &OAuthCallbackResult{Provider: provider, Linked: true}, nil
+	}
+
+	var user *models.User
+	if identity != nil {
+		if user, err = s.userRepo.FindByID(identity.UserID); err != nil || user == nil {
+			return nil, errors.New("用户不存在")
+		}
+	} else if user, err = s.register(provider, info); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.login(user, provider, &payload.Device)
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := utils.IssueActionToken(utils.TokenPurposeOAuthTicket, resp, oauthTicketExpiration)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthCallbackResult{Provider: provider, Ticket: ticket}, nil
+}
+
+// Exchange 使用一次性票据换取登录结果（令牌不通过回调地址传递，避免泄露到浏览器历史和日志）
+func (s *oauthService) Exchange(ticket string) (*models.LoginResponse, error) {
+	var resp models.LoginResponse
+	if err := utils.ConsumeActionToken(utils.TokenPurposeOAuthTicket, ticket, &resp); err != nil {
+		return nil, errOAuthState
+	}
+	return &resp, nil
+}
+
+// ListIdentities 获取用户已绑定的第三方账号
+func (s *oauthService) ListIdentities(userID uint) ([]models.UserIdentity, error) {
+	return s.identityRepo.FindByUserID(userID)
+}
+
+// Unlink 解绑第三方账号
+func (s *oauthService) Unlink(userID uint, provider string, operator *models.Operator) error {
+	deleted, err := s.identityRepo.Delete(userID, provider)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errOAuthNotLinked
+	}
+
+	recordOperatorEvent(operator, &models.SecurityEvent{
+		UserID: userID,
+		Type:   models.SecurityEventIdentityRemoved,
+		Detail: provider,
+	})
+	return nil
+}
+
+// link 将第三方账号绑定到已登录用户
+func (s *oauthService) link(userID uint, provider string, info *OAuthUserInfo, identity *models.UserIdentity, device *models.DeviceInfo) error {
+	if identity != nil {
+		if identity.UserID != userID {
+			return errOAuthIdentityTaken
+		}
+		return nil // 已绑定
+	}
+
+	if err := s.identityRepo.Create(newUserIdentity(userID, provider, info)); err != nil {
+		return err
+	}
+
+	utils.EmitSecurityEvent(&models.SecurityEvent{
+		UserID:     userID,
+		Type:       models.SecurityEventIdentityLinked,
+		OperatorID: userID,
+		IP:         device.IP,
+		UserAgent:  device.UserAgent,
+		Detail:     provider,
+	})
+	return nil
+}
+
+// register 首次使用第三方账号登录时自动注册用户
+func (s *oauthService) register(provider string, info *OAuthUserInfo) (*models.User, error) {
+	// 邮箱已被本地账号占用时不自动合并，防止通过未验证邮箱的第三方账号接管他人账号
+	email := info.Email
+	if email != "" {
+		existing, err := s.userRepo.FindByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, errOAuthEmailExists
+		}
+	} else {
+		email = fmt.Sprintf("%s_%s@oauth.invalid", provider, info.Subject)
+	}
+
+	username, err := s.uniqueUsername(provider, info)
+	if err != nil {
+		return nil, err
+	}
+	// 第三方注册用户没有可用的初始密码，可通过忘记密码设置
+	password, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	nickname := info.Name
+	if nickname == "" {
+		nickname = username
+	}
+	created, err := s.userService.CreateUser(&models.UserCreateRequest{
+		Username: username,
+		Email:    email,
+		Password: password,
+		Nickname: truncate(nickname, 50),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(created.ID)
+	if err != nil || user == nil {
+		return nil, errors.New("用户不存在")
+	}
+	updated := false
+	if info.EmailVerified && info.Email != "" {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		updated = true
+	}
+	if info.Avatar != "" && len(info.Avatar) <= 500 {
+		user.Avatar = info.Avatar
+		updated = true
+	}
+	if updated {
+		if err := s.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+		invalidateUserProfile(user.ID)
+	}
+
+	if err := s.identityRepo.Create(newUserIdentity(user.ID, provider, info)); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// uniqueUsername 根据第三方账号信息生成可用的用户名（3-20 位字母数字）
+func (s *oauthService) uniqueUsername(provider string, info *OAuthUserInfo) (string, error) {
+	base := info.Username
+	if base == "" {
+		base, _, _ = strings.Cut(info.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, base)
+	if len(base) < 3 {
+		base = provider + base
+	}
+	if len(base) > 14 {
+		base = base[:14]
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		existing, err := s.userRepo.FindByUsername(candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+		suffix, err := randomHex(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + suffix
+	}
+	return "", errors.New("生成用户名失败，请重试")
+}
+
+// login 第三方账号登录，已启用或角色要求两步验证时返回登录挑战
+func (s *oauthService) login(user *models.User, provider string, device *models.DeviceInfo) (*models.LoginResponse, error) {
+	device.LoginMethod = models.SecurityEventLoginOAuth
+	if device.DeviceName == "" {
+		device.DeviceName = provider
+	}
+
+	if challenge, err := secondFactorChallenge(s.roleRepo, user, device); err != nil || challenge != nil {
+		return challenge, err
+	}
+
+	return s.userService.LoginByUserID(user.ID, device)
+}
+
+// newUserIdentity 构造第三方身份记录
+func newUserIdentity(userID uint, provider string, info *OAuthUserInfo) *models.UserIdentity {
+	return &models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  info.Subject,
+		Email:    truncate(info.Email, 100),
+		Name:     truncate(info.Name, 100),
+	}
+}
+
+// oauthStateBinding 计算 state 与浏览器绑定用的摘要，Cookie 中只保存摘要
+func oauthStateBinding(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// pkceChallenge 计算 PKCE S256 code_challenge
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
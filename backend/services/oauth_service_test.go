@@ -0,0 +1,273 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeUserIdentityRepository 内存版第三方身份仓储
+type fakeUserIdentityRepository struct {
+	identities []models.UserIdentity
+}
+
+func (r *fakeUserIdentityRepository) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	for i := range r.identities {
+		if r.identities[i].Provider == provider && r.identities[i].Subject == subject {
+			return &r.identities[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserIdentityRepository) FindByUserID(userID uint) ([]models.UserIdentity, error) {
+	var result []models.UserIdentity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			result = append(result, identity)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeUserIdentityRepository) FindByUnionID(unionID string) (*models.UserIdentity, error) {
+	for i := range r.identities {
+		if r.identities[i].UnionID == unionID {
+			return &r.identities[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserIdentityRepository) Update(identity *models.UserIdentity) error {
+	for i := range r.identities {
+		if r.identities[i].ID == identity.ID {
+			r.identities[i] = *identity
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *fakeUserIdentityRepository) Create(identity *models.UserIdentity) error {
+	identity.ID = uint(len(r.identities) + 1)
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *fakeUserIdentityRepository) Delete(userID uint, provider string) (bool, error) {
+	for i, identity := range r.identities {
+		if identity.UserID == userID && identity.Provider == provider {
+			r.identities = append(r.identities[:i], r.identities[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var _ repositories.UserIdentityRepository = (*fakeUserIdentityRepository)(nil)
+
+// stubOIDCServer 本地 OIDC 提供方：发现、令牌（校验 PKCE）、JWKS、userinfo
+type stubOIDCServer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	clientID  string
+	challenge string // 授权请求中的 code_challenge
+	nonce     string
+	sub       string
+	email     string
+}
+
+func newStubOIDCServer(t *testing.T, clientID string) *stubOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	s := &stubOIDCServer{key: key, clientID: clientID}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 s.URL,
+			"authorization_endpoint": s.URL + "/authorize",
+			"token_endpoint":         s.URL + "/token",
+			"userinfo_endpoint":      s.URL + "/userinfo",
+			"jwks_uri":               s.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "stub-key",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "good-code" || pkceChallenge(r.PostForm.Get("code_verifier")) != s.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":   s.URL,
+			"aud":   s.clientID,
+			"sub":   s.sub,
+			"nonce": s.nonce,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+		})
+		idToken.Header["kid"] = "stub-key"
+		signed, _ := idToken.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access-" + s.sub,
+			"token_type":   "Bearer",
+			"id_token":     signed,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-"+s.sub {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub":                s.sub,
+			"email":              s.email,
+			"email_verified":     true,
+			"name":               "Stub User",
+			"preferred_username": "stub.user",
+		})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// authorize 模拟浏览器访问授权地址，返回 state
+func (s *stubOIDCServer) authorize(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, s.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, s.clientID, q.Get("client_id"))
+	s.challenge = q.Get("code_challenge")
+	s.nonce = q.Get("nonce")
+	return q.Get("state")
+}
+
+func TestOAuthLoginAndLink(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	stub := newStubOIDCServer(t, "test-client")
+	stub.sub, stub.email = "stub-1", "stub1@example.com"
+
+	created := &models.User{}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByEmail", "stub1@example.com").Return(nil, nil)
+	userRepo.On("FindByUsername", "stubuser").Return(nil, nil)
+	userRepo.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		user := args.Get(0).(*models.User)
+		user.ID = 9301
+		*created = *user
+	}).Return(nil)
+	userRepo.On("FindByID", uint(9301)).Return(created, nil)
+	userRepo.On("Update", mock.Anything).Return(nil)
+	identityRepo := &fakeUserIdentityRepository{}
+
+	provider := NewOIDCProvider("stub", stub.URL, "test-client", "secret", "http://localhost/callback", []string{"openid", "email"})
+	userService := NewUserService(userRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	service := NewOAuthService(map[string]OAuthProvider{"stub": provider}, identityRepo, userRepo, nil, userService)
+	assert.Equal(t, []string{"stub"}, service.Providers())
+
+	_, _, err := service.AuthURL("unknown", 0, nil)
+	assert.Equal(t, errOAuthProvider, err)
+
+	// 首次登录：自动注册并绑定身份
+	authURL, binding, err := service.AuthURL("stub", 0, &models.DeviceInfo{IP: "127.0.0.1"})
+	assert.NoError(t, err)
+	state := stub.authorize(t, authURL)
+
+	_, err = service.HandleCallback("stub", state+"x", oauthStateBinding(state+"x"), "good-code")
+	assert.Equal(t, errOAuthState, err)
+
+	// 回调必须来自发起授权的浏览器（携带 state 摘要 Cookie），校验失败时 state 不作废
+	_, err = service.HandleCallback("stub", state, "", "good-code")
+	assert.Equal(t, errOAuthState, err)
+	_, err = service.HandleCallback("stub", state, oauthStateBinding("another-state"), "good-code")
+	assert.Equal(t, errOAuthState, err)
+
+	result, err := service.HandleCallback("stub", state, binding, "good-code")
+	assert.NoError(t, err)
+	assert.False(t, result.Linked)
+	assert.Equal(t, "stubuser", created.Username)
+	assert.NotNil(t, created.EmailVerifiedAt)
+	assert.Len(t, identityRepo.identities, 1)
+
+	// state 只能使用一次
+	_, err = service.HandleCallback("stub", state, binding, "good-code")
+	assert.Equal(t, errOAuthState, err)
+
+	// 票据换取登录结果，只能使用一次
+	resp, err := service.Exchange(result.Ticket)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	assert.Equal(t, uint(9301), resp.User.ID)
+	_, err = service.Exchange(result.Ticket)
+	assert.Equal(t, errOAuthState, err)
+	utils.RevokeRefreshToken(resp.RefreshToken)
+
+	// 错误的授权码（或 PKCE 校验失败）
+	authURL, binding, _ = service.AuthURL("stub", 0, nil)
+	state = stub.authorize(t, authURL)
+	_, err = service.HandleCallback("stub", state, binding, "bad-code")
+	assert.Error(t, err)
+
+	// 再次登录：直接使用已绑定的用户，不再注册
+	authURL, binding, _ = service.AuthURL("stub", 0, nil)
+	result, err = service.HandleCallback("stub", stub.authorize(t, authURL), binding, "good-code")
+	assert.NoError(t, err)
+	resp, err = service.Exchange(result.Ticket)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(9301), resp.User.ID)
+	utils.RevokeRefreshToken(resp.RefreshToken)
+	userRepo.AssertNumberOfCalls(t, "Create", 1)
+
+	// 已绑定其他用户的身份不能再绑定
+	authURL, binding, _ = service.AuthURL("stub", 9302, nil)
+	_, err = service.HandleCallback("stub", stub.authorize(t, authURL), binding, "good-code")
+	assert.Equal(t, errOAuthIdentityTaken, err)
+
+	// 邮箱已被本地账号占用时不自动注册
+	stub.sub, stub.email = "stub-2", "taken@example.com"
+	userRepo.On("FindByEmail", "taken@example.com").Return(&models.User{ID: 9302}, nil)
+	authURL, binding, _ = service.AuthURL("stub", 0, nil)
+	_, err = service.HandleCallback("stub", stub.authorize(t, authURL), binding, "good-code")
+	assert.Equal(t, errOAuthEmailExists, err)
+
+	// 已登录用户绑定新身份后可以用它登录，解绑后身份删除
+	authURL, binding, _ = service.AuthURL("stub", 9302, &models.DeviceInfo{})
+	result, err = service.HandleCallback("stub", stub.authorize(t, authURL), binding, "good-code")
+	assert.NoError(t, err)
+	assert.True(t, result.Linked)
+	identities, _ := service.ListIdentities(9302)
+	assert.Len(t, identities, 1)
+
+	assert.NoError(t, service.Unlink(9302, "stub", nil))
+	assert.Equal(t, errOAuthNotLinked, service.Unlink(9302, "stub", nil))
+}
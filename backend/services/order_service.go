@@ -3,6 +3,7 @@ package services
 import (
 	"gin-backend/models"
 	"gin-backend/repositories"
+	"strconv"
 )
 
 // OrderService 订单业务逻辑接口
@@ -17,12 +18,14 @@ type OrderService interface {
 
 type orderService struct {
 	orderRepo repositories.OrderRepository
+	notifier  NotificationService
 }
 
 // NewOrderService 创建订单业务逻辑实例
-func NewOrderService(orderRepo repositories.OrderRepository) OrderService {
+func NewOrderService(orderRepo repositories.OrderRepository, notifier NotificationService) OrderService {
 	return &orderService{
 		orderRepo: orderRepo,
+		notifier:  notifier,
 	}
 }
 
@@ -56,9 +59,25 @@ func (s *orderService) GetOrderById(id uint) (*models.Order, error) {
 	return s.orderRepo.GetOrderById(id)
 }
 
-// UpdateOrder 更新订单
+// UpdateOrder 更新订单，状态变化时通知下单用户
 func (s *orderService) UpdateOrder(order *models.Order) error {
-	return s.orderRepo.UpdateOrder(order)
+	previous, err := s.orderRepo.GetOrderById(order.ID)
+	if err != nil {
+		return err
+	}
+	oldStatus := previous.Status
+
+	if err := s.orderRepo.UpdateOrder(order); err != nil {
+		return err
+	}
+
+	if order.Status != oldStatus && s.notifier != nil {
+		s.notifier.Notify(order.UserID, models.NotifyOrderStatus, map[string]string{
+			"order":  strconv.FormatUint(uint64(order.ID), 10),
+			"status": order.Status,
+		}, "")
+	}
+	return nil
 }
 
 // DeleteOrder 删除订单
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+	"time"
+)
+
+// permissionCacheExpiration 权限缓存过期时间
+const permissionCacheExpiration = 10 * time.Minute
+
+// rolePermissions 角色权限缓存
+type rolePermissions struct {
+	IsSuper bool     `json:"is_super"`
+	Codes   []string `json:"codes"`
+}
+
+// PermissionService 权限校验服务接口
+type PermissionService interface {
+	HasPermission(userID uint, code string) (bool, error)
+}
+
+// permissionService 权限校验服务实现
+type permissionService struct {
+	userRepo repositories.UserRepository
+	roleRepo repositories.RoleRepository
+	menuRepo repositories.MenuRepository
+	cache    utils.Cache
+}
+
+// NewPermissionService 创建权限校验服务实例。权限缓存由 invalidateUserRole、invalidateRolePermissions
+// 通过默认缓存清除，cache 应与 utils.DefaultCache() 为同一实例
+func NewPermissionService(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, menuRepo repositories.MenuRepository, cache utils.Cache) PermissionService {
+	return &permissionService{
+		userRepo: userRepo,
+		roleRepo: roleRepo,
+		menuRepo: menuRepo,
+		cache:    cache,
+	}
+}
+
+func userRoleCacheKey(userID uint) string {
+	return fmt.Sprintf("perm:user:%d", userID)
+}
+
+func rolePermissionCacheKey(roleID uint) string {
+	return fmt.Sprintf("perm:role:%d", roleID)
+}
+
+// HasPermission 判断用户是否拥有指定权限，超级管理员角色拥有所有权限
+func (s *permissionService) HasPermission(userID uint, code string) (bool, error) {
+	roleID, err := s.userRoleID(userID)
+	if err != nil {
+		return false, err
+	}
+	if roleID == 0 {
+		return false, nil
+	}
+
+	perms, err := s.rolePermissions(roleID)
+	if err != nil {
+		return false, err
+	}
+	if perms.IsSuper {
+		return true, nil
+	}
+
+	for _, c := range perms.Codes {
+		if c == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// userRoleID 获取用户所属角色（带缓存）
+func (s *permissionService) userRoleID(userID uint) (uint, error) {
+	key := userRoleCacheKey(userID)
+	var roleID uint
+	if err := s.cache.Get(key, &roleID); err == nil {
+		return roleID, nil
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.cache.Set(key, user.RoleID, permissionCacheExpiration)
+	return user.RoleID, nil
+}
+
+// rolePermissions 获取角色的权限标识集合（带缓存），禁用的角色没有任何权限
+func (s *permissionService) rolePermissions(roleID uint) (*rolePermissions, error) {
+	key := rolePermissionCacheKey(roleID)
+	var perms rolePermissions
+	if err := s.cache.Get(key, &perms); err == nil {
+		return &perms, nil
+	}
+
+	role, err := s.roleRepo.FindByID(roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	perms = rolePermissions{Codes: []string{}}
+	if role.Status == 1 {
+		perms.IsSuper = role.IsSuper
+		codes, err := s.menuRepo.FindPermissionsByRoleID(roleID)
+		if err != nil {
+			return nil, err
+		}
+		perms.Codes = codes
+	}
+
+	s.cache.Set(key, perms, permissionCacheExpiration)
+	return &perms, nil
+}
+
+// invalidateUserRole 用户角色变更或用户删除后清除缓存
+func invalidateUserRole(userID uint) {
+	utils.CacheDel(userRoleCacheKey(userID))
+}
+
+// invalidateRolePermissions 角色或其菜单变更后清除缓存
+func invalidateRolePermissions(roleIDs ...uint) {
+	keys := make([]string, 0, len(roleIDs))
+	for _, id := range roleIDs {
+		keys = append(keys, rolePermissionCacheKey(id))
+	}
+	if len(keys) > 0 {
+		utils.CacheDel(keys...)
+	}
+}
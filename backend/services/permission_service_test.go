@@ -0,0 +1,113 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// MockRoleRepository 模拟角色仓储
+type MockRoleRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepository) Create(role *models.Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) Update(role *models.Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) Delete(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) FindByID(id uint) (*models.Role, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) FindAll() ([]models.Role, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) FindByCode(code string) (*models.Role, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) AssignMenus(roleID uint, menuIDs []uint) error {
+	args := m.Called(roleID, menuIDs)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) GetDB() *gorm.DB {
+	return nil
+}
+
+// 确保 MockRoleRepository 实现了 RoleRepository 接口
+var _ repositories.RoleRepository = (*MockRoleRepository)(nil)
+
+func TestHasPermission(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	userRepo := new(MockUserRepository)
+	roleRepo := new(MockRoleRepository)
+	menuRepo := new(MockMenuRepository)
+	service := NewPermissionService(userRepo, roleRepo, menuRepo, utils.DefaultCache())
+
+	userRepo.On("FindByID", uint(9001)).Return(&models.User{ID: 9001, RoleID: 901}, nil)
+	userRepo.On("FindByID", uint(9002)).Return(&models.User{ID: 9002, RoleID: 902}, nil)
+	userRepo.On("FindByID", uint(9003)).Return(&models.User{ID: 9003, RoleID: 903}, nil)
+	roleRepo.On("FindByID", uint(901)).Return(&models.Role{ID: 901, IsSuper: true, Status: 1}, nil)
+	roleRepo.On("FindByID", uint(902)).Return(&models.Role{ID: 902, Status: 1}, nil)
+	roleRepo.On("FindByID", uint(903)).Return(&models.Role{ID: 903, Status: 0}, nil)
+	menuRepo.On("FindPermissionsByRoleID", uint(901)).Return([]string{}, nil)
+	menuRepo.On("FindPermissionsByRoleID", uint(902)).Return([]string{models.PermUserList}, nil)
+
+	// 超级管理员拥有所有权限
+	allowed, err := service.HasPermission(9001, models.PermUserDelete)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	// 普通角色只拥有分配到的权限
+	allowed, err = service.HasPermission(9002, models.PermUserList)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = service.HasPermission(9002, models.PermUserDelete)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+
+	// 禁用的角色没有任何权限
+	allowed, err = service.HasPermission(9003, models.PermUserList)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+
+	// 第二次校验命中缓存，不再查询数据库
+	_, _ = service.HasPermission(9002, models.PermUserList)
+	userRepo.AssertNumberOfCalls(t, "FindByID", 3)
+	roleRepo.AssertNumberOfCalls(t, "FindByID", 3)
+
+	invalidateUserRole(9001)
+	invalidateUserRole(9002)
+	invalidateUserRole(9003)
+	invalidateRolePermissions(901, 902, 903)
+}
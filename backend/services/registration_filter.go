@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"gin-backend/config"
+	"gin-backend/utils"
+	"strings"
+	"sync/atomic"
+)
+
+// registrationFilterFalsePositive 注册布隆过滤器的误判率，误判只会多查一次数据库
+const registrationFilterFalsePositive = 0.01
+
+// registrationFilter 已注册用户名和邮箱的布隆过滤器，判定一定不存在时注册跳过数据库查询。
+// Redis 可用时各实例共享同一个 Redis 过滤器，否则每个实例使用自己的内存过滤器，超过容量后自动加层。
+// 内存过滤器会漏掉其他实例新注册的值，此时由数据库唯一索引兜底。
+// 两种过滤器都不删除值：内存过滤器只有本实例添加过的值，删除可能清掉其他值共用的位，造成漏判
+type registrationFilter struct {
+	memory *utils.ScalableBloomFilter
+	redis  *utils.RedisBloomFilter
+	// 加载完成前已开始记录新注册的值，但查询一律按可能存在处理
+	ready atomic.Bool
+	// 内存过滤器的快照路径，为空不保存
+	snapshot string
+}
+
+// newRegistrationFilter 创建注册过滤器。Redis 键名包含容量，容量不同的实例不会共用同一组位
+func newRegistrationFilter(capacity uint) *registrationFilter {
+	if config.RedisClient != nil {
+		key := fmt.Sprintf("bloom:register:%d", capacity)
+		return &registrationFilter{redis: utils.NewRedisBloomFilter(config.RedisClient, key, capacity, registrationFilterFalsePositive)}
+	}
+	return &registrationFilter{memory: utils.NewScalableBloomFilter(capacity, registrationFilterFalsePositive)}
+}
+
+// restore 从快照恢复内存过滤器，快照无效时保留新建的空过滤器
+func (f *registrationFilter) restore(path string) error {
+	restored := &utils.ScalableBloomFilter{}
+	if err := utils.LoadBloomFilter(path, restored); err != nil {
+		return err
+	}
+	f.memory = restored
+	return nil
+}
+
+// save 保存内存过滤器快照，共享过滤器和未加载完成的过滤器不保存
+func (f *registrationFilter) save() error {
+	if f.shared() || f.snapshot == "" || !f.ready.Load() {
+		return nil
+	}
+	return utils.SaveBloomFilter(f.snapshot, f.memory)
+}
+
+// 用户名和邮箱放在同一个过滤器中，用前缀区分；数据库按不区分大小写比较，这里统一转为小写
+func usernameFilterKey(username string) string {
+	return "username:" + strings.ToLower(username)
+}
+
+func emailFilterKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+// add 记录已注册的用户名和邮箱，参数为 usernameFilterKey / emailFilterKey 的结果
+func (f *registrationFilter) add(keys ...string) {
+	if err := f.load(keys); err != nil {
+		fmt.Printf("注册过滤器写入失败: %v\n", err)
+	}
+}
+
+// load 批量写入，Redis 过滤器使用管道，启动预热时避免每个值一次往返
+func (f *registrationFilter) load(keys []string) error {
+	if f.redis != nil {
+		return f.redis.AddMany(config.GetRedisContext(), keys...)
+	}
+	for _, key := range keys {
+		f.memory.Add(key)
+	}
+	return nil
+}
+
+// shared 过滤器是否各实例共享（Redis），共享时否定结果也包含其他实例新注册的值
+func (f *registrationFilter) shared() bool {
+	return f.redis != nil
+}
+
+// mightExist 判断值是否可能已注册，返回 false 时一定未注册。
+// 加载完成前、Redis 出错或过滤器键已丢失（被淘汰、清空）时按可能存在处理
+func (f *registrationFilter) mightExist(key string) bool {
+	if !f.ready.Load() {
+		return true
+	}
+	if f.redis == nil {
+		return f.memory.Contains(key)
+	}
+	ctx := config.GetRedisContext()
+	exists, err := f.redis.Contains(ctx, key)
+	if err != nil {
+		fmt.Printf("注册过滤器查询失败: %v\n", err)
+		return true
+	}
+	if exists {
+		return true
+	}
+	loaded, err := f.redis.Exists(ctx)
+	return err != nil || !loaded
+}
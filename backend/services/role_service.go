@@ -2,18 +2,19 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"gin-backend/models"
 	"gin-backend/repositories"
 )
 
 // RoleService 角色服务接口
 type RoleService interface {
-	CreateRole(req *models.RoleCreateRequest) error
-	UpdateRole(id uint, req *models.RoleUpdateRequest) error
+	CreateRole(req *models.RoleCreateRequest, operator *models.Operator) error
+	UpdateRole(id uint, req *models.RoleUpdateRequest, operator *models.Operator) error
 	DeleteRole(id uint) error
 	GetRoleByID(id uint) (*models.Role, error)
 	GetAllRoles() ([]models.Role, error)
-	AssignMenus(roleID uint, menuIDs []uint) error
+	AssignMenus(roleID uint, menuIDs []uint, operator *models.Operator) error
 }
 
 // roleService 角色服务实现
@@ -27,13 +28,15 @@ func NewRoleService(roleRepo repositories.RoleRepository) RoleService {
 }
 
 // CreateRole 创建角色
-func (s *roleService) CreateRole(req *models.RoleCreateRequest) error {
+func (s *roleService) CreateRole(req *models.RoleCreateRequest, operator *models.Operator) error {
 	role := &models.Role{
 		Name:        req.Name,
 		Code:        req.Code,
 		Description: req.Description,
 		IsSuper:     false, // 新创建的角色不能是超级管理员
 		Status:      1,
+		MaxSessions: req.MaxSessions,
+		Require2FA:  req.Require2FA,
 	}
 
 	// 创建角色
@@ -43,14 +46,14 @@ func (s *roleService) CreateRole(req *models.RoleCreateRequest) error {
 
 	// 分配菜单
 	if len(req.MenuIDs) > 0 {
-		return s.roleRepo.AssignMenus(role.ID, req.MenuIDs)
+		return s.assignMenus(role.ID, req.MenuIDs, operator)
 	}
 
 	return nil
 }
 
 // UpdateRole 更新角色
-func (s *roleService) UpdateRole(id uint, req *models.RoleUpdateRequest) error {
+func (s *roleService) UpdateRole(id uint, req *models.RoleUpdateRequest, operator *models.Operator) error {
 	role, err := s.roleRepo.FindByID(id)
 	if err != nil {
 		return err
@@ -70,15 +73,22 @@ func (s *roleService) UpdateRole(id uint, req *models.RoleUpdateRequest) error {
 	if req.Status != nil {
 		role.Status = *req.Status
 	}
+	if req.MaxSessions != nil {
+		role.MaxSessions = *req.MaxSessions
+	}
+	if req.Require2FA != nil {
+		role.Require2FA = *req.Require2FA
+	}
 
 	// 更新角色
 	if err := s.roleRepo.Update(role); err != nil {
 		return err
 	}
+	invalidateRolePermissions(id)
 
 	// 更新菜单关联
 	if req.MenuIDs != nil {
-		return s.roleRepo.AssignMenus(id, req.MenuIDs)
+		return s.assignMenus(id, req.MenuIDs, operator)
 	}
 
 	return nil
@@ -86,7 +96,11 @@ func (s *roleService) UpdateRole(id uint, req *models.RoleUpdateRequest) error {
 
 // DeleteRole 删除角色
 func (s *roleService) DeleteRole(id uint) error {
-	return s.roleRepo.Delete(id)
+	if err := s.roleRepo.Delete(id); err != nil {
+		return err
+	}
+	invalidateRolePermissions(id)
+	return nil
 }
 
 // GetRoleByID 根据ID获取角色
@@ -100,6 +114,23 @@ func (s *roleService) GetAllRoles() ([]models.Role, error) {
 }
 
 // AssignMenus 为角色分配菜单
-func (s *roleService) AssignMenus(roleID uint, menuIDs []uint) error {
-	return s.roleRepo.AssignMenus(roleID, menuIDs)
+func (s *roleService) AssignMenus(roleID uint, menuIDs []uint, operator *models.Operator) error {
+	if err := s.assignMenus(roleID, menuIDs, operator); err != nil {
+		return err
+	}
+	invalidateRolePermissions(roleID)
+	return nil
+}
+
+// assignMenus 保存角色菜单关联并记录安全事件
+func (s *roleService) assignMenus(roleID uint, menuIDs []uint, operator *models.Operator) error {
+	if err := s.roleRepo.AssignMenus(roleID, menuIDs); err != nil {
+		return err
+	}
+
+	recordOperatorEvent(operator, &models.SecurityEvent{
+		Type:   models.SecurityEventRoleMenus,
+		Detail: fmt.Sprintf("角色 %d 的菜单/权限设置为 %v", roleID, menuIDs),
+	})
+	return nil
 }
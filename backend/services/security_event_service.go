@@ -0,0 +1,54 @@
+package services
+
+import (
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+	"log"
+)
+
+// SecurityEventService 安全事件服务接口
+type SecurityEventService interface {
+	Record(event *models.SecurityEvent)
+	ListEvents(query *models.SecurityEventQuery) (*models.PageResponse, error)
+}
+
+// securityEventService 安全事件服务实现
+type securityEventService struct {
+	eventRepo repositories.SecurityEventRepository
+}
+
+// NewSecurityEventService 创建安全事件服务实例，并注册为全局安全事件处理函数
+func NewSecurityEventService(eventRepo repositories.SecurityEventRepository) SecurityEventService {
+	s := &securityEventService{eventRepo: eventRepo}
+	utils.SetSecurityEventHook(s.Record)
+	return s
+}
+
+// Record 保存安全事件，写库失败只记录日志，不影响业务流程
+func (s *securityEventService) Record(event *models.SecurityEvent) {
+	event.UserAgent = truncate(event.UserAgent, 255)
+	event.Detail = truncate(event.Detail, 500)
+	if err := s.eventRepo.Create(event); err != nil {
+		log.Printf("保存安全事件失败: type=%s user=%d err=%v", event.Type, event.UserID, err)
+	}
+}
+
+// ListEvents 分页查询安全事件
+func (s *securityEventService) ListEvents(query *models.SecurityEventQuery) (*models.PageResponse, error) {
+	events, total, err := s.eventRepo.FindWithPage(query)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPageResponse(query.GetPage(), query.GetPageSize(), total, events), nil
+}
+
+// recordOperatorEvent 记录由操作人发起的安全事件
+func recordOperatorEvent(operator *models.Operator, event *models.SecurityEvent) {
+	if operator != nil {
+		event.OperatorID = operator.UserID
+		event.IP = operator.IP
+		event.UserAgent = operator.UserAgent
+	}
+	utils.EmitSecurityEvent(event)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSecurityEventRepository 模拟安全事件仓储，记录保存过的事件
+type MockSecurityEventRepository struct {
+	mock.Mock
+	events []models.SecurityEvent
+}
+
+func (m *MockSecurityEventRepository) Create(event *models.SecurityEvent) error {
+	m.events = append(m.events, *event)
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func (m *MockSecurityEventRepository) FindWithPage(query *models.SecurityEventQuery) ([]models.SecurityEvent, int64, error) {
+	args := m.Called(query)
+	return args.Get(0).([]models.SecurityEvent), args.Get(1).(int64), args.Error(2)
+}
+
+var _ repositories.SecurityEventRepository = (*MockSecurityEventRepository)(nil)
+
+// eventTypes 按顺序返回已保存事件的类型
+func (m *MockSecurityEventRepository) eventTypes() []string {
+	types := make([]string, 0, len(m.events))
+	for _, e := range m.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestSecurityEvents(t *testing.T) {
+	t.Cleanup(func() {
+		utils.SetSecurityEventHook(nil)
+		os.Remove("cache_persistence.json")
+	})
+
+	eventRepo := new(MockSecurityEventRepository)
+	eventRepo.On("Create", mock.Anything).Return(nil)
+	NewSecurityEventService(eventRepo)
+
+	user := &models.User{ID: 9301, Username: "audit_user", RoleID: 2}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", user.ID).Return(user, nil)
+	userRepo.On("Update", mock.Anything).Return(nil)
+	menuRepo := new(MockMenuRepository)
+	menuRepo.On("FindByRoleID", mock.Anything).Return([]models.Menu{}, nil)
+	menuRepo.On("BuildMenuTree", mock.Anything).Return([]models.MenuTreeResponse{})
+	service := NewUserService(userRepo, menuRepo, nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+
+	// 登录：记录登录方式和来源
+	resp, err := service.LoginByUserID(user.ID, &models.DeviceInfo{
+		IP:          "192.0.2.10",
+		LoginMethod: models.SecurityEventLoginWechat,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.SecurityEventLoginWechat}, eventRepo.eventTypes())
+	assert.Equal(t, "192.0.2.10", eventRepo.events[0].IP)
+
+	// 管理员修改角色：记录操作人
+	_, err = service.UpdateUser(user.ID, &models.UserUpdateRequest{RoleID: 3}, &models.Operator{UserID: 1, IP: "192.0.2.1"})
+	assert.NoError(t, err)
+	last := eventRepo.events[len(eventRepo.events)-1]
+	assert.Equal(t, models.SecurityEventRoleChanged, last.Type)
+	assert.Equal(t, user.ID, last.UserID)
+	assert.Equal(t, uint(1), last.OperatorID)
+	invalidateUserRole(user.ID)
+
+	// 刷新令牌重放：吊销会话并拉黑访问令牌
+	eventRepo.events = nil
+	_, err = service.RefreshToken(resp.RefreshToken)
+	assert.NoError(t, err)
+	_, err = service.RefreshToken(resp.RefreshToken)
+	assert.ErrorIs(t, err, utils.ErrRefreshTokenReused)
+	assert.Equal(t, []string{models.SecurityEventTokenBlacklist, models.SecurityEventRefreshReused}, eventRepo.eventTypes())
+}
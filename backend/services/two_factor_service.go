@@ -0,0 +1,422 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+	"strings"
+	"time"
+)
+
+const (
+	// loginChallengeExpiration 两步验证登录挑战有效期
+	loginChallengeExpiration = 5 * time.Minute
+	// loginChallengeMaxAttempts 单个登录挑战允许的最大验证次数
+	loginChallengeMaxAttempts = 5
+	// pendingSecretExpiration 待确认的 TOTP 密钥有效期
+	pendingSecretExpiration = 10 * time.Minute
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+
+	loginChallengePrefix         = "2fa:challenge:"
+	loginChallengeAttemptsPrefix = "2fa:attempts:"
+	pendingSecretPrefix          = "2fa:pending:"
+)
+
+var (
+	errChallengeInvalid  = errors.New("登录验证已过期，请重新登录")
+	errTwoFactorCode     = errors.New("验证码错误")
+	errTwoFactorEnabled  = errors.New("已启用两步验证")
+	errTwoFactorDisabled = errors.New("未启用两步验证")
+	errTwoFactorRequired = errors.New("当前角色要求启用两步验证，不能关闭")
+	errNoPendingSecret   = errors.New("请先获取两步验证绑定信息")
+)
+
+// loginChallenge 密码验证通过后、两步验证完成前的登录挑战
+type loginChallenge struct {
+	UserID uint              `json:"user_id"`
+	Device models.DeviceInfo `json:"device"`
+	Setup  bool              `json:"setup"`          // 需要先绑定两步验证
+	Link   *pendingIdentity  `json:"link,omitempty"` // 第二因素通过后才绑定的第三方身份
+}
+
+// pendingIdentity 待绑定的第三方身份（扫码微信绑定需要两步验证的账号）。
+// models.UserIdentity 不序列化 Subject，因此单独保存
+type pendingIdentity struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	UnionID  string `json:"union_id,omitempty"`
+}
+
+// identity 绑定到指定用户的身份记录
+func (p *pendingIdentity) identity(userID uint) *models.UserIdentity {
+	return &models.UserIdentity{UserID: userID, Provider: p.Provider, Subject: p.Subject, UnionID: p.UnionID}
+}
+
+// TwoFactorService 两步验证服务接口
+type TwoFactorService interface {
+	// 已登录用户管理自己的两步验证
+	BeginSetup(userID uint) (*models.TwoFactorSetupResponse, error)
+	ConfirmSetup(userID uint, code string) ([]string, error)
+	Disable(userID uint, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	// 两步登录
+	BeginChallengeSetup(challengeToken string) (*models.TwoFactorSetupResponse, error)
+	CompleteLogin(challengeToken, code string) (*models.LoginResponse, error)
+}
+
+// twoFactorService 两步验证服务实现
+type twoFactorService struct {
+	userRepo     repositories.UserRepository
+	roleRepo     repositories.RoleRepository
+	recoveryRepo repositories.RecoveryCodeRepository
+	identityRepo repositories.UserIdentityRepository
+	userService  UserService
+	loginGuard   LoginGuardService
+}
+
+// NewTwoFactorService 创建两步验证服务实例
+func NewTwoFactorService(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, recoveryRepo repositories.RecoveryCodeRepository, identityRepo repositories.UserIdentityRepository, userService UserService, loginGuard LoginGuardService) TwoFactorService {
+	return &twoFactorService{
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		recoveryRepo: recoveryRepo,
+		identityRepo: identityRepo,
+		userService:  userService,
+		loginGuard:   loginGuard,
+	}
+}
+
+// newLoginChallenge 创建两步验证登录挑战，返回挑战令牌
+func newLoginChallenge(userID uint, device *models.DeviceInfo, setup bool) (string, error) {
+	return saveLoginChallenge(loginChallenge{UserID: userID, Setup: setup}, device)
+}
+
+// saveLoginChallenge 保存登录挑战，返回挑战令牌
+func saveLoginChallenge(challenge loginChallenge, device *models.DeviceInfo) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+
+	if device != nil {
+		challenge.Device = *device
+	}
+	if err := utils.CacheSet(loginChallengePrefix+token, challenge, loginChallengeExpiration); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// secondFactorChallenge 已启用或角色要求两步验证时创建登录挑战，返回 nil 表示无需两步验证、可直接签发令牌
+func secondFactorChallenge(roleRepo repositories.RoleRepository, user *models.User, device *models.DeviceInfo) (*models.LoginResponse, error) {
+	return linkChallenge(roleRepo, user, device, nil)
+}
+
+// linkChallenge 同 secondFactorChallenge，link 非空时该第三方身份在第二因素通过后才绑定
+func linkChallenge(roleRepo repositories.RoleRepository, user *models.User, device *models.DeviceInfo, link *pendingIdentity) (*models.LoginResponse, error) {
+	if !user.TwoFactorEnabled && !roleRequires2FA(roleRepo, user.RoleID) {
+		return nil, nil
+	}
+
+	setup := !user.TwoFactorEnabled
+	challenge, err := saveLoginChallenge(loginChallenge{UserID: user.ID, Setup: setup, Link: link}, device)
+	if err != nil {
+		return nil, errors.New("创建两步验证失败")
+	}
+	return &models.LoginResponse{
+		TwoFactorRequired:      true,
+		TwoFactorSetupRequired: setup,
+		ChallengeToken:         challenge,
+	}, nil
+}
+
+// loadChallenge 读取登录挑战
+func loadChallenge(token string) (*loginChallenge, error) {
+	var challenge loginChallenge
+	if err := utils.CacheGet(loginChallengePrefix+token, &challenge); err != nil {
+		return nil, errChallengeInvalid
+	}
+	return &challenge, nil
+}
+
+// BeginSetup 生成待确认的 TOTP 密钥
+func (s *twoFactorService) BeginSetup(userID uint) (*models.TwoFactorSetupResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, errTwoFactorEnabled
+	}
+	return s.newPendingSecret(user)
+}
+
+// ConfirmSetup 使用认证器生成的验证码确认绑定，返回恢复码（仅此一次明文返回）
+func (s *twoFactorService) ConfirmSetup(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, errTwoFactorEnabled
+	}
+	return s.enable(user, code)
+}
+
+// Disable 关闭两步验证，需要提供有效的验证码或恢复码
+func (s *twoFactorService) Disable(userID uint, code string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return errTwoFactorDisabled
+	}
+	if s.roleRequires2FA(user.RoleID) {
+		return errTwoFactorRequired
+	}
+	if !s.verifyCode(user, code) {
+		return errTwoFactorCode
+	}
+
+	user.TwoFactorEnabled = false
+	user.TwoFactorSecret = ""
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	invalidateUserProfile(user.ID)
+	return s.recoveryRepo.DeleteByUserID(user.ID)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部作废
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return nil, errTwoFactorDisabled
+	}
+	if !s.verifyCode(user, code) {
+		return nil, errTwoFactorCode
+	}
+	return s.newRecoveryCodes(user.ID)
+}
+
+// BeginChallengeSetup 角色强制两步验证但用户尚未绑定时，凭登录挑战获取绑定信息
+func (s *twoFactorService) BeginChallengeSetup(challengeToken string) (*models.TwoFactorSetupResponse, error) {
+	challenge, err := loadChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.Setup {
+		return nil, errTwoFactorEnabled
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.newPendingSecret(user)
+}
+
+// CompleteLogin 校验两步验证码并完成登录
+func (s *twoFactorService) CompleteLogin(challengeToken, code string) (*models.LoginResponse, error) {
+	challenge, err := loadChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// 限制单个挑战的尝试次数，超出后必须重新输入密码。
+	// 次数单独用原子计数保存，并发提交的验证码不会因为读改写挑战而少计
+	key := loginChallengePrefix + challengeToken
+	attemptsKey := loginChallengeAttemptsPrefix + challengeToken
+	attempts, err := incrAttempts(attemptsKey, loginChallengeExpiration)
+	if err != nil {
+		return nil, errChallengeInvalid
+	}
+	if attempts > loginChallengeMaxAttempts {
+		utils.CacheDel(key, attemptsKey)
+		return nil, errChallengeInvalid
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, errChallengeInvalid
+	}
+
+	// 第二因素与密码共用用户名的失败计数和锁定，锁定期内不再校验验证码
+	if s.loginGuard != nil {
+		if err := s.loginGuard.Check(user.Username, &challenge.Device); err != nil {
+			return nil, err
+		}
+	}
+
+	var recoveryCodes []string
+	if challenge.Setup && !user.TwoFactorEnabled {
+		if recoveryCodes, err = s.enable(user, code); err != nil {
+			if errors.Is(err, errTwoFactorCode) {
+				s.recordFailure(user, &challenge.Device)
+			}
+			return nil, err
+		}
+	} else if !s.verifyCode(user, code) {
+		s.recordFailure(user, &challenge.Device)
+		return nil, errTwoFactorCode
+	}
+
+	utils.CacheDel(key, attemptsKey)
+	if s.loginGuard != nil {
+		s.loginGuard.RecordSuccess(user.Username, user.ID, &challenge.Device)
+	}
+	if challenge.Link != nil {
+		if err := linkWechatIdentity(s.identityRepo, challenge.Link.identity(user.ID), &challenge.Device); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := s.userService.LoginByUserID(user.ID, &challenge.Device)
+	if err != nil {
+		return nil, err
+	}
+	resp.RecoveryCodes = recoveryCodes
+	return resp, nil
+}
+
+// recordFailure 记录一次两步验证码（或恢复码）错误
+func (s *twoFactorService) recordFailure(user *models.User, device *models.DeviceInfo) {
+	if s.loginGuard != nil {
+		s.loginGuard.RecordFailure(user.Username, user.ID, device, "两步验证码错误")
+	}
+}
+
+// newPendingSecret 生成新的待确认密钥
+func (s *twoFactorService) newPendingSecret(user *models.User) (*models.TwoFactorSetupResponse, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.CacheSet(fmt.Sprintf("%s%d", pendingSecretPrefix, user.ID), secret, pendingSecretExpiration); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OtpauthURI: utils.TOTPURI(config.AppConfig.Auth.TOTPIssuer, user.Username, secret),
+	}, nil
+}
+
+// enable 校验待确认密钥的验证码，通过后启用两步验证并生成恢复码
+func (s *twoFactorService) enable(user *models.User, code string) ([]string, error) {
+	pendingKey := fmt.Sprintf("%s%d", pendingSecretPrefix, user.ID)
+	var secret string
+	if err := utils.CacheGet(pendingKey, &secret); err != nil || secret == "" {
+		return nil, errNoPendingSecret
+	}
+
+	counter, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok || !markTOTPUsed(user.ID, counter) {
+		return nil, errTwoFactorCode
+	}
+
+	user.TwoFactorSecret = secret
+	user.TwoFactorEnabled = true
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	invalidateUserProfile(user.ID)
+	utils.CacheDel(pendingKey)
+
+	return s.newRecoveryCodes(user.ID)
+}
+
+// verifyCode 校验 TOTP 验证码或恢复码
+func (s *twoFactorService) verifyCode(user *models.User, code string) bool {
+	code = strings.TrimSpace(code)
+	if counter, ok := utils.ValidateTOTP(user.TwoFactorSecret, code, time.Now()); ok {
+		return markTOTPUsed(user.ID, counter)
+	}
+
+	used, err := s.recoveryRepo.Consume(user.ID, hashRecoveryCode(code))
+	return err == nil && used
+}
+
+// newRecoveryCodes 生成一组新的恢复码，数据库只保存哈希
+func (s *twoFactorService) newRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomHex(5)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := s.recoveryRepo.ReplaceForUser(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// roleRequires2FA 判断角色是否强制两步验证
+func (s *twoFactorService) roleRequires2FA(roleID uint) bool {
+	return roleRequires2FA(s.roleRepo, roleID)
+}
+
+// roleRequires2FA 判断角色是否强制两步验证
+func roleRequires2FA(roleRepo repositories.RoleRepository, roleID uint) bool {
+	if roleID == 0 || roleRepo == nil {
+		return false
+	}
+	role, err := roleRepo.FindByID(roleID)
+	return err == nil && role.Require2FA
+}
+
+// markTOTPUsed 记录已使用的 TOTP 时间步，同一验证码在有效期内不能重复使用。
+// 用 SETNX 原子占用，并发提交同一个验证码时只有一个请求通过；缓存出错时按已使用处理
+func markTOTPUsed(userID uint, counter int64) bool {
+	key := fmt.Sprintf("2fa:used:%d:%d", userID, counter)
+	ok, err := utils.CacheSetNX(key, 1, 3*utils.TOTPPeriod)
+	return err == nil && ok
+}
+
+// incrAttempts 尝试次数加一，第一次计数时设置 window 后过期。计数由缓存原子完成，多实例共享缓存时不会少计
+func incrAttempts(key string, window time.Duration) (int64, error) {
+	attempts, err := utils.CacheIncr(key)
+	if err != nil {
+		return 0, err
+	}
+	if attempts == 1 {
+		if err := utils.CacheExpire(key, window); err != nil {
+			return 0, err
+		}
+	}
+	return attempts, nil
+}
+
+// hashRecoveryCode 恢复码哈希（恢复码为高熵随机值，使用 SHA-256 即可）
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex 生成指定字节数的随机十六进制字符串
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
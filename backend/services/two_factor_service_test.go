@@ -0,0 +1,209 @@
+package services
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeRecoveryCodeRepository 内存恢复码仓储
+type fakeRecoveryCodeRepository struct {
+	codes map[string]bool // hash -> 是否已使用
+}
+
+func (r *fakeRecoveryCodeRepository) ReplaceForUser(userID uint, codeHashes []string) error {
+	r.codes = make(map[string]bool, len(codeHashes))
+	for _, h := range codeHashes {
+		r.codes[h] = false
+	}
+	return nil
+}
+
+func (r *fakeRecoveryCodeRepository) Consume(userID uint, codeHash string) (bool, error) {
+	used, ok := r.codes[codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	r.codes[codeHash] = true
+	return true, nil
+}
+
+func (r *fakeRecoveryCodeRepository) DeleteByUserID(userID uint) error {
+	r.codes = nil
+	return nil
+}
+
+var _ repositories.RecoveryCodeRepository = (*fakeRecoveryCodeRepository)(nil)
+
+func TestTwoFactorLogin(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	user := &models.User{ID: 9101, Username: "totp_user"}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", user.ID).Return(user, nil)
+	userRepo.On("Update", mock.Anything).Return(nil)
+	recoveryRepo := &fakeRecoveryCodeRepository{}
+
+	userService := NewUserService(userRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	service := NewTwoFactorService(userRepo, nil, recoveryRepo, nil, userService, nil)
+
+	// 绑定：先获取密钥，再用当前验证码确认
+	setup, err := service.BeginSetup(user.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, setup.OtpauthURI, "otpauth://totp/")
+
+	_, err = service.ConfirmSetup(user.ID, "000000")
+	assert.Error(t, err)
+
+	code, err := utils.TOTPCode(setup.Secret, time.Now())
+	assert.NoError(t, err)
+	recoveryCodes, err := service.ConfirmSetup(user.ID, code)
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes, recoveryCodeCount)
+	assert.True(t, user.TwoFactorEnabled)
+
+	// 同一时间步的验证码不能再次使用
+	challenge, err := newLoginChallenge(user.ID, nil, false)
+	assert.NoError(t, err)
+	_, err = service.CompleteLogin(challenge, code)
+	assert.Equal(t, errTwoFactorCode, err)
+
+	// 恢复码可以完成登录，但只能使用一次
+	resp, err := service.CompleteLogin(challenge, recoveryCodes[0])
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	assert.NotEmpty(t, resp.RefreshToken)
+
+	challenge, err = newLoginChallenge(user.ID, nil, false)
+	assert.NoError(t, err)
+	_, err = service.CompleteLogin(challenge, recoveryCodes[0])
+	assert.Equal(t, errTwoFactorCode, err)
+
+	// 超出尝试次数后挑战失效
+	for i := 1; i < loginChallengeMaxAttempts; i++ {
+		_, err = service.CompleteLogin(challenge, "bad")
+		assert.Equal(t, errTwoFactorCode, err)
+	}
+	_, err = service.CompleteLogin(challenge, recoveryCodes[1])
+	assert.Equal(t, errChallengeInvalid, err)
+
+	utils.RevokeRefreshToken(resp.RefreshToken)
+}
+
+func TestBeginLoginRequiresSecondFactor(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	user := &models.User{ID: 9102, Username: "wechat_totp_user", TwoFactorEnabled: true}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", user.ID).Return(user, nil)
+	userService := NewUserService(userRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+
+	// 微信扫码等第三方登录同样先返回登录挑战，不签发令牌
+	resp, err := userService.BeginLogin(user.ID, &models.DeviceInfo{LoginMethod: models.SecurityEventLoginWechat})
+	assert.NoError(t, err)
+	assert.True(t, resp.TwoFactorRequired)
+	assert.NotEmpty(t, resp.ChallengeToken)
+	assert.Empty(t, resp.Token)
+	assert.Empty(t, resp.RefreshToken)
+}
+
+func TestCompleteLoginSharesLoginLockout(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	user := &models.User{ID: 9103, Username: "totp_guard_user", TwoFactorEnabled: true, TwoFactorSecret: secret}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", user.ID).Return(user, nil)
+	attemptRepo := new(MockLoginAttemptRepository)
+	attemptRepo.On("Create", mock.Anything).Return(nil)
+	guard := NewLoginGuardService(attemptRepo)
+	device := models.DeviceInfo{IP: "203.0.113.40", UserAgent: "totp-guard-test"}
+	t.Cleanup(func() { guard.Unlock(user.Username, device.IP) })
+
+	userService := NewUserService(userRepo, new(MockMenuRepository), nil, guard, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	service := NewTwoFactorService(userRepo, nil, &fakeRecoveryCodeRepository{}, nil, userService, guard)
+
+	// 密码正确后失败计数不清零，第二因素错误继续累计直至锁定
+	for i := 0; i < guardConfig().LoginMaxFailures-1; i++ {
+		guard.RecordFailure(user.Username, user.ID, &device, "密码错误")
+	}
+	challenge, err := newLoginChallenge(user.ID, &device, false)
+	assert.NoError(t, err)
+	_, err = service.CompleteLogin(challenge, "000000")
+	assert.Equal(t, errTwoFactorCode, err)
+
+	// 锁定期内即使验证码正确也不能完成登录
+	code, err := utils.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	_, err = service.CompleteLogin(challenge, code)
+	assert.ErrorIs(t, err, ErrLoginLocked)
+
+	// 解锁后第二因素通过，用户名失败计数清零
+	guard.Unlock(user.Username, "")
+	for i := 0; i < guardConfig().LoginMaxFailures-1; i++ {
+		guard.RecordFailure(user.Username, user.ID, &device, "密码错误")
+	}
+	resp, err := service.CompleteLogin(challenge, code)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	guard.RecordFailure(user.Username, user.ID, &device, "密码错误")
+	assert.NoError(t, guard.Check(user.Username, &device))
+
+	utils.RevokeRefreshToken(resp.RefreshToken)
+}
+
+func TestCompleteLoginConcurrentAttempts(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	user := &models.User{ID: 9104, Username: "totp_race_user", TwoFactorEnabled: true, TwoFactorSecret: secret}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", user.ID).Return(user, nil)
+	userService := NewUserService(userRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	service := NewTwoFactorService(userRepo, nil, &fakeRecoveryCodeRepository{}, nil, userService, nil)
+
+	// 并发提交同一个验证码，只有一个请求能使用该时间步
+	counter := time.Now().Unix() / int64(utils.TOTPPeriod/time.Second)
+	var used atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if markTOTPUsed(user.ID, counter) {
+				used.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), used.Load())
+
+	// 并发猜测验证码，计入的次数不超过上限
+	challenge, err := newLoginChallenge(user.ID, nil, false)
+	assert.NoError(t, err)
+	var guesses atomic.Int32
+	for i := 0; i < 3*loginChallengeMaxAttempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.CompleteLogin(challenge, "000000"); err == errTwoFactorCode {
+				guesses.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, guesses.Load(), int32(loginChallengeMaxAttempts))
+	_, err = service.CompleteLogin(challenge, "000000")
+	assert.Equal(t, errChallengeInvalid, err)
+}
@@ -1,44 +1,98 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"gin-backend/config"
 	"gin-backend/models"
 	"gin-backend/repositories"
 	"gin-backend/utils"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // UserService 用户业务逻辑接口
 type UserService interface {
 	GetAllUsers() ([]models.UserResponse, error)
 	GetUserByID(id uint) (*models.UserResponse, error)
+	EnableIDFilter(capacity uint) error
+	EnableRegistrationFilter(capacity uint, snapshot string) error
+	SaveRegistrationFilter() error
+	IsUsernameAvailable(username string) (bool, error)
 	CreateUser(req *models.UserCreateRequest) (*models.UserResponse, error)
-	UpdateUser(id uint, req *models.UserUpdateRequest) (*models.UserResponse, error)
+	// CreateUserWithIdentity 创建用户并绑定第三方身份，两者在同一事务中保存
+	CreateUserWithIdentity(req *models.UserCreateRequest, identity *models.UserIdentity) (*models.UserResponse, error)
+	UpdateUser(id uint, req *models.UserUpdateRequest, operator *models.Operator) (*models.UserResponse, error)
 	DeleteUser(id uint) error
 	GetProfile(userID uint) (*models.UserResponse, error)
-	Login(req *models.LoginRequest) (*models.LoginResponse, error)
-	LoginByUserID(userID uint) (*models.LoginResponse, error)
-	ExitLogin(token string) error
-	// 批量操作（演示 make 和 Channel）
-	GetUsersByIDs(ids []uint) ([]models.UserResponse, error)
-	BatchCreateUsers(requests []*models.UserCreateRequest) ([]models.UserResponse, []error)
+	Login(req *models.LoginRequest, device *models.DeviceInfo) (*models.LoginResponse, error)
+	LoginByUserID(userID uint, device *models.DeviceInfo) (*models.LoginResponse, error)
+	// BeginLogin 用户已通过第三方认证（如微信扫码），需要两步验证时返回登录挑战，否则签发令牌
+	BeginLogin(userID uint, device *models.DeviceInfo) (*models.LoginResponse, error)
+	RefreshToken(refreshToken string) (*models.TokenResponse, error)
+	ExitLogin(token, refreshToken string) error
+	// 会话管理
+	ListSessions(userID uint, currentSessionID string) []utils.UserSession
+	RevokeSession(userID uint, sessionID string, operator *models.Operator) error
+	// 批量操作
+	GetUsersByIDs(ctx context.Context, ids []uint) ([]models.UserResponse, error)
+	BatchCreateUsers(ctx context.Context, requests []*models.UserCreateRequest) ([]models.UserResponse, []error)
 }
 
 // userService 用户业务逻辑实现
 type userService struct {
-	userRepo repositories.UserRepository
-	menuRepo repositories.MenuRepository
+	userRepo   repositories.UserRepository
+	menuRepo   repositories.MenuRepository
+	roleRepo   repositories.RoleRepository
+	loginGuard LoginGuardService
+	cache      utils.Cache
+	userLoader *utils.CacheLoader[models.UserResponse]
+	idFilter   *utils.RedisBloomFilter // 可选，存在的用户ID，各实例共享
+	// 可选，已注册的用户名和邮箱；在后台加载时设置，因此用原子指针
+	registerFilter atomic.Pointer[registrationFilter]
 }
 
 // NewUserService 创建用户服务实例
-func NewUserService(userRepo repositories.UserRepository, menuRepo repositories.MenuRepository) UserService {
+func NewUserService(userRepo repositories.UserRepository, menuRepo repositories.MenuRepository, roleRepo repositories.RoleRepository, loginGuard LoginGuardService, cache utils.Cache) UserService {
 	return &userService{
-		userRepo: userRepo,
-		menuRepo: menuRepo,
+		userRepo:   userRepo,
+		menuRepo:   menuRepo,
+		roleRepo:   roleRepo,
+		loginGuard: loginGuard,
+		cache:      cache,
+		userLoader: utils.NewCacheLoader[models.UserResponse](cache, utils.CacheLoaderOptions{
+			TTL:         userCacheExpiration,
+			NegativeTTL: userNegativeCacheExpiration,
+			Jitter:      0.1,
+		}),
 	}
 }
 
+const (
+	// userCacheExpiration 用户信息缓存时间
+	userCacheExpiration = 30 * time.Minute
+	// userNegativeCacheExpiration 不存在的用户ID的缓存时间，较短以免新建用户后长时间查不到
+	userNegativeCacheExpiration = time.Minute
+
+	// userIDFilterFalsePositive 用户ID布隆过滤器的误判率，误判只会多查一次缓存或数据库
+	userIDFilterFalsePositive = 0.01
+)
+
+func userCacheKey(id uint) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// invalidateUserProfile 用户资料变更后清除用户信息缓存，供不持有 userService 的服务调用
+func invalidateUserProfile(userID uint) {
+	utils.CacheDel(userCacheKey(userID))
+}
+
 // GetAllUsers 获取所有用户
 func (s *userService) GetAllUsers() ([]models.UserResponse, error) {
 	users, err := s.userRepo.FindAll()
@@ -55,40 +109,164 @@ func (s *userService) GetAllUsers() ([]models.UserResponse, error) {
 	return response, nil
 }
 
-// GetUserByID 根据ID获取用户
+// GetUserByID 根据ID获取用户（读穿缓存：并发未命中只查询一次数据库，不存在的用户也会短暂缓存）
 func (s *userService) GetUserByID(id uint) (*models.UserResponse, error) {
-	// 尝试从缓存获取
-	cacheKey := fmt.Sprintf("user:%d", id)
-	var cachedUser models.UserResponse
-	err := utils.CacheGet(cacheKey, &cachedUser)
-	if err == nil {
-		// 缓存命中
-		return &cachedUser, nil
+	// 布隆过滤器判定不存在的ID一定不存在，直接返回，不访问缓存和数据库
+	if !s.userIDMightExist(id) {
+		return nil, errors.New("用户不存在")
 	}
 
-	// 缓存未命中，从数据库查询
-	user, err := s.userRepo.FindByID(id)
+	response, err := s.userLoader.Load(userCacheKey(id), func() (*models.UserResponse, error) {
+		user, err := s.userRepo.FindByID(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		response := user.ToResponse()
+		return &response, nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	if response == nil {
+		return nil, errors.New("用户不存在")
+	}
+	return response, nil
+}
 
-	response := user.ToResponse()
+// EnableIDFilter 加载全部用户ID到 Redis 布隆过滤器，之后 GetUserByID 先用过滤器排除不存在的ID。
+// 过滤器由各实例共享，任一实例新建用户时写入，因此必须连接 Redis；内存过滤器会漏掉其他实例新建的用户，不再支持。
+// capacity 各实例必须一致（键名包含容量），应明显大于用户数。需在启动时、开始处理请求前调用
+func (s *userService) EnableIDFilter(capacity uint) error {
+	if config.RedisClient == nil {
+		return errors.New("用户ID布隆过滤器需要 Redis")
+	}
 
-	// 存入缓存，过期时间 30 分钟
-	utils.CacheSet(cacheKey, response, 30*time.Minute)
+	ids, err := s.userRepo.FindAllIDs()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = strconv.FormatUint(uint64(id), 10)
+	}
 
-	return &response, nil
+	key := fmt.Sprintf("bloom:user_id:%d", capacity)
+	filter := utils.NewRedisBloomFilter(config.RedisClient, key, capacity, userIDFilterFalsePositive)
+	if err := filter.AddMany(config.GetRedisContext(), keys...); err != nil {
+		return err
+	}
+	s.idFilter = filter
+	return nil
+}
+
+// userIDMightExist 用户ID是否可能存在，返回 false 时一定不存在。
+// Redis 出错或过滤器键已丢失（被淘汰、清空）时按可能存在处理
+func (s *userService) userIDMightExist(id uint) bool {
+	if s.idFilter == nil {
+		return true
+	}
+	ctx := config.GetRedisContext()
+	exists, err := s.idFilter.Contains(ctx, strconv.FormatUint(uint64(id), 10))
+	if err != nil || exists {
+		return true
+	}
+	loaded, err := s.idFilter.Exists(ctx)
+	return err != nil || !loaded
+}
+
+// EnableRegistrationFilter 加载全部用户名和邮箱到布隆过滤器，之后注册时过滤器判定一定不存在的值不再查询数据库。
+// 加载可能较慢，可在后台调用，加载完成前按原方式查询。capacity 应明显大于用户数，容量不足时只会多查数据库。
+// 过滤器在查询数据库之前设置，加载期间新注册的值同样会写入，不会因为查询结果中没有而漏掉。
+// Redis 不可用时先从 snapshot 快照恢复内存过滤器，恢复后立即启用，再用数据库中的值补全停机期间的变化
+func (s *userService) EnableRegistrationFilter(capacity uint, snapshot string) error {
+	filter := newRegistrationFilter(capacity)
+	if !filter.shared() && snapshot != "" {
+		filter.snapshot = snapshot
+		if err := filter.restore(snapshot); err == nil {
+			filter.ready.Store(true)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("注册过滤器快照加载失败: %v\n", err)
+		}
+	}
+	s.registerFilter.Store(filter)
+
+	users, err := s.userRepo.FindAllUsernamesAndEmails()
+	if err == nil {
+		keys := make([]string, 0, len(users)*2)
+		for _, user := range users {
+			keys = append(keys, usernameFilterKey(user.Username), emailFilterKey(user.Email))
+		}
+		err = filter.load(keys)
+	}
+	if err != nil {
+		s.registerFilter.Store(nil)
+		return err
+	}
+	filter.ready.Store(true)
+	return nil
+}
+
+// SaveRegistrationFilter 保存内存注册过滤器的快照，供下次启动时预热；停止服务时调用
+func (s *userService) SaveRegistrationFilter() error {
+	if filter := s.registerFilter.Load(); filter != nil {
+		return filter.save()
+	}
+	return nil
+}
+
+// usernameExists 检查用户名是否已被注册，过滤器判定一定不存在时不查询数据库
+func (s *userService) usernameExists(username string) bool {
+	if filter := s.registerFilter.Load(); filter != nil && !filter.mightExist(usernameFilterKey(username)) {
+		return false
+	}
+	existingUser, _ := s.userRepo.FindByUsername(username)
+	return existingUser != nil
+}
+
+// emailExists 检查邮箱是否已被注册，过滤器判定一定不存在时不查询数据库
+func (s *userService) emailExists(email string) bool {
+	if filter := s.registerFilter.Load(); filter != nil && !filter.mightExist(emailFilterKey(email)) {
+		return false
+	}
+	existingUser, _ := s.userRepo.FindByEmail(email)
+	return existingUser != nil
+}
+
+// IsUsernameAvailable 注册表单检查用户名是否可用。
+// 只有共享的 Redis 过滤器才能跳过查询：内存过滤器没有其他实例新注册的用户名，而这里没有唯一索引兜底
+func (s *userService) IsUsernameAvailable(username string) (bool, error) {
+	if filter := s.registerFilter.Load(); filter != nil && filter.shared() && !filter.mightExist(usernameFilterKey(username)) {
+		return true, nil
+	}
+	existingUser, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		return false, err
+	}
+	return existingUser == nil, nil
 }
 
 // CreateUser 创建用户
 func (s *userService) CreateUser(req *models.UserCreateRequest) (*models.UserResponse, error) {
+	return s.createUser(req, nil)
+}
+
+// CreateUserWithIdentity 创建用户并绑定第三方身份，身份保存失败时用户也不会创建
+func (s *userService) CreateUserWithIdentity(req *models.UserCreateRequest, identity *models.UserIdentity) (*models.UserResponse, error) {
+	return s.createUser(req, identity)
+}
+
+// createUser 创建用户，identity 不为空时与用户在同一事务中保存
+func (s *userService) createUser(req *models.UserCreateRequest, identity *models.UserIdentity) (*models.UserResponse, error) {
 	// 业务逻辑：检查用户名是否已存在
-	if existingUser, _ := s.userRepo.FindByUsername(req.Username); existingUser != nil {
+	if s.usernameExists(req.Username) {
 		return nil, errors.New("用户名已存在")
 	}
 
 	// 业务逻辑：检查邮箱是否已存在
-	if existingUser, _ := s.userRepo.FindByEmail(req.Email); existingUser != nil {
+	if s.emailExists(req.Email) {
 		return nil, errors.New("邮箱已存在")
 	}
 
@@ -109,16 +287,38 @@ func (s *userService) CreateUser(req *models.UserCreateRequest) (*models.UserRes
 	}
 
 	// 调用仓储层保存数据
-	if err := s.userRepo.Create(user); err != nil {
+	if identity != nil {
+		err = s.userRepo.CreateWithIdentity(user, identity)
+	} else {
+		err = s.userRepo.Create(user)
+	}
+	if err != nil {
+		// 并发注册或过滤器漏掉了其他实例新注册的值时由唯一索引拦截，重新查询给出明确的提示
+		if existingUser, _ := s.userRepo.FindByUsername(req.Username); existingUser != nil {
+			return nil, errors.New("用户名已存在")
+		}
+		if existingUser, _ := s.userRepo.FindByEmail(req.Email); existingUser != nil {
+			return nil, errors.New("邮箱已存在")
+		}
 		return nil, err
 	}
+	if filter := s.registerFilter.Load(); filter != nil {
+		filter.add(usernameFilterKey(user.Username), emailFilterKey(user.Email))
+	}
+	if s.idFilter != nil {
+		if err := s.idFilter.Add(config.GetRedisContext(), strconv.FormatUint(uint64(user.ID), 10)); err != nil {
+			fmt.Printf("用户ID过滤器写入失败: %v\n", err)
+		}
+	}
+	// 清除创建前可能写入的负缓存
+	s.userLoader.Forget(userCacheKey(user.ID))
 
 	response := user.ToResponse()
 	return &response, nil
 }
 
 // UpdateUser 更新用户
-func (s *userService) UpdateUser(id uint, req *models.UserUpdateRequest) (*models.UserResponse, error) {
+func (s *userService) UpdateUser(id uint, req *models.UserUpdateRequest, operator *models.Operator) (*models.UserResponse, error) {
 	// 业务逻辑：检查用户是否存在
 	user, err := s.userRepo.FindByID(id)
 	if err != nil {
@@ -138,15 +338,19 @@ func (s *userService) UpdateUser(id uint, req *models.UserUpdateRequest) (*model
 	}
 
 	// 业务逻辑：如果更新邮箱，检查邮箱是否已被其他用户使用
-	if req.Email != "" && req.Email != user.Email {
+	emailChanged := req.Email != "" && req.Email != user.Email
+	if emailChanged {
 		if existingUser, _ := s.userRepo.FindByEmail(req.Email); existingUser != nil && existingUser.ID != id {
 			return nil, errors.New("邮箱已被使用")
 		}
 		user.Email = req.Email
+		user.EmailVerifiedAt = nil // 新邮箱需要重新验证
 	}
 
 	// 更新字段
-	if req.RoleID > 0 {
+	oldRoleID := user.RoleID
+	roleChanged := req.RoleID > 0 && req.RoleID != user.RoleID
+	if roleChanged {
 		user.RoleID = req.RoleID
 	}
 	if req.Nickname != "" {
@@ -162,6 +366,19 @@ func (s *userService) UpdateUser(id uint, req *models.UserUpdateRequest) (*model
 	if err := s.userRepo.Update(user); err != nil {
 		return nil, err
 	}
+	s.userLoader.Forget(userCacheKey(id))
+	// 旧邮箱留在过滤器中，只会导致多查一次数据库
+	if filter := s.registerFilter.Load(); filter != nil && emailChanged {
+		filter.add(emailFilterKey(user.Email))
+	}
+	if roleChanged {
+		invalidateUserRole(id)
+		recordOperatorEvent(operator, &models.SecurityEvent{
+			UserID: id,
+			Type:   models.SecurityEventRoleChanged,
+			Detail: fmt.Sprintf("角色由 %d 变更为 %d", oldRoleID, user.RoleID),
+		})
+	}
 
 	response := user.ToResponse()
 	return &response, nil
@@ -177,7 +394,13 @@ func (s *userService) DeleteUser(id uint) error {
 	// 业务逻辑：这里可以添加其他检查，比如是否有关联数据等
 
 	// 调用仓储层删除数据
-	return s.userRepo.Delete(id)
+	if err := s.userRepo.Delete(id); err != nil {
+		return err
+	}
+	// 注册过滤器不删除已注销的用户名和邮箱：过滤器只有本实例添加过的值，删除会让其他值漏判，旧值只会导致多查一次数据库
+	s.userLoader.Forget(userCacheKey(id))
+	invalidateUserRole(id)
+	return nil
 }
 
 // GetProfile 获取用户个人信息
@@ -193,69 +416,111 @@ func (s *userService) GetProfile(userID uint) (*models.UserResponse, error) {
 }
 
 // Login 用户登录
-func (s *userService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
-	// 业务逻辑：验证验证码
-	if !utils.VerifyCaptcha(req.CaptchaID, req.Captcha) {
-		return nil, errors.New("验证码错误或已过期")
+func (s *userService) Login(req *models.LoginRequest, device *models.DeviceInfo) (*models.LoginResponse, error) {
+	// 业务逻辑：用户名或 IP 失败次数过多时暂时拒绝登录；未锁定时按风险决定是否需要验证码
+	var risk *models.RiskAssessment
+	if s.loginGuard != nil {
+		s.loginGuard.TrackRequest(device)
+		if err := s.loginGuard.Check(req.Username, device); err != nil {
+			return nil, err
+		}
+		risk = s.loginGuard.Assess(models.RiskSceneLogin, req.Username, device)
+	}
+	if err := CheckCaptcha(risk, req.CaptchaType, req.CaptchaID, req.Captcha); err != nil {
+		return nil, err
 	}
 
 	// 业务逻辑：查找用户并预加载角色信息
 	user, err := s.userRepo.FindByUsername(req.Username)
 	if err != nil || user == nil {
+		s.recordLoginFailure(req.Username, 0, device, "用户不存在")
 		return nil, errors.New("用户名或密码错误")
 	}
 
 	// 业务逻辑：验证密码
 	if !utils.CheckPassword(req.Password, user.Password) {
+		s.recordLoginFailure(req.Username, user.ID, device, "密码错误")
 		return nil, errors.New("用户名或密码错误")
 	}
 
-	// 业务逻辑：生成 JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Email)
-	if err != nil {
-		return nil, errors.New("生成 token 失败")
+	if device != nil && device.LoginMethod == "" {
+		device.LoginMethod = models.SecurityEventLoginPassword
 	}
 
-	// 单点登录：设置用户的当前 token，旧 token 会被自动加入黑名单
-	if err := utils.SetUserToken(user.ID, token); err != nil {
-		return nil, errors.New("设置用户 token 失败")
+	// 已启用两步验证或角色强制两步验证时，先返回登录挑战，不签发令牌；
+	// 失败计数保留到第二因素通过后才清零，见 twoFactorService.CompleteLogin
+	if challenge, err := secondFactorChallenge(s.roleRepo, user, device); err != nil || challenge != nil {
+		return challenge, err
 	}
 
-	// 获取用户菜单
-	var menus []models.MenuTreeResponse
-	if user.RoleID > 0 {
-		userMenus, err := s.menuRepo.FindByRoleID(user.RoleID)
-		if err == nil {
-			menus = s.menuRepo.BuildMenuTree(userMenus)
-		}
+	if s.loginGuard != nil {
+		s.loginGuard.RecordSuccess(req.Username, user.ID, device)
 	}
+	return s.issueLogin(user, device)
+}
 
-	// 返回登录响应
-	return &models.LoginResponse{
-		Token: token,
-		User:  user.ToResponse(),
-		Menus: menus,
-	}, nil
+// recordLoginFailure 记录登录失败
+func (s *userService) recordLoginFailure(username string, userID uint, device *models.DeviceInfo, reason string) {
+	if s.loginGuard != nil {
+		s.loginGuard.RecordFailure(username, userID, device, reason)
+	}
+}
 
+// BeginLogin 已通过第一因素认证的用户登录，与密码登录一样先检查两步验证
+func (s *userService) BeginLogin(userID uint, device *models.DeviceInfo) (*models.LoginResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	if challenge, err := secondFactorChallenge(s.roleRepo, user, device); err != nil || challenge != nil {
+		return challenge, err
+	}
+	return s.issueLogin(user, device)
 }
 
-// LoginByUserID 根据用户ID直接登录（用于微信、OAuth等）
-func (s *userService) LoginByUserID(userID uint) (*models.LoginResponse, error) {
+// LoginByUserID 根据用户ID直接签发令牌，调用方需已完成全部认证（包括两步验证）
+func (s *userService) LoginByUserID(userID uint, device *models.DeviceInfo) (*models.LoginResponse, error) {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil || user == nil {
 		return nil, errors.New("用户不存在")
 	}
 
-	// 生成 JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Email)
+	return s.issueLogin(user, device)
+}
+
+// issueLogin 为已认证的用户签发令牌对、登记会话并组装登录响应
+func (s *userService) issueLogin(user *models.User, device *models.DeviceInfo) (*models.LoginResponse, error) {
+	// 生成访问令牌和刷新令牌
+	pair, err := utils.GenerateTokenPair(user.ID, user.Username, user.Email)
 	if err != nil {
 		return nil, errors.New("生成 token 失败")
 	}
 
-	// 单点登录
-	if err := utils.SetUserToken(user.ID, token); err != nil {
-		return nil, errors.New("设置用户 token 失败")
+	// 登记登录会话，超出角色允许的会话数时踢掉最久未活跃的设备
+	session := utils.UserSession{ID: pair.FamilyID, UserID: user.ID}
+	if device != nil {
+		session.DeviceName = device.DeviceName
+		session.IP = device.IP
+		session.UserAgent = device.UserAgent
+	}
+	if err := utils.RegisterSession(session, s.maxSessions(user.RoleID)); err != nil {
+		return nil, errors.New("登记登录会话失败")
+	}
+
+	// 记录登录事件
+	event := &models.SecurityEvent{
+		UserID:    user.ID,
+		Type:      models.SecurityEventLoginPassword,
+		SessionID: pair.FamilyID,
+		IP:        session.IP,
+		UserAgent: session.UserAgent,
+		Detail:    session.DeviceName,
+	}
+	if device != nil && device.LoginMethod != "" {
+		event.Type = device.LoginMethod
 	}
+	utils.EmitSecurityEvent(event)
 
 	// 获取用户菜单
 	var menus []models.MenuTreeResponse
@@ -267,87 +532,175 @@ func (s *userService) LoginByUserID(userID uint) (*models.LoginResponse, error)
 	}
 
 	return &models.LoginResponse{
-		Token: token,
-		User:  user.ToResponse(),
-		Menus: menus,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+		User:         user.ToResponse(),
+		Menus:        menus,
+	}, nil
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌对（每次使用都会轮换）
+func (s *userService) RefreshToken(refreshToken string) (*models.TokenResponse, error) {
+	userID, familyID, err := utils.ConsumeRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// 会话已被注销（如被其他设备挤下线或被管理员踢出）
+	if !utils.IsSessionActive(userID, familyID) {
+		utils.RevokeRefreshFamily(familyID)
+		return nil, utils.ErrSessionNotFound
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		utils.RevokeRefreshFamily(familyID)
+		return nil, errors.New("用户不存在")
+	}
+
+	pair, err := utils.IssueTokenPair(user.ID, user.Username, user.Email, familyID)
+	if err != nil {
+		return nil, errors.New("生成 token 失败")
+	}
+
+	return &models.TokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
 	}, nil
 }
 
 // ExitLogin 退出登录
-func (s *userService) ExitLogin(token string) error {
+func (s *userService) ExitLogin(token, refreshToken string) error {
 	// 业务逻辑：验证 token
-	if _, err := utils.ParseToken(token); err != nil {
+	claims, err := utils.ParseToken(token)
+	if err != nil {
 		return errors.New("token 无效")
 	}
 
 	// 清除 token 缓存
 	utils.ClearToken(token)
 
+	// 注销当前会话
+	if claims.SessionID != "" {
+		utils.RevokeSession(claims.UserID, claims.SessionID)
+	}
+
+	// 吊销刷新令牌家族，防止退出后继续换取新令牌
+	if refreshToken != "" {
+		utils.RevokeRefreshToken(refreshToken)
+	}
+
+	utils.EmitSecurityEvent(&models.SecurityEvent{
+		UserID:    claims.UserID,
+		Type:      models.SecurityEventLogout,
+		SessionID: claims.SessionID,
+	})
+
 	return nil
 }
 
-// GetUsersByIDs 批量获取用户（演示 make 创建切片和 Channel 并发）
-func (s *userService) GetUsersByIDs(ids []uint) ([]models.UserResponse, error) {
-	// 使用 make 创建结果切片
-	results := make([]models.UserResponse, 0, len(ids))
-
-	// 使用 make 创建 Channel
-	type userResult struct {
-		user *models.UserResponse
-		err  error
+// ListSessions 获取用户的在线会话列表
+func (s *userService) ListSessions(userID uint, currentSessionID string) []utils.UserSession {
+	sessions := utils.ListSessions(userID)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
 	}
-	resultChan := make(chan userResult, len(ids))
+	return sessions
+}
 
-	// 并发获取用户信息
-	for _, id := range ids {
-		go func(userID uint) {
-			user, err := s.GetUserByID(userID)
-			resultChan <- userResult{user: user, err: err}
-		}(id)
+// RevokeSession 注销用户的指定会话
+func (s *userService) RevokeSession(userID uint, sessionID string, operator *models.Operator) error {
+	if err := utils.RevokeSession(userID, sessionID); err != nil {
+		return err
 	}
 
-	// 收集结果
-	for i := 0; i < len(ids); i++ {
-		result := <-resultChan
-		if result.err == nil && result.user != nil {
-			results = append(results, *result.user)
+	recordOperatorEvent(operator, &models.SecurityEvent{
+		UserID:    userID,
+		Type:      models.SecurityEventSessionRevoked,
+		SessionID: sessionID,
+	})
+	return nil
+}
+
+// maxSessions 获取角色允许的最大同时在线会话数，角色未配置时使用系统默认值
+func (s *userService) maxSessions(roleID uint) int {
+	if roleID > 0 && s.roleRepo != nil {
+		if role, err := s.roleRepo.FindByID(roleID); err == nil && role.MaxSessions > 0 {
+			return role.MaxSessions
 		}
 	}
-
-	return results, nil
+	return config.AppConfig.Auth.MaxSessions
 }
 
-// BatchCreateUsers 批量创建用户（演示 make 和 Channel）
-func (s *userService) BatchCreateUsers(requests []*models.UserCreateRequest) ([]models.UserResponse, []error) {
-	// 使用 make 创建结果切片
-	successUsers := make([]models.UserResponse, 0)
-	errors := make([]error, 0)
+// 批量操作的并发数
+const (
+	batchGetUsersWorkers    = 8
+	batchCreateUsersWorkers = 4
+)
+
+// GetUsersByIDs 批量获取用户，结果按 ids 的顺序排列，不存在的用户被忽略
+func (s *userService) GetUsersByIDs(ctx context.Context, ids []uint) ([]models.UserResponse, error) {
+	users, err := utils.ParallelMap(ctx, ids, func(ctx context.Context, id uint) (*models.UserResponse, error) {
+		return s.GetUserByID(id)
+	}, utils.BatchOptions{Workers: batchGetUsersWorkers})
 
-	// 使用 make 创建 Channel
-	type createResult struct {
-		user  *models.UserResponse
-		err   error
-		index int
+	// 单个用户查询失败（如不存在）不影响其他用户，只有整体被取消时才返回错误
+	var itemErrs utils.BatchErrors
+	if err != nil && !errors.As(err, &itemErrs) {
+		return nil, err
 	}
-	resultChan := make(chan createResult, len(requests))
 
-	// 并发创建用户
-	for i, req := range requests {
-		go func(index int, request *models.UserCreateRequest) {
-			user, err := s.CreateUser(request)
-			resultChan <- createResult{user: user, err: err, index: index}
-		}(i, req)
+	results := make([]models.UserResponse, 0, len(ids))
+	for _, user := range users {
+		if user != nil {
+			results = append(results, *user)
+		}
 	}
+	return results, nil
+}
 
-	// 收集结果
-	for i := 0; i < len(requests); i++ {
-		result := <-resultChan
-		if result.err != nil {
-			errors = append(errors, fmt.Errorf("索引 %d: %v", result.index, result.err))
-		} else if result.user != nil {
-			successUsers = append(successUsers, *result.user)
+// errBatchDuplicate 批量创建时同一批中有重复的用户名或邮箱
+var errBatchDuplicate = errors.New("与同批请求中的用户名或邮箱重复")
+
+// BatchCreateUsers 批量创建用户，成功的用户按请求顺序返回，错误带有请求的下标
+func (s *userService) BatchCreateUsers(ctx context.Context, requests []*models.UserCreateRequest) ([]models.UserResponse, []error) {
+	// 同一批中重复的用户名或邮箱直接报错，避免并发创建时相互竞争
+	usernames := make(map[string]bool, len(requests))
+	emails := make(map[string]bool, len(requests))
+	duplicates := make(map[*models.UserCreateRequest]bool)
+	for _, req := range requests {
+		username, email := strings.ToLower(req.Username), strings.ToLower(req.Email)
+		if usernames[username] || emails[email] {
+			duplicates[req] = true
+			continue
 		}
+		usernames[username], emails[email] = true, true
 	}
 
-	return successUsers, errors
+	users, err := utils.ParallelMap(ctx, requests, func(ctx context.Context, req *models.UserCreateRequest) (*models.UserResponse, error) {
+		if duplicates[req] {
+			return nil, errBatchDuplicate
+		}
+		return s.CreateUser(req)
+	}, utils.BatchOptions{Workers: batchCreateUsersWorkers})
+
+	successUsers := make([]models.UserResponse, 0, len(users))
+	for _, user := range users {
+		if user != nil {
+			successUsers = append(successUsers, *user)
+		}
+	}
+
+	errs := make([]error, 0)
+	var itemErrs utils.BatchErrors
+	if errors.As(err, &itemErrs) {
+		for _, itemErr := range itemErrs {
+			errs = append(errs, itemErr)
+		}
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	return successUsers, errs
 }
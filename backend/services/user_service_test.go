@@ -1,15 +1,34 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"gin-backend/config"
 	"gin-backend/models"
 	"gin-backend/repositories"
+	"gin-backend/utils"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
+// TestMain 使用默认配置和不落盘的内存缓存运行服务层测试
+func TestMain(m *testing.M) {
+	config.AppConfig = &config.Config{
+		Auth: config.AuthConfig{MaxSessions: 5, TOTPIssuer: "Test"},
+	}
+	utils.SetDefaultCache(utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	os.Exit(m.Run())
+}
+
 // MockUserRepository 模拟用户仓储
 type MockUserRepository struct {
 	mock.Mock
@@ -20,6 +39,16 @@ func (m *MockUserRepository) FindAll() ([]models.User, error) {
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
+func (m *MockUserRepository) FindAllIDs() ([]uint, error) {
+	args := m.Called()
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func (m *MockUserRepository) FindAllUsernamesAndEmails() ([]models.User, error) {
+	args := m.Called()
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
 func (m *MockUserRepository) FindByID(id uint) (*models.User, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
@@ -49,6 +78,11 @@ func (m *MockUserRepository) Create(user *models.User) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) CreateWithIdentity(user *models.User, identity *models.UserIdentity) error {
+	args := m.Called(user, identity)
+	return args.Error(0)
+}
+
 func (m *MockUserRepository) Update(user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
@@ -67,9 +101,60 @@ func (m *MockUserRepository) CountByRoleID(roleID uint) (int64, error) {
 // 确保 MockUserRepository 实现了 UserRepository 接口
 var _ repositories.UserRepository = (*MockUserRepository)(nil)
 
+// MockMenuRepository 模拟菜单仓储
+type MockMenuRepository struct {
+	mock.Mock
+}
+
+func (m *MockMenuRepository) Create(menu *models.Menu) error {
+	args := m.Called(menu)
+	return args.Error(0)
+}
+
+func (m *MockMenuRepository) Update(menu *models.Menu) error {
+	args := m.Called(menu)
+	return args.Error(0)
+}
+
+func (m *MockMenuRepository) Delete(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockMenuRepository) FindByID(id uint) (*models.Menu, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Menu), args.Error(1)
+}
+
+func (m *MockMenuRepository) FindAll() ([]models.Menu, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Menu), args.Error(1)
+}
+
+func (m *MockMenuRepository) FindByRoleID(roleID uint) ([]models.Menu, error) {
+	args := m.Called(roleID)
+	return args.Get(0).([]models.Menu), args.Error(1)
+}
+
+func (m *MockMenuRepository) FindPermissionsByRoleID(roleID uint) ([]string, error) {
+	args := m.Called(roleID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockMenuRepository) BuildMenuTree(menus []models.Menu) []models.MenuTreeResponse {
+	args := m.Called(menus)
+	return args.Get(0).([]models.MenuTreeResponse)
+}
+
+// 确保 MockMenuRepository 实现了 MenuRepository 接口
+var _ repositories.MenuRepository = (*MockMenuRepository)(nil)
+
 func TestGetAllUsers(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
 
 	expectedUsers := []models.User{
 		{ID: 1, Username: "user1", Email: "user1@example.com"},
@@ -87,7 +172,7 @@ func TestGetAllUsers(t *testing.T) {
 
 func TestCreateUser_Success(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
 
 	req := &models.UserCreateRequest{
 		Username: "newuser",
@@ -110,7 +195,7 @@ func TestCreateUser_Success(t *testing.T) {
 
 func TestCreateUser_UsernameExists(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
 
 	req := &models.UserCreateRequest{
 		Username: "existinguser",
@@ -128,3 +213,232 @@ func TestCreateUser_UsernameExists(t *testing.T) {
 	assert.Equal(t, "用户名已存在", err.Error())
 	mockRepo.AssertExpectations(t)
 }
+
+func TestCreateUser_RegistrationFilter(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+
+	// 加载期间注册的用户名不在查询结果中，也要写入过滤器
+	mockRepo.On("FindAllUsernamesAndEmails").Run(func(args mock.Arguments) {
+		mockRepo.On("FindByUsername", "erin").Return(nil, nil).Once()
+		mockRepo.On("FindByEmail", "erin@example.com").Return(nil, nil).Once()
+		mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil).Once()
+		_, err := service.CreateUser(&models.UserCreateRequest{Username: "erin", Email: "erin@example.com", Password: "password123"})
+		assert.NoError(t, err)
+	}).Return([]models.User{{Username: "Alice", Email: "alice@example.com"}}, nil)
+	assert.NoError(t, service.EnableRegistrationFilter(1000, ""))
+	mockRepo.On("FindByUsername", "erin").Return(&models.User{ID: 5, Username: "erin"}, nil)
+	_, err := service.CreateUser(&models.UserCreateRequest{Username: "erin", Email: "erin2@example.com", Password: "password123"})
+	assert.EqualError(t, err, "用户名已存在")
+
+	// 过滤器判定一定不存在的值注册时不查询数据库
+	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil).Once()
+	_, err = service.CreateUser(&models.UserCreateRequest{Username: "bob", Email: "bob@example.com", Password: "password123"})
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "FindByUsername", "bob")
+	mockRepo.AssertNotCalled(t, "FindByEmail", "bob@example.com")
+
+	// 内存过滤器没有其他实例新注册的用户名，可用性检查仍查询数据库
+	mockRepo.On("FindByUsername", "frank").Return(&models.User{ID: 6, Username: "frank"}, nil)
+	available, err := service.IsUsernameAvailable("frank")
+	assert.NoError(t, err)
+	assert.False(t, available)
+
+	// 不区分大小写
+	mockRepo.On("FindByUsername", "alice").Return(&models.User{ID: 1, Username: "Alice"}, nil)
+	_, err = service.CreateUser(&models.UserCreateRequest{Username: "alice", Email: "other@example.com", Password: "password123"})
+	assert.EqualError(t, err, "用户名已存在")
+
+	// 过滤器漏判时由唯一索引拦截，重新查询给出明确的提示
+	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(errors.New("Duplicate entry 'carol'")).Once()
+	mockRepo.On("FindByUsername", "carol").Return(&models.User{ID: 3, Username: "carol"}, nil)
+	_, err = service.CreateUser(&models.UserCreateRequest{Username: "carol", Email: "carol@example.com", Password: "password123"})
+	assert.EqualError(t, err, "用户名已存在")
+
+	// 删除用户后不从过滤器中移除，避免清掉其他值共用的位
+	mockRepo.On("FindByID", uint(2)).Return(&models.User{ID: 2, Username: "bob", Email: "bob@example.com"}, nil)
+	mockRepo.On("Delete", uint(2)).Return(nil)
+	assert.NoError(t, service.DeleteUser(2))
+	filter := service.(*userService).registerFilter.Load()
+	assert.True(t, filter.mightExist(usernameFilterKey("bob")))
+	assert.True(t, filter.mightExist(emailFilterKey("bob@example.com")))
+}
+
+func TestRegistrationFilterSnapshot(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "register_filter.bloom")
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("FindAllUsernamesAndEmails").Return([]models.User{{Username: "Alice", Email: "alice@example.com"}}, nil).Once()
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+
+	// 加载完成前不保存，避免把不完整的过滤器写入快照
+	assert.NoError(t, service.SaveRegistrationFilter())
+	assert.NoError(t, service.EnableRegistrationFilter(1000, snapshot))
+	assert.NoError(t, service.SaveRegistrationFilter())
+
+	// 重启后先用快照判断，不必等数据库加载完成
+	restarted := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	mockRepo.On("FindAllUsernamesAndEmails").Run(func(args mock.Arguments) {
+		internal := restarted.(*userService)
+		assert.False(t, internal.usernameExists("zed"))
+		mockRepo.AssertNotCalled(t, "FindByUsername", "zed")
+		mockRepo.On("FindByUsername", "alice").Return(&models.User{ID: 1, Username: "Alice"}, nil).Once()
+		assert.True(t, internal.usernameExists("alice"))
+	}).Return([]models.User{{Username: "Alice", Email: "alice@example.com"}}, nil).Once()
+	assert.NoError(t, restarted.EnableRegistrationFilter(1000, snapshot))
+
+	// 快照损坏时不使用，数据库加载完成前照常查询
+	assert.NoError(t, os.WriteFile(snapshot, []byte("broken"), 0644))
+	corrupted := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	mockRepo.On("FindAllUsernamesAndEmails").Run(func(args mock.Arguments) {
+		mockRepo.On("FindByUsername", "zed").Return(nil, nil).Once()
+		assert.False(t, corrupted.(*userService).usernameExists("zed"))
+		mockRepo.AssertCalled(t, "FindByUsername", "zed")
+	}).Return([]models.User{}, nil).Once()
+	assert.NoError(t, corrupted.EnableRegistrationFilter(1000, snapshot))
+}
+
+func TestIsUsernameAvailable_SharedRegistrationFilter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", MaxRetries: -1, DialerRetries: 1})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+	original := config.RedisClient
+	config.RedisClient = client
+	t.Cleanup(func() { config.RedisClient = original })
+	capacity := uint(time.Now().UnixNano()%1000000) + 1000 // 每次使用不同的键
+	key := fmt.Sprintf("bloom:register:%d", capacity)
+	t.Cleanup(func() { client.Del(context.Background(), key) })
+
+	mockRepo := new(MockUserRepository)
+	users := make([]models.User, 2500) // 超过一个管道批次
+	for i := range users {
+		users[i] = models.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i)}
+	}
+	mockRepo.On("FindAllUsernamesAndEmails").Return(users, nil)
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	assert.NoError(t, service.EnableRegistrationFilter(capacity, ""))
+
+	// 共享过滤器判定一定不存在时不查询数据库
+	available, err := service.IsUsernameAvailable("newcomer")
+	assert.NoError(t, err)
+	assert.True(t, available)
+	mockRepo.AssertNotCalled(t, "FindByUsername", "newcomer")
+
+	mockRepo.On("FindByUsername", "User2499").Return(&models.User{ID: 2500, Username: "user2499"}, nil)
+	available, err = service.IsUsernameAvailable("User2499")
+	assert.NoError(t, err)
+	assert.False(t, available)
+
+	// 过滤器键丢失后不再信任否定结果
+	client.Del(context.Background(), key)
+	mockRepo.On("FindByUsername", "newcomer").Return(&models.User{ID: 2501, Username: "newcomer"}, nil)
+	available, err = service.IsUsernameAvailable("newcomer")
+	assert.NoError(t, err)
+	assert.False(t, available)
+}
+
+func TestBatchCreateUsers_DuplicateInBatch(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+
+	mockRepo.On("FindByUsername", "dave").Return(nil, nil)
+	mockRepo.On("FindByEmail", "dave@example.com").Return(nil, nil)
+	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil)
+
+	users, errs := service.BatchCreateUsers(context.Background(), []*models.UserCreateRequest{
+		{Username: "dave", Email: "dave@example.com", Password: "password123"},
+		{Username: "Dave", Email: "dave2@example.com", Password: "password123"},
+	})
+	assert.Len(t, users, 1)
+	assert.Len(t, errs, 1)
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestGetUserByID_ReadThrough(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+
+	user := &models.User{ID: 1, Username: "cached", Nickname: "旧昵称"}
+	mockRepo.On("FindByID", uint(1)).Return(user, nil)
+	mockRepo.On("FindByID", uint(404)).Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("Update", user).Return(nil)
+
+	// 第二次读取命中缓存
+	for i := 0; i < 2; i++ {
+		resp, err := service.GetUserByID(1)
+		assert.NoError(t, err)
+		assert.Equal(t, "旧昵称", resp.Nickname)
+	}
+	mockRepo.AssertNumberOfCalls(t, "FindByID", 1)
+
+	// 不存在的用户也被缓存，不会反复查询数据库
+	for i := 0; i < 2; i++ {
+		_, err := service.GetUserByID(404)
+		assert.EqualError(t, err, "用户不存在")
+	}
+	mockRepo.AssertNumberOfCalls(t, "FindByID", 2)
+
+	// 更新后缓存失效，读到新资料
+	_, err := service.UpdateUser(1, &models.UserUpdateRequest{Nickname: "新昵称"}, nil)
+	assert.NoError(t, err)
+	resp, err := service.GetUserByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "新昵称", resp.Nickname)
+}
+
+func TestGetUserByID_IDFilter(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+
+	// 未连接 Redis 时不启用：内存过滤器会漏掉其他实例新建的用户
+	original := config.RedisClient
+	config.RedisClient = nil
+	assert.Error(t, service.EnableIDFilter(1000))
+	config.RedisClient = original
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", MaxRetries: -1, DialerRetries: 1})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+	config.RedisClient = client
+	t.Cleanup(func() { config.RedisClient = original })
+	capacity := uint(time.Now().UnixNano()%1000000) + 1000 // 每次使用不同的键
+	t.Cleanup(func() { client.Del(context.Background(), fmt.Sprintf("bloom:user_id:%d", capacity)) })
+
+	mockRepo.On("FindAllIDs").Return([]uint{1, 2}, nil)
+	mockRepo.On("FindByID", uint(2)).Return(&models.User{ID: 2, Username: "filtered"}, nil)
+	assert.NoError(t, service.EnableIDFilter(capacity))
+
+	// 过滤器中没有的ID直接返回不存在
+	_, err := service.GetUserByID(999)
+	assert.EqualError(t, err, "用户不存在")
+	mockRepo.AssertNotCalled(t, "FindByID", uint(999))
+
+	resp, err := service.GetUserByID(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "filtered", resp.Username)
+
+	// 其他实例新建的用户写入同一个 Redis 过滤器，本实例也能查到
+	other := NewUserService(mockRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	assert.NoError(t, other.EnableIDFilter(capacity))
+	mockRepo.On("FindByUsername", "newcomer").Return(nil, nil)
+	mockRepo.On("FindByEmail", "newcomer@example.com").Return(nil, nil)
+	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.User).ID = 3
+	}).Return(nil).Once()
+	_, err = other.CreateUser(&models.UserCreateRequest{Username: "newcomer", Email: "newcomer@example.com", Password: "password123"})
+	assert.NoError(t, err)
+	mockRepo.On("FindByID", uint(3)).Return(&models.User{ID: 3, Username: "newcomer"}, nil)
+	resp, err = service.GetUserByID(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "newcomer", resp.Username)
+
+	// 过滤器键丢失后不再信任否定结果
+	client.Del(context.Background(), fmt.Sprintf("bloom:user_id:%d", capacity))
+	mockRepo.On("FindByID", uint(999)).Return(nil, gorm.ErrRecordNotFound)
+	_, err = service.GetUserByID(999)
+	assert.EqualError(t, err, "用户不存在")
+	mockRepo.AssertCalled(t, "FindByID", uint(999))
+}
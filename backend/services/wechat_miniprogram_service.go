@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"log"
+
+	"github.com/silenceper/wechat/v2"
+	"github.com/silenceper/wechat/v2/miniprogram"
+	"github.com/silenceper/wechat/v2/miniprogram/auth"
+	miniConfig "github.com/silenceper/wechat/v2/miniprogram/config"
+	"github.com/silenceper/wechat/v2/miniprogram/encryptor"
+)
+
+// WechatMiniIdentityProvider 小程序 OpenID 在第三方身份表中的提供方标识
+const WechatMiniIdentityProvider = "wechat_mini"
+
+var (
+	errWechatMiniNotConfigured = errors.New("未配置微信小程序")
+	errWechatMiniCode          = errors.New("微信登录凭证无效或已过期")
+	errWechatMiniDecrypt       = errors.New("微信加密数据解密失败")
+	errWechatMiniNotBound      = errors.New("该微信未绑定账号")
+)
+
+// WechatMiniProgramClient 小程序接口：登录凭证校验与加密数据解密，测试中替换为假实现
+type WechatMiniProgramClient interface {
+	Code2Session(code string) (*auth.ResCode2Session, error)
+	Decrypt(sessionKey, encryptedData, iv string) (*encryptor.PlainData, error)
+}
+
+// miniProgramClient 基于 SDK 的小程序接口实现
+type miniProgramClient struct {
+	mp *miniprogram.MiniProgram
+}
+
+// NewWechatMiniProgramClient 根据配置创建小程序接口，未配置 AppID 时返回 nil
+func NewWechatMiniProgramClient() WechatMiniProgramClient {
+	if config.AppConfig.Wechat.MiniAppID == "" {
+		return nil
+	}
+	mp := wechat.NewWechat().GetMiniProgram(&miniConfig.Config{
+		AppID:     config.AppConfig.Wechat.MiniAppID,
+		AppSecret: config.AppConfig.Wechat.MiniAppSecret,
+	})
+	return &miniProgramClient{mp: mp}
+}
+
+func (c *miniProgramClient) Code2Session(code string) (*auth.ResCode2Session, error) {
+	result, err := c.mp.GetAuth().Code2Session(code)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *miniProgramClient) Decrypt(sessionKey, encryptedData, iv string) (*encryptor.PlainData, error) {
+	return c.mp.GetEncryptor().Decrypt(sessionKey, encryptedData, iv)
+}
+
+// WechatMiniProgramService 微信小程序登录接口
+type WechatMiniProgramService interface {
+	Login(req *models.WechatMiniProgramLoginRequest, device *models.DeviceInfo) (*models.LoginResponse, error)
+}
+
+// wechatMiniProgramService 微信小程序登录实现，同一 UnionID 与公众号扫码登录的账号互通
+type wechatMiniProgramService struct {
+	client       WechatMiniProgramClient
+	identityRepo repositories.UserIdentityRepository
+	userRepo     repositories.UserRepository
+	roleRepo     repositories.RoleRepository
+	userService  UserService
+}
+
+// NewWechatMiniProgramService 创建微信小程序登录服务实例，client 为 nil 表示未配置小程序
+func NewWechatMiniProgramService(client WechatMiniProgramClient, identityRepo repositories.UserIdentityRepository, userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, userService UserService) WechatMiniProgramService {
+	return &wechatMiniProgramService{
+		client:       client,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		userService:  userService,
+	}
+}
+
+// Login 用 wx.login 的 code 换取 OpenID 和会话密钥，解密可选的用户信息和手机号后登录：
+// 已绑定的 OpenID 直接登录；未绑定时按 UnionID 关联公众号扫码登录过的账号；都没有时自动注册
+func (s *wechatMiniProgramService) Login(req *models.WechatMiniProgramLoginRequest, device *models.DeviceInfo) (*models.LoginResponse, error) {
+	if s.client == nil {
+		return nil, errWechatMiniNotConfigured
+	}
+
+	session, err := s.client.Code2Session(req.Code)
+	if err != nil {
+		log.Printf("小程序 code2session 失败: %v", err)
+		return nil, errWechatMiniCode
+	}
+
+	unionID := session.UnionID
+	var profile *encryptor.PlainData
+	if req.UserInfo != nil {
+		if profile, err = s.client.Decrypt(session.SessionKey, req.UserInfo.EncryptedData, req.UserInfo.IV); err != nil {
+			return nil, errWechatMiniDecrypt
+		}
+		if unionID == "" {
+			unionID = profile.UnionID
+		}
+	}
+	var phone string
+	if req.Phone != nil {
+		data, err := s.client.Decrypt(session.SessionKey, req.Phone.EncryptedData, req.Phone.IV)
+		if err != nil {
+			return nil, errWechatMiniDecrypt
+		}
+		phone = data.PurePhoneNumber
+	}
+
+	identity, err := s.identityRepo.FindByProviderSubject(WechatMiniIdentityProvider, session.OpenID)
+	if err != nil {
+		return nil, err
+	}
+	if identity == nil {
+		if identity, err = linkWechatByUnionID(s.identityRepo, WechatMiniIdentityProvider, session.OpenID, unionID); err != nil {
+			return nil, err
+		}
+	} else if identity.UnionID == "" && unionID != "" {
+		identity.UnionID = unionID
+		if err := s.identityRepo.Update(identity); err != nil {
+			return nil, err
+		}
+	}
+	registered := false
+	if identity == nil {
+		if identity, err = s.register(session.OpenID, unionID, profile); err != nil {
+			return nil, err
+		}
+		registered = true
+	}
+
+	user, err := s.userRepo.FindByID(identity.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("用户不存在")
+	}
+	changed := false
+	if registered && profile != nil && profile.AvatarURL != "" {
+		user.Avatar = truncate(profile.AvatarURL, 500)
+		changed = true
+	}
+	if phone != "" && phone != user.Phone {
+		user.Phone = phone
+		changed = true
+	}
+	if changed {
+		if err := s.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+		invalidateUserProfile(user.ID)
+	}
+
+	if device == nil {
+		device = &models.DeviceInfo{}
+	}
+	device.LoginMethod = models.SecurityEventLoginWechatMini
+	if challenge, err := secondFactorChallenge(s.roleRepo, user, device); err != nil || challenge != nil {
+		return challenge, err
+	}
+	return s.userService.LoginByUserID(user.ID, device)
+}
+
+// register 为未绑定账号的小程序用户注册新账号
+func (s *wechatMiniProgramService) register(openID, unionID string, profile *encryptor.PlainData) (*models.UserIdentity, error) {
+	if !config.AppConfig.Wechat.AutoRegister {
+		return nil, errWechatMiniNotBound
+	}
+
+	var nickname string
+	if profile != nil {
+		nickname = profile.NickName
+	}
+	identity := &models.UserIdentity{
+		Provider: WechatMiniIdentityProvider,
+		Subject:  openID,
+		UnionID:  unionID,
+	}
+	if profile != nil {
+		identity.Name = truncate(profile.NickName, 100)
+	}
+	if _, err := registerWechatUser(s.userRepo, s.userService, identity, nickname); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
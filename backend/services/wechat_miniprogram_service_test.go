@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/utils"
+
+	"github.com/silenceper/wechat/v2/miniprogram/auth"
+	miniConfig "github.com/silenceper/wechat/v2/miniprogram/config"
+	miniContext "github.com/silenceper/wechat/v2/miniprogram/context"
+	"github.com/silenceper/wechat/v2/miniprogram/encryptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeMiniProgramClient 按 code 返回预设的会话，解密使用 SDK 的真实实现
+type fakeMiniProgramClient struct {
+	sessions  map[string]auth.ResCode2Session
+	encryptor *encryptor.Encryptor
+}
+
+func newFakeMiniProgramClient(appID string) *fakeMiniProgramClient {
+	return &fakeMiniProgramClient{
+		sessions:  make(map[string]auth.ResCode2Session),
+		encryptor: encryptor.NewEncryptor(&miniContext.Context{Config: &miniConfig.Config{AppID: appID}}),
+	}
+}
+
+func (c *fakeMiniProgramClient) Code2Session(code string) (*auth.ResCode2Session, error) {
+	session, ok := c.sessions[code]
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+	return &session, nil
+}
+
+func (c *fakeMiniProgramClient) Decrypt(sessionKey, encryptedData, iv string) (*encryptor.PlainData, error) {
+	return c.encryptor.Decrypt(sessionKey, encryptedData, iv)
+}
+
+// encryptMiniProgramData 按微信的方式（AES-128-CBC，PKCS#7 填充）加密数据
+func encryptMiniProgramData(t *testing.T, sessionKey, iv string, data interface{}) *models.WechatEncryptedData {
+	plain, err := json.Marshal(data)
+	assert.NoError(t, err)
+	key, _ := base64.StdEncoding.DecodeString(sessionKey)
+	ivBytes, _ := base64.StdEncoding.DecodeString(iv)
+
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	plain = append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, err := aes.NewCipher(key)
+	assert.NoError(t, err)
+	cipherText := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, ivBytes).CryptBlocks(cipherText, plain)
+
+	return &models.WechatEncryptedData{
+		EncryptedData: base64.StdEncoding.EncodeToString(cipherText),
+		IV:            iv,
+	}
+}
+
+func TestWechatMiniProgramLogin(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	sessionKey := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	iv := base64.StdEncoding.EncodeToString([]byte("fedcba9876543210"))
+	client := newFakeMiniProgramClient("wxmini")
+	client.sessions["code-linked"] = auth.ResCode2Session{OpenID: "mini-openid-1", SessionKey: sessionKey, UnionID: "union-1"}
+	client.sessions["code-new"] = auth.ResCode2Session{OpenID: "mini-openid-2", SessionKey: sessionKey}
+
+	// 公众号扫码登录过的用户，身份上已记录 UnionID
+	linked := &models.User{ID: 9501, Username: "wx_linked"}
+	created := &models.User{}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", linked.ID).Return(linked, nil)
+	userRepo.On("FindByID", uint(9502)).Return(created, nil)
+	userRepo.On("FindByUsername", mock.Anything).Return(nil, nil)
+	userRepo.On("FindByEmail", mock.Anything).Return(nil, nil)
+	identityRepo := &fakeUserIdentityRepository{}
+	userRepo.On("CreateWithIdentity", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		user, identity := args.Get(0).(*models.User), args.Get(1).(*models.UserIdentity)
+		user.ID = 9502
+		*created = *user
+		identity.UserID = user.ID
+		identityRepo.Create(identity)
+	}).Return(nil)
+	userRepo.On("Update", mock.Anything).Return(nil)
+	identityRepo.Create(&models.UserIdentity{UserID: linked.ID, Provider: WechatIdentityProvider, Subject: "oa-openid-1", UnionID: "union-1"})
+
+	userService := NewUserService(userRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	service := NewWechatMiniProgramService(client, identityRepo, userRepo, nil, userService)
+
+	_, err := NewWechatMiniProgramService(nil, identityRepo, userRepo, nil, userService).Login(&models.WechatMiniProgramLoginRequest{Code: "code-linked"}, nil)
+	assert.Equal(t, errWechatMiniNotConfigured, err)
+	_, err = service.Login(&models.WechatMiniProgramLoginRequest{Code: "bad"}, nil)
+	assert.Equal(t, errWechatMiniCode, err)
+
+	// 按 UnionID 关联到公众号账号，并保存解密出的手机号
+	phone := map[string]interface{}{
+		"phoneNumber":     "+86 13800138000",
+		"purePhoneNumber": "13800138000",
+		"countryCode":     "86",
+		"watermark":       map[string]interface{}{"appid": "wxmini"},
+	}
+	resp, err := service.Login(&models.WechatMiniProgramLoginRequest{
+		Code:  "code-linked",
+		Phone: encryptMiniProgramData(t, sessionKey, iv, phone),
+	}, &models.DeviceInfo{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	assert.Equal(t, linked.ID, resp.User.ID)
+	assert.Equal(t, "13800138000", linked.Phone)
+	utils.RevokeRefreshToken(resp.RefreshToken)
+
+	identity, _ := identityRepo.FindByProviderSubject(WechatMiniIdentityProvider, "mini-openid-1")
+	assert.NotNil(t, identity)
+	assert.Equal(t, linked.ID, identity.UserID)
+
+	// 其他小程序的加密数据（水印 AppID 不符）拒绝
+	phone["watermark"] = map[string]interface{}{"appid": "wxother"}
+	_, err = service.Login(&models.WechatMiniProgramLoginRequest{
+		Code:  "code-linked",
+		Phone: encryptMiniProgramData(t, sessionKey, iv, phone),
+	}, nil)
+	assert.Equal(t, errWechatMiniDecrypt, err)
+
+	// 没有关联账号时，未开启自动注册则拒绝，开启后用解密的昵称、头像注册
+	profile := encryptMiniProgramData(t, sessionKey, iv, map[string]interface{}{
+		"openId":    "mini-openid-2",
+		"unionId":   "union-2",
+		"nickName":  "小程序用户",
+		"avatarUrl": "https://example.com/avatar.png",
+		"watermark": map[string]interface{}{"appid": "wxmini"},
+	})
+	_, err = service.Login(&models.WechatMiniProgramLoginRequest{Code: "code-new", UserInfo: profile}, nil)
+	assert.Equal(t, errWechatMiniNotBound, err)
+
+	config.AppConfig.Wechat.AutoRegister = true
+	t.Cleanup(func() { config.AppConfig.Wechat.AutoRegister = false })
+	resp, err = service.Login(&models.WechatMiniProgramLoginRequest{Code: "code-new", UserInfo: profile}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(9502), resp.User.ID)
+	assert.Equal(t, "小程序用户", created.Nickname)
+	assert.Equal(t, "https://example.com/avatar.png", created.Avatar)
+	utils.RevokeRefreshToken(resp.RefreshToken)
+
+	identity, _ = identityRepo.FindByProviderSubject(WechatMiniIdentityProvider, "mini-openid-2")
+	assert.Equal(t, "union-2", identity.UnionID)
+
+	// 再次登录直接使用已绑定的账号
+	resp, err = service.Login(&models.WechatMiniProgramLoginRequest{Code: "code-new"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(9502), resp.User.ID)
+	utils.RevokeRefreshToken(resp.RefreshToken)
+	userRepo.AssertNumberOfCalls(t, "CreateWithIdentity", 1)
+}
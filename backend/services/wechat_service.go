@@ -1,10 +1,16 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/repositories"
+	"gin-backend/utils"
+	"log"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/silenceper/wechat/v2"
@@ -27,29 +33,78 @@ const (
 
 // WechatSession 微信登录会话
 type WechatSession struct {
-	SceneID  string       `json:"scene_id"`
-	Status   WechatStatus `json:"status"`
-	UserID   uint         `json:"user_id,omitempty"`
-	OpenID   string       `json:"openid,omitempty"`
-	ExpireAt time.Time    `json:"expire_at"`
+	SceneID      string       `json:"scene_id"`
+	Status       WechatStatus `json:"status"`
+	UserID       uint         `json:"user_id,omitempty"`
+	OpenID       string       `json:"openid,omitempty"`
+	UnionID      string       `json:"unionid,omitempty"`       // 公众号返回的 UnionID，注册或绑定时一并保存
+	NeedBind     bool         `json:"need_bind,omitempty"`     // 已扫码但该微信未绑定账号，需注册或绑定已有账号
+	BindAttempts int          `json:"bind_attempts,omitempty"` // 绑定已有账号时密码错误次数
+	Mock         bool         `json:"mock,omitempty"`          // 通过模拟扫码接口授权（开发用）
+	ExpireAt     time.Time    `json:"expire_at"`
 }
 
+const (
+	// WechatIdentityProvider 微信 OpenID 在第三方身份表中的提供方标识
+	WechatIdentityProvider = "wechat"
+
+	// wechatSessionExpiration 二维码登录会话有效期
+	wechatSessionExpiration = 5 * time.Minute
+	// wechatSessionRetention 会话过期后仍保留一段时间，便于轮询拿到 EXPIRED 状态
+	wechatSessionRetention = time.Minute
+	// wechatBindMaxAttempts 单个会话绑定已有账号时允许的密码错误次数
+	wechatBindMaxAttempts = 5
+
+	wechatSessionPrefix  = "wechat:session:"
+	wechatClaimPrefix    = "wechat:claimed:"
+	wechatRegisterPrefix = "wechat:register:"
+	wechatEventsPrefix   = "wechat:events:" // 会话状态变化的发布订阅频道
+)
+
+var (
+	errWechatSession       = errors.New("会话不存在或已过期")
+	errWechatNotScanned    = errors.New("请先使用微信扫码")
+	errWechatRegister      = errors.New("未开启微信自动注册，请绑定已有账号")
+	errWechatBindPassword  = errors.New("用户名或密码错误")
+	errWechatUserBound     = errors.New("该账号已绑定其他微信")
+	errWechatClaimed       = errors.New("登录凭证已领取，请重新扫码")
+	errWechatRegistering   = errors.New("正在注册，请勿重复提交")
+	errWechatNotConfigured = errors.New("未配置微信公众号")
+)
+
 // WechatService 微信业务逻辑接口
 type WechatService interface {
 	GetQRCode() (*WechatSession, string, error)
 	CheckStatus(sceneID string) (*WechatSession, error)
 	GetServer(req *http.Request, writer http.ResponseWriter) *server.Server
 	HandleCallback(msg message.MixMessage) *message.Reply
-	MockScan(sceneID string, userID uint) error
+	RegisterByScene(sceneID string) error
+	BindByScene(sceneID, username, password string, device *models.DeviceInfo) (*models.LoginResponse, error)
+	MockScan(sceneID string, userID uint, openID string) error
+	Watch(ctx context.Context, sceneID string) (<-chan *WechatSession, func(), error)
+	ClaimLogin(sceneID string) (*WechatSession, error)
+	SendTemplate(msg *message.TemplateMessage) (int64, error)
 }
 
+// wechatService 微信业务逻辑实现，登录会话保存在缓存中，多实例部署时共享
 type wechatService struct {
-	sessions sync.Map
-	oa       *officialaccount.OfficialAccount
+	oa           *officialaccount.OfficialAccount
+	identityRepo repositories.UserIdentityRepository
+	userRepo     repositories.UserRepository
+	roleRepo     repositories.RoleRepository
+	userService  UserService
+	loginGuard   LoginGuardService
 }
 
-func NewWechatService() WechatService {
-	s := &wechatService{}
+// NewWechatService 创建微信服务实例
+func NewWechatService(identityRepo repositories.UserIdentityRepository, userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, userService UserService, loginGuard LoginGuardService) WechatService {
+	s := &wechatService{
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		userService:  userService,
+		loginGuard:   loginGuard,
+	}
 
 	if config.AppConfig.Wechat.AppID != "" {
 		wc := wechat.NewWechat()
@@ -62,12 +117,15 @@ func NewWechatService() WechatService {
 		s.oa = wc.GetOfficialAccount(cfg)
 	}
 
-	go s.gc()
 	return s
 }
 
 func (s *wechatService) GetQRCode() (*WechatSession, string, error) {
-	sceneStr := fmt.Sprintf("%d", time.Now().UnixNano())
+	// 场景值即登录会话ID，凭它可以查询状态、领取登录结果，必须不可猜测（微信限制最长 64 个字符）
+	sceneStr, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
 
 	var qrURL string
 	if s.oa != nil {
@@ -103,25 +161,24 @@ func (s *wechatService) GetQRCode() (*WechatSession, string, error) {
 	session := &WechatSession{
 		SceneID:  sceneStr,
 		Status:   StatusPending,
-		ExpireAt: time.Now().Add(5 * time.Minute),
+		ExpireAt: time.Now().Add(wechatSessionExpiration),
+	}
+	if err := saveWechatSession(session); err != nil {
+		return nil, "", err
 	}
-	s.sessions.Store(sceneStr, session)
 
 	return session, qrURL, nil
 }
 
 func (s *wechatService) CheckStatus(sceneID string) (*WechatSession, error) {
-	val, ok := s.sessions.Load(sceneID)
-	if !ok {
-		return nil, fmt.Errorf("会话不存在或已过期")
+	session, err := loadWechatSession(sceneID)
+	if err != nil {
+		return nil, err
 	}
 
-	session := val.(*WechatSession)
 	if time.Now().After(session.ExpireAt) {
 		session.Status = StatusExpired
-		return session, nil
 	}
-
 	return session, nil
 }
 
@@ -144,46 +201,437 @@ func (s *wechatService) HandleCallback(msg message.MixMessage) *message.Reply {
 		}
 	}
 
-	if sceneID != "" {
-		if val, ok := s.sessions.Load(sceneID); ok {
-			session := val.(*WechatSession)
-			session.Status = StatusSuccess
-			session.OpenID = string(msg.FromUserName)
-			session.UserID = 1 // 实际需查询数据库
-			s.sessions.Store(sceneID, session)
+	if sceneID == "" {
+		return nil
+	}
+
+	session, err := s.scan(sceneID, string(msg.FromUserName))
+	if err != nil {
+		log.Printf("处理微信扫码失败: scene=%s err=%v", sceneID, err)
+		return nil
+	}
+
+	text := "登录成功！"
+	if session.NeedBind {
+		text = "扫码成功，请在网页上注册或绑定已有账号"
+	}
+	return &message.Reply{
+		MsgType: message.MsgTypeText,
+		MsgData: message.NewText(text),
+	}
+}
+
+// scan 处理扫码：已绑定账号的微信直接登录成功，未绑定的进入 SCANNING 状态等待网页端注册或绑定
+func (s *wechatService) scan(sceneID, openID string) (*WechatSession, error) {
+	session, err := s.activeSession(sceneID)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.identityRepo.FindByProviderSubject(WechatIdentityProvider, openID)
+	if err != nil {
+		return nil, err
+	}
+
+	unionID := s.fetchUnionID(openID)
+	if identity == nil {
+		// 同一微信用户已通过小程序登录过时，按 UnionID 直接关联到该账号
+		if identity, err = linkWechatByUnionID(s.identityRepo, WechatIdentityProvider, openID, unionID); err != nil {
+			return nil, err
+		}
+	} else if identity.UnionID == "" && unionID != "" {
+		identity.UnionID = unionID
+		if err := s.identityRepo.Update(identity); err != nil {
+			return nil, err
+		}
+	}
+
+	session.OpenID = openID
+	session.UnionID = unionID
+	if identity != nil {
+		session.Status = StatusSuccess
+		session.UserID = identity.UserID
+		session.NeedBind = false
+	} else {
+		session.Status = StatusScanning
+		session.NeedBind = true
+	}
+	return session, saveWechatSession(session)
+}
+
+// RegisterByScene 为扫码的微信注册新账号并绑定。
+// 先领取注册资格，重复提交或多实例同时处理同一会话时只有一个请求注册，用户和身份在同一事务中创建
+func (s *wechatService) RegisterByScene(sceneID string) error {
+	if !config.AppConfig.Wechat.AutoRegister {
+		return errWechatRegister
+	}
+
+	session, err := s.pendingBind(sceneID)
+	if err != nil {
+		return err
+	}
+
+	claimKey := wechatRegisterPrefix + sceneID
+	ok, err := utils.CacheSetNX(claimKey, true, wechatSessionExpiration+wechatSessionRetention)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errWechatRegistering
+	}
+
+	user, err := registerWechatUser(s.userRepo, s.userService, &models.UserIdentity{
+		Provider: WechatIdentityProvider,
+		Subject:  session.OpenID,
+		UnionID:  session.UnionID,
+	}, "")
+	if err != nil {
+		// 注册失败时释放注册资格，允许重试
+		utils.CacheDel(claimKey)
+		return err
+	}
 
-			return &message.Reply{
-				MsgType: message.MsgTypeText,
-				MsgData: message.NewText("登录成功！"),
+	return s.complete(session, user.ID)
+}
+
+// BindByScene 验证已有账号的密码后，将扫码的微信绑定到该账号。
+// 与密码登录共用失败计数和锁定；账号需要两步验证时返回登录挑战，第二因素通过后才绑定并登录，
+// 返回 nil 表示已绑定，扫码会话置为登录成功
+func (s *wechatService) BindByScene(sceneID, username, password string, device *models.DeviceInfo) (*models.LoginResponse, error) {
+	session, err := s.pendingBind(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	if s.loginGuard != nil {
+		if err := s.loginGuard.Check(username, device); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !utils.CheckPassword(password, user.Password) {
+		if s.loginGuard != nil {
+			var userID uint
+			if user != nil {
+				userID = user.ID
 			}
+			s.loginGuard.RecordFailure(username, userID, device, "微信绑定账号密码错误")
+		}
+		// 密码错误次数过多时作废会话，防止借此暴力破解
+		session.BindAttempts++
+		if session.BindAttempts >= wechatBindMaxAttempts {
+			session.Status = StatusExpired
+			session.ExpireAt = time.Now()
 		}
+		if err := saveWechatSession(session); err != nil {
+			return nil, err
+		}
+		return nil, errWechatBindPassword
 	}
 
-	return nil
+	if bound, err := hasWechatIdentity(s.identityRepo, user.ID); err != nil {
+		return nil, err
+	} else if bound {
+		return nil, errWechatUserBound
+	}
+
+	link := &pendingIdentity{Provider: WechatIdentityProvider, Subject: session.OpenID, UnionID: session.UnionID}
+	challenge, err := linkChallenge(s.roleRepo, user, device, link)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		// 登录结果改由两步验证返回，扫码会话就此作废
+		session.Status = StatusExpired
+		session.ExpireAt = time.Now()
+		if err := saveWechatSession(session); err != nil {
+			return nil, err
+		}
+		return challenge, nil
+	}
+
+	if s.loginGuard != nil {
+		s.loginGuard.RecordSuccess(username, user.ID, device)
+	}
+	if err := linkWechatIdentity(s.identityRepo, link.identity(user.ID), device); err != nil {
+		return nil, err
+	}
+	return nil, s.complete(session, user.ID)
+}
+
+// MockScan 模拟扫码（开发用）：指定 openID 时走真实的绑定流程，否则直接以 userID 登录
+func (s *wechatService) MockScan(sceneID string, userID uint, openID string) error {
+	if openID != "" {
+		_, err := s.scan(sceneID, openID)
+		return err
+	}
+
+	session, err := s.activeSession(sceneID)
+	if err != nil {
+		return err
+	}
+	session.Status = StatusSuccess
+	session.UserID = userID
+	session.Mock = true
+	return saveWechatSession(session)
+}
+
+// Watch 订阅会话状态变化（跨实例），用于服务端推送
+func (s *wechatService) Watch(ctx context.Context, sceneID string) (<-chan *WechatSession, func(), error) {
+	messages, stop, err := utils.Subscribe(ctx, wechatEventsPrefix+sceneID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make(chan *WechatSession)
+	go func() {
+		defer close(out)
+		for msg := range messages {
+			var session WechatSession
+			if err := json.Unmarshal(msg, &session); err != nil {
+				continue
+			}
+			select {
+			case out <- &session:
+			case <-ctx.Done():
+				stop()
+				return
+			}
+		}
+	}()
+	return out, stop, nil
 }
 
-func (s *wechatService) MockScan(sceneID string, userID uint) error {
-	val, ok := s.sessions.Load(sceneID)
+// ClaimLogin 领取登录成功的会话，每个会话只能领取一次（多实例、多个连接同时领取时只有一个成功）
+func (s *wechatService) ClaimLogin(sceneID string) (*WechatSession, error) {
+	session, err := loadWechatSession(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != StatusSuccess || session.UserID == 0 {
+		return nil, errWechatNotScanned
+	}
+
+	ok, err := utils.CacheSetNX(wechatClaimPrefix+sceneID, true, wechatSessionExpiration+wechatSessionRetention)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
-		return fmt.Errorf("会话不存在")
+		return nil, errWechatClaimed
 	}
+	return session, nil
+}
 
-	session := val.(*WechatSession)
+// SendTemplate 发送模板消息，返回微信的消息ID
+func (s *wechatService) SendTemplate(msg *message.TemplateMessage) (int64, error) {
+	if s.oa == nil {
+		return 0, errWechatNotConfigured
+	}
+	return s.oa.GetTemplate().Send(msg)
+}
+
+// activeSession 读取未过期的会话
+func (s *wechatService) activeSession(sceneID string) (*WechatSession, error) {
+	session, err := loadWechatSession(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(session.ExpireAt) {
+		return nil, errWechatSession
+	}
+	return session, nil
+}
+
+// pendingBind 读取已扫码、等待注册或绑定的会话
+func (s *wechatService) pendingBind(sceneID string) (*WechatSession, error) {
+	session, err := s.activeSession(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != StatusScanning || !session.NeedBind || session.OpenID == "" {
+		return nil, errWechatNotScanned
+	}
+	return session, nil
+}
+
+// complete 注册或绑定完成，会话置为登录成功
+func (s *wechatService) complete(session *WechatSession, userID uint) error {
 	session.Status = StatusSuccess
 	session.UserID = userID
-	s.sessions.Store(sceneID, session)
+	session.NeedBind = false
+	return saveWechatSession(session)
+}
+
+// hasWechatIdentity 账号是否已绑定微信
+func hasWechatIdentity(identityRepo repositories.UserIdentityRepository, userID uint) (bool, error) {
+	identities, err := identityRepo.FindByUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, identity := range identities {
+		if identity.Provider == WechatIdentityProvider {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// linkWechatIdentity 将扫码的微信绑定到已有账号并记录安全事件。
+// 两步验证完成后才绑定时，期间账号或该微信可能已被绑定，因此再检查一次
+func linkWechatIdentity(identityRepo repositories.UserIdentityRepository, identity *models.UserIdentity, device *models.DeviceInfo) error {
+	if bound, err := hasWechatIdentity(identityRepo, identity.UserID); err != nil {
+		return err
+	} else if bound {
+		return errWechatUserBound
+	}
+	existing, err := identityRepo.FindByProviderSubject(identity.Provider, identity.Subject)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errOAuthIdentityTaken
+	}
+
+	if err := identityRepo.Create(identity); err != nil {
+		return err
+	}
+
+	event := &models.SecurityEvent{
+		UserID:     identity.UserID,
+		Type:       models.SecurityEventIdentityLinked,
+		OperatorID: identity.UserID,
+		Detail:     identity.Provider,
+	}
+	if device != nil {
+		event.IP = device.IP
+		event.UserAgent = device.UserAgent
+	}
+	utils.EmitSecurityEvent(event)
 	return nil
 }
 
-func (s *wechatService) gc() {
-	ticker := time.NewTicker(1 * time.Minute)
-	for range ticker.C {
-		s.sessions.Range(func(key, value interface{}) bool {
-			session := value.(*WechatSession)
-			if time.Now().After(session.ExpireAt) {
-				s.sessions.Delete(key)
-			}
-			return true
-		})
+// fetchUnionID 通过公众号接口查询用户的 UnionID，未配置公众号或未绑定开放平台时返回空
+func (s *wechatService) fetchUnionID(openID string) string {
+	if s.oa == nil {
+		return ""
+	}
+	info, err := s.oa.GetUser().GetUserInfo(openID)
+	if err != nil {
+		log.Printf("获取微信用户信息失败: openid=%s err=%v", openID, err)
+		return ""
+	}
+	return info.UnionID
+}
+
+// registerWechatUser 为未绑定账号的微信用户注册新账号并绑定 identity：随机用户名和密码，邮箱使用占位地址。
+// 用户和身份在同一事务中创建，身份保存失败时不会留下没有绑定的账号
+func registerWechatUser(userRepo repositories.UserRepository, userService UserService, identity *models.UserIdentity, nickname string) (*models.UserResponse, error) {
+	username, err := randomWechatUsername(userRepo)
+	if err != nil {
+		return nil, err
+	}
+	password, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	if nickname == "" {
+		nickname = "微信用户"
+	}
+	return userService.CreateUserWithIdentity(&models.UserCreateRequest{
+		Username: username,
+		Email:    fmt.Sprintf("%s_%s@oauth.invalid", identity.Provider, identity.Subject),
+		Password: password,
+		Nickname: truncate(nickname, 50),
+	}, identity)
+}
+
+// linkWechatByUnionID 同一 UnionID 已在公众号或小程序下绑定过账号时，为新的 OpenID 创建指向该账号的身份，未找到时返回 nil
+func linkWechatByUnionID(identityRepo repositories.UserIdentityRepository, provider, openID, unionID string) (*models.UserIdentity, error) {
+	if unionID == "" {
+		return nil, nil
+	}
+	linked, err := identityRepo.FindByUnionID(unionID)
+	if err != nil || linked == nil {
+		return nil, err
+	}
+
+	// 该账号已在同一应用下绑定了其他微信时不自动关联
+	identities, err := identityRepo.FindByUserID(linked.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		if identity.Provider == provider {
+			return nil, nil
+		}
+	}
+
+	identity := &models.UserIdentity{
+		UserID:   linked.UserID,
+		Provider: provider,
+		Subject:  openID,
+		UnionID:  unionID,
+	}
+	if err := identityRepo.Create(identity); err != nil {
+		return nil, err
+	}
+	utils.EmitSecurityEvent(&models.SecurityEvent{
+		UserID:     linked.UserID,
+		Type:       models.SecurityEventIdentityLinked,
+		OperatorID: linked.UserID,
+		Detail:     provider + " (unionid)",
+	})
+	return identity, nil
+}
+
+// randomWechatUsername 为微信注册用户生成可用的用户名
+func randomWechatUsername(userRepo repositories.UserRepository) (string, error) {
+	for i := 0; i < 5; i++ {
+		suffix, err := randomHex(4)
+		if err != nil {
+			return "", err
+		}
+		username := "wx" + suffix
+		existing, err := userRepo.FindByUsername(username)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return username, nil
+		}
+	}
+	return "", errors.New("生成用户名失败，请重试")
+}
+
+// saveWechatSession 保存会话并通知订阅者，过期后再保留一段时间以便返回 EXPIRED 状态
+func saveWechatSession(session *WechatSession) error {
+	ttl := time.Until(session.ExpireAt) + wechatSessionRetention
+	if ttl <= 0 {
+		if err := utils.CacheDel(wechatSessionPrefix + session.SceneID); err != nil {
+			return err
+		}
+	} else if err := utils.CacheSet(wechatSessionPrefix+session.SceneID, session, ttl); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := utils.Publish(wechatEventsPrefix+session.SceneID, payload); err != nil {
+		log.Printf("推送微信登录状态失败: scene=%s err=%v", session.SceneID, err)
+	}
+	return nil
+}
+
+// loadWechatSession 读取会话
+func loadWechatSession(sceneID string) (*WechatSession, error) {
+	var session WechatSession
+	if err := utils.CacheGet(wechatSessionPrefix+sceneID, &session); err != nil {
+		return nil, errWechatSession
 	}
+	return &session, nil
 }
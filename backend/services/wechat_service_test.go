@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gin-backend/config"
+	"gin-backend/models"
+	"gin-backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWechatScanAndBind(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	hashed, _ := utils.HashPassword("password123")
+	user := &models.User{ID: 9401, Username: "wx_bind_user", Password: hashed}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByUsername", user.Username).Return(user, nil)
+	userRepo.On("FindByUsername", "nobody").Return(nil, nil)
+	identityRepo := &fakeUserIdentityRepository{}
+	service := NewWechatService(identityRepo, userRepo, nil, nil, nil)
+	bind := func(sceneID, username, password string) error {
+		_, err := service.BindByScene(sceneID, username, password, nil)
+		return err
+	}
+
+	session, _, err := service.GetQRCode()
+	assert.NoError(t, err)
+	assert.Equal(t, StatusPending, session.Status)
+	// 场景值随机生成，不能由时间推算
+	assert.Len(t, session.SceneID, 32)
+	another, _, _ := service.GetQRCode()
+	assert.NotEqual(t, session.SceneID, another.SceneID)
+
+	// 未扫码时不能绑定
+	assert.Equal(t, errWechatNotScanned, bind(session.SceneID, user.Username, "password123"))
+
+	// 未绑定的微信扫码后进入 SCANNING，等待绑定
+	assert.NoError(t, service.MockScan(session.SceneID, 0, "openid-1"))
+	status, err := service.CheckStatus(session.SceneID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusScanning, status.Status)
+	assert.True(t, status.NeedBind)
+
+	assert.Equal(t, errWechatBindPassword, bind(session.SceneID, user.Username, "wrong"))
+	assert.Equal(t, errWechatBindPassword, bind(session.SceneID, "nobody", "password123"))
+	assert.NoError(t, bind(session.SceneID, user.Username, "password123"))
+
+	status, _ = service.CheckStatus(session.SceneID)
+	assert.Equal(t, StatusSuccess, status.Status)
+	assert.Equal(t, user.ID, status.UserID)
+
+	// 已绑定的微信再次扫码直接登录成功
+	session, _, _ = service.GetQRCode()
+	assert.NoError(t, service.MockScan(session.SceneID, 0, "openid-1"))
+	status, _ = service.CheckStatus(session.SceneID)
+	assert.Equal(t, StatusSuccess, status.Status)
+	assert.Equal(t, user.ID, status.UserID)
+
+	// 一个账号只能绑定一个微信
+	session, _, _ = service.GetQRCode()
+	assert.NoError(t, service.MockScan(session.SceneID, 0, "openid-2"))
+	assert.Equal(t, errWechatUserBound, bind(session.SceneID, user.Username, "password123"))
+
+	// 密码错误次数过多时会话作废
+	for i := 0; i < wechatBindMaxAttempts; i++ {
+		bind(session.SceneID, user.Username, "wrong")
+	}
+	status, _ = service.CheckStatus(session.SceneID)
+	assert.Equal(t, StatusExpired, status.Status)
+	assert.Equal(t, errWechatSession, bind(session.SceneID, user.Username, "password123"))
+
+	_, err = service.CheckStatus("missing")
+	assert.Equal(t, errWechatSession, err)
+}
+
+func TestWechatBindRequiresSecondFactor(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	hashed, _ := utils.HashPassword("password123")
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	user := &models.User{ID: 9403, Username: "wx_totp_user", Password: hashed, TwoFactorEnabled: true, TwoFactorSecret: secret}
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByUsername", user.Username).Return(user, nil)
+	userRepo.On("FindByID", user.ID).Return(user, nil)
+	attemptRepo := new(MockLoginAttemptRepository)
+	attemptRepo.On("Create", mock.Anything).Return(nil)
+	guard := NewLoginGuardService(attemptRepo)
+	device := &models.DeviceInfo{IP: "203.0.113.50", UserAgent: "wechat-bind-test"}
+	t.Cleanup(func() { guard.Unlock(user.Username, device.IP) })
+
+	identityRepo := &fakeUserIdentityRepository{}
+	userService := NewUserService(userRepo, new(MockMenuRepository), nil, guard, utils.NewMemoryCache(utils.MemoryCacheOptions{}))
+	service := NewWechatService(identityRepo, userRepo, nil, userService, guard)
+	twoFactor := NewTwoFactorService(userRepo, nil, &fakeRecoveryCodeRepository{}, identityRepo, userService, guard)
+
+	// 密码错误计入登录失败次数，锁定后不能再尝试
+	session, _, _ := service.GetQRCode()
+	assert.NoError(t, service.MockScan(session.SceneID, 0, "openid-totp"))
+	for i := 0; i < guardConfig().LoginMaxFailures; i++ {
+		_, err = service.BindByScene(session.SceneID, user.Username, "wrong", device)
+		assert.Equal(t, errWechatBindPassword, err)
+	}
+	session, _, _ = service.GetQRCode()
+	assert.NoError(t, service.MockScan(session.SceneID, 0, "openid-totp"))
+	_, err = service.BindByScene(session.SceneID, user.Username, "password123", device)
+	assert.ErrorIs(t, err, ErrLoginLocked)
+	guard.Unlock(user.Username, "")
+
+	// 密码正确只能换取登录挑战，扫码会话作废，第二因素通过前不绑定
+	challenge, err := service.BindByScene(session.SceneID, user.Username, "password123", device)
+	assert.NoError(t, err)
+	assert.True(t, challenge.TwoFactorRequired)
+	assert.Empty(t, identityRepo.identities)
+	status, _ := service.CheckStatus(session.SceneID)
+	assert.Equal(t, StatusExpired, status.Status)
+
+	code, err := utils.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	resp, err := twoFactor.CompleteLogin(challenge.ChallengeToken, code)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	assert.Len(t, identityRepo.identities, 1)
+	assert.Equal(t, user.ID, identityRepo.identities[0].UserID)
+	assert.Equal(t, "openid-totp", identityRepo.identities[0].Subject)
+
+	utils.RevokeRefreshToken(resp.RefreshToken)
+}
+
+func TestWechatRegister(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByUsername", mock.Anything).Return(nil, nil)
+	userRepo.On("FindByEmail", mock.Anything).Return(nil, nil)
+	identityRepo := &fakeUserIdentityRepository{}
+	userRepo.On("CreateWithIdentity", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		user, identity := args.Get(0).(*models.User), args.Get(1).(*models.UserIdentity)
+		user.ID = 9402
+		identity.UserID = user.ID
+		identityRepo.Create(identity)
+	}).Return(nil)
+	service := NewWechatService(identityRepo, userRepo, nil, NewUserService(userRepo, new(MockMenuRepository), nil, nil, utils.NewMemoryCache(utils.MemoryCacheOptions{})), nil)
+
+	session, _, _ := service.GetQRCode()
+	assert.NoError(t, service.MockScan(session.SceneID, 0, "openid-new"))
+
+	// 未开启自动注册时只能绑定已有账号
+	config.AppConfig.Wechat.AutoRegister = false
+	assert.Equal(t, errWechatRegister, service.RegisterByScene(session.SceneID))
+
+	config.AppConfig.Wechat.AutoRegister = true
+	t.Cleanup(func() { config.AppConfig.Wechat.AutoRegister = false })
+
+	// 重复提交时只有一个请求注册
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if service.RegisterByScene(session.SceneID) == nil {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), succeeded.Load())
+	userRepo.AssertNumberOfCalls(t, "CreateWithIdentity", 1)
+
+	status, _ := service.CheckStatus(session.SceneID)
+	assert.Equal(t, StatusSuccess, status.Status)
+	assert.Equal(t, uint(9402), status.UserID)
+	assert.Len(t, identityRepo.identities, 1)
+	assert.Equal(t, WechatIdentityProvider, identityRepo.identities[0].Provider)
+
+	// 已完成的会话不能重复注册
+	assert.Equal(t, errWechatNotScanned, service.RegisterByScene(session.SceneID))
+}
+
+func TestWechatWatchAndClaim(t *testing.T) {
+	t.Cleanup(func() { os.Remove("cache_persistence.json") })
+
+	identityRepo := &fakeUserIdentityRepository{}
+	identityRepo.Create(&models.UserIdentity{UserID: 9403, Provider: WechatIdentityProvider, Subject: "openid-watch"})
+	service := NewWechatService(identityRepo, new(MockUserRepository), nil, nil, nil)
+
+	session, _, err := service.GetQRCode()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, stop, err := service.Watch(ctx, session.SceneID)
+	assert.NoError(t, err)
+	defer stop()
+
+	// 未登录成功时不能领取
+	_, err = service.ClaimLogin(session.SceneID)
+	assert.Equal(t, errWechatNotScanned, err)
+
+	assert.NoError(t, service.MockScan(session.SceneID, 0, "openid-watch"))
+	select {
+	case next := <-events:
+		assert.Equal(t, StatusSuccess, next.Status)
+		assert.Equal(t, uint(9403), next.UserID)
+	case <-time.After(time.Second):
+		t.Fatal("没有收到状态推送")
+	}
+
+	// 令牌只签发一次
+	claimed, err := service.ClaimLogin(session.SceneID)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(9403), claimed.UserID)
+	_, err = service.ClaimLogin(session.SceneID)
+	assert.Equal(t, errWechatClaimed, err)
+
+	// 取消订阅后通道关闭
+	stop()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("取消订阅后通道没有关闭")
+	}
+}
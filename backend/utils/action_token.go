@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"gin-backend/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TokenPurposePasswordReset 重置密码
+	TokenPurposePasswordReset = "password_reset"
+	// TokenPurposeEmailVerify 验证邮箱
+	TokenPurposeEmailVerify = "email_verify"
+	// TokenPurposeOAuthState 第三方登录授权请求的 state
+	TokenPurposeOAuthState = "oauth_state"
+	// TokenPurposeOAuthTicket 第三方登录回调后换取登录结果的票据
+	TokenPurposeOAuthTicket = "oauth_ticket"
+
+	actionTokenPrefix = "action:token:"
+	actionUsedPrefix  = "action:used:" // 已消费标记，用 SETNX 原子占用，多实例间同一令牌只能消费一次
+)
+
+// ErrActionTokenInvalid 链接令牌无效、已使用或已过期
+var ErrActionTokenInvalid = errors.New("链接无效或已过期")
+
+var (
+	actionSecret   []byte
+	actionSecretMu sync.Mutex
+)
+
+// actionTokenSecret 获取链接令牌签名密钥，未配置时随机生成（重启后旧链接失效）
+func actionTokenSecret() []byte {
+	actionSecretMu.Lock()
+	defer actionSecretMu.Unlock()
+
+	if actionSecret == nil {
+		if config.AppConfig != nil && config.AppConfig.Mail.TokenSecret != "" {
+			actionSecret = []byte(config.AppConfig.Mail.TokenSecret)
+		} else {
+			actionSecret = make([]byte, 32)
+			rand.Read(actionSecret)
+		}
+	}
+	return actionSecret
+}
+
+// signAction 计算 purpose + nonce 的签名，不同用途的令牌不能互换
+func signAction(purpose, nonce string) string {
+	mac := hmac.New(sha256.New, actionTokenSecret())
+	mac.Write([]byte(purpose + ":" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IssueActionToken 签发一次性链接令牌（用于重置密码、验证邮箱等），payload 保存在缓存中
+func IssueActionToken(purpose string, payload interface{}, expiration time.Duration) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(buf)
+
+	if err := CacheSet(actionTokenPrefix+purpose+":"+nonce, payload, expiration); err != nil {
+		return "", err
+	}
+	return nonce + "." + signAction(purpose, nonce), nil
+}
+
+// ConsumeActionToken 校验签名并取出 payload，令牌随即作废。
+// 先用 SETNX 写入已消费标记，共享 Redis 的多个实例并发提交同一令牌时只有一个成功
+func ConsumeActionToken(purpose, token string, dest interface{}) error {
+	nonce, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signAction(purpose, nonce))) {
+		return ErrActionTokenInvalid
+	}
+
+	key := actionTokenPrefix + purpose + ":" + nonce
+	if err := CacheGet(key, dest); err != nil {
+		return ErrActionTokenInvalid
+	}
+
+	// 标记保留到令牌原过期时间，之后令牌本身也已过期
+	ttl, err := CacheTTL(key)
+	if err != nil || ttl <= 0 {
+		ttl = time.Hour
+	}
+	claimed, err := CacheSetNX(actionUsedPrefix+purpose+":"+nonce, true, ttl)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrActionTokenInvalid
+	}
+	CacheDel(key)
+	return nil
+}
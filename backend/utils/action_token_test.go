@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConsumeActionTokenOnce(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	token, err := IssueActionToken(TokenPurposePasswordReset, map[string]uint{"user_id": 7}, time.Minute)
+	if err != nil {
+		t.Fatalf("IssueActionToken failed: %v", err)
+	}
+
+	// 不同用途的令牌不能互换
+	var payload map[string]uint
+	if err := ConsumeActionToken(TokenPurposeEmailVerify, token, &payload); err != ErrActionTokenInvalid {
+		t.Fatalf("expected ErrActionTokenInvalid for another purpose, got %v", err)
+	}
+
+	// 并发提交同一令牌时只有一个成功
+	var succeeded atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var got map[string]uint
+			if ConsumeActionToken(TokenPurposePasswordReset, token, &got) == nil && got["user_id"] == 7 {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded.Load() != 1 {
+		t.Fatalf("expected exactly one successful consume, got %d", succeeded.Load())
+	}
+}
@@ -1,205 +1,286 @@
 package utils
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 )
 
-// BatchProcessor 批量处理器
-type BatchProcessor struct {
-	batchSize int
-	workers   int
+// BatchOptions 批量处理配置
+type BatchOptions struct {
+	Workers  int  // 并发数，小于 1 时按 1 处理
+	FailFast bool // 任一项最终失败后不再开始新的项，并取消正在执行的项的 ctx；否则处理完所有项再汇总错误
+
+	Retries    int                   // 每项失败后的最多重试次数
+	Backoff    time.Duration         // 首次重试前的等待时间，之后每次翻倍，默认 100ms
+	MaxBackoff time.Duration         // 重试等待时间上限，0 表示不限制
+	RetryIf    func(err error) bool  // 判断错误是否值得重试，为空时所有错误都重试
+	RateLimit  float64               // 每秒最多开始的执行次数（包括重试），0 表示不限制
+	OnProgress func(p BatchProgress) // 每项处理结束后调用，调用是串行的
 }
 
-// NewBatchProcessor 创建批量处理器
-func NewBatchProcessor(batchSize, workers int) *BatchProcessor {
-	return &BatchProcessor{
-		batchSize: batchSize,
-		workers:   workers,
-	}
+// BatchProgress 批量处理进度
+type BatchProgress struct {
+	Total     int // 总项数
+	Completed int // 已执行结束的项数，快速失败或 ctx 取消后未开始的项不计入
+	Failed    int // 其中失败的项数，被取消中断的项不计入
 }
 
-// ProcessItems 批量处理项目（使用 Channel 和 Goroutine）
-// 演示：make 创建切片、Channel、并发处理
-func (bp *BatchProcessor) ProcessItems(items []interface{}, processFunc func(interface{}) error) []error {
-	// 使用 make 创建切片存储错误
-	errors := make([]error, 0)
-	errorsMutex := &sync.Mutex{}
+// BatchItemError 某一项的处理错误，Index 为该项在输入中的下标
+type BatchItemError struct {
+	Index int
+	Err   error
+}
 
-	// 使用 make 创建 Channel
-	itemsChan := make(chan interface{}, bp.batchSize)
-	errorsChan := make(chan error, len(items))
-	doneChan := make(chan bool)
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("索引 %d: %v", e.Index, e.Err)
+}
 
-	// 启动工作协程池
-	var wg sync.WaitGroup
-	for i := 0; i < bp.workers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			// 从 Channel 中读取任务
-			for item := range itemsChan {
-				if err := processFunc(item); err != nil {
-					errorsChan <- err
-				}
-			}
-		}(i)
-	}
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
 
-	// 发送任务到 Channel
-	go func() {
-		for _, item := range items {
-			itemsChan <- item
-		}
-		close(itemsChan) // 关闭 Channel，通知工作协程没有更多任务
-	}()
-
-	// 等待所有工作协程完成
-	go func() {
-		wg.Wait()
-		close(errorsChan)
-		doneChan <- true
-	}()
-
-	// 收集错误
-	go func() {
-		for err := range errorsChan {
-			errorsMutex.Lock()
-			errors = append(errors, err)
-			errorsMutex.Unlock()
-		}
-	}()
+// BatchErrors 各失败项的错误，按下标排序
+type BatchErrors []*BatchItemError
 
-	// 等待完成
-	<-doneChan
+func (e BatchErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
 
-	return errors
+// Unwrap 支持 errors.Is / errors.As 匹配其中任一项的错误
+func (e BatchErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
 }
 
 // BatchResult 批量操作结果
 type BatchResult struct {
 	SuccessCount int
 	FailureCount int
-	Errors       []error
+	SkippedCount int         // 因快速失败或 ctx 取消而未执行或被中断的项
+	Errors       BatchErrors // 失败项的错误，按下标排序
+
+	cause error // ctx 被取消时的原因
 }
 
-// ProcessWithResult 批量处理并返回详细结果
-func (bp *BatchProcessor) ProcessWithResult(items []interface{}, processFunc func(interface{}) error) *BatchResult {
-	// 使用 make 创建结果切片
-	result := &BatchResult{
-		Errors: make([]error, 0),
+// Err 有失败项时返回 Errors；没有失败项但因 ctx 取消跳过了部分项时返回 ctx 的错误；否则返回 nil
+func (r *BatchResult) Err() error {
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	if r.SkippedCount > 0 {
+		return r.cause
 	}
+	return nil
+}
 
-	// 使用 Channel 进行并发处理
-	itemsChan := make(chan interface{}, bp.batchSize)
-	resultChan := make(chan error, len(items))
+// BatchProcessor 批量处理器：用固定数量的工作协程并发处理一组数据
+type BatchProcessor[T any] struct {
+	options BatchOptions
+}
 
-	// 工作协程池
-	var wg sync.WaitGroup
-	for i := 0; i < bp.workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for item := range itemsChan {
-				err := processFunc(item)
-				resultChan <- err
-			}
-		}()
-	}
+// NewBatchProcessor 创建批量处理器
+func NewBatchProcessor[T any](options BatchOptions) *BatchProcessor[T] {
+	return &BatchProcessor[T]{options: options}
+}
 
-	// 发送任务
-	go func() {
-		for _, item := range items {
-			itemsChan <- item
-		}
-		close(itemsChan)
-	}()
-
-	// 等待完成
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	// 收集结果
-	for err := range resultChan {
-		if err != nil {
-			result.FailureCount++
-			result.Errors = append(result.Errors, err)
-		} else {
-			result.SuccessCount++
-		}
-	}
+// ProcessItems 处理所有项，返回值同 BatchResult.Err
+func (bp *BatchProcessor[T]) ProcessItems(ctx context.Context, items []T, processFunc func(context.Context, T) error) error {
+	return bp.ProcessWithResult(ctx, items, processFunc).Err()
+}
 
+// ProcessWithResult 处理所有项并返回详细结果
+func (bp *BatchProcessor[T]) ProcessWithResult(ctx context.Context, items []T, processFunc func(context.Context, T) error) *BatchResult {
+	_, result := runBatch(ctx, items, func(ctx context.Context, item T) (struct{}, error) {
+		return struct{}{}, processFunc(ctx, item)
+	}, bp.options)
 	return result
 }
 
-// ChunkSlice 将切片分块（演示 make 创建二维切片）
-func ChunkSlice(items []interface{}, chunkSize int) [][]interface{} {
-	// 计算需要多少个块
-	totalChunks := (len(items) + chunkSize - 1) / chunkSize
-
-	// 使用 make 创建二维切片
-	chunks := make([][]interface{}, 0, totalChunks)
+// ParallelMap 并发地对每一项调用 mapFunc，结果与输入顺序一致，失败或跳过的项为零值。
+// 错误同 BatchResult.Err，可用 errors.As 取出 BatchErrors 得到每个失败项的下标
+func ParallelMap[T, R any](ctx context.Context, items []T, mapFunc func(context.Context, T) (R, error), options BatchOptions) ([]R, error) {
+	results, summary := runBatch(ctx, items, mapFunc, options)
+	return results, summary.Err()
+}
 
+// ChunkSlice 将切片按 chunkSize 分块，每块是独立的副本
+func ChunkSlice[T any](items []T, chunkSize int) [][]T {
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	chunks := make([][]T, 0, (len(items)+chunkSize-1)/chunkSize)
 	for i := 0; i < len(items); i += chunkSize {
-		end := i + chunkSize
-		if end > len(items) {
-			end = len(items)
-		}
-		// 使用 make 创建每个块的切片
-		chunk := make([]interface{}, end-i)
-		copy(chunk, items[i:end])
-		chunks = append(chunks, chunk)
+		end := min(i+chunkSize, len(items))
+		chunks = append(chunks, append([]T(nil), items[i:end]...))
 	}
-
 	return chunks
 }
 
-// ParallelMap 并行映射函数（演示 Channel 和 Goroutine）
-func ParallelMap(items []interface{}, mapFunc func(interface{}) interface{}, workers int) []interface{} {
-	// 使用 make 创建结果切片
-	results := make([]interface{}, len(items))
+// runBatch 批量处理的核心：工作协程按下标领取任务，结果写入各自的位置，不需要额外加锁
+func runBatch[T, R any](parent context.Context, items []T, fn func(context.Context, T) (R, error), options BatchOptions) ([]R, *BatchResult) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
 
-	// 使用 Channel 分发任务
-	type task struct {
-		index int
-		item  interface{}
-	}
+	results := make([]R, len(items))
+	errs := make([]error, len(items))
+	started := make([]bool, len(items))
+	limiter := newRateLimiter(options.RateLimit)
 
-	tasksChan := make(chan task, len(items))
-	resultsChan := make(chan task, len(items))
+	var (
+		mu       sync.Mutex
+		progress = BatchProgress{Total: len(items)}
+	)
+	finish := func(index int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs[index] = err
+		progress.Completed++
+		if err != nil && !interrupted(ctx, err) {
+			progress.Failed++
+			if options.FailFast {
+				cancel()
+			}
+		}
+		if options.OnProgress != nil {
+			options.OnProgress(progress)
+		}
+	}
 
-	// 启动工作协程
+	indexes := make(chan int)
 	var wg sync.WaitGroup
-	for i := 0; i < workers; i++ {
+	for i := 0; i < max(options.Workers, 1); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for t := range tasksChan {
-				result := mapFunc(t.item)
-				resultsChan <- task{index: t.index, item: result}
+			for index := range indexes {
+				started[index] = true
+				value, err := runWithRetry(ctx, items[index], fn, options, limiter)
+				results[index] = value
+				finish(index, err)
 			}
 		}()
 	}
 
-	// 发送任务
-	go func() {
-		for i, item := range items {
-			tasksChan <- task{index: i, item: item}
+	// 分发任务，ctx 取消后不再分发
+dispatch:
+	for i := range items {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
 		}
-		close(tasksChan)
-	}()
+	}
+	close(indexes)
+	wg.Wait()
 
-	// 等待完成
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-	}()
+	summary := &BatchResult{cause: parent.Err()}
+	if summary.cause == nil {
+		summary.cause = context.Canceled
+	}
+	for i, err := range errs {
+		switch {
+		case !started[i]:
+			summary.SkippedCount++
+		case err == nil:
+			summary.SuccessCount++
+		case interrupted(ctx, err):
+			// 被快速失败或上层 ctx 取消中断的项不算失败
+			summary.SkippedCount++
+		default:
+			summary.FailureCount++
+			summary.Errors = append(summary.Errors, &BatchItemError{Index: i, Err: err})
+		}
+	}
+	return results, summary
+}
 
-	// 收集结果（保持顺序）
-	for t := range resultsChan {
-		results[t.index] = t.item
+// runWithRetry 执行一项，失败时按指数退避重试
+func runWithRetry[T, R any](ctx context.Context, item T, fn func(context.Context, T) (R, error), options BatchOptions, limiter *rateLimiter) (R, error) {
+	backoff := options.Backoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
 	}
 
-	return results
+	for attempt := 0; ; attempt++ {
+		var zero R
+		if err := limiter.wait(ctx); err != nil {
+			return zero, err
+		}
+		value, err := fn(ctx, item)
+		if err == nil {
+			return value, nil
+		}
+		if attempt >= options.Retries || ctx.Err() != nil || (options.RetryIf != nil && !options.RetryIf(err)) {
+			return zero, err
+		}
+		if err := sleepContext(ctx, backoff); err != nil {
+			return zero, err
+		}
+		backoff *= 2
+		if options.MaxBackoff > 0 && backoff > options.MaxBackoff {
+			backoff = options.MaxBackoff
+		}
+	}
+}
+
+// interrupted 判断错误是否由 ctx 取消（快速失败或上层取消）引起
+func interrupted(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimiter 按固定间隔放行，每次调用预约下一个时间片，不需要后台协程
+type rateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// newRateLimiter 创建每秒放行 rate 次的限速器，rate 不大于 0 时返回 nil（不限速）
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay := time.Until(slot); delay > 0 {
+		return sleepContext(ctx, delay)
+	}
+	return ctx.Err()
 }
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelMapOrderAndErrors(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6}
+	var calls []BatchProgress
+	results, err := ParallelMap(context.Background(), items, func(ctx context.Context, n int) (string, error) {
+		if n%3 == 0 {
+			return "", fmt.Errorf("bad %d", n)
+		}
+		return fmt.Sprint(n * 10), nil
+	}, BatchOptions{Workers: 3, OnProgress: func(p BatchProgress) { calls = append(calls, p) }})
+
+	// 结果与输入顺序一致，失败的项为零值
+	expected := []string{"10", "20", "", "40", "50", ""}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatalf("unexpected results %v", results)
+		}
+	}
+
+	// 错误带有失败项的下标
+	var itemErrs BatchErrors
+	if !errors.As(err, &itemErrs) || len(itemErrs) != 2 || itemErrs[0].Index != 2 || itemErrs[1].Index != 5 {
+		t.Fatalf("expected errors for index 2 and 5, got %v", err)
+	}
+
+	if len(calls) != len(items) {
+		t.Fatalf("expected a progress call per item, got %d", len(calls))
+	}
+	if last := calls[len(calls)-1]; last.Completed != 6 || last.Failed != 2 || last.Total != 6 {
+		t.Fatalf("unexpected final progress %+v", last)
+	}
+}
+
+func TestBatchProcessorFailFast(t *testing.T) {
+	failure := errors.New("boom")
+	var started atomic.Int32
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+
+	bp := NewBatchProcessor[int](BatchOptions{Workers: 2, FailFast: true})
+	result := bp.ProcessWithResult(context.Background(), items, func(ctx context.Context, n int) error {
+		started.Add(1)
+		if n == 3 {
+			return failure
+		}
+		// 正在执行的项在快速失败后被取消
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+			return nil
+		}
+	})
+
+	if result.FailureCount != 1 || !errors.Is(result.Err(), failure) {
+		t.Fatalf("expected only the failing item to be reported, got %+v", result)
+	}
+	if started.Load() >= 100 || result.SkippedCount == 0 {
+		t.Fatalf("expected remaining items to be skipped, started %d", started.Load())
+	}
+	if result.SuccessCount+result.FailureCount+result.SkippedCount != len(items) {
+		t.Fatalf("expected every item to be accounted for, got %+v", result)
+	}
+}
+
+func TestBatchProcessorRetry(t *testing.T) {
+	var attempts atomic.Int32
+	bp := NewBatchProcessor[string](BatchOptions{Retries: 3, Backoff: time.Millisecond})
+	err := bp.ProcessItems(context.Background(), []string{"flaky"}, func(ctx context.Context, s string) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+	if err != nil || attempts.Load() != 3 {
+		t.Fatalf("expected success on third attempt, got %v after %d attempts", err, attempts.Load())
+	}
+
+	// RetryIf 拒绝的错误不重试
+	permanent := errors.New("permanent")
+	attempts.Store(0)
+	bp = NewBatchProcessor[string](BatchOptions{Retries: 3, Backoff: time.Millisecond, RetryIf: func(err error) bool {
+		return !errors.Is(err, permanent)
+	}})
+	err = bp.ProcessItems(context.Background(), []string{"bad"}, func(ctx context.Context, s string) error {
+		attempts.Add(1)
+		return permanent
+	})
+	if !errors.Is(err, permanent) || attempts.Load() != 1 {
+		t.Fatalf("expected a single attempt for permanent errors, got %v after %d attempts", err, attempts.Load())
+	}
+}
+
+func TestBatchProcessorCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	items := make([]int, 50)
+	bp := NewBatchProcessor[int](BatchOptions{Workers: 2})
+	var processed atomic.Int32
+	err := bp.ProcessItems(ctx, items, func(ctx context.Context, n int) error {
+		if processed.Add(1) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if processed.Load() >= 50 {
+		t.Fatal("expected processing to stop after cancellation")
+	}
+}
+
+func TestBatchProcessorRateLimit(t *testing.T) {
+	bp := NewBatchProcessor[int](BatchOptions{Workers: 4, RateLimit: 100})
+	start := time.Now()
+	if err := bp.ProcessItems(context.Background(), make([]int, 6), func(ctx context.Context, n int) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 每秒 100 次，6 次至少间隔 50ms
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected rate limit to slow processing, took %v", elapsed)
+	}
+}
+
+func TestChunkSlice(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	chunks := ChunkSlice(items, 2)
+	if len(chunks) != 3 || len(chunks[2]) != 1 || chunks[1][0] != 3 {
+		t.Fatalf("unexpected chunks %v", chunks)
+	}
+	// 每块是副本
+	chunks[0][0] = 100
+	if items[0] != 1 {
+		t.Fatal("expected chunks to be copies")
+	}
+}
@@ -1,8 +1,14 @@
 package utils
 
 import (
-	"hash/fnv"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
 	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
 	"sync"
 )
 
@@ -10,6 +16,26 @@ import (
 type BloomFilter interface {
 	Add(data string)           // 添加元素
 	Contains(data string) bool // 判断元素是否可能存在
+	// EstimatedFalsePositiveRate 按当前填充程度估算的误报率
+	EstimatedFalsePositiveRate() float64
+}
+
+// ErrInvalidBloomSnapshot 快照格式错误或与过滤器类型不符
+var ErrInvalidBloomSnapshot = errors.New("无效的布隆过滤器快照")
+
+// bloomParams 按预计元素个数 n 和误报率 p 计算位数 m 和哈希函数个数 k
+func bloomParams(n uint, p float64) (uint, uint) {
+	n = max(n, 1)
+	// 计算所需的二进制位数 m = -(n * ln(p)) / (ln(2)^2)
+	m := uint(math.Ceil(-(float64(n) * math.Log(p)) / math.Pow(math.Log(2), 2)))
+	m = max(m, 1)
+
+	// 计算所需的哈希函数个数 k = (m/n) * ln(2)
+	k := uint(math.Round(float64(m) / float64(n) * math.Log(2)))
+	if k == 0 {
+		k = 1
+	}
+	return m, k
 }
 
 // MemoryBloomFilter 内存版布隆过滤器
@@ -17,6 +43,7 @@ type MemoryBloomFilter struct {
 	bitset []uint64
 	size   uint
 	k      uint // 哈希函数的个数
+	count  uint // 已添加的元素个数（重复添加也计数）
 	mu     sync.RWMutex
 }
 
@@ -24,14 +51,7 @@ type MemoryBloomFilter struct {
 // n: 预计存储的元素个数
 // p: 允许的误报率 (0 < p < 1)
 func NewMemoryBloomFilter(n uint, p float64) *MemoryBloomFilter {
-	// 计算所需的二进制位数 m = -(n * ln(p)) / (ln(2)^2)
-	m := uint(math.Ceil(-(float64(n) * math.Log(p)) / math.Pow(math.Log(2), 2)))
-
-	// 计算所需的哈希函数个数 k = (m/n) * ln(2)
-	k := uint(math.Round(float64(m) / float64(n) * math.Log(2)))
-	if k == 0 {
-		k = 1
-	}
+	m, k := bloomParams(n, p)
 
 	// 将 m 向上取整到 64 的倍数，方便用 uint64 数组存储
 	numWords := (m + 63) / 64
@@ -47,8 +67,8 @@ func (b *MemoryBloomFilter) Add(data string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	for i := uint(0); i < b.k; i++ {
-		index := b.hash(data, i) % b.size
+	b.count++
+	for _, index := range bloomLocations(data, b.k, b.size) {
 		wordIndex := index / 64
 		bitOffset := index % 64
 		b.bitset[wordIndex] |= (1 << bitOffset)
@@ -60,8 +80,7 @@ func (b *MemoryBloomFilter) Contains(data string) bool {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	for i := uint(0); i < b.k; i++ {
-		index := b.hash(data, i) % b.size
+	for _, index := range bloomLocations(data, b.k, b.size) {
 		wordIndex := index / 64
 		bitOffset := index % 64
 		if (b.bitset[wordIndex] & (1 << bitOffset)) == 0 {
@@ -71,14 +90,116 @@ func (b *MemoryBloomFilter) Contains(data string) bool {
 	return true // 可能存在
 }
 
-// hash 使用 FNV-1a 算法并结合不同的种子生成多个哈希索引
-func (b *MemoryBloomFilter) hash(data string, seed uint) uint {
-	h := fnv.New64a()
-	h.Write([]byte(data))
-	// 简单的加盐或者是多次哈希，这里采用种子作为额外输入
-	result := h.Sum64()
+// Count 已添加的元素个数
+func (b *MemoryBloomFilter) Count() uint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.count
+}
+
+// EstimatedFalsePositiveRate 按已置位的比例估算误报率：(置位数/m)^k
+func (b *MemoryBloomFilter) EstimatedFalsePositiveRate() float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 
-	// 为了得到 k 个不同的哈希值，常用双重哈希策略: hash(i) = h1 + i * h2
-	// 这里简化处理：将 seed 混入
-	return uint(result ^ uint64(seed)*0xBF58476D1CE4E5B9)
+	var set int
+	for _, word := range b.bitset {
+		set += bits.OnesCount64(word)
+	}
+	return math.Pow(float64(set)/float64(b.size), float64(b.k))
+}
+
+// bloomMagicMemory 等为快照头部的类型标识
+const (
+	bloomMagicMemory   = "BLM1"
+	bloomMagicCounting = "BLC1"
+	bloomMagicScalable = "BLS1"
+)
+
+// MarshalBinary 导出快照：类型标识 + m + k + count（均为 uint64 大端）+ 位数组
+func (b *MemoryBloomFilter) MarshalBinary() ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var buf bytes.Buffer
+	buf.WriteString(bloomMagicMemory)
+	binary.Write(&buf, binary.BigEndian, []uint64{uint64(b.size), uint64(b.k), uint64(b.count)})
+	binary.Write(&buf, binary.BigEndian, b.bitset)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary 从快照恢复，过滤器的大小和哈希函数个数以快照为准
+func (b *MemoryBloomFilter) UnmarshalBinary(data []byte) error {
+	size, k, count, body, err := readBloomHeader(data, bloomMagicMemory)
+	if err != nil {
+		return err
+	}
+	words := (size + 63) / 64
+	if uint64(len(body)) != words*8 {
+		return ErrInvalidBloomSnapshot
+	}
+	bitset := make([]uint64, words)
+	if err := binary.Read(bytes.NewReader(body), binary.BigEndian, bitset); err != nil {
+		return ErrInvalidBloomSnapshot
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bitset, b.size, b.k, b.count = bitset, uint(size), uint(k), uint(count)
+	return nil
+}
+
+// readBloomHeader 解析快照头部，返回 m、k、count 和剩余数据
+func readBloomHeader(data []byte, magic string) (size, k, count uint64, body []byte, err error) {
+	if len(data) < len(magic)+24 || string(data[:len(magic)]) != magic {
+		return 0, 0, 0, nil, ErrInvalidBloomSnapshot
+	}
+	data = data[len(magic):]
+	size = binary.BigEndian.Uint64(data)
+	k = binary.BigEndian.Uint64(data[8:])
+	count = binary.BigEndian.Uint64(data[16:])
+	// 限制大小，避免损坏的快照导致分配过多内存
+	if size == 0 || k == 0 || k > 64 || size > 1<<36 {
+		return 0, 0, 0, nil, ErrInvalidBloomSnapshot
+	}
+	return size, k, count, data[24:], nil
+}
+
+// SaveBloomFilter 把过滤器快照写入文件，先写临时文件并 fsync，再原子重命名
+func SaveBloomFilter(path string, filter encoding.BinaryMarshaler) error {
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// LoadBloomFilter 从文件恢复过滤器，用于启动时预热；文件不存在时返回 os.ErrNotExist
+func LoadBloomFilter(path string, filter encoding.BinaryUnmarshaler) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return filter.UnmarshalBinary(data)
 }
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// countingMax 计数器的最大值，每个计数器占 4 位
+const countingMax = 15
+
+// CountingBloomFilter 计数布隆过滤器：每个位置是 4 位计数器而不是单个二进制位，支持删除元素。
+// 计数器加到 15 后不再变化（饱和），饱和的计数器删除时也不再减少，
+// 否则可能把其他元素共用的位置减到 0，造成漏判
+type CountingBloomFilter struct {
+	counters []uint64 // 每个 uint64 存放 16 个计数器
+	size     uint
+	k        uint
+	count    uint
+	mu       sync.RWMutex
+}
+
+// NewCountingBloomFilter 创建一个计数布隆过滤器，参数含义同 NewMemoryBloomFilter，
+// 内存占用是同规格 MemoryBloomFilter 的 4 倍
+func NewCountingBloomFilter(n uint, p float64) *CountingBloomFilter {
+	m, k := bloomParams(n, p)
+	return &CountingBloomFilter{
+		counters: make([]uint64, (m+15)/16),
+		size:     m,
+		k:        k,
+	}
+}
+
+// Add 向过滤器中添加数据
+func (b *CountingBloomFilter) Add(data string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.count++
+	for _, index := range bloomLocations(data, b.k, b.size) {
+		if c := b.counter(index); c < countingMax {
+			b.setCounter(index, c+1)
+		}
+	}
+}
+
+// Remove 删除数据，只能删除确实添加过的数据，否则会影响其他元素。
+// 数据一定不存在时返回 false 且不做修改
+func (b *CountingBloomFilter) Remove(data string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	locations := bloomLocations(data, b.k, b.size)
+	if !b.containsUnsafe(locations) {
+		return false
+	}
+	if b.count > 0 {
+		b.count--
+	}
+	for _, index := range locations {
+		// 同一个下标可能出现多次，已减到 0 时不再减少
+		if c := b.counter(index); c > 0 && c < countingMax {
+			b.setCounter(index, c-1)
+		}
+	}
+	return true
+}
+
+// Contains 判断数据是否可能存在
+func (b *CountingBloomFilter) Contains(data string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.containsUnsafe(bloomLocations(data, b.k, b.size))
+}
+
+func (b *CountingBloomFilter) containsUnsafe(locations []uint) bool {
+	for _, index := range locations {
+		if b.counter(index) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count 当前元素个数
+func (b *CountingBloomFilter) Count() uint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.count
+}
+
+// EstimatedFalsePositiveRate 按非零计数器的比例估算误报率
+func (b *CountingBloomFilter) EstimatedFalsePositiveRate() float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var set uint
+	for index := uint(0); index < b.size; index++ {
+		if b.counter(index) > 0 {
+			set++
+		}
+	}
+	return math.Pow(float64(set)/float64(b.size), float64(b.k))
+}
+
+func (b *CountingBloomFilter) counter(index uint) uint64 {
+	return b.counters[index/16] >> (index % 16 * 4) & 0xF
+}
+
+func (b *CountingBloomFilter) setCounter(index uint, value uint64) {
+	shift := index % 16 * 4
+	word := &b.counters[index/16]
+	*word = *word&^(0xF<<shift) | value<<shift
+}
+
+// MarshalBinary 导出快照，格式同 MemoryBloomFilter，数据部分为计数器数组
+func (b *CountingBloomFilter) MarshalBinary() ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var buf bytes.Buffer
+	buf.WriteString(bloomMagicCounting)
+	binary.Write(&buf, binary.BigEndian, []uint64{uint64(b.size), uint64(b.k), uint64(b.count)})
+	binary.Write(&buf, binary.BigEndian, b.counters)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary 从快照恢复
+func (b *CountingBloomFilter) UnmarshalBinary(data []byte) error {
+	size, k, count, body, err := readBloomHeader(data, bloomMagicCounting)
+	if err != nil {
+		return err
+	}
+	words := (size + 15) / 16
+	if uint64(len(body)) != words*8 {
+		return ErrInvalidBloomSnapshot
+	}
+	counters := make([]uint64, words)
+	if err := binary.Read(bytes.NewReader(body), binary.BigEndian, counters); err != nil {
+		return ErrInvalidBloomSnapshot
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.counters, b.size, b.k, b.count = counters, uint(size), uint(k), uint(count)
+	return nil
+}
@@ -2,7 +2,6 @@ package utils
 
 import (
 	"context"
-	"hash/fnv"
 	"math"
 
 	"github.com/redis/go-redis/v9"
@@ -18,12 +17,7 @@ type RedisBloomFilter struct {
 
 // NewRedisBloomFilter 创建一个基于 Redis 的布隆过滤器
 func NewRedisBloomFilter(client *redis.Client, key string, n uint, p float64) *RedisBloomFilter {
-	m := uint(math.Ceil(-(float64(n) * math.Log(p)) / math.Pow(math.Log(2), 2)))
-	k := uint(math.Round(float64(m) / float64(n) * math.Log(2)))
-	if k == 0 {
-		k = 1
-	}
-
+	m, k := bloomParams(n, p)
 	return &RedisBloomFilter{
 		client: client,
 		key:    key,
@@ -32,35 +26,57 @@ func NewRedisBloomFilter(client *redis.Client, key string, n uint, p float64) *R
 	}
 }
 
-// Add 向 Redis 过滤器中添加数据
+// redisBloomBatchSize AddMany 每个管道发送的 BITFIELD 命令数
+const redisBloomBatchSize = 1000
+
+// Add 向 Redis 过滤器中添加数据，k 个位在一次 BITFIELD 调用中设置
 func (b *RedisBloomFilter) Add(ctx context.Context, data string) error {
-	pipe := b.client.Pipeline()
-	for i := uint(0); i < b.k; i++ {
-		offset := int64(b.hash(data, i) % b.size)
-		pipe.SetBit(ctx, b.key, offset, 1)
+	return b.client.BitField(ctx, b.key, b.setArgs(data)...).Err()
+}
+
+// AddMany 批量添加数据，按批使用管道发送，预热大量数据时避免每个元素一次往返
+func (b *RedisBloomFilter) AddMany(ctx context.Context, data ...string) error {
+	for start := 0; start < len(data); start += redisBloomBatchSize {
+		pipe := b.client.Pipeline()
+		for _, item := range data[start:min(start+redisBloomBatchSize, len(data))] {
+			pipe.BitField(ctx, b.key, b.setArgs(item)...)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setArgs 设置 data 对应 k 个位的 BITFIELD 参数
+func (b *RedisBloomFilter) setArgs(data string) []interface{} {
+	locations := bloomLocations(data, b.k, b.size)
+	args := make([]interface{}, 0, len(locations)*4)
+	for _, offset := range locations {
+		args = append(args, "SET", "u1", offset, 1)
 	}
-	_, err := pipe.Exec(ctx)
-	return err
+	return args
+}
+
+// Exists 过滤器的键是否存在。键被淘汰或清空后所有查询都返回不存在，调用方应据此停止信任否定结果
+func (b *RedisBloomFilter) Exists(ctx context.Context) (bool, error) {
+	n, err := b.client.Exists(ctx, b.key).Result()
+	return n > 0, err
 }
 
-// Contains 判断数据是否可能存在于 Redis 过滤器中
+// Contains 判断数据是否可能存在于 Redis 过滤器中，k 个位在一次 BITFIELD 调用中读取
 func (b *RedisBloomFilter) Contains(ctx context.Context, data string) (bool, error) {
-	pipe := b.client.Pipeline()
-	for i := uint(0); i < b.k; i++ {
-		offset := int64(b.hash(data, i) % b.size)
-		pipe.GetBit(ctx, b.key, offset)
+	locations := bloomLocations(data, b.k, b.size)
+	args := make([]interface{}, 0, len(locations)*3)
+	for _, offset := range locations {
+		args = append(args, "GET", "u1", offset)
 	}
 
-	cmds, err := pipe.Exec(ctx)
+	bits, err := b.client.BitField(ctx, b.key, args...).Result()
 	if err != nil {
 		return false, err
 	}
-
-	for _, cmd := range cmds {
-		bit, err := cmd.(*redis.IntCmd).Result()
-		if err != nil {
-			return false, err
-		}
+	for _, bit := range bits {
 		if bit == 0 {
 			return false, nil
 		}
@@ -68,11 +84,11 @@ func (b *RedisBloomFilter) Contains(ctx context.Context, data string) (bool, err
 	return true, nil
 }
 
-// hash 生成哈希索引
-func (b *RedisBloomFilter) hash(data string, seed uint) uint {
-	h := fnv.New64a()
-	h.Write([]byte(data))
-	result := h.Sum64()
-	// 使用 seed 混淆，保证得到 k 个相互独立的哈希值
-	return uint(result ^ uint64(seed)*0xBF58476D1CE4E5B9)
+// EstimatedFalsePositiveRate 按 Redis 中已置位的比例估算误报率
+func (b *RedisBloomFilter) EstimatedFalsePositiveRate(ctx context.Context) (float64, error) {
+	set, err := b.client.BitCount(ctx, b.key, nil).Result()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(float64(set)/float64(b.size), float64(b.k)), nil
 }
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// 可扩容布隆过滤器的参数：每层容量是上一层的 2 倍，误报率是上一层的 0.8 倍，
+// 各层误报率之和收敛到 p0 / (1 - 0.8)，因此第一层取 p * (1 - 0.8) 即可保证总误报率不超过 p
+const (
+	scalableGrowth     = 2
+	scalableTightening = 0.8
+)
+
+// ScalableBloomFilter 可扩容布隆过滤器：当前层达到容量后新增一层更大、误报率更低的过滤器，
+// 查询时任一层命中即认为可能存在。元素个数事先无法估计时使用
+type ScalableBloomFilter struct {
+	capacity uint    // 第一层的容量
+	p        float64 // 总误报率上限
+	layers   []*MemoryBloomFilter
+	mu       sync.RWMutex
+}
+
+// NewScalableBloomFilter 创建一个可扩容布隆过滤器
+// n: 第一层的容量
+// p: 总误报率上限 (0 < p < 1)
+func NewScalableBloomFilter(n uint, p float64) *ScalableBloomFilter {
+	b := &ScalableBloomFilter{capacity: max(n, 1), p: p}
+	b.layers = []*MemoryBloomFilter{b.newLayer(0)}
+	return b
+}
+
+// newLayer 创建第 i 层
+func (b *ScalableBloomFilter) newLayer(i int) *MemoryBloomFilter {
+	return NewMemoryBloomFilter(b.layerCapacity(i), b.layerFalsePositive(i))
+}
+
+func (b *ScalableBloomFilter) layerCapacity(i int) uint {
+	return b.capacity * uint(math.Pow(scalableGrowth, float64(i)))
+}
+
+func (b *ScalableBloomFilter) layerFalsePositive(i int) float64 {
+	return b.p * (1 - scalableTightening) * math.Pow(scalableTightening, float64(i))
+}
+
+// Add 向过滤器中添加数据，已存在的数据不再重复添加，避免占用容量
+func (b *ScalableBloomFilter) Add(data string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.containsUnsafe(data) {
+		return
+	}
+	last := len(b.layers) - 1
+	if b.layers[last].Count() >= b.layerCapacity(last) {
+		last++
+		b.layers = append(b.layers, b.newLayer(last))
+	}
+	b.layers[last].Add(data)
+}
+
+// Contains 判断数据是否可能存在
+func (b *ScalableBloomFilter) Contains(data string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.containsUnsafe(data)
+}
+
+func (b *ScalableBloomFilter) containsUnsafe(data string) bool {
+	// 新数据都在后面的层，倒序查找
+	for i := len(b.layers) - 1; i >= 0; i-- {
+		if b.layers[i].Contains(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count 已添加的元素个数
+func (b *ScalableBloomFilter) Count() uint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var count uint
+	for _, layer := range b.layers {
+		count += layer.Count()
+	}
+	return count
+}
+
+// Layers 当前的层数
+func (b *ScalableBloomFilter) Layers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.layers)
+}
+
+// EstimatedFalsePositiveRate 估算总误报率：1 - Π(1 - 各层误报率)
+func (b *ScalableBloomFilter) EstimatedFalsePositiveRate() float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	miss := 1.0
+	for _, layer := range b.layers {
+		miss *= 1 - layer.EstimatedFalsePositiveRate()
+	}
+	return 1 - miss
+}
+
+// MarshalBinary 导出快照：类型标识 + 第一层容量 + 误报率 + 层数（均为 8 字节大端），
+// 之后依次是每层的长度（8 字节）和 MemoryBloomFilter 快照
+func (b *ScalableBloomFilter) MarshalBinary() ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var buf bytes.Buffer
+	buf.WriteString(bloomMagicScalable)
+	binary.Write(&buf, binary.BigEndian, []uint64{uint64(b.capacity), math.Float64bits(b.p), uint64(len(b.layers))})
+	for _, layer := range b.layers {
+		data, err := layer.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&buf, binary.BigEndian, uint64(len(data)))
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary 从快照恢复
+func (b *ScalableBloomFilter) UnmarshalBinary(data []byte) error {
+	magic := len(bloomMagicScalable)
+	if len(data) < magic+24 || string(data[:magic]) != bloomMagicScalable {
+		return ErrInvalidBloomSnapshot
+	}
+	data = data[magic:]
+	capacity := binary.BigEndian.Uint64(data)
+	p := math.Float64frombits(binary.BigEndian.Uint64(data[8:]))
+	count := binary.BigEndian.Uint64(data[16:])
+	data = data[24:]
+	if capacity == 0 || !(p > 0 && p < 1) || count == 0 || count > 64 {
+		return ErrInvalidBloomSnapshot
+	}
+
+	layers := make([]*MemoryBloomFilter, 0, count)
+	for i := uint64(0); i < count; i++ {
+		if len(data) < 8 {
+			return ErrInvalidBloomSnapshot
+		}
+		length := binary.BigEndian.Uint64(data)
+		data = data[8:]
+		if uint64(len(data)) < length {
+			return ErrInvalidBloomSnapshot
+		}
+		layer := &MemoryBloomFilter{}
+		if err := layer.UnmarshalBinary(data[:length]); err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+		data = data[length:]
+	}
+	if len(data) != 0 {
+		return ErrInvalidBloomSnapshot
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.capacity, b.p, b.layers = uint(capacity), p, layers
+	return nil
+}
@@ -1,7 +1,14 @@
 package utils
 
 import (
+	"context"
+	"encoding"
+	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func TestMemoryBloomFilter(t *testing.T) {
@@ -29,3 +36,253 @@ func TestMemoryBloomFilter(t *testing.T) {
 		t.Log("'dog' correctly identified as not present")
 	}
 }
+
+func TestCountingBloomFilterRemove(t *testing.T) {
+	bf := NewCountingBloomFilter(1000, 0.01)
+	bf.Add("alice")
+	bf.Add("bob")
+
+	if !bf.Remove("alice") {
+		t.Fatal("expected alice to be removed")
+	}
+	if bf.Contains("alice") {
+		t.Fatal("expected alice to be gone after remove")
+	}
+	if !bf.Contains("bob") {
+		t.Fatal("expected remove not to affect other elements")
+	}
+	if bf.Remove("carol") {
+		t.Fatal("expected removing an absent element to fail")
+	}
+
+	// 同一元素添加两次需要删除两次
+	bf.Add("dave")
+	bf.Add("dave")
+	bf.Remove("dave")
+	if !bf.Contains("dave") {
+		t.Fatal("expected dave to remain after one of two removes")
+	}
+	bf.Remove("dave")
+	if bf.Contains("dave") || bf.Count() != 1 {
+		t.Fatalf("expected only bob to remain, count %d", bf.Count())
+	}
+
+	// 饱和的计数器不再减少
+	for i := 0; i < 20; i++ {
+		bf.Add("hot")
+	}
+	for i := 0; i < 20; i++ {
+		bf.Remove("hot")
+	}
+	if !bf.Contains("hot") {
+		t.Fatal("expected saturated counters to stay set")
+	}
+}
+
+func TestScalableBloomFilterGrowth(t *testing.T) {
+	bf := NewScalableBloomFilter(100, 0.01)
+	for i := 0; i < 1000; i++ {
+		bf.Add(fmt.Sprintf("user-%d", i))
+	}
+	if bf.Layers() < 3 {
+		t.Fatalf("expected filter to grow, got %d layers", bf.Layers())
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Contains(fmt.Sprintf("user-%d", i)) {
+			t.Fatalf("expected user-%d to be contained", i)
+		}
+	}
+
+	// 重复添加不占用容量
+	layers, count := bf.Layers(), bf.Count()
+	bf.Add("user-1")
+	if bf.Layers() != layers || bf.Count() != count {
+		t.Fatal("expected re-adding an element not to change the filter")
+	}
+	if rate := bf.EstimatedFalsePositiveRate(); rate <= 0 || rate > 0.01 {
+		t.Fatalf("unexpected estimated false positive rate %v", rate)
+	}
+}
+
+func TestBloomFilterSnapshot(t *testing.T) {
+	memory := NewMemoryBloomFilter(1000, 0.01)
+	counting := NewCountingBloomFilter(1000, 0.01)
+	scalable := NewScalableBloomFilter(10, 0.01)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		memory.Add(key)
+		counting.Add(key)
+		scalable.Add(key)
+	}
+
+	dir := t.TempDir()
+	cases := []struct {
+		name    string
+		filter  BloomFilter
+		restore func() BloomFilter
+	}{
+		{"memory", memory, func() BloomFilter { return &MemoryBloomFilter{} }},
+		{"counting", counting, func() BloomFilter { return &CountingBloomFilter{} }},
+		{"scalable", scalable, func() BloomFilter { return &ScalableBloomFilter{} }},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name+".bloom")
+		if err := SaveBloomFilter(path, c.filter.(encoding.BinaryMarshaler)); err != nil {
+			t.Fatalf("%s: save failed: %v", c.name, err)
+		}
+		restored := c.restore()
+		if err := LoadBloomFilter(path, restored.(encoding.BinaryUnmarshaler)); err != nil {
+			t.Fatalf("%s: load failed: %v", c.name, err)
+		}
+		for i := 0; i < 50; i++ {
+			if !restored.Contains(fmt.Sprintf("key-%d", i)) {
+				t.Fatalf("%s: expected key-%d after restore", c.name, i)
+			}
+		}
+		if restored.EstimatedFalsePositiveRate() != c.filter.EstimatedFalsePositiveRate() {
+			t.Fatalf("%s: expected the same estimated false positive rate after restore", c.name)
+		}
+	}
+
+	// 类型不符或数据被截断时拒绝加载
+	data, _ := memory.MarshalBinary()
+	if err := (&CountingBloomFilter{}).UnmarshalBinary(data); err != ErrInvalidBloomSnapshot {
+		t.Fatalf("expected type mismatch to be rejected, got %v", err)
+	}
+	if err := (&MemoryBloomFilter{}).UnmarshalBinary(data[:len(data)-1]); err != ErrInvalidBloomSnapshot {
+		t.Fatalf("expected truncated snapshot to be rejected, got %v", err)
+	}
+}
+
+// measureFalsePositiveRate 添加 n 个元素后用另外 trials 个元素统计实际误报率
+func measureFalsePositiveRate(t *testing.T, add func(string), contains func(string) bool, n, trials int) float64 {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		add(fmt.Sprintf("member:%d", i))
+	}
+	for i := 0; i < n; i++ {
+		if !contains(fmt.Sprintf("member:%d", i)) {
+			t.Fatalf("false negative for member:%d", i)
+		}
+	}
+	var positives int
+	for i := 0; i < trials; i++ {
+		if contains(fmt.Sprintf("absent:%d", i)) {
+			positives++
+		}
+	}
+	return float64(positives) / float64(trials)
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	for _, p := range []float64{0.01, 0.001} {
+		const n, trials = 10000, 200000
+
+		memory := NewMemoryBloomFilter(n, p)
+		rate := measureFalsePositiveRate(t, memory.Add, memory.Contains, n, trials)
+		// 期望的误报次数为 trials*p，允许 1.5 倍的统计波动
+		if rate > 1.5*p {
+			t.Errorf("memory filter: measured false positive rate %.5f exceeds p=%v", rate, p)
+		}
+		if estimated := memory.EstimatedFalsePositiveRate(); estimated > 2*p || estimated < p/2 {
+			t.Errorf("memory filter: estimated rate %.5f far from p=%v", estimated, p)
+		}
+
+		counting := NewCountingBloomFilter(n, p)
+		if rate := measureFalsePositiveRate(t, counting.Add, counting.Contains, n, trials); rate > 1.5*p {
+			t.Errorf("counting filter: measured false positive rate %.5f exceeds p=%v", rate, p)
+		}
+
+		// 容量只有实际元素数的 1/10，依靠加层保持误报率
+		scalable := NewScalableBloomFilter(n/10, p)
+		if rate := measureFalsePositiveRate(t, scalable.Add, scalable.Contains, n, trials); rate > 1.5*p {
+			t.Errorf("scalable filter: measured false positive rate %.5f exceeds p=%v", rate, p)
+		}
+	}
+}
+
+func TestBloomLocations(t *testing.T) {
+	locations := bloomLocations("alice", 7, 1000)
+	if len(locations) != 7 {
+		t.Fatalf("expected 7 locations, got %d", len(locations))
+	}
+	distinct := make(map[uint]bool)
+	for _, index := range locations {
+		if index >= 1000 {
+			t.Fatalf("location %d out of range", index)
+		}
+		distinct[index] = true
+	}
+	if len(distinct) < 6 {
+		t.Fatalf("expected locations to be spread out, got %v", locations)
+	}
+
+	// 结果只取决于数据和参数，内存版和 Redis 版、快照前后保持一致
+	again := bloomLocations("alice", 7, 1000)
+	for i := range locations {
+		if locations[i] != again[i] {
+			t.Fatal("expected locations to be deterministic")
+		}
+	}
+}
+
+func TestRedisBloomFilter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", MaxRetries: -1, DialerRetries: 1})
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	key := fmt.Sprintf("test:bloom:%d", time.Now().UnixNano())
+	defer client.Del(context.Background(), key)
+
+	const n, p = 2000, 0.01
+	bf := NewRedisBloomFilter(client, key, n, p)
+	add := func(data string) {
+		if err := bf.Add(ctx, data); err != nil {
+			t.Fatalf("redis add failed: %v", err)
+		}
+	}
+	contains := func(data string) bool {
+		ok, err := bf.Contains(ctx, data)
+		if err != nil {
+			t.Fatalf("redis contains failed: %v", err)
+		}
+		return ok
+	}
+	if rate := measureFalsePositiveRate(t, add, contains, n, 20000); rate > 1.5*p {
+		t.Errorf("redis filter: measured false positive rate %.5f exceeds p=%v", rate, p)
+	}
+
+	// 与同样参数的内存版使用相同的位
+	memory := NewMemoryBloomFilter(n, p)
+	for i := 0; i < n; i++ {
+		memory.Add(fmt.Sprintf("member:%d", i))
+	}
+	estimated, err := bf.EstimatedFalsePositiveRate(ctx)
+	if err != nil || estimated != memory.EstimatedFalsePositiveRate() {
+		t.Fatalf("expected redis and memory filters to set the same bits, got %v (%v)", estimated, err)
+	}
+
+	// 批量添加与逐个添加设置相同的位，添加前键不存在
+	batch := NewRedisBloomFilter(client, key+":batch", n, p)
+	defer client.Del(context.Background(), key+":batch")
+	if exists, err := batch.Exists(ctx); err != nil || exists {
+		t.Fatalf("expected empty filter key to be absent, got %v (%v)", exists, err)
+	}
+	members := make([]string, n)
+	for i := range members {
+		members[i] = fmt.Sprintf("member:%d", i)
+	}
+	if err := batch.AddMany(ctx, members...); err != nil {
+		t.Fatalf("redis add many failed: %v", err)
+	}
+	if exists, err := batch.Exists(ctx); err != nil || !exists {
+		t.Fatalf("expected filter key to exist after AddMany, got %v (%v)", exists, err)
+	}
+	if estimated, err := batch.EstimatedFalsePositiveRate(ctx); err != nil || estimated != memory.EstimatedFalsePositiveRate() {
+		t.Fatalf("expected AddMany to set the same bits, got %v (%v)", estimated, err)
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import "hash/fnv"
+
+// bloomLocations 用 Kirsch–Mitzenmacher 双重哈希计算数据在 m 个位置中的 k 个下标：
+// g_i = (h1 + i * h2) mod m。只需计算一次哈希，误报率与 k 个独立哈希函数渐近相同。
+// 内存版和 Redis 版共用，保证同样的参数下两者的下标一致
+func bloomLocations(data string, k, m uint) []uint {
+	h1, h2 := bloomHashPair(data)
+	a := h1 % uint64(m)
+	b := h2 % uint64(m)
+	// 步长为 0 时 k 个下标全部相同，退化为单哈希
+	if b == 0 {
+		b = 1
+	}
+
+	locations := make([]uint, k)
+	for i := range locations {
+		locations[i] = uint((a + uint64(i)*b) % uint64(m))
+	}
+	return locations
+}
+
+// bloomHashPair 由 FNV-1a 派生两个相互独立的 64 位哈希值。
+// FNV 的低位分布较差，取模前用 splitmix64 的终结函数充分混合
+func bloomHashPair(data string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(data))
+	sum := h.Sum64()
+	return mix64(sum), mix64(sum ^ 0x9E3779B97F4A7C15)
+}
+
+// mix64 splitmix64 的终结函数，每个输入位都会影响所有输出位
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return x
+}
@@ -2,59 +2,15 @@ package utils
 
 import (
 	"context"
-	"encoding/json"
+	"encoding"
 	"errors"
 	"fmt"
 	"gin-backend/config"
-	"os"
+	"strconv"
 	"sync"
 	"time"
 )
 
-const cacheFile = "cache_persistence.json"
-
-// memoryCacheItem 内存缓存项
-type memoryCacheItem struct {
-	Value      string    `json:"value"`
-	Expiration time.Time `json:"expiration"`
-}
-
-var (
-	memoryCache   map[string]memoryCacheItem
-	memoryCacheMu sync.RWMutex
-)
-
-func init() {
-	loadCache()
-}
-
-func loadCache() {
-	memoryCacheMu.Lock()
-	defer memoryCacheMu.Unlock()
-
-	memoryCache = make(map[string]memoryCacheItem)
-	data, err := os.ReadFile(cacheFile)
-	if err != nil {
-		return
-	}
-
-	if err := json.Unmarshal(data, &memoryCache); err != nil {
-		fmt.Printf("加载缓存文件失败: %v\n", err)
-	}
-}
-
-func saveCacheUnsafe() {
-	data, err := json.MarshalIndent(memoryCache, "", "  ")
-	if err != nil {
-		fmt.Printf("序列化缓存失败: %v\n", err)
-		return
-	}
-
-	if err := os.WriteFile(cacheFile, data, 0644); err != nil {
-		fmt.Printf("保存缓存文件失败: %v\n", err)
-	}
-}
-
 var (
 	lastRedisCheck      time.Time
 	redisAvailableState bool
@@ -88,237 +44,221 @@ func isRedisAvailable() bool {
 
 // CacheSet 设置缓存
 func CacheSet(key string, value interface{}, expiration time.Duration) error {
-	// 将值序列化为 JSON
-	jsonValue, err := json.Marshal(value)
-	if err != nil {
-		return err
-	}
-
-	if isRedisAvailable() {
-		return config.RedisClient.Set(config.GetRedisContext(), key, jsonValue, expiration).Err()
-	}
-
-	// 降级使用内存
-	memoryCacheMu.Lock()
-	defer memoryCacheMu.Unlock()
-
-	var exp time.Time
-	if expiration > 0 {
-		exp = time.Now().Add(expiration)
-	}
-
-	memoryCache[key] = memoryCacheItem{
-		Value:      string(jsonValue),
-		Expiration: exp,
-	}
-	saveCacheUnsafe()
-	return nil
+	return DefaultCache().Set(key, value, expiration)
 }
 
 // CacheGet 获取缓存
 func CacheGet(key string, dest interface{}) error {
-	if isRedisAvailable() {
-		val, err := config.RedisClient.Get(config.GetRedisContext(), key).Result()
-		if err == nil {
-			return json.Unmarshal([]byte(val), dest)
-		}
-	}
-
-	// 从内存获取
-	memoryCacheMu.RLock()
-	item, ok := memoryCache[key]
-	memoryCacheMu.RUnlock()
-
-	if !ok {
-		return errors.New("redis: nil")
-	}
-
-	// 检查过期
-	if !item.Expiration.IsZero() && time.Now().After(item.Expiration) {
-		CacheDel(key)
-		return errors.New("redis: nil")
-	}
-
-	return json.Unmarshal([]byte(item.Value), dest)
+	return DefaultCache().Get(key, dest)
 }
 
 // CacheGetString 获取字符串缓存
 func CacheGetString(key string) (string, error) {
-	if isRedisAvailable() {
-		return config.RedisClient.Get(config.GetRedisContext(), key).Result()
-	}
-
-	memoryCacheMu.RLock()
-	item, ok := memoryCache[key]
-	memoryCacheMu.RUnlock()
-
-	if !ok {
-		return "", errors.New("redis: nil")
-	}
-
-	if !item.Expiration.IsZero() && time.Now().After(item.Expiration) {
-		CacheDel(key)
-		return "", errors.New("redis: nil")
-	}
-
-	return item.Value, nil
+	return DefaultCache().GetString(key)
 }
 
 // CacheSetString 设置字符串缓存
 func CacheSetString(key string, value string, expiration time.Duration) error {
-	if isRedisAvailable() {
-		return config.RedisClient.Set(config.GetRedisContext(), key, value, expiration).Err()
-	}
-
-	memoryCacheMu.Lock()
-	defer memoryCacheMu.Unlock()
-
-	var exp time.Time
-	if expiration > 0 {
-		exp = time.Now().Add(expiration)
-	}
+	return DefaultCache().SetString(key, value, expiration)
+}
 
-	memoryCache[key] = memoryCacheItem{
-		Value:      value,
-		Expiration: exp,
-	}
-	saveCacheUnsafe()
-	return nil
+// CacheSetNX 键不存在时才设置，返回是否设置成功（用于一次性领取、分布式互斥等）
+func CacheSetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	return DefaultCache().SetNX(key, value, expiration)
 }
 
 // CacheDel 删除缓存
 func CacheDel(keys ...string) error {
-	if isRedisAvailable() {
-		return config.RedisClient.Del(config.GetRedisContext(), keys...).Err()
-	}
-
-	memoryCacheMu.Lock()
-	defer memoryCacheMu.Unlock()
-	for _, key := range keys {
-		delete(memoryCache, key)
-	}
-	saveCacheUnsafe()
-	return nil
+	return DefaultCache().Del(keys...)
 }
 
 // CacheExists 检查缓存是否存在
 func CacheExists(key string) (bool, error) {
-	if isRedisAvailable() {
-		count, err := config.RedisClient.Exists(config.GetRedisContext(), key).Result()
-		return count > 0, err
-	}
-
-	memoryCacheMu.RLock()
-	item, ok := memoryCache[key]
-	memoryCacheMu.RUnlock()
-
-	if !ok {
-		return false, nil
-	}
-
-	if !item.Expiration.IsZero() && time.Now().After(item.Expiration) {
-		CacheDel(key)
-		return false, nil
-	}
-
-	return true, nil
+	return DefaultCache().Exists(key)
 }
 
 // CacheExpire 设置过期时间
 func CacheExpire(key string, expiration time.Duration) error {
-	if isRedisAvailable() {
-		return config.RedisClient.Expire(config.GetRedisContext(), key, expiration).Err()
-	}
-
-	memoryCacheMu.Lock()
-	defer memoryCacheMu.Unlock()
-
-	if item, ok := memoryCache[key]; ok {
-		item.Expiration = time.Now().Add(expiration)
-		memoryCache[key] = item
-		saveCacheUnsafe()
-		return nil
-	}
-
-	return errors.New("key not found")
+	return DefaultCache().Expire(key, expiration)
 }
 
 // CacheTTL 获取剩余过期时间
 func CacheTTL(key string) (time.Duration, error) {
-	if isRedisAvailable() {
-		return config.RedisClient.TTL(config.GetRedisContext(), key).Result()
-	}
-
-	memoryCacheMu.RLock()
-	item, ok := memoryCache[key]
-	memoryCacheMu.RUnlock()
-
-	if !ok {
-		return -2, errors.New("key not found")
-	}
-
-	if item.Expiration.IsZero() {
-		return -1, nil
-	}
-
-	ttl := time.Until(item.Expiration)
-	if ttl <= 0 {
-		CacheDel(key)
-		return -2, errors.New("key not found")
-	}
-
-	return ttl, nil
+	return DefaultCache().TTL(key)
 }
 
-// CacheIncr 自增
+// CacheIncr 自增，键不存在时从 0 开始，保留原有过期时间
 func CacheIncr(key string) (int64, error) {
-	return 0, errors.New("not implemented in memory fallback")
+	return CacheIncrBy(key, 1)
 }
 
-// CacheIncrBy 指定步长自增
+// CacheIncrBy 指定步长自增，键不存在时从 0 开始，保留原有过期时间
 func CacheIncrBy(key string, value int64) (int64, error) {
-	return 0, errors.New("not implemented in memory fallback")
+	return DefaultCache().IncrBy(key, value)
 }
 
 // CacheDecr 自减
 func CacheDecr(key string) (int64, error) {
-	return 0, errors.New("not implemented in memory fallback")
+	return CacheIncrBy(key, -1)
 }
 
-// CacheKeys 获取匹配的键列表
+// CacheKeys 获取匹配的键列表，支持 Redis 风格的 glob 模式（*、?、[abc]、[^a]、[a-z]、\ 转义）
 func CacheKeys(pattern string) ([]string, error) {
-	return nil, errors.New("not implemented in memory fallback")
+	return DefaultCache().Keys(pattern)
 }
 
 // CacheFlushDB 清空当前数据库
 func CacheFlushDB() error {
-	if isRedisAvailable() {
-		return config.RedisClient.FlushDB(config.GetRedisContext()).Err()
-	}
-
-	memoryCacheMu.Lock()
-	defer memoryCacheMu.Unlock()
-	memoryCache = make(map[string]memoryCacheItem)
-	saveCacheUnsafe()
-	return nil
+	return DefaultCache().FlushDB()
 }
 
-// CacheHSet 设置哈希字段
+// CacheHSet 设置哈希字段，值的格式与 Redis 客户端一致（数字转十进制、bool 转 1/0）
 func CacheHSet(key string, field string, value interface{}) error {
-	return errors.New("not implemented in memory fallback")
+	return DefaultCache().HSet(key, field, value)
 }
 
 // CacheHGet 获取哈希字段
 func CacheHGet(key string, field string) (string, error) {
-	return "", errors.New("not implemented in memory fallback")
+	return DefaultCache().HGet(key, field)
 }
 
-// CacheHGetAll 获取所有哈希字段
+// CacheHGetAll 获取所有哈希字段，键不存在时返回空 map
 func CacheHGetAll(key string) (map[string]string, error) {
-	return nil, errors.New("not implemented in memory fallback")
+	return DefaultCache().HGetAll(key)
 }
 
-// CacheHDel 删除哈希字段
+// CacheHDel 删除哈希字段，字段全部删除后键也随之删除
 func CacheHDel(key string, fields ...string) error {
-	return errors.New("not implemented in memory fallback")
+	return DefaultCache().HDel(key, fields...)
+}
+
+// errWrongType 对哈希键执行字符串操作或反之（与 Redis 的 WRONGTYPE 对应）
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// hashFieldString 按 Redis 客户端的规则把哈希字段值转为字符串
+func hashFieldString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case encoding.BinaryMarshaler:
+		data, err := v.MarshalBinary()
+		return string(data), err
+	default:
+		return "", fmt.Errorf("can't marshal %T (implement encoding.BinaryMarshaler)", value)
+	}
+}
+
+// globMatch Redis 风格的 glob 匹配：* 匹配任意字符串（包括 /），? 匹配单个字符，
+// [abc]、[^abc]、[a-z] 匹配字符集合，\ 转义下一个字符
+func globMatch(pattern, s string) bool {
+	p := []rune(pattern)
+	str := []rune(s)
+	// 回溯点：最近一个 * 的位置及其对应的字符串位置
+	starP, starS := -1, 0
+	pi, si := 0, 0
+	for si < len(str) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				starP, starS = pi, si
+				pi++
+				continue
+			case '?':
+				pi++
+				si++
+				continue
+			case '[':
+				if matched, next := matchClass(p, pi, str[si]); matched {
+					pi = next
+					si++
+					continue
+				}
+			case '\\':
+				if pi+1 < len(p) && p[pi+1] == str[si] {
+					pi += 2
+					si++
+					continue
+				}
+			default:
+				if p[pi] == str[si] {
+					pi++
+					si++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		starS++
+		pi, si = starP+1, starS
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// matchClass 匹配 p[start] 开始的字符集合，返回是否匹配及集合结束后的位置；
+// 与 Redis 一致，缺少 ] 时集合延续到模式末尾
+func matchClass(p []rune, start int, c rune) (bool, int) {
+	i := start + 1
+	negate := false
+	if i < len(p) && p[i] == '^' {
+		negate = true
+		i++
+	}
+	matched := false
+	for ; i < len(p) && p[i] != ']'; i++ {
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' {
+			hi = p[i+2]
+			i += 2
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if c >= lo && c <= hi {
+			matched = true
+		}
+	}
+	return matched != negate, min(i+1, len(p))
 }
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheNullValue 负缓存的占位值：数据不存在时缓存 JSON null，避免不存在的键反复穿透到数据库
+const cacheNullValue = "null"
+
+// CacheLoaderOptions 读穿缓存配置
+type CacheLoaderOptions struct {
+	TTL         time.Duration // 数据的缓存时间
+	NegativeTTL time.Duration // 数据不存在时的缓存时间，0 表示不缓存空结果
+	Jitter      float64       // 过期时间随机浮动的比例（如 0.1 表示 ±10%），避免同一批键同时过期
+}
+
+// CacheLoader 读穿缓存：未命中时调用加载函数并写回缓存。
+// 同一个键的并发未命中只会加载一次（singleflight），防止热点键过期时请求全部打到数据库
+type CacheLoader[T any] struct {
+	cache   Cache
+	options CacheLoaderOptions
+	group   singleflight.Group
+}
+
+// NewCacheLoader 创建读穿缓存
+func NewCacheLoader[T any](cache Cache, options CacheLoaderOptions) *CacheLoader[T] {
+	return &CacheLoader[T]{cache: cache, options: options}
+}
+
+// Load 读取键对应的数据，load 返回 nil, nil 表示数据不存在，此时 Load 也返回 nil, nil。
+// 返回值是副本，调用方可以修改
+func (l *CacheLoader[T]) Load(key string, load func() (*T, error)) (*T, error) {
+	if value, ok := l.get(key); ok {
+		return clonePtr(value), nil
+	}
+
+	result, err, _ := l.group.Do(key, func() (interface{}, error) {
+		// 等待期间可能已有其他请求写入缓存
+		if value, ok := l.get(key); ok {
+			return value, nil
+		}
+
+		value, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			if l.options.NegativeTTL > 0 {
+				l.cache.SetString(key, cacheNullValue, l.jitter(l.options.NegativeTTL))
+			}
+			return (*T)(nil), nil
+		}
+		l.cache.Set(key, value, l.jitter(l.options.TTL))
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clonePtr(result.(*T)), nil
+}
+
+// Forget 数据变更后删除缓存
+func (l *CacheLoader[T]) Forget(key string) error {
+	l.group.Forget(key)
+	return l.cache.Del(key)
+}
+
+// get 读取缓存，ok 表示命中（包括负缓存命中，此时 value 为 nil）
+func (l *CacheLoader[T]) get(key string) (*T, bool) {
+	data, err := l.cache.GetString(key)
+	if err != nil {
+		return nil, false
+	}
+	if data == cacheNullValue {
+		return nil, true
+	}
+	var value T
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return nil, false
+	}
+	return &value, true
+}
+
+// jitter 在 ttl 上加随机浮动
+func (l *CacheLoader[T]) jitter(ttl time.Duration) time.Duration {
+	if l.options.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	delta := time.Duration((rand.Float64()*2 - 1) * l.options.Jitter * float64(ttl))
+	if ttl+delta <= 0 {
+		return ttl
+	}
+	return ttl + delta
+}
+
+func clonePtr[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	clone := *value
+	return &clone
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type loaderItem struct {
+	Name string `json:"name"`
+}
+
+func TestCacheLoaderSingleflight(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{})
+	defer cache.Close()
+	loader := NewCacheLoader[loaderItem](cache, CacheLoaderOptions{TTL: time.Minute})
+
+	// 并发未命中只加载一次
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func() (*loaderItem, error) {
+		loads.Add(1)
+		<-release
+		return &loaderItem{Name: "hot"}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*loaderItem, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = loader.Load("loader:hot", load)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads.Load() != 1 {
+		t.Fatalf("expected a single load, got %d", loads.Load())
+	}
+	for _, item := range results {
+		if item == nil || item.Name != "hot" {
+			t.Fatalf("unexpected result: %+v", item)
+		}
+	}
+	// 每个调用方拿到的是副本
+	results[0].Name = "changed"
+	if results[1].Name != "hot" {
+		t.Fatal("expected callers not to share the same value")
+	}
+
+	// 之后命中缓存，不再加载
+	if item, _ := loader.Load("loader:hot", load); item.Name != "hot" || loads.Load() != 1 {
+		t.Fatalf("expected cache hit, got %+v after %d loads", item, loads.Load())
+	}
+}
+
+func TestCacheLoaderNegativeAndErrors(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{})
+	defer cache.Close()
+	loader := NewCacheLoader[loaderItem](cache, CacheLoaderOptions{TTL: time.Minute, NegativeTTL: time.Minute})
+
+	var loads int
+	missing := func() (*loaderItem, error) {
+		loads++
+		return nil, nil
+	}
+	for i := 0; i < 3; i++ {
+		if item, err := loader.Load("loader:missing", missing); item != nil || err != nil {
+			t.Fatalf("expected nil, nil for missing data, got %+v (%v)", item, err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected missing data to be cached, loaded %d times", loads)
+	}
+
+	// Forget 之后重新加载
+	loader.Forget("loader:missing")
+	loader.Load("loader:missing", func() (*loaderItem, error) { return &loaderItem{Name: "created"}, nil })
+	if item, _ := loader.Load("loader:missing", missing); item == nil || item.Name != "created" {
+		t.Fatalf("expected reloaded value, got %+v", item)
+	}
+
+	// 加载失败不写入缓存
+	failure := errors.New("db down")
+	if _, err := loader.Load("loader:error", func() (*loaderItem, error) { return nil, failure }); err != failure {
+		t.Fatalf("expected load error, got %v", err)
+	}
+	if exists, _ := cache.Exists("loader:error"); exists {
+		t.Fatal("expected failed load not to be cached")
+	}
+}
+
+func TestCacheLoaderJitter(t *testing.T) {
+	loader := NewCacheLoader[loaderItem](nil, CacheLoaderOptions{Jitter: 0.1})
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		ttl := loader.jitter(time.Hour)
+		if ttl < 54*time.Minute || ttl > 66*time.Minute {
+			t.Fatalf("jittered ttl %v out of range", ttl)
+		}
+		seen[ttl] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("expected ttl to vary")
+	}
+}
@@ -0,0 +1,502 @@
+package utils
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// memoryCacheItem 内存缓存项
+type memoryCacheItem struct {
+	Value      string            `json:"value"`
+	Hash       map[string]string `json:"hash,omitempty"` // 哈希类型的字段，非哈希键为 nil
+	Expiration time.Time         `json:"expiration"`
+}
+
+// expired 判断缓存项在 now 时是否已过期
+func (item memoryCacheItem) expired(now time.Time) bool {
+	return !item.Expiration.IsZero() && now.After(item.Expiration)
+}
+
+// memoryEntry LRU 链表中的节点
+type memoryEntry struct {
+	key  string
+	item memoryCacheItem
+}
+
+// MemoryCacheOptions 内存缓存配置
+type MemoryCacheOptions struct {
+	MaxEntries    int           // 最多保存的键数，超出时淘汰最久未访问的键，0 表示不限制
+	SweepInterval time.Duration // 后台清理过期键的间隔，0 表示不启动后台清理（只在访问时惰性删除）
+
+	// 持久化：PersistFile 为快照路径，写操作追加到 {PersistFile}.aof，为空表示不持久化
+	PersistFile     string
+	CompactInterval time.Duration // 把追加日志合并为快照的间隔，0 表示只在 Close 时合并
+	SyncEveryWrite  bool          // 每次写入后 fsync，否则每秒 fsync 一次（系统崩溃时最多丢失 1 秒的写入）
+}
+
+// memorySyncInterval 未开启 SyncEveryWrite 时 fsync 追加日志的间隔
+const memorySyncInterval = time.Second
+
+// memoryCache 进程内缓存：按 LRU 限制容量，过期键在访问时惰性删除并由后台定期清理
+type memoryCache struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List // 队首为最近访问
+	options MemoryCacheOptions
+	persist *cachePersister // 未开启持久化时为 nil
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemoryCache 创建内存缓存，开启持久化时先从快照和追加日志恢复数据
+func NewMemoryCache(options MemoryCacheOptions) Cache {
+	c := &memoryCache{
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		options: options,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if options.PersistFile != "" {
+		persist, err := openCachePersister(options.PersistFile, options.SyncEveryWrite, c.applyUnsafe)
+		if err != nil {
+			fmt.Printf("缓存持久化初始化失败，将只保存在内存中: %v\n", err)
+		} else {
+			c.persist = persist
+		}
+		// 恢复时不淘汰，避免按日志顺序误删，全部恢复后再按容量淘汰
+		c.evictUnsafe()
+	}
+
+	if options.SweepInterval > 0 || c.persist != nil {
+		go c.backgroundLoop()
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+// applyUnsafe 重放一条持久化记录，已过期的键不恢复
+func (c *memoryCache) applyUnsafe(record cacheLogRecord) {
+	switch record.Op {
+	case cacheLogSet:
+		if record.Item == nil {
+			return
+		}
+		if elem, ok := c.items[record.Key]; ok {
+			c.removeUnsafe(record.Key, elem)
+		}
+		if !record.Item.expired(time.Now()) {
+			c.items[record.Key] = c.lru.PushFront(&memoryEntry{key: record.Key, item: *record.Item})
+		}
+	case cacheLogDel:
+		for _, key := range record.Keys {
+			if elem, ok := c.items[key]; ok {
+				c.removeUnsafe(key, elem)
+			}
+		}
+	case cacheLogFlush:
+		c.items = make(map[string]*list.Element)
+		c.lru.Init()
+	}
+}
+
+// backgroundLoop 定期清理过期键、fsync 追加日志和合并快照
+func (c *memoryCache) backgroundLoop() {
+	defer close(c.done)
+
+	var sweep, sync, compact <-chan time.Time
+	if c.options.SweepInterval > 0 {
+		ticker := time.NewTicker(c.options.SweepInterval)
+		defer ticker.Stop()
+		sweep = ticker.C
+	}
+	if c.persist != nil && !c.options.SyncEveryWrite {
+		ticker := time.NewTicker(memorySyncInterval)
+		defer ticker.Stop()
+		sync = ticker.C
+	}
+	if c.persist != nil && c.options.CompactInterval > 0 {
+		ticker := time.NewTicker(c.options.CompactInterval)
+		defer ticker.Stop()
+		compact = ticker.C
+	}
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-sweep:
+			c.sweep()
+		case <-sync:
+			c.mu.Lock()
+			c.persist.sync()
+			c.mu.Unlock()
+		case <-compact:
+			c.compact()
+		}
+	}
+}
+
+// sweep 删除所有已过期的键
+func (c *memoryCache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, elem := range c.items {
+		if elem.Value.(*memoryEntry).item.expired(now) {
+			c.removeUnsafe(key, elem)
+		}
+	}
+}
+
+// compact 把当前数据写成快照并删除已合并的追加日志。只在切换日志时持有锁，写快照期间不阻塞读写
+func (c *memoryCache) compact() {
+	c.mu.Lock()
+	if c.persist == nil || (c.persist.written == 0 && !c.persist.broken) {
+		c.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	items := make(map[string]memoryCacheItem, len(c.items))
+	for key, elem := range c.items {
+		// 哈希字段写入时整体替换 map，这里共享引用是安全的
+		if item := elem.Value.(*memoryEntry).item; !item.expired(now) {
+			items[key] = item
+		}
+	}
+	rotated, err := c.persist.rotate()
+	c.mu.Unlock()
+	if err != nil {
+		fmt.Printf("切换缓存日志失败: %v\n", err)
+		return
+	}
+
+	if err := c.persist.writeSnapshot(items, rotated); err != nil {
+		fmt.Printf("保存缓存快照失败: %v\n", err)
+	}
+}
+
+// Close 停止后台任务，合并快照并关闭追加日志；之后的写入只保存在内存中
+func (c *memoryCache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		<-c.done
+		c.compact()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.persist != nil {
+			err = c.persist.close()
+			c.persist = nil
+		}
+	})
+	return err
+}
+
+// logUnsafe 追加持久化记录，调用方需持有锁
+func (c *memoryCache) logUnsafe(record cacheLogRecord) {
+	if c.persist != nil {
+		c.persist.append(record)
+	}
+}
+
+// getUnsafe 读取未过期的缓存项并标记为最近访问，已过期的顺带删除，调用方需持有锁
+func (c *memoryCache) getUnsafe(key string) (memoryCacheItem, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return memoryCacheItem{}, false
+	}
+	entry := elem.Value.(*memoryEntry)
+	if entry.item.expired(time.Now()) {
+		c.removeUnsafe(key, elem)
+		return memoryCacheItem{}, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.item, true
+}
+
+// putUnsafe 写入缓存项并记录日志，超出容量时淘汰最久未访问的键，调用方需持有锁
+func (c *memoryCache) putUnsafe(key string, item memoryCacheItem) {
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*memoryEntry).item = item
+		c.lru.MoveToFront(elem)
+	} else {
+		c.items[key] = c.lru.PushFront(&memoryEntry{key: key, item: item})
+		c.evictUnsafe()
+	}
+	c.logUnsafe(cacheLogRecord{Op: cacheLogSet, Key: key, Item: &item})
+}
+
+// removeUnsafe 删除键，调用方需持有锁。过期删除不需要记录日志（重放时按过期时间跳过），
+// 主动删除由调用方记录
+func (c *memoryCache) removeUnsafe(key string, elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, key)
+}
+
+// evictUnsafe 超出容量时从队尾淘汰最久未访问的键，调用方需持有锁
+func (c *memoryCache) evictUnsafe() {
+	if c.options.MaxEntries <= 0 {
+		return
+	}
+	for c.lru.Len() > c.options.MaxEntries {
+		oldest := c.lru.Back()
+		key := oldest.Value.(*memoryEntry).key
+		c.removeUnsafe(key, oldest)
+		c.logUnsafe(cacheLogRecord{Op: cacheLogDel, Keys: []string{key}})
+	}
+}
+
+func expirationAt(expiration time.Duration) time.Time {
+	if expiration > 0 {
+		return time.Now().Add(expiration)
+	}
+	return time.Time{}
+}
+
+func (c *memoryCache) Get(key string, dest interface{}) error {
+	value, err := c.GetString(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(value), dest)
+}
+
+func (c *memoryCache) GetString(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.getUnsafe(key)
+	if !ok {
+		return "", redis.Nil
+	}
+	if item.Hash != nil {
+		return "", errWrongType
+	}
+	return item.Value, nil
+}
+
+func (c *memoryCache) Set(key string, value interface{}, expiration time.Duration) error {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.SetString(key, string(jsonValue), expiration)
+}
+
+func (c *memoryCache) SetString(key string, value string, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.putUnsafe(key, memoryCacheItem{Value: value, Expiration: expirationAt(expiration)})
+	return nil
+}
+
+func (c *memoryCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.getUnsafe(key); ok {
+		return false, nil
+	}
+	c.putUnsafe(key, memoryCacheItem{Value: string(jsonValue), Expiration: expirationAt(expiration)})
+	return true, nil
+}
+
+func (c *memoryCache) Del(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var removed []string
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.removeUnsafe(key, elem)
+			removed = append(removed, key)
+		}
+	}
+	if len(removed) > 0 {
+		c.logUnsafe(cacheLogRecord{Op: cacheLogDel, Keys: removed})
+	}
+	return nil
+}
+
+func (c *memoryCache) Exists(key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.getUnsafe(key)
+	return ok, nil
+}
+
+func (c *memoryCache) Expire(key string, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.getUnsafe(key)
+	if !ok {
+		return errors.New("key not found")
+	}
+	item.Expiration = time.Now().Add(expiration)
+	c.putUnsafe(key, item)
+	return nil
+}
+
+func (c *memoryCache) TTL(key string) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.getUnsafe(key)
+	if !ok {
+		return -2, errors.New("key not found")
+	}
+	if item.Expiration.IsZero() {
+		return -1, nil
+	}
+	return time.Until(item.Expiration), nil
+}
+
+func (c *memoryCache) IncrBy(key string, value int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, _ := c.getUnsafe(key)
+	if item.Hash != nil {
+		return 0, errWrongType
+	}
+	var current int64
+	if item.Value != "" {
+		n, err := strconv.ParseInt(item.Value, 10, 64)
+		if err != nil {
+			return 0, errors.New("value is not an integer or out of range")
+		}
+		current = n
+	}
+	current += value
+	item.Value = strconv.FormatInt(current, 10)
+	c.putUnsafe(key, item)
+	return current, nil
+}
+
+func (c *memoryCache) Keys(pattern string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var keys []string
+	for key, elem := range c.items {
+		if !elem.Value.(*memoryEntry).item.expired(now) && globMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (c *memoryCache) FlushDB() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+	c.logUnsafe(cacheLogRecord{Op: cacheLogFlush})
+	return nil
+}
+
+func (c *memoryCache) HSet(key string, field string, value interface{}) error {
+	str, err := hashFieldString(value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.getUnsafe(key)
+	if ok && item.Hash == nil {
+		return errWrongType
+	}
+	// 复制一份再修改，避免与快照或其他读取方共享同一个 map
+	hash := make(map[string]string, len(item.Hash)+1)
+	for f, v := range item.Hash {
+		hash[f] = v
+	}
+	hash[field] = str
+	item.Hash = hash
+	c.putUnsafe(key, item)
+	return nil
+}
+
+func (c *memoryCache) HGet(key string, field string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.getUnsafe(key)
+	if !ok {
+		return "", redis.Nil
+	}
+	if item.Hash == nil {
+		return "", errWrongType
+	}
+	value, ok := item.Hash[field]
+	if !ok {
+		return "", redis.Nil
+	}
+	return value, nil
+}
+
+func (c *memoryCache) HGetAll(key string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.getUnsafe(key)
+	if ok && item.Hash == nil {
+		return nil, errWrongType
+	}
+	result := make(map[string]string, len(item.Hash))
+	for field, value := range item.Hash {
+		result[field] = value
+	}
+	return result, nil
+}
+
+func (c *memoryCache) HDel(key string, fields ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.getUnsafe(key)
+	if !ok {
+		return nil
+	}
+	if item.Hash == nil {
+		return errWrongType
+	}
+	hash := make(map[string]string, len(item.Hash))
+	for f, v := range item.Hash {
+		hash[f] = v
+	}
+	for _, field := range fields {
+		delete(hash, field)
+	}
+	if len(hash) == 0 {
+		c.removeUnsafe(key, c.items[key])
+		c.logUnsafe(cacheLogRecord{Op: cacheLogDel, Keys: []string{key}})
+		return nil
+	}
+	item.Hash = hash
+	c.putUnsafe(key, item)
+	return nil
+}
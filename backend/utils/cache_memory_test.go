@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheLRU(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{MaxEntries: 3})
+	defer cache.Close()
+
+	cache.SetString("a", "1", 0)
+	cache.SetString("b", "2", 0)
+	cache.SetString("c", "3", 0)
+
+	// 访问 a 后 b 成为最久未访问的键，写入 d 时被淘汰
+	if _, err := cache.GetString("a"); err != nil {
+		t.Fatalf("expected a to exist: %v", err)
+	}
+	cache.SetString("d", "4", 0)
+	if exists, _ := cache.Exists("b"); exists {
+		t.Fatal("expected least recently used key to be evicted")
+	}
+	keys, _ := cache.Keys("*")
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "c" || keys[2] != "d" {
+		t.Fatalf("unexpected keys after eviction: %v", keys)
+	}
+
+	// 覆盖已有键不占用额外容量
+	cache.SetString("a", "5", 0)
+	if keys, _ := cache.Keys("*"); len(keys) != 3 {
+		t.Fatalf("expected overwrite to keep size, got %v", keys)
+	}
+}
+
+func TestMemoryCacheSweep(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{SweepInterval: 10 * time.Millisecond})
+	defer cache.Close()
+
+	cache.SetString("short", "1", 5*time.Millisecond)
+	cache.SetString("long", "1", time.Minute)
+
+	// 不访问过期键，由后台清理删除
+	mc := cache.(*memoryCache)
+	deadline := time.Now().Add(time.Second)
+	for {
+		mc.mu.Lock()
+		_, ok := mc.items["short"]
+		size := len(mc.items)
+		mc.mu.Unlock()
+		if !ok {
+			if size != 1 {
+				t.Fatalf("expected only the live key to remain, got %d keys", size)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected expired key to be swept")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
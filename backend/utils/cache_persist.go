@@ -0,0 +1,322 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 追加日志记录类型
+const (
+	cacheLogSet   = "set"   // 写入键的完整状态（值、哈希字段、过期时间）
+	cacheLogDel   = "del"   // 删除键
+	cacheLogFlush = "flush" // 清空
+)
+
+// cacheLogRecord 追加日志中的一条记录。记录的是写入后的完整状态和绝对过期时间，
+// 重复重放同一段日志结果不变，因此合并中断后重放已合并过的日志也是安全的
+type cacheLogRecord struct {
+	Op   string           `json:"op"`
+	Key  string           `json:"key,omitempty"`
+	Keys []string         `json:"keys,omitempty"`
+	Item *memoryCacheItem `json:"item,omitempty"`
+}
+
+// cachePersister 内存缓存的持久化：快照文件 + 追加日志（AOF）。
+//   - 每次写操作向 {path}.aof 追加一行 "crc32 JSON"，崩溃时最多损坏最后一行，启动时丢弃并截断
+//   - 合并时先把当前日志重命名为 {path}.aof.{序号} 并开始新日志，再把内存中的全部键写入临时文件，
+//     fsync 后原子重命名为快照，最后删除已合并的旧日志
+//   - 启动时依次加载快照、未删除的旧日志（合并中断时留下）和当前日志
+type cachePersister struct {
+	snapshotPath   string
+	logPath        string
+	syncEveryWrite bool
+
+	log      cacheLogFile
+	written  int64 // 当前日志中完整记录的字节数，为 0 时无需合并
+	unsynced bool  // 是否有尚未 fsync 的写入
+	broken   bool  // 写入失败且无法截断，日志末尾留有残缺记录，下次轮换前不再追加
+}
+
+// cacheLogFile 追加日志文件（*os.File），测试时替换以模拟写入失败
+type cacheLogFile interface {
+	io.WriteCloser
+	Sync() error
+	Truncate(size int64) error
+}
+
+// openCachePersister 恢复快照和日志中的数据（逐条交给 apply），并打开日志准备追加
+func openCachePersister(path string, syncEveryWrite bool, apply func(cacheLogRecord)) (*cachePersister, error) {
+	p := &cachePersister{
+		snapshotPath:   path,
+		logPath:        path + ".aof",
+		syncEveryWrite: syncEveryWrite,
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.loadSnapshot(apply); err != nil {
+		// 快照只会被原子替换，损坏说明是旧版本直接覆盖写入的文件，跳过它继续重放日志
+		fmt.Printf("加载缓存快照失败: %v\n", err)
+	}
+
+	rotated, err := p.rotatedLogs()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range rotated {
+		if _, err := replayCacheLog(file, apply); err != nil {
+			fmt.Printf("缓存日志 %s 末尾损坏，已忽略损坏部分: %v\n", file, err)
+		}
+	}
+
+	valid, err := replayCacheLog(p.logPath, apply)
+	if err != nil {
+		// 丢弃最后一条不完整的记录，之后追加的记录才能被正确读取
+		fmt.Printf("缓存日志末尾损坏，截断到 %d 字节: %v\n", valid, err)
+		if err := os.Truncate(p.logPath, valid); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(p.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	p.log = file
+	p.written = valid
+	return p, nil
+}
+
+// loadSnapshot 加载快照，快照是 key -> 缓存项的 JSON 对象
+func (p *cachePersister) loadSnapshot(apply func(cacheLogRecord)) error {
+	data, err := os.ReadFile(p.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var items map[string]memoryCacheItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	for key, item := range items {
+		apply(cacheLogRecord{Op: cacheLogSet, Key: key, Item: &item})
+	}
+	return nil
+}
+
+// rotatedLogs 按生成顺序返回合并中断时留下的旧日志
+func (p *cachePersister) rotatedLogs() ([]string, error) {
+	files, err := filepath.Glob(p.logPath + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var rotated []string
+	for _, file := range files {
+		if _, err := strconv.ParseUint(filepath.Ext(file)[1:], 10, 64); err == nil {
+			rotated = append(rotated, file)
+		}
+	}
+	sort.Strings(rotated)
+	return rotated, nil
+}
+
+// replayCacheLog 逐条重放日志，返回完整有效记录的总字节数；遇到不完整或校验失败的记录时停止并返回错误
+func replayCacheLog(path string, apply func(cacheLogRecord)) (int64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var valid int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return valid, errors.New("记录不完整")
+			}
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+
+		record, err := decodeCacheLogLine(line)
+		if err != nil {
+			return valid, err
+		}
+		apply(record)
+		valid += int64(len(line))
+	}
+}
+
+// encodeCacheLogLine 编码为 "8 位十六进制 CRC32 + 空格 + JSON + 换行"
+func encodeCacheLogLine(record cacheLogRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(payload)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.ChecksumIEEE(payload))
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+func decodeCacheLogLine(line []byte) (cacheLogRecord, error) {
+	var record cacheLogRecord
+	if len(line) < 10 || line[8] != ' ' {
+		return record, errors.New("记录格式错误")
+	}
+	payload := line[9 : len(line)-1]
+	checksum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if err != nil || uint32(checksum) != crc32.ChecksumIEEE(payload) {
+		return record, errors.New("记录校验失败")
+	}
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// append 追加一条记录，调用方需持有缓存的锁。
+// 写入失败或只写入一部分时截断回上一条完整记录的末尾，否则重放会停在残缺记录处，丢掉之后追加的全部记录
+func (p *cachePersister) append(record cacheLogRecord) {
+	if p.broken {
+		return
+	}
+	line, err := encodeCacheLogLine(record)
+	if err != nil {
+		fmt.Printf("序列化缓存日志失败: %v\n", err)
+		return
+	}
+	n, err := p.log.Write(line)
+	if err == nil && n < len(line) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		fmt.Printf("写入缓存日志失败: %v\n", err)
+		if n > 0 {
+			if err := p.log.Truncate(p.written); err != nil {
+				// 日志以追加方式打开，残缺记录之后的写入都无法重放，等下次合并轮换出新日志
+				fmt.Printf("截断缓存日志失败，下次合并前暂停写入日志: %v\n", err)
+				p.broken = true
+			}
+		}
+		return
+	}
+	p.written += int64(n)
+	if p.syncEveryWrite {
+		p.log.Sync()
+	} else {
+		p.unsynced = true
+	}
+}
+
+// sync 把未落盘的写入 fsync 到磁盘，调用方需持有缓存的锁
+func (p *cachePersister) sync() {
+	if !p.unsynced {
+		return
+	}
+	if err := p.log.Sync(); err != nil {
+		fmt.Printf("同步缓存日志失败: %v\n", err)
+		return
+	}
+	p.unsynced = false
+}
+
+// rotate 把当前日志重命名为旧日志并开始新日志，返回旧日志路径，调用方需持有缓存的锁
+func (p *cachePersister) rotate() (string, error) {
+	p.sync()
+	if err := p.log.Close(); err != nil {
+		return "", err
+	}
+
+	rotated := fmt.Sprintf("%s.%020d", p.logPath, time.Now().UnixNano())
+	renameErr := os.Rename(p.logPath, rotated)
+
+	// 无论重命名是否成功都要重新打开日志，保证后续写入不丢
+	file, err := os.OpenFile(p.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", err
+	}
+	p.log = file
+	if renameErr != nil {
+		return "", renameErr
+	}
+	p.written = 0
+	p.broken = false
+	return rotated, nil
+}
+
+// writeSnapshot 原子地写入快照，成功后删除 rotated 及更早的旧日志
+func (p *cachePersister) writeSnapshot(items map[string]memoryCacheItem, rotated string) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	tmp := p.snapshotPath + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	// 先落盘再重命名，否则崩溃后可能得到一个已重命名但内容为空的快照
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p.snapshotPath); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(p.snapshotPath))
+
+	files, err := p.rotatedLogs()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file <= rotated {
+			os.Remove(file)
+		}
+	}
+	return nil
+}
+
+// close 同步并关闭日志，调用方需持有缓存的锁
+func (p *cachePersister) close() error {
+	p.sync()
+	return p.log.Close()
+}
+
+// syncDir fsync 目录，使重命名在断电后也能保留
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// crashCache 模拟进程崩溃：停止后台任务、关闭日志，但不做 Close 时的合并
+func crashCache(c Cache) {
+	mc := c.(*memoryCache)
+	close(mc.stop)
+	<-mc.done
+	mc.mu.Lock()
+	mc.persist.log.Close()
+	mc.persist = nil
+	mc.mu.Unlock()
+}
+
+func openPersistentCache(file string) Cache {
+	return NewMemoryCache(MemoryCacheOptions{PersistFile: file, SyncEveryWrite: true})
+}
+
+func TestMemoryCacheLogReplay(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.json")
+
+	cache := openPersistentCache(file)
+	cache.Set("obj", map[string]int{"n": 1}, time.Minute)
+	cache.SetString("expired", "x", time.Millisecond)
+	cache.SetString("deleted", "x", 0)
+	cache.Del("deleted")
+	cache.HSet("hash", "f", "v")
+	cache.IncrBy("counter", 3)
+	crashCache(cache)
+	time.Sleep(2 * time.Millisecond)
+
+	// 写入时只追加日志，不生成快照
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("expected no snapshot before compaction, got %v", err)
+	}
+
+	restored := openPersistentCache(file)
+	defer restored.Close()
+	var obj map[string]int
+	if err := restored.Get("obj", &obj); err != nil || obj["n"] != 1 {
+		t.Fatalf("expected value to be restored, got %v (%v)", obj, err)
+	}
+	if ttl, _ := restored.TTL("obj"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("expected expiration to be restored, got %v", ttl)
+	}
+	if v, err := restored.HGet("hash", "f"); err != nil || v != "v" {
+		t.Fatalf("expected hash to be restored, got %q (%v)", v, err)
+	}
+	if n, _ := restored.IncrBy("counter", 0); n != 3 {
+		t.Fatalf("expected counter 3, got %d", n)
+	}
+	for _, key := range []string{"expired", "deleted"} {
+		if exists, _ := restored.Exists(key); exists {
+			t.Fatalf("expected %s not to be restored", key)
+		}
+	}
+}
+
+func TestMemoryCacheTruncatedLog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.json")
+	logFile := file + ".aof"
+
+	cache := openPersistentCache(file)
+	cache.SetString("a", "1", 0)
+	cache.SetString("b", "2", 0)
+	cache.SetString("c", "3", 0)
+	crashCache(cache)
+
+	// 最后一条记录只写了一半
+	data, _ := os.ReadFile(logFile)
+	os.WriteFile(logFile, data[:len(data)-5], 0644)
+
+	restored := openPersistentCache(file)
+	if v, _ := restored.GetString("b"); v != "2" {
+		t.Fatalf("expected complete records to be restored, got %q", v)
+	}
+	if exists, _ := restored.Exists("c"); exists {
+		t.Fatal("expected truncated record to be dropped")
+	}
+	// 损坏部分已被截断，之后追加的记录可以正常恢复
+	restored.SetString("d", "4", 0)
+	crashCache(restored)
+
+	again := openPersistentCache(file)
+	defer again.Close()
+	keys, _ := again.Keys("*")
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "d" {
+		t.Fatalf("unexpected keys after recovery: %v", keys)
+	}
+}
+
+func TestMemoryCacheCorruptedLog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.json")
+	logFile := file + ".aof"
+
+	cache := openPersistentCache(file)
+	cache.SetString("a", "1", 0)
+	cache.SetString("b", "2", 0)
+	cache.SetString("c", "3", 0)
+	crashCache(cache)
+
+	// 第二条记录的内容被破坏，校验失败后停止重放
+	data, _ := os.ReadFile(logFile)
+	second := bytes.IndexByte(data, '\n') + 1
+	data[second+20] ^= 0xff
+	os.WriteFile(logFile, data, 0644)
+
+	restored := openPersistentCache(file)
+	defer restored.Close()
+	keys, _ := restored.Keys("*")
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Fatalf("expected replay to stop at the corrupted record, got %v", keys)
+	}
+	if info, _ := os.Stat(logFile); info.Size() != int64(second) {
+		t.Fatalf("expected log to be truncated to %d bytes, got %d", second, info.Size())
+	}
+}
+
+// tornLogFile 模拟磁盘写满：写入前一半后返回错误，可选截断也失败
+type tornLogFile struct {
+	*os.File
+	failWrite    bool
+	failTruncate bool
+}
+
+func (f *tornLogFile) Write(p []byte) (int, error) {
+	if !f.failWrite {
+		return f.File.Write(p)
+	}
+	n, _ := f.File.Write(p[:len(p)/2])
+	return n, errors.New("no space left on device")
+}
+
+func (f *tornLogFile) Truncate(size int64) error {
+	if f.failTruncate {
+		return errors.New("truncate failed")
+	}
+	return f.File.Truncate(size)
+}
+
+func TestMemoryCacheTornWrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.json")
+	logFile := file + ".aof"
+
+	cache := openPersistentCache(file)
+	mc := cache.(*memoryCache)
+	torn := &tornLogFile{File: mc.persist.log.(*os.File)}
+	mc.persist.log = torn
+
+	cache.SetString("a", "1", 0)
+	torn.failWrite = true
+	cache.SetString("b", "2", 0)
+	torn.failWrite = false
+	cache.SetString("c", "3", 0)
+
+	// 写了一半的记录被截断，之后追加的记录仍能重放
+	data, _ := os.ReadFile(logFile)
+	if int64(len(data)) != mc.persist.written {
+		t.Fatalf("expected log to hold only complete records (%d bytes), got %d", mc.persist.written, len(data))
+	}
+	crashCache(cache)
+
+	restored := openPersistentCache(file)
+	keys, _ := restored.Keys("*")
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Fatalf("expected records after the failed write to be replayed, got %v", keys)
+	}
+	if info, _ := os.Stat(logFile); info.Size() != int64(len(data)) {
+		t.Fatalf("expected no truncation on reopen, log is %d bytes instead of %d", info.Size(), len(data))
+	}
+
+	// 截断也失败时暂停追加，合并写出快照并轮换日志后恢复
+	mc = restored.(*memoryCache)
+	torn = &tornLogFile{File: mc.persist.log.(*os.File), failWrite: true, failTruncate: true}
+	mc.persist.log = torn
+	restored.SetString("d", "4", 0)
+	torn.failWrite = false
+	restored.SetString("e", "5", 0)
+	if !mc.persist.broken {
+		t.Fatal("expected log to be marked broken after a failed truncate")
+	}
+	mc.compact()
+	restored.SetString("f", "6", 0)
+	crashCache(restored)
+
+	restored = openPersistentCache(file)
+	defer restored.Close()
+	keys, _ = restored.Keys("*")
+	if len(keys) != 5 || keys[2] != "d" || keys[3] != "e" || keys[4] != "f" {
+		t.Fatalf("expected snapshot and new log to restore all in-memory keys, got %v", keys)
+	}
+}
+
+func TestMemoryCacheCompaction(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "cache.json")
+	logFile := file + ".aof"
+
+	cache := openPersistentCache(file)
+	mc := cache.(*memoryCache)
+	cache.SetString("a", "1", 0)
+	cache.SetString("b", "2", 0)
+
+	// 合并后生成快照，日志清空
+	mc.compact()
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("expected snapshot after compaction: %v", err)
+	}
+	if info, _ := os.Stat(logFile); info.Size() != 0 {
+		t.Fatalf("expected empty log after compaction, got %d bytes", info.Size())
+	}
+
+	// 模拟合并中断：日志已切换，但快照还没写完就崩溃，留下旧日志和不完整的临时快照
+	cache.SetString("a", "10", 0)
+	cache.Del("b")
+	mc.mu.Lock()
+	if _, err := mc.persist.rotate(); err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	mc.mu.Unlock()
+	cache.SetString("c", "3", 0)
+	crashCache(cache)
+	os.WriteFile(file+".tmp", []byte(`{"a":{"val`), 0644)
+
+	restored := openPersistentCache(file)
+	keys, _ := restored.Keys("*")
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Fatalf("unexpected keys after interrupted compaction: %v", keys)
+	}
+	if v, _ := restored.GetString("a"); v != "10" {
+		t.Fatalf("expected latest value from rotated log, got %q", v)
+	}
+
+	// 正常关闭时合并，旧日志被删除
+	restored.Close()
+	rotated, _ := filepath.Glob(logFile + ".*")
+	if len(rotated) != 0 {
+		t.Fatalf("expected rotated logs to be removed, got %v", rotated)
+	}
+	reopened := openPersistentCache(file)
+	defer reopened.Close()
+	if v, _ := reopened.GetString("a"); v != "10" {
+		t.Fatalf("expected value from snapshot, got %q", v)
+	}
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/json"
+	"gin-backend/config"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisCache 基于 Redis 的缓存，多实例共享
+type redisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache 创建 Redis 缓存，连接由调用方管理，Close 不会关闭连接
+func NewRedisCache(client *redis.Client) Cache {
+	return &redisCache{client: client}
+}
+
+func (c *redisCache) Get(key string, dest interface{}) error {
+	val, err := c.client.Get(config.GetRedisContext(), key).Result()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(val), dest)
+}
+
+func (c *redisCache) GetString(key string) (string, error) {
+	return c.client.Get(config.GetRedisContext(), key).Result()
+}
+
+func (c *redisCache) Set(key string, value interface{}, expiration time.Duration) error {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(config.GetRedisContext(), key, jsonValue, expiration).Err()
+}
+
+func (c *redisCache) SetString(key string, value string, expiration time.Duration) error {
+	return c.client.Set(config.GetRedisContext(), key, value, expiration).Err()
+}
+
+func (c *redisCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return c.client.SetNX(config.GetRedisContext(), key, jsonValue, expiration).Result()
+}
+
+func (c *redisCache) Del(keys ...string) error {
+	return c.client.Del(config.GetRedisContext(), keys...).Err()
+}
+
+func (c *redisCache) Exists(key string) (bool, error) {
+	count, err := c.client.Exists(config.GetRedisContext(), key).Result()
+	return count > 0, err
+}
+
+func (c *redisCache) Expire(key string, expiration time.Duration) error {
+	return c.client.Expire(config.GetRedisContext(), key, expiration).Err()
+}
+
+func (c *redisCache) TTL(key string) (time.Duration, error) {
+	return c.client.TTL(config.GetRedisContext(), key).Result()
+}
+
+func (c *redisCache) IncrBy(key string, value int64) (int64, error) {
+	return c.client.IncrBy(config.GetRedisContext(), key, value).Result()
+}
+
+// Keys 使用 SCAN 代替 KEYS，避免大库时阻塞 Redis
+func (c *redisCache) Keys(pattern string) ([]string, error) {
+	var keys []string
+	iter := c.client.Scan(config.GetRedisContext(), 0, pattern, 100).Iterator()
+	for iter.Next(config.GetRedisContext()) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func (c *redisCache) FlushDB() error {
+	return c.client.FlushDB(config.GetRedisContext()).Err()
+}
+
+func (c *redisCache) HSet(key string, field string, value interface{}) error {
+	return c.client.HSet(config.GetRedisContext(), key, field, value).Err()
+}
+
+func (c *redisCache) HGet(key string, field string) (string, error) {
+	return c.client.HGet(config.GetRedisContext(), key, field).Result()
+}
+
+func (c *redisCache) HGetAll(key string) (map[string]string, error) {
+	return c.client.HGetAll(config.GetRedisContext(), key).Result()
+}
+
+func (c *redisCache) HDel(key string, fields ...string) error {
+	return c.client.HDel(config.GetRedisContext(), key, fields...).Err()
+}
+
+func (c *redisCache) Close() error {
+	return nil
+}
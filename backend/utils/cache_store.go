@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"errors"
+	"gin-backend/config"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache 缓存接口，语义与 Redis 对应的命令一致：键不存在时 Get 类方法返回 redis.Nil，
+// 对类型不符的键操作返回 WRONGTYPE 错误
+type Cache interface {
+	Get(key string, dest interface{}) error // 读取并按 JSON 反序列化
+	GetString(key string) (string, error)
+	Set(key string, value interface{}, expiration time.Duration) error // 按 JSON 序列化后写入，expiration 为 0 表示不过期
+	SetString(key string, value string, expiration time.Duration) error
+	SetNX(key string, value interface{}, expiration time.Duration) (bool, error)
+	Del(keys ...string) error
+	Exists(key string) (bool, error)
+	Expire(key string, expiration time.Duration) error
+	TTL(key string) (time.Duration, error)
+	IncrBy(key string, value int64) (int64, error)
+	Keys(pattern string) ([]string, error)
+	FlushDB() error
+
+	HSet(key string, field string, value interface{}) error
+	HGet(key string, field string) (string, error)
+	HGetAll(key string) (map[string]string, error)
+	HDel(key string, fields ...string) error
+
+	// Close 释放后台资源（清理协程、订阅等），不会关闭外部传入的 Redis 连接
+	Close() error
+}
+
+const (
+	cacheFile = "cache_persistence.json"
+
+	// defaultCacheMaxEntries 内存缓存默认容量
+	defaultCacheMaxEntries = 100000
+	// defaultCacheSweepInterval 内存缓存默认清理间隔
+	defaultCacheSweepInterval = time.Minute
+	// defaultCacheCompactInterval 内存缓存默认合并追加日志的间隔
+	defaultCacheCompactInterval = 5 * time.Minute
+)
+
+var (
+	defaultCache   Cache
+	defaultCacheMu sync.Mutex
+)
+
+// DefaultCache 返回包级 Cache* 函数使用的缓存。未调用 SetDefaultCache 时，
+// Redis 可用则使用 Redis，否则降级为持久化到当前目录 cache_persistence.json 的内存缓存
+func DefaultCache() Cache {
+	defaultCacheMu.Lock()
+	defer defaultCacheMu.Unlock()
+	if defaultCache == nil {
+		defaultCache = NewFallbackCache(NewMemoryCache(MemoryCacheOptions{
+			MaxEntries:      defaultCacheMaxEntries,
+			SweepInterval:   defaultCacheSweepInterval,
+			PersistFile:     cacheFile,
+			CompactInterval: defaultCacheCompactInterval,
+		}))
+	}
+	return defaultCache
+}
+
+// SetDefaultCache 替换包级 Cache* 函数使用的缓存，原缓存会被关闭。
+// 包级 Publish/Subscribe 同时切换为与该缓存相同存储的发布订阅后端
+func SetDefaultCache(cache Cache) {
+	defaultCacheMu.Lock()
+	previous := defaultCache
+	defaultCache = cache
+	defaultCacheMu.Unlock()
+	setDefaultBroker(brokerFor(cache))
+
+	if previous != nil && previous != cache {
+		previous.Close()
+	}
+}
+
+// NewCache 按配置创建缓存：
+//   - memory：只使用内存
+//   - redis：只使用 Redis
+//   - tiered：内存 + Redis 两级，多实例之间通过发布订阅失效
+//   - auto（默认）：Redis 可用时使用 Redis，不可用时降级为内存
+func NewCache(cfg config.CacheConfig) (Cache, error) {
+	memoryOptions := MemoryCacheOptions{
+		MaxEntries:      cfg.MaxEntries,
+		SweepInterval:   time.Duration(cfg.SweepIntervalSeconds) * time.Second,
+		PersistFile:     cfg.PersistFile,
+		CompactInterval: time.Duration(cfg.CompactIntervalSeconds) * time.Second,
+		SyncEveryWrite:  cfg.FsyncAlways,
+	}
+
+	switch cfg.Driver {
+	case "memory":
+		return NewMemoryCache(memoryOptions), nil
+	case "redis":
+		if config.RedisClient == nil {
+			return nil, errors.New("缓存驱动为 redis，但 Redis 未连接")
+		}
+		return NewRedisCache(config.RedisClient), nil
+	case "tiered":
+		if config.RedisClient == nil {
+			return nil, errors.New("缓存驱动为 tiered，但 Redis 未连接")
+		}
+		// L1 只是 L2 的副本，不需要持久化
+		memoryOptions.PersistFile = ""
+		return NewTieredCache(NewMemoryCache(memoryOptions), NewRedisCache(config.RedisClient), time.Duration(cfg.L1TTLSeconds)*time.Second)
+	case "", "auto":
+		return NewFallbackCache(NewMemoryCache(memoryOptions)), nil
+	default:
+		return nil, errors.New("未知的缓存驱动: " + cfg.Driver)
+	}
+}
+
+// fallbackCache 每次操作时检查 Redis 是否可用，可用时使用 Redis，否则降级为内存缓存。
+// 两个后端互不同步：Redis 不可用期间写入内存的键在 Redis 恢复后不可见
+type fallbackCache struct {
+	memory Cache
+
+	mu     sync.Mutex
+	client *redis.Client
+	redis  Cache
+}
+
+// NewFallbackCache 创建可降级的缓存，Redis 不可用时使用 memory
+func NewFallbackCache(memory Cache) Cache {
+	return &fallbackCache{memory: memory}
+}
+
+// current 返回当前应使用的缓存，Redis 重新连接（客户端变化）后重建 Redis 缓存
+func (c *fallbackCache) current() Cache {
+	if !isRedisAvailable() {
+		return c.memory
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != config.RedisClient {
+		c.client = config.RedisClient
+		c.redis = NewRedisCache(c.client)
+	}
+	return c.redis
+}
+
+// Get 与其他操作一样只读当前后端。Redis 恢复后不再读取不可用期间写入内存的键，
+// 否则 Del/Set 只作用于 Redis，内存中的旧值会重新生效
+func (c *fallbackCache) Get(key string, dest interface{}) error {
+	return c.current().Get(key, dest)
+}
+
+func (c *fallbackCache) GetString(key string) (string, error) {
+	return c.current().GetString(key)
+}
+
+func (c *fallbackCache) Set(key string, value interface{}, expiration time.Duration) error {
+	return c.current().Set(key, value, expiration)
+}
+
+func (c *fallbackCache) SetString(key string, value string, expiration time.Duration) error {
+	return c.current().SetString(key, value, expiration)
+}
+
+func (c *fallbackCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	return c.current().SetNX(key, value, expiration)
+}
+
+func (c *fallbackCache) Del(keys ...string) error {
+	return c.current().Del(keys...)
+}
+
+func (c *fallbackCache) Exists(key string) (bool, error) {
+	return c.current().Exists(key)
+}
+
+func (c *fallbackCache) Expire(key string, expiration time.Duration) error {
+	return c.current().Expire(key, expiration)
+}
+
+func (c *fallbackCache) TTL(key string) (time.Duration, error) {
+	return c.current().TTL(key)
+}
+
+func (c *fallbackCache) IncrBy(key string, value int64) (int64, error) {
+	return c.current().IncrBy(key, value)
+}
+
+func (c *fallbackCache) Keys(pattern string) ([]string, error) {
+	return c.current().Keys(pattern)
+}
+
+func (c *fallbackCache) FlushDB() error {
+	return c.current().FlushDB()
+}
+
+func (c *fallbackCache) HSet(key string, field string, value interface{}) error {
+	return c.current().HSet(key, field, value)
+}
+
+func (c *fallbackCache) HGet(key string, field string) (string, error) {
+	return c.current().HGet(key, field)
+}
+
+func (c *fallbackCache) HGetAll(key string) (map[string]string, error) {
+	return c.current().HGetAll(key)
+}
+
+func (c *fallbackCache) HDel(key string, fields ...string) error {
+	return c.current().HDel(key, fields...)
+}
+
+func (c *fallbackCache) Close() error {
+	return c.memory.Close()
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"gin-backend/config"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TestMain 包级缓存函数使用不落盘的内存缓存，测试不在包目录下留下持久化文件
+func TestMain(m *testing.M) {
+	SetDefaultCache(NewMemoryCache(MemoryCacheOptions{}))
+	os.Exit(m.Run())
+}
+
+func TestCacheCounters(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+	t.Cleanup(func() { CacheDel("test:counter", "test:text", "test:hash") })
+
+	if n, err := CacheIncr("test:counter"); err != nil || n != 1 {
+		t.Fatalf("expected 1, got %d (%v)", n, err)
+	}
+	if n, _ := CacheIncrBy("test:counter", 10); n != 11 {
+		t.Fatalf("expected 11, got %d", n)
+	}
+	if n, _ := CacheDecr("test:counter"); n != 10 {
+		t.Fatalf("expected 10, got %d", n)
+	}
+
+	// 计数器与 CacheGet 互通，自增保留原有过期时间
+	var value int64
+	if err := CacheGet("test:counter", &value); err != nil || value != 10 {
+		t.Fatalf("expected CacheGet to read 10, got %d (%v)", value, err)
+	}
+	if err := CacheExpire("test:counter", 50*time.Millisecond); err != nil {
+		t.Fatalf("CacheExpire failed: %v", err)
+	}
+	CacheIncr("test:counter")
+	if ttl, err := CacheTTL("test:counter"); err != nil || ttl <= 0 {
+		t.Fatalf("expected ttl to be kept, got %v (%v)", ttl, err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n, _ := CacheIncr("test:counter"); n != 1 {
+		t.Fatalf("expected expired counter to restart at 1, got %d", n)
+	}
+
+	CacheSetString("test:text", "abc", 0)
+	if _, err := CacheIncr("test:text"); err == nil {
+		t.Fatal("expected incrementing a non-integer to fail")
+	}
+	CacheHSet("test:hash", "f", 1)
+	if _, err := CacheIncr("test:hash"); err != errWrongType {
+		t.Fatalf("expected wrong type error, got %v", err)
+	}
+}
+
+func TestCacheIncrConcurrent(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+	t.Cleanup(func() { CacheDel("test:concurrent") })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				CacheIncr("test:concurrent")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, _ := CacheIncrBy("test:concurrent", 0); n != 200 {
+		t.Fatalf("expected 200 after concurrent increments, got %d", n)
+	}
+}
+
+func TestCacheHash(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+	t.Cleanup(func() { CacheDel("test:hash", "test:plain") })
+
+	CacheHSet("test:hash", "name", "alice")
+	CacheHSet("test:hash", "age", 30)
+	CacheHSet("test:hash", "admin", true)
+
+	if v, err := CacheHGet("test:hash", "age"); err != nil || v != "30" {
+		t.Fatalf("expected age 30, got %q (%v)", v, err)
+	}
+	if _, err := CacheHGet("test:hash", "missing"); err == nil {
+		t.Fatal("expected missing field to return an error")
+	}
+	all, err := CacheHGetAll("test:hash")
+	if err != nil || len(all) != 3 || all["admin"] != "1" || all["name"] != "alice" {
+		t.Fatalf("unexpected hash: %v (%v)", all, err)
+	}
+	if err := CacheHSet("test:hash", "bad", struct{}{}); err == nil {
+		t.Fatal("expected unsupported value type to fail")
+	}
+
+	// 删除全部字段后键也不存在
+	CacheHDel("test:hash", "name", "age")
+	CacheHDel("test:hash", "admin")
+	if exists, _ := CacheExists("test:hash"); exists {
+		t.Fatal("expected empty hash to be removed")
+	}
+	if all, _ := CacheHGetAll("test:hash"); len(all) != 0 {
+		t.Fatalf("expected empty map, got %v", all)
+	}
+
+	CacheSetString("test:plain", "x", 0)
+	if err := CacheHSet("test:plain", "f", "v"); err != errWrongType {
+		t.Fatalf("expected wrong type error, got %v", err)
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	keys := []string{"glob:user:1", "glob:user:2", "glob:user:10", "glob:order/1", "glob:a*b"}
+	for _, key := range keys {
+		CacheSetString(key, "1", 0)
+	}
+	CacheSetString("glob:expired", "1", time.Millisecond)
+	t.Cleanup(func() { CacheDel(append(keys, "glob:expired")...) })
+	time.Sleep(5 * time.Millisecond)
+
+	cases := map[string][]string{
+		"glob:*":          {"glob:a*b", "glob:order/1", "glob:user:1", "glob:user:10", "glob:user:2"},
+		"glob:user:?":     {"glob:user:1", "glob:user:2"},
+		"glob:user:[12]*": {"glob:user:1", "glob:user:10", "glob:user:2"},
+		"glob:user:[^1]":  {"glob:user:2"},
+		"glob:user:[0-1]": {"glob:user:1"},
+		"glob:o*1":        {"glob:order/1"},
+		"glob:a\\*b":      {"glob:a*b"},
+		"nomatch:*":       nil,
+	}
+	for pattern, expected := range cases {
+		got, err := CacheKeys(pattern)
+		if err != nil {
+			t.Fatalf("CacheKeys(%q) failed: %v", pattern, err)
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("CacheKeys(%q) = %v, expected %v", pattern, got, expected)
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Fatalf("CacheKeys(%q) = %v, expected %v", pattern, got, expected)
+			}
+		}
+	}
+}
+
+func TestFallbackCacheReadsCurrentBackendOnly(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", MaxRetries: -1, DialerRetries: 1})
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	original := config.RedisClient
+	config.RedisClient = client
+	checkMutex.Lock()
+	lastRedisCheck = time.Time{}
+	checkMutex.Unlock()
+	t.Cleanup(func() {
+		config.RedisClient = original
+		checkMutex.Lock()
+		lastRedisCheck = time.Time{}
+		checkMutex.Unlock()
+	})
+
+	key := fmt.Sprintf("test:fallback:%d", time.Now().UnixNano())
+	defer client.Del(context.Background(), key)
+
+	memory := NewMemoryCache(MemoryCacheOptions{})
+	cache := NewFallbackCache(memory)
+
+	// Redis 不可用期间写入内存的值，Redis 恢复后不再可见
+	memory.Set(key, "stale", time.Minute)
+	var value string
+	if err := cache.Get(key, &value); err == nil {
+		t.Fatalf("expected miss, got %q from memory", value)
+	}
+
+	// 删除后 Get 与 GetString 一致地返回不存在
+	if err := cache.Set(key, "fresh", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cache.Get(key, &value); err != nil || value != "fresh" {
+		t.Fatalf("expected fresh, got %q (%v)", value, err)
+	}
+	cache.Del(key)
+	if err := cache.Get(key, &value); err == nil {
+		t.Fatalf("expected miss after Del, got %q", value)
+	}
+	if _, err := cache.GetString(key); err == nil {
+		t.Fatal("expected GetString miss after Del")
+	}
+}
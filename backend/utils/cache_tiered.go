@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CacheInvalidationChannel 两级缓存广播失效消息的频道
+const CacheInvalidationChannel = "cache:invalidate"
+
+// cacheInvalidation 失效消息：Origin 为发送实例，接收方忽略自己发出的消息
+type cacheInvalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	Flush  bool     `json:"flush,omitempty"`
+}
+
+// tieredCache 两级缓存：L1 为本进程内存，L2 为多实例共享的存储（通常是 Redis）。
+// 字符串值读取时先查 L1，未命中再读 L2 并回填 L1；所有写操作直接写 L2，
+// 随后删除本地 L1 并通过发布订阅通知其他实例删除。计数器、哈希等只读写 L2。
+// 失效消息可能丢失（如订阅断开），因此 L1 的过期时间应较短，作为最终一致的兜底
+type tieredCache struct {
+	l1     Cache
+	l2     Cache
+	l1TTL  time.Duration
+	origin string
+	broker broker // 失效消息与 L2 使用同一存储广播
+
+	// mu 保护 generation：每次失效都递增，回填 L1 前确认读取 L2 期间没有发生失效，
+	// 避免把失效前读到的旧值写回 L1
+	mu         sync.Mutex
+	generation uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTieredCache 创建两级缓存，返回时失效订阅已生效；l1TTL 为 L1 中值的最长保留时间
+func NewTieredCache(l1, l2 Cache, l1TTL time.Duration) (Cache, error) {
+	origin, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := brokerFor(l2)
+	messages, _, err := b.Subscribe(ctx, CacheInvalidationChannel)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("订阅缓存失效消息失败: %w", err)
+	}
+
+	c := &tieredCache{
+		l1:     l1,
+		l2:     l2,
+		l1TTL:  l1TTL,
+		origin: origin,
+		broker: b,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go c.listen(ctx, messages)
+	return c, nil
+}
+
+// listen 处理其他实例的失效消息；订阅意外断开时清空 L1 并重新订阅
+func (c *tieredCache) listen(ctx context.Context, messages <-chan []byte) {
+	defer close(c.done)
+	for {
+		for data := range messages {
+			var msg cacheInvalidation
+			if err := json.Unmarshal(data, &msg); err != nil || msg.Origin == c.origin {
+				continue
+			}
+			if msg.Flush {
+				c.invalidateLocal(nil, true)
+			} else {
+				c.invalidateLocal(msg.Keys, false)
+			}
+		}
+
+		// 断开期间可能错过失效消息，L1 中的值不再可信
+		c.invalidateLocal(nil, true)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			var err error
+			if messages, _, err = c.broker.Subscribe(ctx, CacheInvalidationChannel); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// invalidateLocal 删除本地 L1 中的键（flush 时清空 L1）
+func (c *tieredCache) invalidateLocal(keys []string, flush bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if flush {
+		c.l1.FlushDB()
+	} else if len(keys) > 0 {
+		c.l1.Del(keys...)
+	}
+}
+
+// invalidate 写 L2 之后调用：删除本地 L1 并通知其他实例
+func (c *tieredCache) invalidate(keys ...string) {
+	c.invalidateLocal(keys, false)
+	c.publish(cacheInvalidation{Origin: c.origin, Keys: keys})
+}
+
+func (c *tieredCache) publish(msg cacheInvalidation) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	if err := c.broker.Publish(CacheInvalidationChannel, data); err != nil {
+		fmt.Printf("发布缓存失效消息失败: %v\n", err)
+	}
+}
+
+func (c *tieredCache) Get(key string, dest interface{}) error {
+	value, err := c.GetString(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(value), dest)
+}
+
+func (c *tieredCache) GetString(key string) (string, error) {
+	if value, err := c.l1.GetString(key); err == nil {
+		return value, nil
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	value, err := c.l2.GetString(key)
+	if err != nil {
+		return "", err
+	}
+
+	// L1 不能比 L2 活得更久
+	ttl := c.l1TTL
+	if remaining, err := c.l2.TTL(key); err == nil && remaining > 0 && remaining < ttl {
+		ttl = remaining
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.l1.SetString(key, value, ttl)
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+func (c *tieredCache) Set(key string, value interface{}, expiration time.Duration) error {
+	if err := c.l2.Set(key, value, expiration); err != nil {
+		return err
+	}
+	c.invalidate(key)
+	return nil
+}
+
+func (c *tieredCache) SetString(key string, value string, expiration time.Duration) error {
+	if err := c.l2.SetString(key, value, expiration); err != nil {
+		return err
+	}
+	c.invalidate(key)
+	return nil
+}
+
+func (c *tieredCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	ok, err := c.l2.SetNX(key, value, expiration)
+	if ok {
+		c.invalidate(key)
+	}
+	return ok, err
+}
+
+func (c *tieredCache) Del(keys ...string) error {
+	if err := c.l2.Del(keys...); err != nil {
+		return err
+	}
+	c.invalidate(keys...)
+	return nil
+}
+
+func (c *tieredCache) Exists(key string) (bool, error) {
+	if exists, _ := c.l1.Exists(key); exists {
+		return true, nil
+	}
+	return c.l2.Exists(key)
+}
+
+func (c *tieredCache) Expire(key string, expiration time.Duration) error {
+	if err := c.l2.Expire(key, expiration); err != nil {
+		return err
+	}
+	c.invalidate(key)
+	return nil
+}
+
+func (c *tieredCache) TTL(key string) (time.Duration, error) {
+	return c.l2.TTL(key)
+}
+
+func (c *tieredCache) IncrBy(key string, value int64) (int64, error) {
+	n, err := c.l2.IncrBy(key, value)
+	if err == nil {
+		c.invalidate(key)
+	}
+	return n, err
+}
+
+func (c *tieredCache) Keys(pattern string) ([]string, error) {
+	return c.l2.Keys(pattern)
+}
+
+func (c *tieredCache) FlushDB() error {
+	if err := c.l2.FlushDB(); err != nil {
+		return err
+	}
+	c.invalidateLocal(nil, true)
+	c.publish(cacheInvalidation{Origin: c.origin, Flush: true})
+	return nil
+}
+
+func (c *tieredCache) HSet(key string, field string, value interface{}) error {
+	return c.l2.HSet(key, field, value)
+}
+
+func (c *tieredCache) HGet(key string, field string) (string, error) {
+	return c.l2.HGet(key, field)
+}
+
+func (c *tieredCache) HGetAll(key string) (map[string]string, error) {
+	return c.l2.HGetAll(key)
+}
+
+func (c *tieredCache) HDel(key string, fields ...string) error {
+	return c.l2.HDel(key, fields...)
+}
+
+// Close 停止接收失效消息并关闭 L1，L2 由调用方关闭
+func (c *tieredCache) Close() error {
+	c.cancel()
+	<-c.done
+	return c.l1.Close()
+}
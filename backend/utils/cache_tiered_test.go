@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// waitFor 等待条件成立，失效消息异步投递
+func waitFor(t *testing.T, cond func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	// 两个实例共享同一个 L2，各自有独立的 L1
+	l2 := NewMemoryCache(MemoryCacheOptions{})
+	defer l2.Close()
+	l1a := NewMemoryCache(MemoryCacheOptions{})
+	l1b := NewMemoryCache(MemoryCacheOptions{})
+	a, err := NewTieredCache(l1a, l2, time.Minute)
+	if err != nil {
+		t.Fatalf("NewTieredCache failed: %v", err)
+	}
+	defer a.Close()
+	b, err := NewTieredCache(l1b, l2, time.Minute)
+	if err != nil {
+		t.Fatalf("NewTieredCache failed: %v", err)
+	}
+	defer b.Close()
+
+	// 读取时回填 L1
+	a.Set("tiered:user", "alice", 0)
+	var name string
+	if err := b.Get("tiered:user", &name); err != nil || name != "alice" {
+		t.Fatalf("expected alice, got %q (%v)", name, err)
+	}
+	if _, err := l1b.GetString("tiered:user"); err != nil {
+		t.Fatal("expected value to be cached in L1")
+	}
+
+	// 另一个实例写入后，本实例的 L1 被失效，不会读到旧值
+	a.Set("tiered:user", "bob", 0)
+	waitFor(t, func() bool {
+		exists, _ := l1b.Exists("tiered:user")
+		return !exists
+	}, "expected L1 to be invalidated by another instance's write")
+	if err := b.Get("tiered:user", &name); err != nil || name != "bob" {
+		t.Fatalf("expected bob, got %q (%v)", name, err)
+	}
+
+	// 删除同样广播失效
+	a.Del("tiered:user")
+	waitFor(t, func() bool {
+		exists, _ := b.Exists("tiered:user")
+		return !exists
+	}, "expected delete to be visible on another instance")
+
+	// L1 不比 L2 活得更久
+	a.Set("tiered:short", 1, 20*time.Millisecond)
+	var n int
+	b.Get("tiered:short", &n)
+	if ttl, _ := l1b.TTL("tiered:short"); ttl <= 0 || ttl > 20*time.Millisecond {
+		t.Fatalf("expected L1 ttl to follow L2, got %v", ttl)
+	}
+
+	// 计数器直接读写 L2
+	a.IncrBy("tiered:counter", 2)
+	if n, _ := b.IncrBy("tiered:counter", 3); n != 5 {
+		t.Fatalf("expected counter 5, got %d", n)
+	}
+
+	// 清空广播到所有实例
+	b.Set("tiered:flush", "x", 0)
+	a.Get("tiered:flush", &name)
+	b.FlushDB()
+	waitFor(t, func() bool {
+		keys, _ := l1a.Keys("*")
+		return len(keys) == 0
+	}, "expected flush to clear other instances' L1")
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"time"
 
@@ -8,84 +10,112 @@ import (
 )
 
 const (
-	// CaptchaPrefix Redis 验证码键前缀
+	// CaptchaPrefix 验证码缓存键前缀
 	CaptchaPrefix = "captcha:"
 	// CaptchaExpiration 验证码过期时间
 	CaptchaExpiration = 10 * time.Minute
+
+	// captchaUsedSuffix 已校验过的验证码标记，标记存在期间该验证码不能再次校验或刷新
+	captchaUsedSuffix = ":used"
 )
 
-// GenerateCaptchaRedis 生成验证码并存储到 Redis
-func GenerateCaptchaRedis() (string, string, error) {
-	// 生成验证码 ID 和答案
-	id := captcha.New()
+// 验证码类型
+const (
+	CaptchaTypeDigit  = "digit"  // 6 位数字图片验证码
+	CaptchaTypeSlider = "slider" // 滑块拼图验证码
+)
 
-	// 获取验证码答案
-	digits := captcha.RandomDigits(captcha.DefaultLen)
-	answer := ""
-	for _, d := range digits {
-		answer += fmt.Sprintf("%d", d)
-	}
+// CaptchaChallenge 下发给客户端的验证码
+type CaptchaChallenge struct {
+	ID    string `json:"captcha_id"`
+	Type  string `json:"captcha_type"`
+	Image string `json:"captcha_image,omitempty"` // 数字验证码图片（data URL）
+
+	// 滑块验证码：背景图带缺口，拼图块需水平拖动到缺口位置，提交的答案见 SliderAnswer
+	BackgroundImage string `json:"background_image,omitempty"`
+	PieceImage      string `json:"piece_image,omitempty"`
+	PieceY          int    `json:"piece_y,omitempty"` // 拼图块在背景图中的纵坐标
+	Width           int    `json:"width,omitempty"`
+	Height          int    `json:"height,omitempty"`
+}
 
-	// 存储到 Redis
-	key := CaptchaPrefix + id
-	err := CacheSetString(key, answer, CaptchaExpiration)
-	if err != nil {
-		return "", "", err
-	}
+// CaptchaProvider 验证码提供方
+type CaptchaProvider interface {
+	Type() string
+	Generate() (*CaptchaChallenge, error)
+	Verify(id, answer string) bool
+}
 
-	return id, answer, nil
+var captchaProviders = map[string]CaptchaProvider{
+	CaptchaTypeDigit:  digitCaptcha{},
+	CaptchaTypeSlider: sliderCaptcha{},
 }
 
-// VerifyCaptchaRedis 验证 Redis 中的验证码
-func VerifyCaptchaRedis(id, answer string) bool {
-	key := CaptchaPrefix + id
+// GetCaptchaProvider 根据类型获取验证码提供方，类型为空时使用数字验证码
+func GetCaptchaProvider(captchaType string) (CaptchaProvider, bool) {
+	if captchaType == "" {
+		captchaType = CaptchaTypeDigit
+	}
+	provider, ok := captchaProviders[captchaType]
+	return provider, ok
+}
 
-	// 从 Redis 获取答案
-	correctAnswer, err := CacheGetString(key)
-	if err != nil {
+// VerifyCaptchaByType 按类型校验验证码，未知类型视为校验失败
+func VerifyCaptchaByType(captchaType, id, answer string) bool {
+	provider, ok := GetCaptchaProvider(captchaType)
+	if !ok {
 		return false
 	}
+	return provider.Verify(id, answer)
+}
 
-	// 验证成功后删除（一次性使用）
-	if correctAnswer == answer {
-		CacheDel(key)
-		return true
-	}
+// digitCaptcha 数字图片验证码（dchest/captcha）
+type digitCaptcha struct{}
+
+func (digitCaptcha) Type() string { return CaptchaTypeDigit }
+
+func (digitCaptcha) Generate() (*CaptchaChallenge, error) {
+	return digitChallenge(captcha.New())
+}
 
-	return false
+func (digitCaptcha) Verify(id, answer string) bool {
+	return VerifyCaptcha(id, answer)
 }
 
-// RefreshCaptchaRedis 刷新验证码
-func RefreshCaptchaRedis(id string) (string, error) {
-	key := CaptchaPrefix + id
+// cacheCaptchaStore 数字验证码存储：dchest/captcha 生成和渲染图片时读写的数字直接保存在缓存中，
+// 图片与答案始终一致；有无 Redis 时行为相同，多实例部署时共享
+type cacheCaptchaStore struct{}
 
-	// 检查验证码是否存在
-	exists, err := CacheExists(key)
-	if err != nil || !exists {
-		return "", fmt.Errorf("验证码不存在或已过期")
+func (cacheCaptchaStore) Set(id string, digits []byte) {
+	if err := CacheSet(CaptchaPrefix+id, digits, CaptchaExpiration); err != nil {
+		fmt.Printf("保存验证码失败: %v\n", err)
 	}
+}
 
-	// 生成新的答案
-	digits := captcha.RandomDigits(captcha.DefaultLen)
-	answer := ""
-	for _, d := range digits {
-		answer += fmt.Sprintf("%d", d)
+func (cacheCaptchaStore) Get(id string, clear bool) []byte {
+	var digits []byte
+	if err := CacheGet(CaptchaPrefix+id, &digits); err != nil {
+		return nil
 	}
-
-	// 更新 Redis
-	err = CacheSetString(key, answer, CaptchaExpiration)
-	if err != nil {
-		return "", err
+	if clear {
+		CacheDel(CaptchaPrefix + id)
 	}
-
-	return answer, nil
+	return digits
 }
 
-// 保留原有的内存存储方式作为备用
-var captchaStore = captcha.NewMemoryStore(captcha.CollectNum, captcha.Expiration)
-
 func init() {
-	captcha.SetCustomStore(captchaStore)
+	captcha.SetCustomStore(cacheCaptchaStore{})
+}
+
+// consumeCaptcha 取出并作废验证码答案。每个验证码只允许校验一次，无论对错随即作废；
+// 先用 SetNX 占位，并发提交同一验证码时只有一个请求能拿到答案
+func consumeCaptcha(key string, dest interface{}) bool {
+	claimed, err := CacheSetNX(key+captchaUsedSuffix, true, CaptchaExpiration)
+	if err != nil || !claimed {
+		return false
+	}
+	defer CacheDel(key)
+	return CacheGet(key, dest) == nil
 }
 
 // GenerateCaptcha 生成验证码（兼容旧版本）
@@ -98,17 +128,49 @@ func GenerateCaptchaWithLength(length int) string {
 	return captcha.NewLen(length)
 }
 
-// VerifyCaptcha 验证验证码（兼容旧版本）
+// VerifyCaptcha 验证数字验证码，验证码只能校验一次
 func VerifyCaptcha(id, answer string) bool {
-	// 优先使用 Redis
-	if VerifyCaptchaRedis(id, answer) {
-		return true
+	var digits []byte
+	if !consumeCaptcha(CaptchaPrefix+id, &digits) {
+		return false
+	}
+	if len(digits) == 0 || len(answer) != len(digits) {
+		return false
+	}
+	for i := range digits {
+		if answer[i] != '0'+digits[i] {
+			return false
+		}
 	}
-	// 降级到内存存储
-	return captcha.VerifyString(id, answer)
+	return true
 }
 
-// ReloadCaptcha 重新加载验证码
+// ReloadCaptcha 重新加载验证码，已校验过（包括校验失败）的验证码不能刷新
 func ReloadCaptcha(id string) bool {
+	if exists, _ := CacheExists(CaptchaPrefix + id + captchaUsedSuffix); exists {
+		return false
+	}
 	return captcha.Reload(id)
 }
+
+// RefreshDigitCaptcha 为未过期的数字验证码换一组数字，验证码不存在时返回 false
+func RefreshDigitCaptcha(id string) (*CaptchaChallenge, bool) {
+	if !ReloadCaptcha(id) {
+		return nil, false
+	}
+	challenge, err := digitChallenge(id)
+	return challenge, err == nil
+}
+
+// digitChallenge 渲染数字验证码图片
+func digitChallenge(id string) (*CaptchaChallenge, error) {
+	var buf bytes.Buffer
+	if err := captcha.WriteImage(&buf, id, captcha.StdWidth, captcha.StdHeight); err != nil {
+		return nil, err
+	}
+	return &CaptchaChallenge{
+		ID:    id,
+		Type:  CaptchaTypeDigit,
+		Image: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
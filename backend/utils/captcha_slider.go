@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand"
+)
+
+const (
+	// SliderCaptchaPrefix 滑块验证码答案键前缀
+	SliderCaptchaPrefix = CaptchaPrefix + "slider:"
+
+	sliderWidth     = 300
+	sliderHeight    = 150
+	sliderPieceSize = 50 // 拼图块外框边长（含凸起）
+	sliderPieceBody = 36 // 拼图块主体边长
+	sliderKnob      = 7  // 凸起半径
+
+	// sliderTolerance 提交位置与缺口位置允许的误差（像素）
+	sliderTolerance = 5
+	// 拖动轨迹的合理范围
+	sliderMinTrackPoints = 5
+	sliderMinDurationMs  = 150
+	sliderMaxDurationMs  = 30000
+	sliderMinSpeedCV     = 0.1 // 拖动速度的最小变异系数，低于该值视为匀速
+)
+
+// SliderPoint 拖动轨迹中的一个采样点，T 为距开始拖动的毫秒数
+type SliderPoint struct {
+	X int   `json:"x"`
+	Y int   `json:"y"`
+	T int64 `json:"t"`
+}
+
+// SliderAnswer 滑块验证码答案：拼图块最终的横坐标和拖动轨迹，以 JSON 字符串提交
+type SliderAnswer struct {
+	X     int           `json:"x"`
+	Track []SliderPoint `json:"track"`
+}
+
+// sliderPosition 缺口位置，只保存在服务端
+type sliderPosition struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// sliderCaptcha 滑块拼图验证码：服务端生成带缺口的背景图和拼图块，校验位置误差和拖动轨迹
+type sliderCaptcha struct{}
+
+func (sliderCaptcha) Type() string { return CaptchaTypeSlider }
+
+func (sliderCaptcha) Generate() (*CaptchaChallenge, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// 缺口不放在最左侧，避免不拖动就通过
+	pos := sliderPosition{
+		X: sliderPieceSize + 10 + rand.Intn(sliderWidth-2*sliderPieceSize-20),
+		Y: 5 + rand.Intn(sliderHeight-sliderPieceSize-10),
+	}
+	background, piece := drawSliderImages(pos)
+
+	backgroundPNG, err := encodePNG(background)
+	if err != nil {
+		return nil, err
+	}
+	piecePNG, err := encodePNG(piece)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CacheSet(SliderCaptchaPrefix+id, pos, CaptchaExpiration); err != nil {
+		return nil, err
+	}
+
+	return &CaptchaChallenge{
+		ID:              id,
+		Type:            CaptchaTypeSlider,
+		BackgroundImage: backgroundPNG,
+		PieceImage:      piecePNG,
+		PieceY:          pos.Y,
+		Width:           sliderWidth,
+		Height:          sliderHeight,
+	}, nil
+}
+
+// Verify 校验滑块答案，无论成功与否验证码都作废，防止逐个位置尝试
+func (sliderCaptcha) Verify(id, answer string) bool {
+	var pos sliderPosition
+	if !consumeCaptcha(SliderCaptchaPrefix+id, &pos) {
+		return false
+	}
+
+	var submitted SliderAnswer
+	if err := json.Unmarshal([]byte(answer), &submitted); err != nil {
+		return false
+	}
+	if abs(submitted.X-pos.X) > sliderTolerance {
+		return false
+	}
+	return sliderTrackValid(submitted.Track, submitted.X)
+}
+
+// sliderTrackValid 轨迹合理性检查：采样点足够、时间递增、总时长合理、终点与提交位置一致、
+// 拖动速度有变化（脚本生成的轨迹往往是匀速直线）
+func sliderTrackValid(track []SliderPoint, x int) bool {
+	if len(track) < sliderMinTrackPoints {
+		return false
+	}
+
+	duration := track[len(track)-1].T - track[0].T
+	if duration < sliderMinDurationMs || duration > sliderMaxDurationMs {
+		return false
+	}
+	if abs(track[len(track)-1].X-x) > sliderTolerance {
+		return false
+	}
+
+	var speeds []float64
+	minY, maxY := track[0].Y, track[0].Y
+	for i := 1; i < len(track); i++ {
+		dt := track[i].T - track[i-1].T
+		if dt <= 0 {
+			return false
+		}
+		speeds = append(speeds, float64(track[i].X-track[i-1].X)/float64(dt))
+		minY = min(minY, track[i].Y)
+		maxY = max(maxY, track[i].Y)
+	}
+	// 纵向偏移过大说明不是水平拖动
+	if maxY-minY > sliderHeight/2 {
+		return false
+	}
+
+	var mean float64
+	for _, v := range speeds {
+		mean += v
+	}
+	mean /= float64(len(speeds))
+	var variance float64
+	for _, v := range speeds {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(speeds))
+	return mean > 0 && math.Sqrt(variance)/mean > sliderMinSpeedCV
+}
+
+// drawSliderImages 绘制带缺口的背景图和对应的拼图块
+func drawSliderImages(pos sliderPosition) (*image.RGBA, *image.RGBA) {
+	background := image.NewRGBA(image.Rect(0, 0, sliderWidth, sliderHeight))
+
+	// 随机渐变底色加若干色块，使缺口无法通过纯色比对定位
+	c1 := randomColor()
+	c2 := randomColor()
+	for y := 0; y < sliderHeight; y++ {
+		for x := 0; x < sliderWidth; x++ {
+			ratio := float64(x+y) / float64(sliderWidth+sliderHeight)
+			background.Set(x, y, blend(c1, c2, ratio))
+		}
+	}
+	for i := 0; i < 12; i++ {
+		cx, cy, r := rand.Intn(sliderWidth), rand.Intn(sliderHeight), 8+rand.Intn(30)
+		fill := randomColor()
+		for y := max(0, cy-r); y < min(sliderHeight, cy+r); y++ {
+			for x := max(0, cx-r); x < min(sliderWidth, cx+r); x++ {
+				if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r {
+					background.Set(x, y, blend(background.RGBAAt(x, y), fill, 0.6))
+				}
+			}
+		}
+	}
+	for i := 0; i < sliderWidth*sliderHeight/20; i++ {
+		x, y := rand.Intn(sliderWidth), rand.Intn(sliderHeight)
+		background.Set(x, y, blend(background.RGBAAt(x, y), randomColor(), 0.5))
+	}
+
+	// 拼图块复制缺口处的像素，背景对应位置压暗作为缺口
+	piece := image.NewRGBA(image.Rect(0, 0, sliderPieceSize, sliderPieceSize))
+	for y := 0; y < sliderPieceSize; y++ {
+		for x := 0; x < sliderPieceSize; x++ {
+			if !inSliderPiece(x, y) {
+				continue
+			}
+			bx, by := pos.X+x, pos.Y+y
+			src := background.RGBAAt(bx, by)
+			if onSliderPieceEdge(x, y) {
+				piece.Set(x, y, color.RGBA{255, 255, 255, 230})
+				background.Set(bx, by, color.RGBA{255, 255, 255, 160})
+				continue
+			}
+			piece.Set(x, y, src)
+			background.Set(bx, by, blend(src, color.RGBA{0, 0, 0, 255}, 0.55))
+		}
+	}
+	return background, piece
+}
+
+// inSliderPiece 判断拼图块外框内的点是否属于拼图块：主体方块加顶部和右侧两个半圆凸起
+func inSliderPiece(x, y int) bool {
+	offset := sliderPieceSize - sliderPieceBody
+	if x < sliderPieceBody && y >= offset {
+		return true
+	}
+	// 顶部凸起
+	tx, ty := sliderPieceBody/2, offset
+	if (x-tx)*(x-tx)+(y-ty)*(y-ty) <= sliderKnob*sliderKnob {
+		return true
+	}
+	// 右侧凸起
+	rx, ry := sliderPieceBody, offset+sliderPieceBody/2
+	return (x-rx)*(x-rx)+(y-ry)*(y-ry) <= sliderKnob*sliderKnob
+}
+
+// onSliderPieceEdge 判断拼图块上的点是否在轮廓上
+func onSliderPieceEdge(x, y int) bool {
+	return !inSliderPiece(x-1, y) || !inSliderPiece(x+1, y) || !inSliderPiece(x, y-1) || !inSliderPiece(x, y+1)
+}
+
+func randomColor() color.RGBA {
+	return color.RGBA{uint8(40 + rand.Intn(180)), uint8(40 + rand.Intn(180)), uint8(40 + rand.Intn(180)), 255}
+}
+
+func blend(a, b color.RGBA, ratio float64) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8(float64(x)*(1-ratio) + float64(y)*ratio) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// encodePNG 编码为 PNG 的 data URL
+func encodePNG(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
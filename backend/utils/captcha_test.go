@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// humanTrack 模拟人工拖动：先加速后减速，纵向有轻微抖动
+func humanTrack(x int) []SliderPoint {
+	track := []SliderPoint{{X: 0, Y: 0, T: 0}}
+	steps := []float64{0.05, 0.2, 0.45, 0.7, 0.85, 0.95, 1}
+	for i, ratio := range steps {
+		track = append(track, SliderPoint{X: int(float64(x) * ratio), Y: i % 2, T: int64(60 * (i + 1))})
+	}
+	return track
+}
+
+func sliderAnswer(t *testing.T, x int, track []SliderPoint) string {
+	data, err := json.Marshal(SliderAnswer{X: x, Track: track})
+	if err != nil {
+		t.Fatalf("marshal answer failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestSliderCaptcha(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	provider, ok := GetCaptchaProvider(CaptchaTypeSlider)
+	if !ok {
+		t.Fatal("slider provider not registered")
+	}
+
+	newChallenge := func() (*CaptchaChallenge, sliderPosition) {
+		challenge, err := provider.Generate()
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		var pos sliderPosition
+		if err := CacheGet(SliderCaptchaPrefix+challenge.ID, &pos); err != nil {
+			t.Fatalf("answer not stored: %v", err)
+		}
+		return challenge, pos
+	}
+
+	challenge, pos := newChallenge()
+	if challenge.BackgroundImage == "" || challenge.PieceImage == "" || challenge.PieceY != pos.Y {
+		t.Fatalf("unexpected challenge: %+v", challenge)
+	}
+
+	// 误差范围内、轨迹合理时通过，且只能使用一次
+	x := pos.X + sliderTolerance - 1
+	if !provider.Verify(challenge.ID, sliderAnswer(t, x, humanTrack(x))) {
+		t.Fatal("expected slider answer within tolerance to pass")
+	}
+	if provider.Verify(challenge.ID, sliderAnswer(t, x, humanTrack(x))) {
+		t.Fatal("expected slider captcha to be single-use")
+	}
+
+	// 位置偏差过大时失败，并且验证码随即作废
+	challenge, pos = newChallenge()
+	x = pos.X + sliderTolerance + 3
+	if provider.Verify(challenge.ID, sliderAnswer(t, x, humanTrack(x))) {
+		t.Fatal("expected slider answer outside tolerance to fail")
+	}
+	if provider.Verify(challenge.ID, sliderAnswer(t, pos.X, humanTrack(pos.X))) {
+		t.Fatal("expected slider captcha to be invalidated after a failure")
+	}
+
+	// 匀速、过快或点数不足的轨迹视为脚本
+	challenge, pos = newChallenge()
+	var uniform []SliderPoint
+	for i := 0; i <= 10; i++ {
+		uniform = append(uniform, SliderPoint{X: pos.X * i / 10, T: int64(i * 50)})
+	}
+	uniform[len(uniform)-1].X = pos.X
+	if provider.Verify(challenge.ID, sliderAnswer(t, pos.X, uniform)) {
+		t.Fatal("expected uniform track to fail")
+	}
+	if sliderTrackValid(humanTrack(pos.X)[:3], pos.X) {
+		t.Fatal("expected short track to fail")
+	}
+	fast := humanTrack(pos.X)
+	for i := range fast {
+		fast[i].T /= 10
+	}
+	if sliderTrackValid(fast, pos.X) {
+		t.Fatal("expected too fast track to fail")
+	}
+
+	if VerifyCaptchaByType("unknown", challenge.ID, "") {
+		t.Fatal("expected unknown captcha type to fail")
+	}
+}
+
+// digitAnswer 读取数字验证码的答案（与渲染图片使用同一份数据）
+func digitAnswer(t *testing.T, id string) string {
+	digits := cacheCaptchaStore{}.Get(id, false)
+	if len(digits) == 0 {
+		t.Fatalf("captcha %s not stored", id)
+	}
+	answer := make([]byte, len(digits))
+	for i, d := range digits {
+		answer[i] = '0' + d
+	}
+	return string(answer)
+}
+
+func TestDigitCaptchaProvider(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	provider, ok := GetCaptchaProvider("")
+	if !ok || provider.Type() != CaptchaTypeDigit {
+		t.Fatal("expected digit captcha as default provider")
+	}
+	challenge, err := provider.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if challenge.Image == "" {
+		t.Fatal("expected digit captcha image")
+	}
+
+	// 刷新后答案随之变化，旧图片对应的答案不再有效
+	refreshed, ok := RefreshDigitCaptcha(challenge.ID)
+	if !ok || refreshed.ID != challenge.ID || refreshed.Image == "" {
+		t.Fatal("expected digit captcha to be refreshed")
+	}
+	answer := digitAnswer(t, challenge.ID)
+	if !provider.Verify(challenge.ID, answer) {
+		t.Fatal("expected stored answer to pass")
+	}
+	if provider.Verify(challenge.ID, answer) {
+		t.Fatal("expected captcha to be single-use")
+	}
+	if _, ok := RefreshDigitCaptcha("missing"); ok {
+		t.Fatal("expected refreshing a missing captcha to fail")
+	}
+}
+
+func TestDigitCaptchaInvalidatedOnFailure(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	// 猜错一次后验证码作废，正确答案也不再通过，且不能再刷新
+	id := GenerateCaptcha()
+	answer := digitAnswer(t, id)
+	wrong := []byte(answer)
+	wrong[0] = '0' + (wrong[0]-'0'+1)%10
+	if VerifyCaptcha(id, string(wrong)) {
+		t.Fatal("expected wrong answer to fail")
+	}
+	if VerifyCaptcha(id, answer) {
+		t.Fatal("expected captcha to be invalidated after a wrong guess")
+	}
+	if ReloadCaptcha(id) {
+		t.Fatal("expected invalidated captcha not to be reloadable")
+	}
+
+	// 并发提交同一验证码只有一个请求能通过
+	id = GenerateCaptcha()
+	answer = digitAnswer(t, id)
+	var wg sync.WaitGroup
+	var passed atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if VerifyCaptcha(id, answer) {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if passed.Load() != 1 {
+		t.Fatalf("expected exactly one successful verification, got %d", passed.Load())
+	}
+}
@@ -1,32 +1,72 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"fmt"
+	"gin-backend/models"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key-change-this-in-production") // 生产环境应该从环境变量读取
+const (
+	// AccessTokenExpiration 访问令牌有效期
+	AccessTokenExpiration = 15 * time.Minute
+	// RefreshTokenExpiration 刷新令牌有效期
+	RefreshTokenExpiration = 7 * 24 * time.Hour
+
+	refreshTokenPrefix  = "refresh:token:"
+	refreshUsedPrefix   = "refresh:used:" // 已轮换的刷新令牌标记，用 SETNX 原子占用，多实例间只有一个能成功
+	refreshFamilyPrefix = "refresh:family:"
+)
+
+var (
+	// ErrRefreshTokenInvalid 刷新令牌不存在或已过期
+	ErrRefreshTokenInvalid = errors.New("刷新令牌无效或已过期")
+	// ErrRefreshTokenReused 刷新令牌被重复使用，整个令牌家族已被吊销
+	ErrRefreshTokenReused = errors.New("刷新令牌已被使用，请重新登录")
+)
 
 // Claims JWT 声明
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"` // 所属登录会话
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 JWT token
-func GenerateToken(userID uint, username, email string) (string, error) {
-	// 设置过期时间为 24 小时
-	expirationTime := time.Now().Add(24 * time.Hour)
+// TokenPair 访问令牌 + 刷新令牌
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌有效秒数
+	FamilyID     string `json:"-"`
+}
+
+// refreshTokenRecord 刷新令牌记录
+type refreshTokenRecord struct {
+	UserID   uint   `json:"user_id"`
+	FamilyID string `json:"family_id"`
+}
+
+// refreshFamily 刷新令牌家族（同一次登录派生出的所有刷新令牌）
+type refreshFamily struct {
+	UserID      uint   `json:"user_id"`
+	Revoked     bool   `json:"revoked"`
+	AccessToken string `json:"access_token"` // 家族最新签发的访问令牌
+}
+
+// GenerateToken 生成 JWT 访问令牌
+func GenerateToken(userID uint, username, email, sessionID string) (string, error) {
+	expirationTime := time.Now().Add(AccessTokenExpiration)
 
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
+		UserID:    userID,
+		Username:  username,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -34,20 +74,13 @@ func GenerateToken(userID uint, username, email string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
+	return signToken(claims)
 }
 
 // ParseToken 解析 JWT token
 func ParseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -60,19 +93,124 @@ func ParseToken(tokenString string) (*Claims, error) {
 	return nil, errors.New("无效的 token")
 }
 
-// RefreshToken 刷新 token
-func RefreshToken(tokenString string) (string, error) {
-	claims, err := ParseToken(tokenString)
-	if err != nil {
+// IsTokenExpired 判断解析错误是否由访问令牌过期引起（客户端应使用刷新令牌换取新令牌）
+func IsTokenExpired(err error) bool {
+	return errors.Is(err, jwt.ErrTokenExpired)
+}
+
+// randomToken 生成不透明的随机令牌
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
 		return "", err
 	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GenerateTokenPair 登录时生成令牌对，并开启一个新的刷新令牌家族（即新的登录会话）
+func GenerateTokenPair(userID uint, username, email string) (*TokenPair, error) {
+	familyID, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return IssueTokenPair(userID, username, email, familyID)
+}
 
-	// 如果 token 在 30 分钟内过期，则刷新
-	if time.Until(claims.ExpiresAt.Time) < 30*time.Minute {
-		return GenerateToken(claims.UserID, claims.Username, claims.Email)
+// IssueTokenPair 在指定家族下签发新的令牌对
+func IssueTokenPair(userID uint, username, email, familyID string) (*TokenPair, error) {
+	accessToken, err := GenerateToken(userID, username, email, familyID)
+	if err != nil {
+		return nil, err
 	}
 
-	return tokenString, nil
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	record := refreshTokenRecord{UserID: userID, FamilyID: familyID}
+	if err := CacheSet(refreshTokenPrefix+refreshToken, record, RefreshTokenExpiration); err != nil {
+		return nil, err
+	}
+
+	family := refreshFamily{UserID: userID, AccessToken: accessToken}
+	if err := CacheSet(refreshFamilyPrefix+familyID, family, RefreshTokenExpiration); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(AccessTokenExpiration / time.Second),
+		FamilyID:     familyID,
+	}, nil
+}
+
+// ConsumeRefreshToken 消费刷新令牌（轮换）
+// 成功时返回所属用户和家族；若令牌已被使用过，则判定为重放并吊销整个家族。
+// 用 SETNX 写入已使用标记完成消费，共享 Redis 的多个实例并发提交同一令牌时只有一个成功
+func ConsumeRefreshToken(refreshToken string) (uint, string, error) {
+	key := refreshTokenPrefix + refreshToken
+	var record refreshTokenRecord
+	if err := CacheGet(key, &record); err != nil {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+
+	var family refreshFamily
+	if err := CacheGet(refreshFamilyPrefix+record.FamilyID, &family); err != nil || family.Revoked {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+
+	// 标记保留到令牌原过期时间以便检测重放
+	ttl, err := CacheTTL(key)
+	if err != nil || ttl <= 0 {
+		ttl = RefreshTokenExpiration
+	}
+	claimed, err := CacheSetNX(refreshUsedPrefix+refreshToken, true, ttl)
+	if err != nil {
+		return 0, "", err
+	}
+	if !claimed {
+		RevokeRefreshFamily(record.FamilyID)
+		EmitSecurityEvent(&models.SecurityEvent{
+			UserID:    record.UserID,
+			Type:      models.SecurityEventRefreshReused,
+			SessionID: record.FamilyID,
+			Detail:    "已使用的刷新令牌被再次提交，会话已吊销",
+		})
+		return 0, "", ErrRefreshTokenReused
+	}
+
+	return record.UserID, record.FamilyID, nil
+}
+
+// RevokeRefreshFamily 吊销刷新令牌家族，将其最新的访问令牌加入黑名单并移除对应会话
+func RevokeRefreshFamily(familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+
+	key := refreshFamilyPrefix + familyID
+	var family refreshFamily
+	if err := CacheGet(key, &family); err != nil {
+		return nil
+	}
+	if family.AccessToken != "" {
+		ClearToken(family.AccessToken)
+	}
+	removeSession(family.UserID, familyID)
+
+	family.Revoked = true
+	return CacheSet(key, family, RefreshTokenExpiration)
+}
+
+// RevokeRefreshToken 吊销刷新令牌所在的家族（退出登录时使用）
+func RevokeRefreshToken(refreshToken string) error {
+	var record refreshTokenRecord
+	if err := CacheGet(refreshTokenPrefix+refreshToken, &record); err != nil {
+		return nil
+	}
+	return RevokeRefreshFamily(record.FamilyID)
 }
 
 // ClearToken 清除 token（将 token 加入黑名单）
@@ -92,7 +230,17 @@ func ClearToken(tokenString string) error {
 
 	// 将 token 加入黑名单，过期时间与 token 剩余有效时间一致
 	blacklistKey := "blacklist:token:" + tokenString
-	return CacheSet(blacklistKey, true, remainingTime)
+	if err := CacheSet(blacklistKey, true, remainingTime); err != nil {
+		return err
+	}
+
+	EmitSecurityEvent(&models.SecurityEvent{
+		UserID:    claims.UserID,
+		Type:      models.SecurityEventTokenBlacklist,
+		SessionID: claims.SessionID,
+		Detail:    "访问令牌已加入黑名单，剩余有效期 " + remainingTime.Round(time.Second).String(),
+	})
+	return nil
 }
 
 // IsTokenBlacklisted 检查 token 是否在黑名单中
@@ -102,41 +250,3 @@ func IsTokenBlacklisted(tokenString string) bool {
 	err := CacheGet(blacklistKey, &isBlacklisted)
 	return err == nil && isBlacklisted
 }
-
-// SetUserToken 设置用户的当前 token（单点登录）
-// 如果用户已有 token，会将旧 token 加入黑名单
-func SetUserToken(userID uint, newToken string) error {
-	userTokenKey := fmt.Sprintf("user:token:%d", userID)
-
-	// 获取用户的旧 token
-	var oldToken string
-	err := CacheGet(userTokenKey, &oldToken)
-	// 打印日志
-	fmt.Println("oldToken", oldToken)
-	fmt.Println("newToken", newToken)
-	fmt.Println("err", err)
-	if err == nil && oldToken != "" && oldToken != newToken {
-		// 将旧 token 加入黑名单
-		ClearToken(oldToken)
-	}
-
-	// 存储新 token，过期时间 24 小时（与 token 有效期一致）
-	return CacheSet(userTokenKey, newToken, 24*time.Hour)
-}
-
-// GetUserToken 获取用户的当前 token
-func GetUserToken(userID uint) (string, error) {
-	userTokenKey := fmt.Sprintf("user:token:%d", userID)
-	var token string
-	err := CacheGet(userTokenKey, &token)
-	return token, err
-}
-
-// IsUserCurrentToken 检查 token 是否是用户的当前有效 token
-func IsUserCurrentToken(userID uint, tokenString string) bool {
-	currentToken, err := GetUserToken(userID)
-	if err != nil {
-		return false
-	}
-	return currentToken == tokenString
-}
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gin-backend/config"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtKey 一把 JWT 密钥
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.PrivateKey // 仅签名密钥持有
+	publicKey crypto.PublicKey
+}
+
+// jwtKeySet 签名密钥 + 所有可用于验证的公钥（轮换期间旧公钥继续有效）
+type jwtKeySet struct {
+	signing *jwtKey
+	verify  map[string]*jwtKey
+}
+
+var (
+	jwtKeys   *jwtKeySet
+	jwtKeysMu sync.RWMutex
+)
+
+// JWK JSON Web Key（RFC 7517）
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA 模数
+	E   string `json:"e,omitempty"`   // RSA 指数
+	Crv string `json:"crv,omitempty"` // OKP 曲线
+	X   string `json:"x,omitempty"`   // OKP 公钥
+}
+
+// JWKSet JWK 集合
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// InitJWTKeys 根据配置加载 JWT 签名密钥和验证公钥
+// 未配置私钥时仅在 cfg.AllowEphemeral（debug 模式）下生成临时密钥，重启后已签发的令牌全部失效，
+// 多实例之间也无法互相验证；其他模式下返回错误，避免生产环境静默使用临时密钥
+func InitJWTKeys(cfg config.JWTConfig) error {
+	var signing *jwtKey
+	var err error
+
+	switch {
+	case cfg.PrivateKey != "":
+		signing, err = parsePrivateKey([]byte(cfg.PrivateKey), cfg.KeyID)
+	case cfg.PrivateKeyFile != "":
+		var data []byte
+		if data, err = os.ReadFile(cfg.PrivateKeyFile); err == nil {
+			signing, err = parsePrivateKey(data, cfg.KeyID)
+		}
+	case !cfg.AllowEphemeral:
+		return errors.New("未配置 JWT 私钥，请设置 JWT_PRIVATE_KEY 或 JWT_PRIVATE_KEY_FILE（仅 debug 模式允许使用临时密钥）")
+	default:
+		log.Printf("未配置 JWT 私钥，将生成临时 %s 密钥（仅限开发环境）", cfg.Algorithm)
+		signing, err = generateJWTKey(cfg.Algorithm)
+	}
+	if err != nil {
+		return fmt.Errorf("加载 JWT 签名密钥失败: %v", err)
+	}
+
+	set := &jwtKeySet{
+		signing: signing,
+		verify:  map[string]*jwtKey{signing.id: signing},
+	}
+
+	// 轮换期间仍需验证的旧公钥，格式 kid=path,kid2=path2
+	for _, entry := range strings.Split(cfg.VerifyKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return fmt.Errorf("JWT 验证公钥配置格式错误: %s", entry)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取 JWT 验证公钥 %s 失败: %v", kid, err)
+		}
+		key, err := parsePublicKey(data, kid)
+		if err != nil {
+			return fmt.Errorf("解析 JWT 验证公钥 %s 失败: %v", kid, err)
+		}
+		set.verify[kid] = key
+	}
+
+	jwtKeysMu.Lock()
+	jwtKeys = set
+	jwtKeysMu.Unlock()
+	return nil
+}
+
+// currentJWTKeys 获取当前密钥集合，未初始化时使用临时密钥
+func currentJWTKeys() *jwtKeySet {
+	jwtKeysMu.RLock()
+	set := jwtKeys
+	jwtKeysMu.RUnlock()
+	if set != nil {
+		return set
+	}
+
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	if jwtKeys == nil {
+		key, err := generateJWTKey("RS256")
+		if err != nil {
+			panic(fmt.Sprintf("生成临时 JWT 密钥失败: %v", err))
+		}
+		jwtKeys = &jwtKeySet{signing: key, verify: map[string]*jwtKey{key.id: key}}
+	}
+	return jwtKeys
+}
+
+// signToken 使用当前签名密钥签发令牌，并在头部写入 kid
+func signToken(claims jwt.Claims) (string, error) {
+	key := currentJWTKeys().signing
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// verificationKey 根据令牌头部的 kid 选择验证公钥
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := currentJWTKeys().verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("未知的密钥ID: %s", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("签名算法不匹配: %s", token.Method.Alg())
+	}
+	return key.publicKey, nil
+}
+
+// JWKS 返回所有验证公钥，供其他服务验证令牌
+func JWKS() JWKSet {
+	set := currentJWTKeys()
+	result := JWKSet{Keys: make([]JWK, 0, len(set.verify))}
+	for _, key := range set.verify {
+		jwk := JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		result.Keys = append(result.Keys, jwk)
+	}
+	return result
+}
+
+// generateJWTKey 生成临时密钥
+func generateJWTKey(alg string) (*jwtKey, error) {
+	switch strings.ToUpper(alg) {
+	case "EDDSA", "ED25519":
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return newJWTKey(priv, pub, "")
+	case "", "RS256":
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return newJWTKey(priv, &priv.PublicKey, "")
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", alg)
+	}
+}
+
+// parsePrivateKey 解析 PEM 格式私钥（PKCS#8 或 PKCS#1）
+func parsePrivateKey(data []byte, kid string) (*jwtKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("无效的 PEM 数据")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch priv := key.(type) {
+		case *rsa.PrivateKey:
+			return newJWTKey(priv, &priv.PublicKey, kid)
+		case ed25519.PrivateKey:
+			return newJWTKey(priv, priv.Public(), kid)
+		default:
+			return nil, errors.New("仅支持 RSA 和 Ed25519 私钥")
+		}
+	}
+
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return newJWTKey(priv, &priv.PublicKey, kid)
+}
+
+// parsePublicKey 解析 PEM 格式公钥（PKIX）
+func parsePublicKey(data []byte, kid string) (*jwtKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("无效的 PEM 数据")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return newJWTKey(nil, pub, kid)
+}
+
+// newJWTKey 根据密钥类型确定签名算法，kid 为空时使用公钥指纹
+func newJWTKey(priv crypto.PrivateKey, pub crypto.PublicKey, kid string) (*jwtKey, error) {
+	key := &jwtKey{id: kid, private: priv, publicKey: pub}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("仅支持 RSA 和 Ed25519 公钥")
+	}
+
+	if key.id == "" {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		key.id = hex.EncodeToString(sum[:8])
+	}
+	return key, nil
+}
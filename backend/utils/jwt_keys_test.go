@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gin-backend/config"
+)
+
+func TestJWTKeyRotation(t *testing.T) {
+	t.Cleanup(func() {
+		jwtKeysMu.Lock()
+		jwtKeys = nil
+		jwtKeysMu.Unlock()
+	})
+
+	// 旧密钥：RSA，签发一个令牌
+	oldPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDER, _ := x509.MarshalPKCS8PrivateKey(oldPriv)
+	if err := InitJWTKeys(config.JWTConfig{
+		KeyID:      "old",
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: oldDER})),
+	}); err != nil {
+		t.Fatalf("InitJWTKeys(old) failed: %v", err)
+	}
+	oldToken, err := GenerateToken(1, "admin", "admin@example.com", "sid")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	// 新密钥：Ed25519，旧公钥作为验证密钥保留
+	dir := t.TempDir()
+	oldPubDER, _ := x509.MarshalPKIXPublicKey(&oldPriv.PublicKey)
+	oldPubPath := filepath.Join(dir, "old.pub.pem")
+	os.WriteFile(oldPubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: oldPubDER}), 0600)
+
+	_, newPriv, _ := ed25519.GenerateKey(rand.Reader)
+	newDER, _ := x509.MarshalPKCS8PrivateKey(newPriv)
+	newPath := filepath.Join(dir, "new.pem")
+	os.WriteFile(newPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: newDER}), 0600)
+
+	if err := InitJWTKeys(config.JWTConfig{
+		KeyID:          "new",
+		PrivateKeyFile: newPath,
+		VerifyKeys:     "old=" + oldPubPath,
+	}); err != nil {
+		t.Fatalf("InitJWTKeys(new) failed: %v", err)
+	}
+
+	newToken, err := GenerateToken(1, "admin", "admin@example.com", "sid")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := ParseToken(token); err != nil {
+			t.Errorf("token signed with %s key should verify: %v", name, err)
+		}
+	}
+
+	jwks := JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("expected 2 keys in JWKS, got %d", len(jwks.Keys))
+	}
+
+	// 旧公钥下线后，旧令牌不再有效
+	if err := InitJWTKeys(config.JWTConfig{KeyID: "new", PrivateKeyFile: newPath}); err != nil {
+		t.Fatalf("InitJWTKeys failed: %v", err)
+	}
+	if _, err := ParseToken(oldToken); err == nil {
+		t.Error("token signed with retired key should be rejected")
+	}
+}
+
+func TestJWTKeysRequiredOutsideDebug(t *testing.T) {
+	t.Cleanup(func() {
+		jwtKeysMu.Lock()
+		jwtKeys = nil
+		jwtKeysMu.Unlock()
+	})
+
+	// 非 debug 模式未配置私钥时启动失败
+	if err := InitJWTKeys(config.JWTConfig{Algorithm: "EdDSA"}); err == nil {
+		t.Fatal("expected an error when no private key is configured")
+	}
+	if err := InitJWTKeys(config.JWTConfig{Algorithm: "EdDSA", AllowEphemeral: true}); err != nil {
+		t.Fatalf("expected an ephemeral key in debug mode, got %v", err)
+	}
+}
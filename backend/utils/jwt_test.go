@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	pair, err := GenerateTokenPair(1, "admin", "admin@example.com")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+
+	userID, familyID, err := ConsumeRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("ConsumeRefreshToken failed: %v", err)
+	}
+	if userID != 1 || familyID != pair.FamilyID {
+		t.Fatalf("unexpected owner: user=%d family=%s", userID, familyID)
+	}
+
+	rotated, err := IssueTokenPair(userID, "admin", "admin@example.com", familyID)
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	if rotated.RefreshToken == pair.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// 重放旧令牌应被识别，并吊销整个家族
+	if _, _, err := ConsumeRefreshToken(pair.RefreshToken); err != ErrRefreshTokenReused {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, _, err := ConsumeRefreshToken(rotated.RefreshToken); err != ErrRefreshTokenInvalid {
+		t.Fatalf("expected rotated token to be revoked, got %v", err)
+	}
+	if !IsTokenBlacklisted(rotated.AccessToken) {
+		t.Fatal("expected latest access token of the family to be blacklisted")
+	}
+}
+
+func TestRefreshTokenConcurrentConsume(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	pair, err := GenerateTokenPair(2, "alice", "alice@example.com")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+
+	// 同一令牌并发提交时只有一个请求能换取新令牌
+	var succeeded atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := ConsumeRefreshToken(pair.RefreshToken); err == nil {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded.Load() != 1 {
+		t.Fatalf("expected exactly one successful consume, got %d", succeeded.Load())
+	}
+}
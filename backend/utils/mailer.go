@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"gin-backend/config"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail 一封待发送的邮件
+type Mail struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"` // 纯文本正文
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Mailer 邮件发送接口
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+var (
+	mailer   Mailer
+	mailerMu sync.RWMutex
+)
+
+// InitMailer 根据配置初始化全局邮件发送器
+func InitMailer(cfg config.MailConfig) {
+	switch cfg.Driver {
+	case "smtp":
+		SetMailer(NewSMTPMailer(cfg))
+		log.Printf("邮件发送使用 SMTP: %s:%d", cfg.Host, cfg.Port)
+	default:
+		SetMailer(NewOutboxMailer(cfg.OutboxFile))
+		log.Printf("邮件发送使用本地发件箱: %s", cfg.OutboxFile)
+	}
+}
+
+// SetMailer 替换全局邮件发送器
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	mailer = m
+}
+
+// GetMailer 获取全局邮件发送器，未初始化时使用内存发件箱
+func GetMailer() Mailer {
+	mailerMu.RLock()
+	m := mailer
+	mailerMu.RUnlock()
+	if m != nil {
+		return m
+	}
+
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	if mailer == nil {
+		mailer = NewOutboxMailer("")
+	}
+	return mailer
+}
+
+// SMTPMailer 通过 SMTP 服务器发送邮件
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer 创建 SMTP 邮件发送器
+func NewSMTPMailer(cfg config.MailConfig) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		from: cfg.From,
+	}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return m
+}
+
+// Send 发送邮件
+func (m *SMTPMailer) Send(mail Mail) error {
+	if strings.ContainsAny(mail.To, "\r\n") {
+		return errors.New("收件人地址不合法")
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + m.from + "\r\n")
+	msg.WriteString("To: " + mail.To + "\r\n")
+	msg.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", mail.Subject) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(mail.Body)
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{mail.To}, []byte(msg.String()))
+}
+
+// OutboxMailer 不真正发送邮件，只保存到内存（可选追加到文件），用于本地开发和测试
+type OutboxMailer struct {
+	mu   sync.Mutex
+	file string
+	sent []Mail
+}
+
+// NewOutboxMailer 创建发件箱邮件发送器，file 为空时只保存在内存
+func NewOutboxMailer(file string) *OutboxMailer {
+	return &OutboxMailer{file: file}
+}
+
+// Send 保存邮件到发件箱
+func (m *OutboxMailer) Send(mail Mail) error {
+	if mail.SentAt.IsZero() {
+		mail.SentAt = time.Now()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, mail)
+
+	if m.file == "" {
+		return nil
+	}
+	f, err := os.OpenFile(m.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "=== %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		mail.SentAt.Format(time.RFC3339), mail.To, mail.Subject, mail.Body)
+	return err
+}
+
+// Messages 返回发件箱中的全部邮件
+func (m *OutboxMailer) Messages() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.sent...)
+}
+
+// Last 返回发给指定收件人的最后一封邮件
+func (m *OutboxMailer) Last(to string) (Mail, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To == to {
+			return m.sent[i], true
+		}
+	}
+	return Mail{}, false
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"gin-backend/config"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pubsubBuffer 每个订阅者的消息缓冲，订阅者处理不过来时丢弃新消息
+const pubsubBuffer = 16
+
+// broker 发布订阅后端
+type broker interface {
+	Publish(channel string, message []byte) error
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, func(), error)
+}
+
+var (
+	// defaultBroker 包级 Publish/Subscribe 使用的后端，随 SetDefaultCache 按缓存驱动选定，之后不再切换
+	defaultBroker broker
+	brokerMu      sync.Mutex
+)
+
+// brokerFor 按缓存驱动选择发布订阅后端，与缓存使用同一存储：
+// redis 驱动和 L2 为 Redis 的两级缓存通过 Redis 广播到所有实例；
+// auto 驱动在创建时 Redis 已连接则使用 Redis，否则与 memory 驱动一样只在本进程内投递。
+// 选定后不随 Redis 的可用状态切换，否则 Redis 短暂不可用后发布方和订阅方会分散在两个后端
+func brokerFor(cache Cache) broker {
+	switch c := cache.(type) {
+	case *redisCache:
+		return &redisBroker{client: c.client}
+	case *tieredCache:
+		return brokerFor(c.l2)
+	case *fallbackCache:
+		if config.RedisClient != nil {
+			return &redisBroker{client: config.RedisClient}
+		}
+	}
+	return localBroker
+}
+
+// setDefaultBroker 替换包级 Publish/Subscribe 使用的后端
+func setDefaultBroker(b broker) {
+	brokerMu.Lock()
+	defer brokerMu.Unlock()
+	defaultBroker = b
+}
+
+// currentBroker 返回包级后端。未调用 SetDefaultCache 时与默认缓存一样：Redis 已连接则使用 Redis
+func currentBroker() broker {
+	brokerMu.Lock()
+	defer brokerMu.Unlock()
+	if defaultBroker == nil {
+		if config.RedisClient != nil {
+			defaultBroker = &redisBroker{client: config.RedisClient}
+		} else {
+			defaultBroker = localBroker
+		}
+	}
+	return defaultBroker
+}
+
+// Publish 向频道发布消息。使用 Redis 的缓存驱动通过 Redis 广播到所有实例，否则只在本进程内投递
+func Publish(channel string, message []byte) error {
+	return currentBroker().Publish(channel, message)
+}
+
+// Subscribe 订阅频道，返回时订阅已生效；ctx 结束或调用返回的取消函数后停止接收并关闭通道
+func Subscribe(ctx context.Context, channel string) (<-chan []byte, func(), error) {
+	return currentBroker().Subscribe(ctx, channel)
+}
+
+// redisBroker 通过 Redis 发布订阅，消息广播到所有实例
+type redisBroker struct {
+	client *redis.Client
+}
+
+func (b *redisBroker) Publish(channel string, message []byte) error {
+	return b.client.Publish(config.GetRedisContext(), channel, message).Err()
+}
+
+func (b *redisBroker) Subscribe(ctx context.Context, channel string) (<-chan []byte, func(), error) {
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan []byte, pubsubBuffer)
+
+	ps := b.client.Subscribe(ctx, channel)
+	// 等待订阅确认，避免订阅生效前发布的消息丢失
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		cancel()
+		return nil, nil, err
+	}
+	go func() {
+		defer close(out)
+		defer ps.Close()
+		msgs := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(msg.Payload):
+				default:
+				}
+			}
+		}
+	}()
+	return out, cancel, nil
+}
+
+// memoryBroker 进程内发布订阅，不使用 Redis 时的后端
+type memoryBroker struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan []byte]struct{}
+}
+
+var localBroker = &memoryBroker{subs: make(map[string]map[chan []byte]struct{})}
+
+func (b *memoryBroker) Publish(channel string, message []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs[channel] {
+		select {
+		case ch <- message:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(ctx context.Context, channel string) (<-chan []byte, func(), error) {
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan []byte, pubsubBuffer)
+
+	b.mu.Lock()
+	if b.subs[channel] == nil {
+		b.subs[channel] = make(map[chan []byte]struct{})
+	}
+	b.subs[channel][out] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs[channel], out)
+		if len(b.subs[channel]) == 0 {
+			delete(b.subs, channel)
+		}
+		b.mu.Unlock()
+		close(out)
+	}()
+	return out, cancel, nil
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"gin-backend/config"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBrokerFollowsCacheDriver(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer client.Close()
+	original := config.RedisClient
+	t.Cleanup(func() {
+		config.RedisClient = original
+		SetDefaultCache(NewMemoryCache(MemoryCacheOptions{}))
+	})
+
+	memory := NewMemoryCache(MemoryCacheOptions{})
+	if brokerFor(memory) != localBroker {
+		t.Fatal("expected the memory driver to use the local broker")
+	}
+	if b, ok := brokerFor(NewRedisCache(client)).(*redisBroker); !ok || b.client != client {
+		t.Fatal("expected the redis driver to use its client")
+	}
+
+	// auto 驱动按设置时 Redis 是否连接选定，之后不再切换
+	config.RedisClient = nil
+	SetDefaultCache(NewFallbackCache(NewMemoryCache(MemoryCacheOptions{})))
+	if currentBroker() != localBroker {
+		t.Fatal("expected the local broker without redis")
+	}
+	config.RedisClient = client
+	if currentBroker() != localBroker {
+		t.Fatal("expected the broker not to switch after redis connects")
+	}
+	SetDefaultCache(NewFallbackCache(NewMemoryCache(MemoryCacheOptions{})))
+	if _, ok := currentBroker().(*redisBroker); !ok {
+		t.Fatal("expected the redis broker when redis is connected")
+	}
+
+	// Redis 不可用时发布返回错误，不会改为本进程内投递
+	SetDefaultCache(memory)
+	messages, stop, err := Subscribe(context.Background(), "test:pubsub")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer stop()
+	SetDefaultCache(NewRedisCache(client))
+	if err := Publish("test:pubsub", []byte("hello")); err == nil {
+		t.Fatal("expected publish to fail while redis is unreachable")
+	}
+	select {
+	case msg := <-messages:
+		t.Fatalf("expected no local delivery, got %q", msg)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
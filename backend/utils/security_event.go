@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"gin-backend/models"
+	"sync"
+	"time"
+)
+
+// SecurityEventHook 安全事件处理函数
+type SecurityEventHook func(event *models.SecurityEvent)
+
+var (
+	securityEventHook   SecurityEventHook
+	securityEventHookMu sync.RWMutex
+)
+
+// SetSecurityEventHook 注册安全事件处理函数（通常由安全事件服务在启动时注册）
+func SetSecurityEventHook(hook SecurityEventHook) {
+	securityEventHookMu.Lock()
+	defer securityEventHookMu.Unlock()
+	securityEventHook = hook
+}
+
+// EmitSecurityEvent 触发安全事件，未注册处理函数时忽略
+func EmitSecurityEvent(event *models.SecurityEvent) {
+	securityEventHookMu.RLock()
+	hook := securityEventHook
+	securityEventHookMu.RUnlock()
+
+	if hook == nil {
+		return
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	hook(event)
+}
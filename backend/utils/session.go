@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-backend/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionTouchInterval 最近活跃时间的最小刷新间隔，避免每个请求都写缓存
+	sessionTouchInterval = time.Minute
+)
+
+// ErrSessionNotFound 会话不存在
+var ErrSessionNotFound = errors.New("会话不存在或已失效")
+
+// UserSession 用户登录会话（一个设备一次登录对应一个会话，会话ID 即刷新令牌家族ID）
+type UserSession struct {
+	ID         string    `json:"id"`
+	UserID     uint      `json:"user_id"`
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"` // 是否为发起请求的会话（仅在查询时填充）
+}
+
+// sessionActivity 会话最近的活跃时间和来源，与会话本身分字段保存
+type sessionActivity struct {
+	LastSeenAt time.Time `json:"last_seen_at"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+// 用户的会话保存在哈希 user:sessions:<id> 中：字段 <会话ID> 为会话，字段 seen:<会话ID> 为最近活跃信息。
+// 每个会话单独一个字段，多个实例并发登录、注销时互不覆盖；刷新活跃时间只写活跃字段，
+// 不会让已注销的会话重新出现
+const sessionActivityPrefix = "seen:"
+
+func userSessionsKey(userID uint) string {
+	return fmt.Sprintf("user:sessions:%d", userID)
+}
+
+// loadSessions 读取用户的所有会话（会话ID -> 会话），活跃信息已合并。
+// orphans 为会话已移除但仍残留的活跃字段
+func loadSessions(userID uint) (sessions map[string]UserSession, orphans []string) {
+	sessions = make(map[string]UserSession)
+	fields, err := CacheHGetAll(userSessionsKey(userID))
+	if err != nil {
+		return sessions, nil
+	}
+
+	for field, value := range fields {
+		if strings.HasPrefix(field, sessionActivityPrefix) {
+			continue
+		}
+		var s UserSession
+		if json.Unmarshal([]byte(value), &s) == nil {
+			sessions[field] = s
+		}
+	}
+	for field, value := range fields {
+		id, ok := strings.CutPrefix(field, sessionActivityPrefix)
+		if !ok {
+			continue
+		}
+		s, exists := sessions[id]
+		if !exists {
+			orphans = append(orphans, field)
+			continue
+		}
+		var activity sessionActivity
+		if json.Unmarshal([]byte(value), &activity) == nil && activity.LastSeenAt.After(s.LastSeenAt) {
+			s.LastSeenAt = activity.LastSeenAt
+			if activity.IP != "" {
+				s.IP = activity.IP
+			}
+			if activity.UserAgent != "" {
+				s.UserAgent = activity.UserAgent
+			}
+			sessions[id] = s
+		}
+	}
+	return sessions, orphans
+}
+
+// RegisterSession 登记新的登录会话
+// maxSessions 为该用户允许的最大同时在线会话数（<=0 表示不限制），超出时踢掉最久未活跃的会话
+func RegisterSession(session UserSession, maxSessions int) error {
+	now := time.Now()
+	session.CreatedAt = now
+	session.LastSeenAt = now
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	key := userSessionsKey(session.UserID)
+	if err := CacheHSet(key, session.ID, data); err != nil {
+		return err
+	}
+	CacheExpire(key, RefreshTokenExpiration)
+
+	// 写入后再按当前全部会话计算超出的数量，并发登录的各实例都能看到彼此新登记的会话
+	sessions, orphans := loadSessions(session.UserID)
+	if len(orphans) > 0 {
+		CacheHDel(key, orphans...)
+	}
+	if maxSessions <= 0 || len(sessions) <= maxSessions {
+		return nil
+	}
+
+	list := make([]UserSession, 0, len(sessions))
+	for _, s := range sessions {
+		if s.ID != session.ID {
+			list = append(list, s)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeenAt.Before(list[j].LastSeenAt)
+	})
+
+	// 被挤下线的会话：吊销其刷新令牌家族并拉黑访问令牌
+	for _, s := range list[:len(sessions)-maxSessions] {
+		if !removeSession(session.UserID, s.ID) {
+			continue
+		}
+		RevokeRefreshFamily(s.ID)
+		EmitSecurityEvent(&models.SecurityEvent{
+			UserID:    session.UserID,
+			Type:      models.SecurityEventSessionEvicted,
+			SessionID: s.ID,
+			IP:        session.IP,
+			UserAgent: session.UserAgent,
+			Detail:    "在线设备数超出上限，被新登录的设备挤下线：" + session.DeviceName,
+		})
+	}
+	return nil
+}
+
+// ListSessions 获取用户的所有在线会话，按最近活跃时间倒序
+func ListSessions(userID uint) []UserSession {
+	sessions, _ := loadSessions(userID)
+
+	list := make([]UserSession, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeenAt.After(list[j].LastSeenAt)
+	})
+	return list
+}
+
+// IsSessionActive 检查会话是否仍然在线
+func IsSessionActive(userID uint, sessionID string) bool {
+	if sessionID == "" || strings.HasPrefix(sessionID, sessionActivityPrefix) {
+		return false
+	}
+	_, err := CacheHGet(userSessionsKey(userID), sessionID)
+	return err == nil
+}
+
+// TouchSession 更新会话的最近活跃时间和来源信息。只写活跃字段，与注销并发时也不会恢复会话本身
+func TouchSession(userID uint, sessionID, ip, userAgent string) {
+	if sessionID == "" {
+		return
+	}
+	key := userSessionsKey(userID)
+	value, err := CacheHGet(key, sessionID)
+	if err != nil {
+		return
+	}
+	var s UserSession
+	if json.Unmarshal([]byte(value), &s) != nil {
+		return
+	}
+	var activity sessionActivity
+	if seen, err := CacheHGet(key, sessionActivityPrefix+sessionID); err == nil {
+		json.Unmarshal([]byte(seen), &activity)
+	}
+	if time.Since(s.LastSeenAt) < sessionTouchInterval || time.Since(activity.LastSeenAt) < sessionTouchInterval {
+		return
+	}
+
+	data, err := json.Marshal(sessionActivity{LastSeenAt: time.Now(), IP: ip, UserAgent: userAgent})
+	if err != nil {
+		return
+	}
+	if CacheHSet(key, sessionActivityPrefix+sessionID, data) == nil {
+		CacheExpire(key, RefreshTokenExpiration)
+	}
+}
+
+// removeSession 从会话表中移除会话，会话不存在时返回 false
+func removeSession(userID uint, sessionID string) bool {
+	if sessionID == "" || strings.HasPrefix(sessionID, sessionActivityPrefix) {
+		return false
+	}
+	key := userSessionsKey(userID)
+	if _, err := CacheHGet(key, sessionID); err != nil {
+		return false
+	}
+	return CacheHDel(key, sessionID, sessionActivityPrefix+sessionID) == nil
+}
+
+// RevokeSession 注销指定会话：移出会话表，吊销刷新令牌并拉黑访问令牌。
+// 只能注销 userID 自己会话表中的会话，其他用户的会话ID返回 ErrSessionNotFound 且不做任何修改
+func RevokeSession(userID uint, sessionID string) error {
+	if !removeSession(userID, sessionID) {
+		return ErrSessionNotFound
+	}
+	return RevokeRefreshFamily(sessionID)
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegisterSessionEvictsOldest(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	const userID = 42
+	var pairs []*TokenPair
+	for i := 0; i < 3; i++ {
+		pair, err := GenerateTokenPair(userID, "tester", "tester@example.com")
+		if err != nil {
+			t.Fatalf("GenerateTokenPair failed: %v", err)
+		}
+		if err := RegisterSession(UserSession{ID: pair.FamilyID, UserID: userID}, 2); err != nil {
+			t.Fatalf("RegisterSession failed: %v", err)
+		}
+		pairs = append(pairs, pair)
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := len(ListSessions(userID)); got != 2 {
+		t.Fatalf("expected 2 sessions, got %d", got)
+	}
+	if IsSessionActive(userID, pairs[0].FamilyID) {
+		t.Fatal("expected the oldest session to be evicted")
+	}
+	if !IsTokenBlacklisted(pairs[0].AccessToken) {
+		t.Fatal("expected the evicted session's access token to be blacklisted")
+	}
+	if _, _, err := ConsumeRefreshToken(pairs[0].RefreshToken); err == nil {
+		t.Fatal("expected the evicted session's refresh token to be rejected")
+	}
+
+	// 注销会话后，该会话不再在线
+	if err := RevokeSession(userID, pairs[2].FamilyID); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if IsSessionActive(userID, pairs[2].FamilyID) {
+		t.Fatal("expected revoked session to be inactive")
+	}
+	if err := RevokeSession(userID, pairs[2].FamilyID); err != ErrSessionNotFound {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestRevokeSessionOfAnotherUser(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	const owner, attacker = 51, 52
+	pair, err := GenerateTokenPair(owner, "owner", "owner@example.com")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+	if err := RegisterSession(UserSession{ID: pair.FamilyID, UserID: owner}, 0); err != nil {
+		t.Fatalf("RegisterSession failed: %v", err)
+	}
+
+	// 其他用户提交该会话ID时不能注销
+	if err := RevokeSession(attacker, pair.FamilyID); err != ErrSessionNotFound {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+	if !IsSessionActive(owner, pair.FamilyID) {
+		t.Fatal("expected the owner's session to stay active")
+	}
+	if IsTokenBlacklisted(pair.AccessToken) {
+		t.Fatal("expected the owner's access token to stay valid")
+	}
+	if _, _, err := ConsumeRefreshToken(pair.RefreshToken); err != nil {
+		t.Fatalf("expected the owner's refresh token to stay valid, got %v", err)
+	}
+}
+
+func TestRegisterSessionConcurrent(t *testing.T) {
+	t.Cleanup(func() { os.Remove(cacheFile) })
+
+	const userID, maxSessions = 61, 3
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := RegisterSession(UserSession{ID: fmt.Sprintf("concurrent-%d", i), UserID: userID}, maxSessions); err != nil {
+				t.Errorf("RegisterSession failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 并发登录互不覆盖，在线会话数不超过上限
+	sessions := ListSessions(userID)
+	if len(sessions) == 0 || len(sessions) > maxSessions {
+		t.Fatalf("expected 1..%d sessions, got %d", maxSessions, len(sessions))
+	}
+
+	// 注销后刷新活跃时间不会让会话重新出现
+	revoked := sessions[0].ID
+	if err := RevokeSession(userID, revoked); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	TouchSession(userID, revoked, "127.0.0.1", "test")
+	if IsSessionActive(userID, revoked) {
+		t.Fatal("expected revoked session to stay inactive")
+	}
+	if got := len(ListSessions(userID)); got != len(sessions)-1 {
+		t.Fatalf("expected %d sessions, got %d", len(sessions)-1, got)
+	}
+}
@@ -1,23 +1,77 @@
 import { http } from '../utils/request';
 
+// 整页跳转、EventSource 等不经过 axios 的请求使用的接口地址
+const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || "http://localhost:8080/api/v1";
+
 // 获取验证码
-export const getCaptcha = (refreshId = null) => {
-  return http.get('/captcha', refreshId ? { refresh: refreshId } : {});
+// captchaType: digit（默认，6 位数字图片）或 slider（滑块拼图）
+export const getCaptcha = (refreshId = null, captchaType = null) => {
+  const params = {};
+  if (refreshId) params.refresh = refreshId;
+  if (captchaType) params.captcha_type = captchaType;
+  return http.get('/captcha', params);
 };
 
 // 用户登录
-export const login = async (username, password, captchaId, captcha) => {
+// 滑块验证码的 captcha 为 JSON 字符串：{ x, track: [{ x, y, t }] }
+export const login = async (username, password, captchaId, captcha, captchaType = undefined) => {
   const data = await http.post('/login', {
     username,
     password,
     captcha_id: captchaId,
     captcha,
+    captcha_type: captchaType,
   });
 
+  // 需要两步验证时还没有令牌，由页面继续调用 loginTwoFactor
+  if (!data.two_factor_required) {
+    saveLogin(data);
+  }
+  return data;
+};
+
+// 两步验证：提交验证码或恢复码完成登录
+export const loginTwoFactor = async (challengeToken, code) => {
+  const data = await http.post('/login/2fa', {
+    challenge_token: challengeToken,
+    code,
+  });
+
+  saveLogin(data);
+  return data;
+};
+
+// 两步验证：角色强制启用但尚未绑定时获取绑定信息
+export const getTwoFactorLoginSetup = (challengeToken) => {
+  return http.post('/login/2fa/setup', { challenge_token: challengeToken });
+};
+
+// 获取已启用的第三方登录方式
+export const getOAuthProviders = () => {
+  return http.get('/auth/oauth/providers');
+};
+
+// 第三方登录授权地址（整页跳转，由后端重定向到提供方）
+export const oauthLoginUrl = (provider) => {
+  return `${API_BASE_URL}/auth/oauth/${provider}/login`;
+};
+
+// 第三方登录回调后，凭一次性票据换取登录结果
+export const exchangeOAuthTicket = async (ticket) => {
+  const data = await http.post('/auth/oauth/exchange', { ticket });
+
+  if (!data.two_factor_required) {
+    saveLogin(data);
+  }
+  return data;
+};
+
+// 保存登录结果
+const saveLogin = (data) => {
   localStorage.setItem("token", data.token);
+  localStorage.setItem("refresh_token", data.refresh_token);
   localStorage.setItem("user", JSON.stringify(data.user));
   localStorage.setItem("menus", JSON.stringify(data.menus || []));
-  return data;
 };
 
 // 用户注册
@@ -32,6 +86,21 @@ export const register = (username, email, password, nickname, captchaId, captcha
   });
 };
 
+// 注册前检查用户名是否可用，返回 { available }
+export const checkUsername = (username) => {
+  return http.get('/register/check', { username });
+};
+
+// 忘记密码：发送重置密码邮件
+export const forgotPassword = (email) => {
+  return http.post('/password/forgot', { email });
+};
+
+// 使用邮件中的令牌重置密码
+export const resetPassword = (token, password) => {
+  return http.post('/password/reset', { token, password });
+};
+
 // 获取用户信息
 export const getProfile = () => {
   return http.get('/auth/profile');
@@ -52,12 +121,13 @@ export const logout = async () => {
   try {
     const token = localStorage.getItem("token");
     if (token) {
-      await http.post('/logout');
+      await http.post('/logout', { refresh_token: localStorage.getItem("refresh_token") });
     }
   } catch (error) {
     console.error("退出登录请求失败:", error);
   } finally {
     localStorage.removeItem("token");
+    localStorage.removeItem("refresh_token");
     localStorage.removeItem("user");
     localStorage.removeItem("menus");
   }
@@ -100,3 +170,28 @@ export const checkWeChatLoginStatus = async (sceneId) => {
   const response = await http.get(`/auth/wechat/status?scene_id=${sceneId}`);
   return response;
 };
+
+/**
+ * 订阅微信登录状态推送（SSE），status 事件携带状态，登录成功时携带令牌
+ * @param {string} sceneId 场景ID
+ * @returns {EventSource}
+ */
+export const watchWeChatLogin = (sceneId) => {
+  return new EventSource(`${API_BASE_URL}/auth/wechat/events?scene_id=${encodeURIComponent(sceneId)}`);
+};
+
+/**
+ * 扫码的微信未绑定账号时注册新账号
+ * @param {string} sceneId 场景ID
+ */
+export const registerWeChatAccount = (sceneId) => {
+  return http.post('/auth/wechat/register', { scene_id: sceneId });
+};
+
+/**
+ * 扫码的微信未绑定账号时绑定已有账号
+ * @param {string} sceneId 场景ID
+ */
+export const bindWeChatAccount = (sceneId, username, password) => {
+  return http.post('/auth/wechat/bind', { scene_id: sceneId, username, password });
+};
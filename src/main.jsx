@@ -13,6 +13,8 @@ import AntdHome from './pages/AntdHome.jsx';
 import Orders from './pages/Orders.jsx';
 import Login from './pages/Login.jsx';
 import Register from './pages/Register.jsx';
+import ResetPassword from './pages/ResetPassword.jsx';
+import OAuthCallback from './pages/OAuthCallback.jsx';
 import SystemUsers from './pages/SystemUsers.jsx';
 import SystemRoles from './pages/SystemRoles.jsx';
 import SystemMenus from './pages/SystemMenus.jsx';
@@ -43,6 +45,14 @@ const router = createBrowserRouter([
     path: "/register",
     element: <Register />,
   },
+  {
+    path: "/reset-password",
+    element: <ResetPassword />,
+  },
+  {
+    path: "/oauth/callback",
+    element: <OAuthCallback />,
+  },
   {
     path: "/",
     element: (
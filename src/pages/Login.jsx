@@ -22,7 +22,7 @@ import {
   Laptop,
 } from '@mui/icons-material';
 import WeChatIcon from '@mui/icons-material/WhatsApp'; // Using a similar icon or we can use custom
-import { getCaptcha, login, isAuthenticated, getWeChatQrCode, checkWeChatLoginStatus } from '../api/auth';
+import { getCaptcha, login, loginTwoFactor, getTwoFactorLoginSetup, isAuthenticated, getWeChatQrCode, watchWeChatLogin, registerWeChatAccount, bindWeChatAccount, getOAuthProviders, oauthLoginUrl } from '../api/auth';
 import Welcome3D from '../components/Welcome3D';
 
 import loginBg from '../assets/login-bg.png';
@@ -40,7 +40,8 @@ const Login = () => {
   const [error, setError] = useState('');
   const [loginMethod, setLoginMethod] = useState('account'); // 'account' or 'wechat'
   const [wechatData, setWechatData] = useState({ qr_url: '', scene_id: '', status: 'IDLE' }); // IDLE, SCANNING, SUCCESS, EXPIRED
-  const pollingRef = useRef(null);
+  const [oauthProviders, setOauthProviders] = useState([]);
+  const pollingRef = useRef(null); // 微信登录状态推送连接（EventSource）
   const captchaLoaded = useRef(false); // 防止重复加载
 
   // 检查是否已登录并获取验证码
@@ -54,10 +55,11 @@ const Login = () => {
     if (!captchaLoaded.current) {
       captchaLoaded.current = true;
       loadCaptcha();
+      getOAuthProviders().then((list) => setOauthProviders(list || [])).catch(() => {});
     }
 
     return () => {
-      if (pollingRef.current) clearInterval(pollingRef.current);
+      if (pollingRef.current) pollingRef.current.close();
     };
   }, [navigate]);
 
@@ -81,34 +83,101 @@ const Login = () => {
   };
 
   const startPolling = (sceneId) => {
-    if (pollingRef.current) clearInterval(pollingRef.current);
-    
-    pollingRef.current = setInterval(async () => {
-      try {
-        const result = await checkWeChatLoginStatus(sceneId);
-        if (result.status === 'SUCCESS') {
-          stopPolling();
-          setWechatData(prev => ({ ...prev, status: 'SUCCESS' }));
-          
-          // 模拟登录成功逻辑
+    stopPolling();
+
+    // 服务端推送状态变化，登录成功的令牌只下发一次
+    const source = watchWeChatLogin(sceneId);
+    pollingRef.current = source;
+
+    source.addEventListener('status', async (e) => {
+      const result = JSON.parse(e.data);
+      if (result.status === 'SUCCESS') {
+        stopPolling();
+        setWechatData(prev => ({ ...prev, status: 'SUCCESS' }));
+
+        if (result.two_factor_required) {
+          // 已启用两步验证：扫码只完成第一步，还需验证码
+          try {
+            await completeTwoFactor(result);
+          } catch (err) {
+            setError(err.message);
+            setWechatData(prev => ({ ...prev, status: 'EXPIRED' }));
+            return;
+          }
+        } else {
           localStorage.setItem("token", result.token);
+          localStorage.setItem("refresh_token", result.refresh_token);
           localStorage.setItem("user", JSON.stringify(result.user));
           localStorage.setItem("menus", JSON.stringify(result.menus));
-          
-          setTimeout(() => navigate('/'), 1000);
-        } else if (result.status === 'EXPIRED') {
-          stopPolling();
-          setWechatData(prev => ({ ...prev, status: 'EXPIRED' }));
         }
-      } catch (err) {
-        console.error('轮询出错', err);
+
+        setTimeout(() => navigate('/'), 1000);
+      } else if (result.status === 'EXPIRED') {
+        stopPolling();
+        setWechatData(prev => ({ ...prev, status: 'EXPIRED' }));
+      } else if (result.status === 'SCANNING' && result.need_bind) {
+        // 微信未绑定账号：绑定已有账号或注册新账号，完成后重新订阅获取令牌
+        stopPolling();
+        if (await bindOrRegisterWeChat(sceneId)) {
+          startPolling(sceneId);
+        }
       }
-    }, 2000); // 2秒轮询一次
+    });
+
+    source.addEventListener('fail', (e) => {
+      stopPolling();
+      setError(JSON.parse(e.data).message);
+      setWechatData(prev => ({ ...prev, status: 'EXPIRED' }));
+    });
+  };
+
+  // 两步验证：提示输入验证码（角色强制启用但未绑定时先展示密钥），完成登录
+  const completeTwoFactor = async (result) => {
+    let tip = '请输入认证器中的 6 位验证码（或恢复码）';
+    if (result.two_factor_setup_required) {
+      const setup = await getTwoFactorLoginSetup(result.challenge_token);
+      tip = `当前账号需要启用两步验证，请在认证器中添加密钥 ${setup.secret} 后输入验证码`;
+    }
+    const code = window.prompt(tip);
+    if (!code) {
+      throw new Error('已取消两步验证');
+    }
+    const data = await loginTwoFactor(result.challenge_token, code.trim());
+    if (data.recovery_codes?.length) {
+      window.alert(`请妥善保存以下恢复码，每个只能使用一次：\n${data.recovery_codes.join('\n')}`);
+    }
+  };
+
+  const bindOrRegisterWeChat = async (sceneId) => {
+    try {
+      if (window.confirm('该微信尚未绑定账号。点击"确定"绑定已有账号，点击"取消"注册新账号')) {
+        const username = window.prompt('请输入要绑定的用户名');
+        const password = username && window.prompt('请输入密码');
+        if (!password) {
+          throw new Error('已取消绑定');
+        }
+        const result = await bindWeChatAccount(sceneId, username.trim(), password);
+        if (result?.two_factor_required) {
+          // 账号需要两步验证：验证通过后才完成绑定并直接登录，不再等待扫码会话
+          await completeTwoFactor(result);
+          navigate('/');
+          return false;
+        }
+      } else {
+        await registerWeChatAccount(sceneId);
+      }
+      return true;
+    } catch (err) {
+      // 失败或取消时让用户刷新二维码重新扫码
+      setError(err.message);
+      setWechatData(prev => ({ ...prev, status: 'EXPIRED' }));
+      return false;
+    }
   };
 
   const stopPolling = () => {
     if (pollingRef.current) {
-      clearInterval(pollingRef.current);
+      pollingRef.current.close();
       pollingRef.current = null;
     }
   };
@@ -152,12 +221,17 @@ const Login = () => {
     setLoading(true);
 
     try {
-      await login(
+      const result = await login(
         formData.username,
         formData.password,
         captchaData.captcha_id,
         formData.captcha
       );
+
+      // 两步验证
+      if (result.two_factor_required) {
+        await completeTwoFactor(result);
+      }
       
       // 登录成功，跳转到首页
       navigate('/');
@@ -427,6 +501,23 @@ const Login = () => {
                   'ACCESS SYSTEM'
                 )}
               </Button>
+
+              {/* 第三方登录 */}
+              {oauthProviders.length > 0 && (
+                <Box sx={{ display: 'flex', gap: 1, mt: 2 }}>
+                  {oauthProviders.map((provider) => (
+                    <Button
+                      key={provider}
+                      fullWidth
+                      variant="outlined"
+                      onClick={() => { window.location.href = oauthLoginUrl(provider); }}
+                      sx={{ borderRadius: '14px', color: '#cbd5e1', borderColor: 'rgba(255,255,255,0.2)', textTransform: 'none' }}
+                    >
+                      {provider}
+                    </Button>
+                  ))}
+                </Box>
+              )}
             </form>
           ) : (
             /* 微信扫码登录逻辑 */
@@ -530,6 +621,13 @@ const Login = () => {
             >
               启动注册序列
             </Button>
+            <Button
+              size="small"
+              sx={{ ml: 1, color: 'rgba(255,255,255,0.6)' }}
+              onClick={() => navigate('/reset-password')}
+            >
+              忘记密码
+            </Button>
           </Typography>
         </Paper>
       </Container>
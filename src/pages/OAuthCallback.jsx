@@ -0,0 +1,72 @@
+import { useEffect, useRef, useState } from 'react';
+import { useNavigate, useSearchParams, Link } from 'react-router-dom';
+import { Box, Container, Paper, Typography, Alert, CircularProgress } from '@mui/material';
+import { exchangeOAuthTicket, loginTwoFactor, getTwoFactorLoginSetup } from '../api/auth';
+
+// 第三方登录回调页：后端处理完授权后带 ticket / linked / error 跳转到这里
+const OAuthCallback = () => {
+  const navigate = useNavigate();
+  const [searchParams] = useSearchParams();
+  const [error, setError] = useState(searchParams.get('error') || '');
+  const [message, setMessage] = useState('');
+  const handled = useRef(false); // 票据只能使用一次，防止重复请求
+
+  useEffect(() => {
+    if (handled.current || error) return;
+    handled.current = true;
+
+    const linked = searchParams.get('linked');
+    if (linked) {
+      setMessage(`已绑定 ${linked} 账号`);
+      setTimeout(() => navigate('/'), 1500);
+      return;
+    }
+
+    const finish = async () => {
+      const result = await exchangeOAuthTicket(searchParams.get('ticket') || '');
+
+      // 两步验证
+      if (result.two_factor_required) {
+        let tip = '请输入认证器中的 6 位验证码（或恢复码）';
+        if (result.two_factor_setup_required) {
+          const setup = await getTwoFactorLoginSetup(result.challenge_token);
+          tip = `当前账号需要启用两步验证，请在认证器中添加密钥 ${setup.secret} 后输入验证码`;
+        }
+        const code = window.prompt(tip);
+        if (!code) {
+          throw new Error('已取消两步验证');
+        }
+        const data = await loginTwoFactor(result.challenge_token, code.trim());
+        if (data.recovery_codes?.length) {
+          window.alert(`请妥善保存以下恢复码，每个只能使用一次：\n${data.recovery_codes.join('\n')}`);
+        }
+      }
+      navigate('/');
+    };
+    finish().catch((err) => setError(err.message));
+  }, [error, navigate, searchParams]);
+
+  return (
+    <Box sx={{ minHeight: '100vh', display: 'flex', alignItems: 'center', background: '#0f172a' }}>
+      <Container maxWidth="xs">
+        <Paper sx={{ p: 4, textAlign: 'center' }}>
+          <Typography variant="h5" gutterBottom>
+            第三方登录
+          </Typography>
+          {error ? (
+            <>
+              <Alert severity="error" sx={{ mb: 2 }}>{error}</Alert>
+              <Link to="/login">返回登录</Link>
+            </>
+          ) : message ? (
+            <Alert severity="success">{message}</Alert>
+          ) : (
+            <CircularProgress />
+          )}
+        </Paper>
+      </Container>
+    </Box>
+  );
+};
+
+export default OAuthCallback;
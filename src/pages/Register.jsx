@@ -22,7 +22,7 @@ import {
   PersonAdd,
   Refresh,
 } from '@mui/icons-material';
-import { getCaptcha, register, isAuthenticated } from '../api/auth';
+import { getCaptcha, register, checkUsername, isAuthenticated } from '../api/auth';
 
 const Register = () => {
   const navigate = useNavigate();
@@ -40,6 +40,7 @@ const Register = () => {
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState('');
   const [success, setSuccess] = useState(false);
+  const [usernameTaken, setUsernameTaken] = useState(false);
   const captchaLoaded = useRef(false); // 防止重复加载
 
   // 检查是否已登录并获取验证码
@@ -82,9 +83,26 @@ const Register = () => {
       ...formData,
       [e.target.name]: e.target.value,
     });
+    if (e.target.name === 'username') {
+      setUsernameTaken(false);
+    }
     setError('');
   };
 
+  // 用户名输入完成后检查是否已被注册，检查失败不影响提交
+  const handleUsernameBlur = async () => {
+    const username = formData.username;
+    if (!/^[a-zA-Z0-9]{3,20}$/.test(username)) {
+      return;
+    }
+    try {
+      const data = await checkUsername(username);
+      setUsernameTaken(!data.available);
+    } catch {
+      setUsernameTaken(false);
+    }
+  };
+
   const validateForm = () => {
     if (formData.username.length < 3 || formData.username.length > 20) {
       setError('用户名长度必须在3-20个字符之间');
@@ -274,11 +292,13 @@ const Register = () => {
               name="username"
               value={formData.username}
               onChange={handleChange}
+              onBlur={handleUsernameBlur}
               margin="normal"
               required
               autoFocus
               disabled={loading}
-              helperText="3-20个字符，只能包含字母和数字"
+              error={usernameTaken}
+              helperText={usernameTaken ? '用户名已存在' : '3-20个字符，只能包含字母和数字'}
               sx={{ mb: 2 }}
             />
 
@@ -0,0 +1,115 @@
+import { useState } from 'react';
+import { useNavigate, useSearchParams, Link } from 'react-router-dom';
+import {
+  Box,
+  Container,
+  Paper,
+  TextField,
+  Button,
+  Typography,
+  Alert,
+  CircularProgress,
+} from '@mui/material';
+import { forgotPassword, resetPassword } from '../api/auth';
+
+// 找回密码：无 token 时输入邮箱发送重置邮件，带 token 时设置新密码
+const ResetPassword = () => {
+  const navigate = useNavigate();
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+
+  const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState('');
+  const [success, setSuccess] = useState('');
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError('');
+    setSuccess('');
+
+    if (token && password !== confirmPassword) {
+      setError('两次输入的密码不一致');
+      return;
+    }
+
+    setLoading(true);
+    try {
+      if (token) {
+        await resetPassword(token, password);
+        setSuccess('密码已重置，即将跳转到登录页');
+        setTimeout(() => navigate('/login'), 1500);
+      } else {
+        await forgotPassword(email);
+        setSuccess('如果该邮箱已注册，重置密码邮件已发送，请查收');
+      }
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <Box sx={{ minHeight: '100vh', display: 'flex', alignItems: 'center', background: '#0f172a' }}>
+      <Container maxWidth="xs">
+        <Paper sx={{ p: 4 }}>
+          <Typography variant="h5" gutterBottom>
+            {token ? '设置新密码' : '找回密码'}
+          </Typography>
+
+          {error && <Alert severity="error" sx={{ mb: 2 }}>{error}</Alert>}
+          {success && <Alert severity="success" sx={{ mb: 2 }}>{success}</Alert>}
+
+          <Box component="form" onSubmit={handleSubmit}>
+            {token ? (
+              <>
+                <TextField
+                  fullWidth
+                  margin="normal"
+                  type="password"
+                  label="新密码"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  inputProps={{ minLength: 6, maxLength: 50 }}
+                  required
+                />
+                <TextField
+                  fullWidth
+                  margin="normal"
+                  type="password"
+                  label="确认新密码"
+                  value={confirmPassword}
+                  onChange={(e) => setConfirmPassword(e.target.value)}
+                  required
+                />
+              </>
+            ) : (
+              <TextField
+                fullWidth
+                margin="normal"
+                type="email"
+                label="注册邮箱"
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+                required
+              />
+            )}
+
+            <Button fullWidth type="submit" variant="contained" sx={{ mt: 2 }} disabled={loading}>
+              {loading ? <CircularProgress size={24} /> : token ? '重置密码' : '发送重置邮件'}
+            </Button>
+          </Box>
+
+          <Typography variant="body2" sx={{ mt: 2, textAlign: 'center' }}>
+            <Link to="/login">返回登录</Link>
+          </Typography>
+        </Paper>
+      </Container>
+    </Box>
+  );
+};
+
+export default ResetPassword;
@@ -49,9 +49,25 @@ service.interceptors.response.use(
     // 返回脱壳后的业务数据，如果不存在 data 字段则返回完整的 res 对象以便读取提醒信息
     return res.data !== undefined ? res.data : res;
   },
-  (error) => {
+  async (error) => {
     let message = "连接服务器失败";
-    
+
+    // 访问令牌过期：使用刷新令牌换取新令牌后重试一次
+    const original = error.config;
+    if (error.response && error.response.status === 401 && original && !original._retry && !original.url.includes('/auth/refresh')) {
+      const refreshToken = localStorage.getItem("refresh_token");
+      if (refreshToken) {
+        original._retry = true;
+        try {
+          const token = await refreshAccessToken(refreshToken);
+          original.headers["Authorization"] = `Bearer ${token}`;
+          return service(original);
+        } catch (refreshError) {
+          console.error("刷新令牌失败:", refreshError);
+        }
+      }
+    }
+
     if (error.response) {
       const status = error.response.status;
       switch (status) {
@@ -80,11 +96,35 @@ service.interceptors.response.use(
   }
 );
 
+// 正在进行中的刷新请求，避免并发请求重复刷新（旧刷新令牌重放会导致整个会话被吊销）
+let refreshPromise = null;
+
+/**
+ * 使用刷新令牌换取新的访问令牌
+ */
+function refreshAccessToken(refreshToken) {
+  if (!refreshPromise) {
+    refreshPromise = axios
+      .post(`${API_BASE_URL}/auth/refresh`, { refresh_token: refreshToken })
+      .then((response) => {
+        const data = response.data.data;
+        localStorage.setItem("token", data.token);
+        localStorage.setItem("refresh_token", data.refresh_token);
+        return data.token;
+      })
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+  return refreshPromise;
+}
+
 /**
  * 处理授权相关错误（如 401）
  */
 function handleAuthError() {
   localStorage.removeItem("token");
+  localStorage.removeItem("refresh_token");
   localStorage.removeItem("user");
   // 跳转到登录页面
   window.location.href = "/login";